@@ -6,6 +6,8 @@ import (
 	"os"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync"
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
@@ -18,13 +20,17 @@ var chainCmd = &cmds.Command{
 		Tagline: "Inspect the filecoin blockchain",
 	},
 	Subcommands: map[string]*cmds.Command{
-		"export":   storeExportCmd,
-		"head":     storeHeadCmd,
-		"import":   storeImportCmd,
-		"ls":       storeLsCmd,
-		"status":   storeStatusCmd,
-		"set-head": storeSetHeadCmd,
-		"sync":     storeSyncCmd,
+		"bad":        storeBadCmd,
+		"checkpoint": storeCheckpointCmd,
+		"export":     storeExportCmd,
+		"head":       storeHeadCmd,
+		"import":     storeImportCmd,
+		"ls":         storeLsCmd,
+		"notify":     storeNotifyCmd,
+		"quarantine": storeQuarantineCmd,
+		"status":     storeStatusCmd,
+		"set-head":   storeSetHeadCmd,
+		"sync":       storeSyncCmd,
 	},
 }
 
@@ -71,6 +77,39 @@ var storeLsCmd = &cmds.Command{
 	Type: []block.Block{},
 }
 
+var storeNotifyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Stream the tipsets applied to and reverted from the chain as its head changes.",
+		ShortDescription: `
+Emits a batch of changes each time the head changes. A batch lists the tipsets
+reverted by a reorg, highest first, then the tipsets applied, lowest first.
+The first batch holds the current head. Given the block CIDs of the last
+tipset a client saw, the first batch instead brings it from that tipset to the
+current head, so a client can resume after a disconnect.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("from", false, true, "CID's of the blocks of the tipset to resume from."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		fromCids, err := cidsFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		changes, err := GetPorcelainAPI(env).ChainNotify(req.Context, block.NewTipSetKey(fromCids...))
+		if err != nil {
+			return err
+		}
+		for batch := range changes {
+			if err := re.Emit(batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+	Type: []*chain.HeadChange{},
+}
+
 var storeStatusCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Show status of chain sync operation.",
@@ -101,6 +140,100 @@ var storeSetHeadCmd = &cmds.Command{
 	},
 }
 
+var storeCheckpointCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Pin a tipset the chain must include, or show the current checkpoint.",
+		ShortDescription: `
+Sets the tipset with the given block CIDs, which must be on the chain of the
+current head, as the checkpoint. The syncer refuses any chain that does not
+include the checkpoint. Without arguments, prints the current checkpoint.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", false, true, "CID's of the blocks of the tipset to checkpoint."),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("clear", "Remove the current checkpoint"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+		if clear, _ := req.Options["clear"].(bool); clear {
+			if len(req.Arguments) > 0 {
+				return fmt.Errorf("cannot both set and clear the checkpoint")
+			}
+			if err := api.ChainCheckpoint(req.Context, block.TipSetKey{}); err != nil {
+				return err
+			}
+		} else if len(req.Arguments) > 0 {
+			checkpointCids, err := cidsFromSlice(req.Arguments)
+			if err != nil {
+				return err
+			}
+			if err := api.ChainCheckpoint(req.Context, block.NewTipSetKey(checkpointCids...)); err != nil {
+				return err
+			}
+		}
+		return re.Emit(api.ChainGetCheckpoint())
+	},
+	Type: []cid.Cid{},
+}
+
+var storeQuarantineCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List heavier forks refused for being deeper than the maximum reorg depth.",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).SyncerQuarantined())
+	},
+	Type: []chainsync.QuarantinedFork{},
+}
+
+var storeBadCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and clear the tipsets the syncer refuses for failing validation.",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":    storeBadLsCmd,
+		"rm":    storeBadRmCmd,
+		"clear": storeBadClearCmd,
+	},
+}
+
+var storeBadLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the tipsets refused for failing validation, with the reason.",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return re.Emit(GetPorcelainAPI(env).SyncerBadTipSets())
+	},
+	Type: []chainsync.BadTipSet{},
+}
+
+var storeBadRmCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Forget that a tipset failed validation, so that it is validated again.",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cids", true, true, "CID's of the blocks of the tipset."),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		badCids, err := cidsFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		return GetPorcelainAPI(env).SyncerRemoveBadTipSet(block.NewTipSetKey(badCids...))
+	},
+}
+
+var storeBadClearCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Forget every tipset that failed validation.",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		return GetPorcelainAPI(env).SyncerClearBadTipSets()
+	},
+}
+
 var storeSyncCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Instruct the chain syncer to sync a specific chain head, going to network if required.",
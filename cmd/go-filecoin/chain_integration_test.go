@@ -16,6 +16,7 @@ import (
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
 )
 
 func TestChainHead(t *testing.T) {
@@ -33,6 +34,50 @@ func TestChainHead(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestChainCheckpoint(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	ctx := context.Background()
+	builder := test.NewNodeBuilder(t)
+
+	_, cmdClient, done := builder.BuildAndStartAPI(ctx)
+	defer done()
+
+	var head []cid.Cid
+	headJSON := cmdClient.RunSuccess(ctx, "chain", "head", "--enc", "json").ReadStdoutTrimNewlines()
+	require.NoError(t, json.Unmarshal([]byte(headJSON), &head))
+
+	args := append([]string{"chain", "checkpoint", "--enc", "json"}, head[0].String())
+	var checkpoint []cid.Cid
+	require.NoError(t, json.Unmarshal([]byte(cmdClient.RunSuccess(ctx, args...).ReadStdoutTrimNewlines()), &checkpoint))
+	assert.Equal(t, head, checkpoint)
+
+	cleared := cmdClient.RunSuccess(ctx, "chain", "checkpoint", "--clear", "--enc", "json").ReadStdoutTrimNewlines()
+	require.NoError(t, json.Unmarshal([]byte(cleared), &checkpoint))
+	assert.Empty(t, checkpoint)
+
+	unknown := types.CidFromString(t, "unknown")
+	cmdClient.RunFail(ctx, "not found", "chain", "checkpoint", unknown.String())
+}
+
+func TestChainBad(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	ctx := context.Background()
+	builder := test.NewNodeBuilder(t)
+
+	_, cmdClient, done := builder.BuildAndStartAPI(ctx)
+	defer done()
+
+	var bad []interface{}
+	cmdClient.RunMarshaledJSON(ctx, &bad, "chain", "bad", "ls")
+	assert.Empty(t, bad)
+
+	unknown := types.CidFromString(t, "unknown")
+	cmdClient.RunFail(ctx, "not marked bad", "chain", "bad", "rm", unknown.String())
+	cmdClient.RunSuccess(ctx, "chain", "bad", "clear")
+}
+
 func TestChainLs(t *testing.T) {
 	tf.IntegrationTest(t)
 	t.Skip("DRAGONS: fake post for integration test")
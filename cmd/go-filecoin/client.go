@@ -277,11 +277,19 @@ var clientListAsksCmd = &cmds.Command{
 Lists all asks in the storage market. This command takes no arguments.
 `,
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
-		minerAddr, err := GetBlockAPI(env).MinerAddress()
+		minerAddr, err := optionalAddr(req.Options["miner"])
 		if err != nil {
 			return err
 		}
+		if minerAddr == address.Undef {
+			if minerAddr, err = GetBlockAPI(env).MinerAddress(); err != nil {
+				return err
+			}
+		}
 
 		asks, err := GetStorageAPI(env).ListAsks(minerAddr)
 		if err != nil {
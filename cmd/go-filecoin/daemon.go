@@ -24,7 +24,9 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/paths"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
 	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/journal"
+	"github.com/sbwtw/go-filecoin/internal/pkg/proofs"
 	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
 )
 
@@ -39,6 +41,8 @@ var daemonCmd = &cmds.Command{
 		cmdkit.BoolOption(ELStdout),
 		cmdkit.BoolOption(IsRelay, "advertise and allow filecoin network traffic to be relayed through this node"),
 		cmdkit.StringOption(BlockTime, "time a node waits before trying to mine the next block").WithDefault(clock.DefaultEpochDuration.String()),
+		cmdkit.BoolOption(FakeProofs, "generate and accept fake proofs, for local test networks only"),
+		cmdkit.BoolOption(MockClock, "run on a clock which only advances through `debug clock advance`, for local test networks only"),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		return daemonRun(req, re)
@@ -84,6 +88,14 @@ func daemonRun(req *cmds.Request, re cmds.ResponseEmitter) error {
 		opts = append(opts, node.IsRelay())
 	}
 
+	if fakeProofs, ok := req.Options[FakeProofs].(bool); ok && fakeProofs {
+		opts = append(opts, node.VerifierConfigOption(&proofs.FakeVerifier{}), node.PoStGeneratorOption(&consensus.TestElectionPoster{}))
+	}
+
+	if mockClock, ok := req.Options[MockClock].(bool); ok && mockClock {
+		opts = append(opts, node.MockClockOption())
+	}
+
 	durStr, ok := req.Options[BlockTime].(string)
 	if !ok {
 		return errors.New("Bad block time passed")
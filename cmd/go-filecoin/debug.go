@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+)
+
+var debugCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Control a node running on a local test network",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"clock": debugClockCmd,
+	},
+}
+
+var debugClockCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and control the node's clock",
+		ShortDescription: `
+A daemon started with --mock-clock begins at the genesis time and only moves when
+advanced with 'go-filecoin debug clock advance'. To keep a local network in step,
+advance every node by the same number of epochs.
+`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"now":     debugClockNowCmd,
+		"advance": debugClockAdvanceCmd,
+	},
+}
+
+// ClockResult is the node's time and epoch.
+type ClockResult struct {
+	Time  time.Time
+	Epoch abi.ChainEpoch
+}
+
+var debugClockNowCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the node's current time and epoch",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		now, epoch := GetPorcelainAPI(env).ClockNow()
+		return re.Emit(&ClockResult{Time: now, Epoch: epoch})
+	},
+	Type: &ClockResult{},
+}
+
+var debugClockAdvanceCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Advance the node's mock clock by a number of epochs",
+		ShortDescription: `
+Moves the clock of a daemon started with --mock-clock forward. With --interval the
+clock moves one epoch at a time, pausing in between so the node can mine and
+process blocks in every epoch. Otherwise it jumps straight to the final epoch.
+`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("epochs", true, false, "Number of epochs to advance"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("interval", "Real time to wait after each epoch, e.g. 500ms"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		epochs, err := strconv.ParseUint(req.Arguments[0], 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid number of epochs")
+		}
+
+		var interval time.Duration
+		if s, ok := req.Options["interval"].(string); ok {
+			if interval, err = time.ParseDuration(s); err != nil {
+				return errors.Wrap(err, "invalid interval")
+			}
+		}
+
+		api := GetPorcelainAPI(env)
+		if interval == 0 {
+			if _, err := api.ClockAdvance(abi.ChainEpoch(epochs)); err != nil {
+				return err
+			}
+		} else {
+			for i := uint64(0); i < epochs; i++ {
+				if _, err := api.ClockAdvance(1); err != nil {
+					return err
+				}
+				select {
+				case <-time.After(interval):
+				case <-req.Context.Done():
+					return req.Context.Err()
+				}
+			}
+		}
+
+		now, epoch := api.ClockNow()
+		return re.Emit(&ClockResult{Time: now, Epoch: epoch})
+	},
+	Type: &ClockResult{},
+}
@@ -0,0 +1,39 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	commands "github.com/sbwtw/go-filecoin/cmd/go-filecoin"
+	th "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestDebugClockAdvance(t *testing.T) {
+	tf.IntegrationTest(t)
+
+	t.Run("advances a mock clock", func(t *testing.T) {
+		td := th.NewDaemon(t, th.MockClock).Start()
+		defer td.ShutdownSuccess()
+
+		var before commands.ClockResult
+		require.NoError(t, json.Unmarshal([]byte(td.RunSuccess("debug", "clock", "now").ReadStdout()), &before))
+		assert.Equal(t, abi.ChainEpoch(0), before.Epoch)
+
+		var after commands.ClockResult
+		require.NoError(t, json.Unmarshal([]byte(td.RunSuccess("debug", "clock", "advance", "5").ReadStdout()), &after))
+		assert.Equal(t, abi.ChainEpoch(5), after.Epoch)
+		assert.Equal(t, th.BlockTimeTest*5, after.Time.Sub(before.Time))
+	})
+
+	t.Run("fails without a mock clock", func(t *testing.T) {
+		td := th.NewDaemon(t).Start()
+		defer td.ShutdownSuccess()
+
+		td.RunFail("not running with a mock clock", "debug", "clock", "advance", "1")
+	})
+}
@@ -10,6 +10,8 @@ import (
 	"os"
 	"syscall"
 
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
 	"github.com/ipfs/go-ipfs-cmds/cli"
@@ -96,6 +98,14 @@ const (
 	// IsRelay when set causes the the daemon to provide libp2p relay
 	// services allowing other filecoin nodes behind NATs to talk directly.
 	IsRelay = "is-relay"
+
+	// FakeProofs when set causes the daemon to generate and accept fake proofs.
+	// It is only meant for local test networks.
+	FakeProofs = "fake-proofs"
+
+	// MockClock when set causes the daemon to run on a clock which only advances
+	// through `debug clock advance`. It is only meant for local test networks.
+	MockClock = "mock-clock"
 )
 
 func init() {
@@ -126,8 +136,10 @@ STORE AND RETRIEVE DATA
   go-filecoin retrieval-client       - Manage retrieval client operations
 
 MINE
-  go-filecoin miner                  - Manage a single miner actor
+  go-filecoin miner                  - Manage the miner actors of the node
   go-filecoin mining                 - Manage all mining operations for a node
+  go-filecoin sectors                - Inspect and retry the sectors of the node's miner
+  go-filecoin storage                - Manage the local storage paths of the node's miner
 
 VIEW DATA STRUCTURES
   go-filecoin chain                  - Inspect the filecoin blockchain
@@ -155,6 +167,7 @@ MESSAGE COMMANDS
   go-filecoin outbox                 - Manage the outbound message queue
 
 TOOL COMMANDS
+  go-filecoin debug                  - Control a node running on a local test network
   go-filecoin inspect                - Show info about the go-filecoin node
   go-filecoin leb128                 - Leb128 cli encode/decode
   go-filecoin log                    - Interact with the daemon event log output
@@ -195,6 +208,7 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"client":           clientCmd,
 	"drand":            drandCmd,
 	"dag":              dagCmd,
+	"debug":            debugCmd,
 	"deals":            dealsCmd,
 	"dht":              dhtCmd,
 	"id":               idCmd,
@@ -209,8 +223,10 @@ var rootSubcmdsDaemon = map[string]*cmds.Command{
 	"ping":             pingCmd,
 	"protocol":         protocolCmd,
 	"retrieval-client": retrievalClientCmd,
+	"sectors":          sectorsCmd,
 	"show":             showCmd,
 	"stats":            statsCmd,
+	"storage":          storageCmd,
 	"swarm":            swarmCmd,
 	"wallet":           walletCmd,
 	"version":          versionCmd,
@@ -369,28 +385,79 @@ var limitOption = cmdkit.Int64Option("gas-limit", "Maximum GasUnits this message
 var previewOption = cmdkit.BoolOption("preview", "Preview the Gas cost of this command without actually executing it")
 
 func parseGasOptions(req *cmds.Request) (types.AttoFIL, gas.Unit, bool, error) {
+	price, ok, err := parseGasPriceOption(req)
+	if err != nil {
+		return types.ZeroAttoFIL, gas.NewGas(0), false, err
+	}
+	if !ok {
+		return types.ZeroAttoFIL, gas.Zero, false, errors.New("gas-price option is required")
+	}
+
+	limit, ok, err := parseGasLimitOption(req)
+	if err != nil {
+		return types.ZeroAttoFIL, gas.NewGas(0), false, err
+	}
+	if !ok {
+		return types.ZeroAttoFIL, gas.NewGas(0), false, errors.New("gas-limit option is required")
+	}
+
+	preview, _ := req.Options["preview"].(bool)
+
+	return price, limit, preview, nil
+}
+
+// parseGasOptionsOrEstimate parses the gas options like parseGasOptions, but suggests a gas price
+// and estimates a gas limit for the described message when the options are omitted.
+func parseGasOptionsOrEstimate(req *cmds.Request, env cmds.Environment, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (types.AttoFIL, gas.Unit, bool, error) {
+	api := GetPorcelainAPI(env)
+	preview, _ := req.Options["preview"].(bool)
+
+	price, ok, err := parseGasPriceOption(req)
+	if err != nil {
+		return types.ZeroAttoFIL, gas.NewGas(0), false, err
+	}
+	if !ok {
+		if price, err = api.MessageSuggestGasPrice(req.Context); err != nil {
+			return types.ZeroAttoFIL, gas.NewGas(0), false, errors.Wrap(err, "failed to suggest gas price")
+		}
+	}
+
+	limit, ok, err := parseGasLimitOption(req)
+	if err != nil {
+		return types.ZeroAttoFIL, gas.NewGas(0), false, err
+	}
+	if !ok && !preview {
+		if limit, err = api.MessageEstimateGasLimit(req.Context, from, to, value, method, params); err != nil {
+			return types.ZeroAttoFIL, gas.NewGas(0), false, errors.Wrap(err, "failed to estimate gas limit")
+		}
+	}
+
+	return price, limit, preview, nil
+}
+
+func parseGasPriceOption(req *cmds.Request) (types.AttoFIL, bool, error) {
 	priceOption := req.Options["gas-price"]
 	if priceOption == nil {
-		return types.ZeroAttoFIL, gas.Zero, false, errors.New("gas-price option is required")
+		return types.ZeroAttoFIL, false, nil
 	}
 
 	price, ok := types.NewAttoFILFromFILString(priceOption.(string))
 	if !ok {
-		return types.ZeroAttoFIL, gas.NewGas(0), false, errors.New("invalid gas price (specify FIL as a decimal number)")
+		return types.ZeroAttoFIL, false, errors.New("invalid gas price (specify FIL as a decimal number)")
 	}
+	return price, true, nil
+}
 
+func parseGasLimitOption(req *cmds.Request) (gas.Unit, bool, error) {
 	limitOption := req.Options["gas-limit"]
 	if limitOption == nil {
-		return types.ZeroAttoFIL, gas.NewGas(0), false, errors.New("gas-limit option is required")
+		return gas.NewGas(0), false, nil
 	}
 
 	gasLimitInt, ok := limitOption.(int64)
 	if !ok {
 		msg := fmt.Sprintf("invalid gas limit: %s", limitOption)
-		return types.ZeroAttoFIL, gas.NewGas(0), false, errors.New(msg)
+		return gas.NewGas(0), false, errors.New(msg)
 	}
-
-	preview, _ := req.Options["preview"].(bool)
-
-	return price, gas.NewGas(gasLimitInt), preview, nil
+	return gas.NewGas(gasLimitInt), true, nil
 }
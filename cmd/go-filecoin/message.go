@@ -39,14 +39,21 @@ var msgCmd = &cmds.Command{
 
 // MessageSendResult is the return type for message send command
 type MessageSendResult struct {
-	Cid     cid.Cid
-	GasUsed gas.Unit
-	Preview bool
+	Cid      cid.Cid
+	GasUsed  gas.Unit
+	GasPrice types.AttoFIL
+	GasLimit gas.Unit
+	Preview  bool
 }
 
 var msgSendCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Send a message", // This feels too generic...
+		ShortDescription: `
+Send a message to an actor. When --gas-price is omitted a price is suggested from the
+prices of messages recently included on chain. When --gas-limit is omitted the limit is
+estimated by running the message locally after any of the sender's pending messages.
+`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("target", true, false, "Address of the actor to send the message to"),
@@ -80,31 +87,34 @@ var msgSendCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, preview, err := parseGasOptions(req)
-		if err != nil {
-			return err
-		}
-
 		methodID := builtin.MethodSend
 		methodInput, ok := req.Options["method"].(uint64)
 		if ok {
 			methodID = abi.MethodNum(methodInput)
 		}
 
+		gasPrice, gasLimit, preview, err := parseGasOptionsOrEstimate(req, env, fromAddr, target, val, methodID, adt.Empty)
+		if err != nil {
+			return err
+		}
+
 		if preview {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
 				target,
+				val,
 				methodID,
+				adt.Empty,
 			)
 			if err != nil {
 				return err
 			}
 			return re.Emit(&MessageSendResult{
-				Cid:     cid.Cid{},
-				GasUsed: usedGas,
-				Preview: true,
+				Cid:      cid.Cid{},
+				GasUsed:  usedGas,
+				GasPrice: gasPrice,
+				Preview:  true,
 			})
 		}
 
@@ -123,9 +133,11 @@ var msgSendCmd = &cmds.Command{
 		}
 
 		return re.Emit(&MessageSendResult{
-			Cid:     c,
-			GasUsed: gas.NewGas(0),
-			Preview: false,
+			Cid:      c,
+			GasUsed:  gas.NewGas(0),
+			GasPrice: gasPrice,
+			GasLimit: gasLimit,
+			Preview:  false,
 		})
 	},
 	Type: &MessageSendResult{},
@@ -161,19 +173,23 @@ var signedMsgSendCmd = &cmds.Command{
 		}
 
 		return re.Emit(&MessageSendResult{
-			Cid:     c,
-			GasUsed: gas.NewGas(0),
-			Preview: false,
+			Cid:      c,
+			GasUsed:  gas.NewGas(0),
+			GasPrice: signed.Message.GasPrice,
+			GasLimit: signed.Message.GasLimit,
+			Preview:  false,
 		})
 	},
 	Type: &MessageSendResult{},
 }
 
-// WaitResult is the result of a message wait call.
+// WaitResult is the result of a message wait call. A result with Reverted set
+// reports an inclusion of the message undone by a reorg while waiting.
 type WaitResult struct {
 	Message   *types.SignedMessage
 	Receipt   *vm.MessageReceipt
 	Signature vm.ActorMethodSignature
+	Reverted  bool
 }
 
 var msgWaitCmd = &cmds.Command{
@@ -188,6 +204,7 @@ var msgWaitCmd = &cmds.Command{
 		cmdkit.BoolOption("receipt", "Print the whole message receipt").WithDefault(true),
 		cmdkit.BoolOption("return", "Print the return value from the receipt").WithDefault(false),
 		cmdkit.StringOption("timeout", "Maximum time to wait for message. e.g., 300ms, 1.5h, 2h45m.").WithDefault("10m"),
+		cmdkit.Uint64Option("confidence", "Number of tipsets required on top of the message's inclusion").WithDefault(uint64(0)),
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		msgCid, err := cid.Parse(req.Arguments[0])
@@ -207,7 +224,12 @@ var msgWaitCmd = &cmds.Command{
 		ctx, cancel := context.WithTimeout(req.Context, timeoutDuration)
 		defer cancel()
 
-		err = GetPorcelainAPI(env).MessageWait(ctx, msgCid, func(blk *block.Block, msg *types.SignedMessage, receipt *vm.MessageReceipt) error {
+		confidence, _ := req.Options["confidence"].(uint64)
+		reverted := func(_ *block.Block, msg *types.SignedMessage) error {
+			return re.Emit(&WaitResult{Message: msg, Reverted: true})
+		}
+
+		err = GetPorcelainAPI(env).MessageWaitConfirmed(ctx, msgCid, confidence, func(blk *block.Block, msg *types.SignedMessage, receipt *vm.MessageReceipt) error {
 			found = true
 			sig, err := GetPorcelainAPI(env).ActorGetSignature(req.Context, msg.Message.To, msg.Message.Method)
 			if err != nil && err != cst.ErrNoMethod && err != cst.ErrNoActorImpl {
@@ -223,7 +245,7 @@ var msgWaitCmd = &cmds.Command{
 			re.Emit(&res) // nolint: errcheck
 
 			return nil
-		})
+		}, reverted)
 
 		if err != nil && !found {
 			return err
@@ -240,6 +262,7 @@ type MessageStatusResult struct {
 	InOutbox  bool // Whether the message is found in the outbox
 	OutboxMsg *message.Queued
 	ChainMsg  *msg.ChainMessage
+	Depth     uint64 // Number of tipsets on top of the message's inclusion, if on chain
 }
 
 var msgStatusCmd = &cmds.Command{
@@ -249,7 +272,9 @@ var msgStatusCmd = &cmds.Command{
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("cid", true, false, "CID of the message to inspect"),
 	},
-	Options: []cmdkit.Option{},
+	Options: []cmdkit.Option{
+		cmdkit.Uint64Option("lookback", "Number of tipsets to search for the message on chain").WithDefault(uint64(100)),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		msgCid, err := cid.Parse(req.Arguments[0])
 		if err != nil {
@@ -276,6 +301,22 @@ var msgStatusCmd = &cmds.Command{
 			}
 		}
 
+		// Look on chain
+		lookback, _ := req.Options["lookback"].(uint64)
+		if lookback == 0 {
+			return errors.New("lookback must be at least 1")
+		}
+		chainMsg, found, err := api.MessageFind(req.Context, msgCid, lookback)
+		if err != nil {
+			return err
+		}
+		if found {
+			result.ChainMsg = chainMsg
+			if result.Depth, err = api.MessageDepth(req.Context, chainMsg.Block); err != nil {
+				return err
+			}
+		}
+
 		return re.Emit(&result)
 	},
 	Type: &MessageStatusResult{},
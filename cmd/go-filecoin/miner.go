@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"math/big"
+	"strconv"
 
 	address "github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/sector-storage/ffiwrapper"
@@ -17,26 +18,39 @@ import (
 
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/sbwtw/go-filecoin/internal/pkg/constants"
+	"github.com/sbwtw/go-filecoin/internal/pkg/poster"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
 )
 
 var minerCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
-		Tagline: "Manage a single miner actor",
+		Tagline: "Manage the miner actors of the node",
 	},
 	Subcommands: map[string]*cmds.Command{
 		"create":        minerCreateCmd,
+		"await-create":  minerAwaitCreateCmd,
+		"ls":            minerLsCmd,
 		"status":        minerStatusCommand,
 		"set-price":     minerSetPriceCmd,
 		"update-peerid": minerUpdatePeerIDCmd,
 		"set-worker":    minerSetWorkerAddressCmd,
+		"withdraw":      minerWithdrawCmd,
+		"extend":        minerExtendCmd,
+		"terminate":     minerTerminateCmd,
+		"faults":        minerFaultsCmd,
+		"proving":       minerProvingCmd,
 	},
 }
 
-// MinerCreateResult is the type returned when creating a miner.
+// MinerCreateResult is the type returned when creating a miner. When the
+// owner's key is not in the node, Message is the unsigned message creating
+// the miner, for the owner to sign and send.
 type MinerCreateResult struct {
 	Address address.Address
+	Owner   address.Address
+	Worker  address.Address
+	Message *types.UnsignedMessage `json:",omitempty"`
 	GasUsed gas.Unit
 	Preview bool
 }
@@ -48,7 +62,15 @@ var minerCreateCmd = &cmds.Command{
 message to be mined as this is required to return the address of the new miner.
 Collateral will be committed at the rate of 0.001FIL per sector. When the
 miner's collateral drops below 0.001FIL, the miner will not be able to commit
-additional sectors.`,
+additional sectors.
+
+The miner is owned by --owner, or the --from address, and operated by --worker,
+which defaults to the owner. With --new-worker a new BLS key is generated in
+the node's wallet for the worker, and sent --worker-funds from the owner to
+create its account before the miner is created. When the owner's key is not in
+the node's wallet, the unsigned message creating the miner is output instead. Sign it with
+the owner key, send it with 'message sendsigned', and run 'miner await-create'
+with its CID to record the miner.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("collateral", true, false, "The amount of collateral, in FIL."),
@@ -56,6 +78,10 @@ additional sectors.`,
 	Options: []cmdkit.Option{
 		cmdkit.StringOption("sectorsize", "size of the sectors which this miner will commit, in bytes"),
 		cmdkit.StringOption("from", "address to send from"),
+		cmdkit.StringOption("owner", "address owning the miner, defaults to --from"),
+		cmdkit.StringOption("worker", "address operating the miner, defaults to the owner"),
+		cmdkit.BoolOption("new-worker", "generate a new BLS key in the wallet for the worker"),
+		cmdkit.StringOption("worker-funds", "amount of FIL the owner sends to the --new-worker account").WithDefault("1"),
 		cmdkit.StringOption("peerid", "Base58-encoded libp2p peer ID that the miner will operate"),
 		priceOption,
 		limitOption,
@@ -74,10 +100,15 @@ additional sectors.`,
 			return err
 		}
 
-		fromAddr, err := fromAddrOrDefault(req, env)
+		ownerAddr, err := fromAddrOrDefault(req, env)
 		if err != nil {
 			return err
 		}
+		if o, ok := req.Options["owner"].(string); ok {
+			if ownerAddr, err = address.NewFromString(o); err != nil {
+				return errors.Wrap(err, "invalid owner address")
+			}
+		}
 
 		var pid peer.ID
 		peerid := req.Options["peerid"]
@@ -101,10 +132,25 @@ additional sectors.`,
 			return err
 		}
 
+		workerAddr := ownerAddr
+		w, hasWorker := req.Options["worker"].(string)
+		newWorker, _ := req.Options["new-worker"].(bool)
+		if hasWorker && newWorker {
+			return errors.New("only one of --worker and --new-worker may be given")
+		}
+		if hasWorker {
+			if workerAddr, err = address.NewFromString(w); err != nil {
+				return errors.Wrap(err, "invalid worker address")
+			}
+		}
+
 		if preview {
+			// A new worker key is not generated to preview, the cost is that
+			// of a worker with the owner's address.
 			usedGas, err := GetPorcelainAPI(env).MinerPreviewCreate(
 				req.Context,
-				fromAddr,
+				ownerAddr,
+				workerAddr,
 				sectorSize,
 				pid,
 			)
@@ -113,14 +159,55 @@ additional sectors.`,
 			}
 			return re.Emit(&MinerCreateResult{
 				Address: address.Undef,
+				Owner:   ownerAddr,
+				Worker:  workerAddr,
 				GasUsed: usedGas,
 				Preview: true,
 			})
 		}
 
+		ownerInWallet := walletHasAddress(GetPorcelainAPI(env).WalletAddresses(), ownerAddr)
+		if newWorker {
+			if !ownerInWallet {
+				return errors.New("--new-worker needs the owner's key in the wallet to fund the worker, pass the --worker address of an existing account instead")
+			}
+			funds, ok := types.NewAttoFILFromFILString(req.Options["worker-funds"].(string))
+			if !ok {
+				return errors.New("invalid worker funds")
+			}
+			if workerAddr, err = GetPorcelainAPI(env).MinerNewWorker(req.Context, ownerAddr, funds, gasPrice, gasLimit); err != nil {
+				return err
+			}
+		}
+
+		if !ownerInWallet {
+			msg, err := GetPorcelainAPI(env).MinerCreateUnsigned(
+				req.Context,
+				ownerAddr,
+				workerAddr,
+				gasPrice,
+				gasLimit,
+				sealProofType,
+				pid,
+				collateral,
+			)
+			if err != nil {
+				return err
+			}
+			return re.Emit(&MinerCreateResult{
+				Address: address.Undef,
+				Owner:   ownerAddr,
+				Worker:  workerAddr,
+				Message: msg,
+				GasUsed: gas.NewGas(0),
+				Preview: false,
+			})
+		}
+
 		addr, err := GetPorcelainAPI(env).MinerCreate(
 			req.Context,
-			fromAddr,
+			ownerAddr,
+			workerAddr,
 			gasPrice,
 			gasLimit,
 			sealProofType,
@@ -133,6 +220,8 @@ additional sectors.`,
 
 		return re.Emit(&MinerCreateResult{
 			Address: addr,
+			Owner:   ownerAddr,
+			Worker:  workerAddr,
 			GasUsed: gas.NewGas(0),
 			Preview: false,
 		})
@@ -140,6 +229,55 @@ additional sectors.`,
 	Type: &MinerCreateResult{},
 }
 
+var minerLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the miner actors the node operates, its primary miner first",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		miners, err := GetPorcelainAPI(env).NodeMiners()
+		if err != nil {
+			return err
+		}
+		return re.Emit(miners)
+	},
+	Type: []address.Address{},
+}
+
+var minerAwaitCreateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Record the miner created by a message sent outside the node",
+		ShortDescription: `Waits for a message creating a miner, such as one output by 'miner create'
+for an owner whose key is not in the node, signed offline and sent with
+'message sendsigned'. Records the created miner and its owner and worker in the
+config.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("cid", true, false, "CID of the message creating the miner"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		msgCid, err := cid.Decode(req.Arguments[0])
+		if err != nil {
+			return errors.Wrap(err, "invalid message cid")
+		}
+
+		addr, err := GetPorcelainAPI(env).MinerAwaitCreate(req.Context, msgCid)
+		if err != nil {
+			return err
+		}
+		return re.Emit(addr)
+	},
+	Type: address.Undef,
+}
+
+func walletHasAddress(addrs []address.Address, addr address.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
 // MinerSetPriceResult is the return type for miner set-price command
 type MinerSetPriceResult struct {
 	MinerAddress address.Address
@@ -150,12 +288,16 @@ var minerSetPriceCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Set the minimum price for storage",
 		ShortDescription: `Sets the mining.minimumPrice in config and creates a new ask for the given price.
-This command waits for the ask to be mined.`,
+This command waits for the ask to be mined. Each miner of the node makes storage
+deals at its own ask.`,
 	},
 	Arguments: []cmdkit.Argument{
 		cmdkit.StringArg("storageprice", true, false, "The new price of storage in FIL per byte per block"),
 		cmdkit.StringArg("duration", true, false, "How long this ask is valid for in epochs"),
 	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		price, ok := types.NewAttoFILFromFILString(req.Arguments[0])
 		if !ok {
@@ -167,12 +309,17 @@ This command waits for the ask to be mined.`,
 			return fmt.Errorf("expiry must be a valid integer")
 		}
 
-		err := GetStorageAPI(env).AddAsk(price, abi.ChainEpoch(expiry.Uint64()))
+		minerAddr, err := optionalAddr(req.Options["miner"])
 		if err != nil {
 			return err
 		}
+		if minerAddr == address.Undef {
+			if minerAddr, err = GetBlockAPI(env).MinerAddress(); err != nil {
+				return err
+			}
+		}
 
-		minerAddr, err := GetBlockAPI(env).MinerAddress()
+		err = GetStorageAPI(env).AddAsk(minerAddr, price, abi.ChainEpoch(expiry.Uint64()))
 		if err != nil {
 			return err
 		}
@@ -225,13 +372,16 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 			return err
 		}
 
+		params := miner.ChangePeerIDParams{NewID: newPid}
+
 		if preview {
 			usedGas, err := GetPorcelainAPI(env).MessagePreview(
 				req.Context,
 				fromAddr,
 				minerAddr,
+				types.ZeroAttoFIL,
 				builtin.MethodsMiner.ChangePeerID,
-				newPid,
+				&params,
 			)
 			if err != nil {
 				return err
@@ -244,8 +394,6 @@ var minerUpdatePeerIDCmd = &cmds.Command{
 			})
 		}
 
-		params := miner.ChangePeerIDParams{NewID: newPid}
-
 		c, _, err := GetPorcelainAPI(env).MessageSend(
 			req.Context,
 			fromAddr,
@@ -292,6 +440,13 @@ var minerStatusCommand = &cmds.Command{
 	},
 }
 
+// MinerSetWorkerResult is the type returned when setting the miner worker address.
+type MinerSetWorkerResult struct {
+	Cid     cid.Cid
+	GasUsed gas.Unit
+	Preview bool
+}
+
 var minerSetWorkerAddressCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline:          "Set the address of the miner worker. Returns a message CID",
@@ -301,8 +456,10 @@ var minerSetWorkerAddressCmd = &cmds.Command{
 		cmdkit.StringArg("new-address", true, false, "The address of the new miner worker."),
 	},
 	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
 		priceOption,
 		limitOption,
+		previewOption,
 	},
 	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
 		newWorker, err := address.NewFromString(req.Arguments[0])
@@ -310,17 +467,286 @@ var minerSetWorkerAddressCmd = &cmds.Command{
 			return err
 		}
 
-		gasPrice, gasLimit, _, err := parseGasOptions(req)
+		gasPrice, gasLimit, preview, err := parseGasOptions(req)
 		if err != nil {
 			return err
 		}
 
-		msgCid, err := GetPorcelainAPI(env).MinerSetWorkerAddress(req.Context, newWorker, gasPrice, gasLimit)
+		minerAddr, err := optionalAddr(req.Options["miner"])
 		if err != nil {
 			return err
 		}
 
+		if preview {
+			usedGas, err := GetPorcelainAPI(env).MinerPreviewSetWorkerAddress(req.Context, minerAddr, newWorker)
+			if err != nil {
+				return err
+			}
+			return re.Emit(&MinerSetWorkerResult{
+				Cid:     cid.Undef,
+				GasUsed: usedGas,
+				Preview: true,
+			})
+		}
+
+		msgCid, err := GetPorcelainAPI(env).MinerSetWorkerAddress(req.Context, minerAddr, newWorker, gasPrice, gasLimit)
+		if err != nil {
+			return err
+		}
+
+		return re.Emit(&MinerSetWorkerResult{
+			Cid:     msgCid,
+			GasUsed: gas.NewGas(0),
+			Preview: false,
+		})
+	},
+	Type: &MinerSetWorkerResult{},
+}
+
+var minerWithdrawCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Withdraw available balance of the miner to its owner",
+		ShortDescription: `Withdraws FIL from the balance of the node's miner that is not locked as
+pledge or pre-commit deposit, sending it to the miner owner. Waits for the
+message to be mined and shows the resulting balances of the miner.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("amount", true, false, "The amount of FIL to withdraw"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+		priceOption,
+		limitOption,
+		previewOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		amount, ok := types.NewAttoFILFromFILString(req.Arguments[0])
+		if !ok {
+			return errors.Errorf("invalid amount %s", req.Arguments[0])
+		}
+
+		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		minerAddr, err := optionalAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+
+		res, err := GetPorcelainAPI(env).MinerWithdrawBalance(req.Context, minerAddr, amount, gasPrice, gasLimit, preview)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&res)
+	},
+	Type: &porcelain.MinerMessageResult{},
+}
+
+var minerExtendCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Extend the expiration of a sector of the miner",
+		ShortDescription: `Extends the expiration of a sector of the node's miner to the given epoch.
+Waits for the message to be mined and shows the resulting balances of the
+miner.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector to extend"),
+		cmdkit.StringArg("expiration", true, false, "The epoch the sector expires at"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+		priceOption,
+		limitOption,
+		previewOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments[:1])
+		if err != nil {
+			return err
+		}
+		expiration, err := strconv.ParseInt(req.Arguments[1], 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid expiration %s", req.Arguments[1])
+		}
+
+		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		minerAddr, err := optionalAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+
+		res, err := GetPorcelainAPI(env).MinerExtendSectorExpiration(req.Context, minerAddr, sectors[0], abi.ChainEpoch(expiration), gasPrice, gasLimit, preview)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&res)
+	},
+	Type: &porcelain.MinerMessageResult{},
+}
+
+var minerTerminateCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Terminate sectors of the miner",
+		ShortDescription: `Terminates sectors of the node's miner before their expiration, paying the
+termination fee. Waits for the message to be mined and shows the resulting
+balances of the miner.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sectors", true, true, "Numbers of the sectors to terminate"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+		priceOption,
+		limitOption,
+		previewOption,
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+
+		gasPrice, gasLimit, preview, err := parseGasOptions(req)
+		if err != nil {
+			return err
+		}
+
+		minerAddr, err := optionalAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+
+		res, err := GetPorcelainAPI(env).MinerTerminateSectors(req.Context, minerAddr, sectors, gasPrice, gasLimit, preview)
+		if err != nil {
+			return err
+		}
+		return re.Emit(&res)
+	},
+	Type: &porcelain.MinerMessageResult{},
+}
+
+var minerFaultsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and declare the faults of the node's miner sectors",
+		ShortDescription: `The node checks the health of the sectors due a few deadlines ahead and
+declares faults and recoveries automatically. These commands list the faulty
+sectors and declare faults and recoveries manually.`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":      minerFaultsLsCmd,
+		"declare": minerFaultsDeclareCmd,
+		"recover": minerFaultsRecoverCmd,
+	},
+}
+
+var minerFaultsLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the sectors declared faulty or recovering, or failing the local health check",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		faults, err := GetStorageAPI(env).SectorFaults(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(faults)
+	},
+	Type: []poster.SectorHealth{},
+}
+
+var minerFaultsDeclareCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Declare sectors faulty. Returns a message CID",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sectors", true, true, "Numbers of the sectors to declare faulty"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		msgCid, err := GetStorageAPI(env).DeclareFaults(req.Context, sectors)
+		if err != nil {
+			return err
+		}
 		return re.Emit(msgCid)
 	},
 	Type: cid.Cid{},
 }
+
+var minerFaultsRecoverCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Declare faulty sectors recovered. Returns a message CID",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sectors", true, true, "Numbers of the sectors to declare recovered"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		msgCid, err := GetStorageAPI(env).DeclareRecoveries(req.Context, sectors)
+		if err != nil {
+			return err
+		}
+		return re.Emit(msgCid)
+	},
+	Type: cid.Cid{},
+}
+
+var minerProvingCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect the window PoSts of the node's miner",
+	},
+	Subcommands: map[string]*cmds.Command{
+		"info":      minerProvingInfoCmd,
+		"deadlines": minerProvingDeadlinesCmd,
+	},
+}
+
+var minerProvingInfoCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the current proving period and deadline, with the state of its PoSt",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		info, err := GetStorageAPI(env).ProvingInfo(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(info)
+	},
+	Type: poster.ProvingInfo{},
+}
+
+var minerProvingDeadlinesCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the deadlines of the proving period with their upcoming window and last PoSt result",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		deadlines, err := GetStorageAPI(env).ProvingDeadlines(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(deadlines)
+	},
+	Type: []poster.DeadlineSchedule{},
+}
+
+func sectorNumbersFromSlice(args []string) ([]abi.SectorNumber, error) {
+	sectors := make([]abi.SectorNumber, len(args))
+	for i, arg := range args {
+		n, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid sector number %s", arg)
+		}
+		sectors[i] = abi.SectorNumber(n)
+	}
+	return sectors, nil
+}
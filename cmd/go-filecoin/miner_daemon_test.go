@@ -81,7 +81,7 @@ func TestMinerHelp(t *testing.T) {
 	t.Run("set-worker --help shows set-worker help", func(t *testing.T) {
 		expected := []string{
 			"go-filecoin miner set-worker <new-address> - Set the address of the miner worker",
-			"go-filecoin miner set-worker [--gas-price=<gas-price>] [--gas-limit=<gas-limit>] [--] <new-address>",
+			"go-filecoin miner set-worker [--miner=<miner>] [--gas-price=<gas-price>] [--gas-limit=<gas-limit>] [--preview] [--] <new-address>",
 			"<new-address> - The address of the new miner worker.",
 			"--gas-price string - Price (FIL e.g. 0.00013) to pay for each GasUnit consumed mining this message.",
 			"--gas-limit uint64 - Maximum GasUnits this message is allowed to consume.",
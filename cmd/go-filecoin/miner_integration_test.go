@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -32,7 +33,7 @@ func TestMinerCreateIntegration(t *testing.T) {
 	defaultAddr := newMiner.Repo.Config().Wallet.DefaultAddress
 	peer := newMiner.Network().Network.GetPeerID()
 
-	minerAddr, err := porcelainAPI.MinerCreate(ctx, defaultAddr, types.NewAttoFILFromFIL(1), 10000, abi.RegisteredProof_StackedDRG2KiBSeal, peer, types.NewAttoFILFromFIL(1))
+	minerAddr, err := porcelainAPI.MinerCreate(ctx, defaultAddr, address.Undef, types.NewAttoFILFromFIL(1), 10000, abi.RegisteredProof_StackedDRG2KiBSeal, peer, types.NewAttoFILFromFIL(1))
 	require.NoError(t, err)
 
 	// inspect results on chain
@@ -59,7 +60,7 @@ func TestSetPrice(t *testing.T) {
 
 	env := commands.CreateServerEnv(ctx, nodes[0])
 
-	err := commands.GetStorageAPI(env).AddAsk(abi.NewTokenAmount(1000), abi.ChainEpoch(400))
+	err := commands.GetStorageAPI(env).AddAsk(address.Undef, abi.NewTokenAmount(1000), abi.ChainEpoch(400))
 	require.NoError(t, err)
 
 	minerAddr, err := commands.GetBlockAPI(env).MinerAddress()
@@ -5,6 +5,12 @@ import (
 	"github.com/ipfs/go-cid"
 	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
 	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	"github.com/sbwtw/go-filecoin/internal/pkg/mining"
+	"github.com/sbwtw/go-filecoin/internal/pkg/pledger"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
 )
 
 var miningCmd = &cmds.Command{
@@ -16,9 +22,11 @@ var miningCmd = &cmds.Command{
 		"once":          miningOnceCmd,
 		"start":         miningStartCmd,
 		"status":        miningStatusCmd,
+		"history":       miningHistoryCmd,
 		"stop":          miningStopCmd,
 		"setup":         miningSetupCmd,
 		"pledge-sector": miningPledgeSectorCmd,
+		"pledge-policy": miningPledgePolicyCmd,
 	},
 }
 
@@ -103,6 +111,28 @@ var miningStatusCmd = &cmds.Command{
 	Type: &MiningStatusResult{},
 }
 
+var miningHistoryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the outcome of mining in recent epochs",
+		ShortDescription: `Each epoch the node mined in is reported, most recent first, with its base,
+its election ticket against the miner's threshold, the power the election
+was run with, the time taken to fetch drand entries and generate the winning
+PoSt, and the block mined, if any, with whether it is in the chain.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.UintOption("limit", "Number of epochs to show, all if 0").WithDefault(uint(20)),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		limit, _ := req.Options["limit"].(uint)
+		reports, err := GetBlockAPI(env).MiningHistory(limit)
+		if err != nil {
+			return err
+		}
+		return re.Emit(reports)
+	},
+	Type: []mining.EpochReport{},
+}
+
 var miningStopCmd = &cmds.Command{
 	Helptext: cmdkit.HelpText{
 		Tagline: "Stop block mining",
@@ -126,3 +156,88 @@ var miningPledgeSectorCmd = &cmds.Command{
 	},
 	Type: "",
 }
+
+var miningPledgePolicyCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the policy the node pledges sectors by",
+		ShortDescription: `When enabled, the node pledges committed-capacity sectors on its own to keep
+a number of sectors sealing. It pauses while free space under the sector
+directory or the worker balance for pre-commit deposits runs short, and stops
+at a target raw power. The policy is checked every
+mining.autoSealIntervalSeconds.`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"get": miningPledgePolicyGetCmd,
+		"set": miningPledgePolicySetCmd,
+	},
+}
+
+// PledgePolicyResult is the pledge policy of the node with the outcome of its
+// last check, if the node has checked it.
+type PledgePolicyResult struct {
+	Policy config.PledgePolicyConfig
+	Status *pledger.Status
+}
+
+var miningPledgePolicyGetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the pledge policy and the outcome of its last check",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		policy, err := GetPorcelainAPI(env).PledgePolicy()
+		if err != nil {
+			return err
+		}
+		// the node checks the policy only while it is storage mining
+		status, _ := GetStorageAPI(env).PledgeStatus()
+		return re.Emit(&PledgePolicyResult{Policy: policy, Status: status})
+	},
+	Type: &PledgePolicyResult{},
+}
+
+var miningPledgePolicySetCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline:          "Change the pledge policy",
+		ShortDescription: `Only the settings given are changed.`,
+	},
+	Options: []cmdkit.Option{
+		cmdkit.BoolOption("enabled", "Pledge sectors automatically"),
+		cmdkit.UintOption("max-sealing", "Number of sectors to keep sealing at once"),
+		cmdkit.Uint64Option("min-free-space", "Bytes to keep free across the sector storage paths"),
+		cmdkit.StringOption("deposit", "Least worker balance to set aside per sector pledged, in FIL"),
+		cmdkit.Uint64Option("target-raw-power", "Raw power in bytes at which to stop pledging, 0 for no target"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		api := GetPorcelainAPI(env)
+		policy, err := api.PledgePolicy()
+		if err != nil {
+			return err
+		}
+
+		if enabled, ok := req.Options["enabled"].(bool); ok {
+			policy.Enabled = enabled
+		}
+		if maxSealing, ok := req.Options["max-sealing"].(uint); ok {
+			policy.MaxSealing = maxSealing
+		}
+		if minFreeSpace, ok := req.Options["min-free-space"].(uint64); ok {
+			policy.MinFreeSpace = minFreeSpace
+		}
+		if deposit, ok := req.Options["deposit"].(string); ok {
+			amount, valid := types.NewAttoFILFromFILString(deposit)
+			if !valid {
+				return errors.Errorf("invalid deposit %s", deposit)
+			}
+			policy.Deposit = amount
+		}
+		if target, ok := req.Options["target-raw-power"].(uint64); ok {
+			policy.TargetRawPower = target
+		}
+
+		if err := api.SetPledgePolicy(policy); err != nil {
+			return err
+		}
+		return re.Emit(&PledgePolicyResult{Policy: policy})
+	},
+	Type: &PledgePolicyResult{},
+}
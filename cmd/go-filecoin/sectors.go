@@ -0,0 +1,130 @@
+package commands
+
+import (
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/sectorstatus"
+)
+
+var sectorsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Inspect and retry the sectors of the node's miner",
+		ShortDescription: `Sectors are reported with their state in the sealing pipeline, the deals
+they hold and their pre-commitment or commitment on chain.`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"ls":     sectorsLsCmd,
+		"status": sectorsStatusCmd,
+		"log":    sectorsLogCmd,
+		"retry":  sectorsRetryCmd,
+		"remove": sectorsRemoveCmd,
+	},
+}
+
+var sectorsLsCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the sectors being sealed or on chain",
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := GetStorageAPI(env).ListSectors(req.Context)
+		if err != nil {
+			return err
+		}
+		return re.Emit(sectors)
+	},
+	Type: []sectorstatus.Sector{},
+}
+
+var sectorsStatusCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the sealing and on-chain state of a sector",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		sector, err := GetStorageAPI(env).SectorStatus(req.Context, sectors[0])
+		if err != nil {
+			return err
+		}
+		return re.Emit(sector)
+	},
+	Type: sectorstatus.Sector{},
+}
+
+var sectorsLogCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Show the events recorded while sealing a sector",
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		entries, err := GetStorageAPI(env).SectorLog(req.Context, sectors[0])
+		if err != nil {
+			return err
+		}
+		return re.Emit(entries)
+	},
+	Type: []sectorstatus.LogEntry{},
+}
+
+var sectorsRetryCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Move a failed sector back into the sealing pipeline",
+		ShortDescription: `The sector is moved back to the sealing step that failed, or to the state
+given with --state, one of Packing, PreCommit1, PreCommitting, Committing or
+FinalizeSector.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("state", "Sealing state to move the sector to"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		state, _ := req.Options["state"].(string)
+		sector, err := GetStorageAPI(env).RetrySector(req.Context, sectors[0], state)
+		if err != nil {
+			return err
+		}
+		return re.Emit(sector)
+	},
+	Type: sectorstatus.Sector{},
+}
+
+var sectorsRemoveCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Take a sector that is not on chain out of the sealing pipeline",
+		ShortDescription: `The sector is left in a final state and its files are kept in storage.
+Sectors pre-committed or proven on chain are terminated with
+'go-filecoin miner terminate' instead.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("sector", true, false, "Number of the sector"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		sectors, err := sectorNumbersFromSlice(req.Arguments)
+		if err != nil {
+			return err
+		}
+		if err := GetStorageAPI(env).RemoveSector(req.Context, sectors[0]); err != nil {
+			return err
+		}
+		return re.Emit("Sector removed")
+	},
+	Type: "",
+}
@@ -0,0 +1,111 @@
+package commands
+
+import (
+	cmdkit "github.com/ipfs/go-ipfs-cmdkit"
+	cmds "github.com/ipfs/go-ipfs-cmds"
+	"github.com/pkg/errors"
+
+	fsmstorage "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_storage"
+)
+
+var storageCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Manage the local storage paths of the node's miners",
+		ShortDescription: `The primary miner seals and stores sectors in the sector root directory and
+in the storage paths attached to it, a further miner in its directory of the
+sector root and the paths attached to it. The sector manager places new files
+in the paths allowing sealing or storing, preferring those of higher weight.`,
+	},
+	Subcommands: map[string]*cmds.Command{
+		"attach": storageAttachCmd,
+		"detach": storageDetachCmd,
+		"list":   storageListCmd,
+	},
+}
+
+var storageAttachCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Attach a local storage path to the miner",
+		ShortDescription: `The directory is created and initialized with the weight and uses given,
+unless it is initialized already, in which case its metadata is kept.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "Path of the storage directory"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+		cmdkit.Uint64Option("weight", "Preference for placing sector files in the path").WithDefault(uint64(10)),
+		cmdkit.BoolOption("seal", "Allow sectors to be sealed in the path"),
+		cmdkit.BoolOption("store", "Allow sealed sectors to be stored in the path"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		weight, _ := req.Options["weight"].(uint64)
+		canSeal, _ := req.Options["seal"].(bool)
+		canStore, _ := req.Options["store"].(bool)
+		if !canSeal && !canStore {
+			return errors.New("storage path must allow sealing, storing or both")
+		}
+
+		minerAddr, err := optionalAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+
+		if err := GetStorageAPI(env).AttachStorage(req.Context, minerAddr, req.Arguments[0], weight, canSeal, canStore); err != nil {
+			return err
+		}
+		return re.Emit("Storage path attached")
+	},
+	Type: "",
+}
+
+var storageDetachCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "Detach a local storage path from the miner",
+		ShortDescription: `A path holding sector files is detached only with --force. The files are
+kept in place. The sector manager keeps the paths it opened, so a path is
+only detached before mining starts.`,
+	},
+	Arguments: []cmdkit.Argument{
+		cmdkit.StringArg("path", true, false, "Path of the storage directory"),
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+		cmdkit.BoolOption("force", "Detach the path even though it holds sector files"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		force, _ := req.Options["force"].(bool)
+		minerAddr, err := optionalAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+
+		if err := GetStorageAPI(env).DetachStorage(minerAddr, req.Arguments[0], force); err != nil {
+			return err
+		}
+		return re.Emit("Storage path detached")
+	},
+	Type: "",
+}
+
+var storageListCmd = &cmds.Command{
+	Helptext: cmdkit.HelpText{
+		Tagline: "List the local storage paths of the miner with their capacity",
+	},
+	Options: []cmdkit.Option{
+		cmdkit.StringOption("miner", "Address of the miner, defaults to the node's primary miner"),
+	},
+	Run: func(req *cmds.Request, re cmds.ResponseEmitter, env cmds.Environment) error {
+		minerAddr, err := optionalAddr(req.Options["miner"])
+		if err != nil {
+			return err
+		}
+
+		paths, err := GetStorageAPI(env).ListStorage(minerAddr)
+		if err != nil {
+			return err
+		}
+		return re.Emit(paths)
+	},
+	Type: []fsmstorage.StoragePath{},
+}
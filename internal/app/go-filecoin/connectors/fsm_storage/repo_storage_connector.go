@@ -1,9 +1,11 @@
 package fsmstorage
 
 import (
-	"errors"
+	"path/filepath"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/paths"
 	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
@@ -11,12 +13,23 @@ import (
 
 type RepoStorageConnector struct {
 	inner repo.Repo
+	// miner is the further miner actor of the node whose sectors are kept,
+	// undefined for the primary miner.
+	miner address.Address
 }
 
 var _ stores.LocalStorage = new(RepoStorageConnector)
 
 func NewRepoStorageConnector(r repo.Repo) *RepoStorageConnector {
-	return &RepoStorageConnector{inner: r}
+	return &RepoStorageConnector{inner: r, miner: address.Undef}
+}
+
+// NewMinerStorageConnector stores the sectors of a further miner actor of the
+// node in a directory of the sector root named after its address, apart from
+// those of the node's primary miner, and in the storage paths attached to it
+// in its miner config.
+func NewMinerStorageConnector(r repo.Repo, minerAddr address.Address) *RepoStorageConnector {
+	return &RepoStorageConnector{inner: r, miner: minerAddr}
 }
 
 func (b *RepoStorageConnector) GetStorage() (stores.StorageConfig, error) {
@@ -32,15 +45,81 @@ func (b *RepoStorageConnector) GetStorage() (stores.StorageConfig, error) {
 		return stores.StorageConfig{}, err
 	}
 
-	out := stores.StorageConfig{StoragePaths: []stores.LocalPath{{Path: spt}}}
+	attached, err := b.attachedPaths()
+	if err != nil {
+		return stores.StorageConfig{}, err
+	}
 
-	if scg.PreSealedSectorsDirPath != "" {
-		out.StoragePaths = append(out.StoragePaths, stores.LocalPath{Path: scg.PreSealedSectorsDirPath})
+	out := stores.StorageConfig{StoragePaths: b.fixedPaths(spt)}
+	for _, path := range attached {
+		out.StoragePaths = append(out.StoragePaths, stores.LocalPath{Path: path})
 	}
 
 	return out, nil
 }
 
+// SetStorage persists the storage paths besides the sector root and the
+// pre-sealed sector directory in the repo config, those of a further miner in
+// its miner config. The sector manager sets them as storage paths are
+// attached.
 func (b *RepoStorageConnector) SetStorage(f func(*stores.StorageConfig)) error {
-	return errors.New("unsupported operation: manipulating store paths must happen through go-filecoin")
+	scg, err := b.GetStorage()
+	if err != nil {
+		return err
+	}
+	fixed := b.fixedCount()
+	f(&scg)
+	if len(scg.StoragePaths) < fixed {
+		return errors.New("unsupported operation: the sector root and pre-sealed sector directory cannot be removed")
+	}
+
+	attached := []string{}
+	for _, path := range scg.StoragePaths[fixed:] {
+		attached = append(attached, path.Path)
+	}
+	cfg := b.inner.Config()
+	if b.miner == address.Undef {
+		cfg.SectorBase.StoragePaths = attached
+		return b.inner.ReplaceConfig(cfg)
+	}
+	for i := range cfg.Mining.Miners {
+		if cfg.Mining.Miners[i].Address == b.miner {
+			cfg.Mining.Miners[i].StoragePaths = attached
+			return b.inner.ReplaceConfig(cfg)
+		}
+	}
+	return errors.Errorf("miner %s is not configured", b.miner)
+}
+
+// fixedPaths returns the storage paths derived from the sector base config,
+// the sector root first. A further miner has the directory of the sector root
+// named after it only, as pre-sealed sectors belong to the primary miner.
+func (b *RepoStorageConnector) fixedPaths(root string) []stores.LocalPath {
+	if b.miner != address.Undef {
+		return []stores.LocalPath{{Path: filepath.Join(root, b.miner.String())}}
+	}
+	out := []stores.LocalPath{{Path: root}}
+	if preSealed := b.inner.Config().SectorBase.PreSealedSectorsDirPath; preSealed != "" {
+		out = append(out, stores.LocalPath{Path: preSealed})
+	}
+	return out
+}
+
+// fixedCount returns the number of fixed paths leading the storage paths.
+func (b *RepoStorageConnector) fixedCount() int {
+	return len(b.fixedPaths(""))
+}
+
+// attachedPaths returns the storage paths attached in the config.
+func (b *RepoStorageConnector) attachedPaths() ([]string, error) {
+	cfg := b.inner.Config()
+	if b.miner == address.Undef {
+		return cfg.SectorBase.StoragePaths, nil
+	}
+	for _, m := range cfg.Mining.Miners {
+		if m.Address == b.miner {
+			return m.StoragePaths, nil
+		}
+	}
+	return nil, errors.Errorf("miner %s is not configured", b.miner)
 }
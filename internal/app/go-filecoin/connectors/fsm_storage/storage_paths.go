@@ -0,0 +1,211 @@
+package fsmstorage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/util/fsutil"
+)
+
+// sectorFileDirs are the directories of a storage path the sector manager
+// keeps sector files in.
+var sectorFileDirs = []string{"unsealed", "sealed", "cache"}
+
+// StoragePath is a local directory the miner seals or stores sectors in.
+type StoragePath struct {
+	ID       stores.ID
+	Path     string
+	Weight   uint64
+	CanSeal  bool
+	CanStore bool
+	// Attached is set for the paths attached besides the sector root and the
+	// pre-sealed sector directory.
+	Attached bool
+
+	// Capacity and Available are the size of the file system holding the
+	// path and the bytes free on it.
+	Capacity  uint64
+	Available uint64
+	// Sectors is the number of sealed sectors stored in the path.
+	Sectors int
+}
+
+// InitStoragePath creates the directory and storage metadata of a storage
+// path, unless the directory already holds metadata, which is kept.
+func InitStoragePath(path string, weight uint64, canSeal, canStore bool) error {
+	_, err := os.Stat(filepath.Join(path, stores.MetaFile))
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	// TODO: Set the appropriate permissions.
+	if err := os.MkdirAll(path, 0777); err != nil {
+		return err
+	}
+
+	meta := stores.LocalStorageMeta{
+		ID:       stores.ID(uuid.New().String()),
+		Weight:   weight,
+		CanSeal:  canSeal,
+		CanStore: canStore,
+	}
+	b, err := json.MarshalIndent(&meta, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// TODO: Set the appropriate permissions.
+	return ioutil.WriteFile(filepath.Join(path, stores.MetaFile), b, 0777)
+}
+
+// ListStorage returns the storage paths of the miner, the sector root first,
+// with their metadata and usage.
+func (b *RepoStorageConnector) ListStorage() ([]StoragePath, error) {
+	scg, err := b.GetStorage()
+	if err != nil {
+		return nil, err
+	}
+	fixed := b.fixedCount()
+
+	out := make([]StoragePath, len(scg.StoragePaths))
+	for i, lp := range scg.StoragePaths {
+		meta, err := readStorageMeta(lp.Path)
+		if err != nil {
+			return nil, err
+		}
+		capacity, available, err := fsutil.Stat(lp.Path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get usage of storage path %s", lp.Path)
+		}
+		sealed, err := countFiles(filepath.Join(lp.Path, "sealed"))
+		if err != nil {
+			return nil, err
+		}
+
+		out[i] = StoragePath{
+			ID:        meta.ID,
+			Path:      lp.Path,
+			Weight:    meta.Weight,
+			CanSeal:   meta.CanSeal,
+			CanStore:  meta.CanStore,
+			Attached:  i >= fixed,
+			Capacity:  capacity,
+			Available: available,
+			Sectors:   sealed,
+		}
+	}
+	return out, nil
+}
+
+// FreeSpace returns the bytes free for sectors across the storage paths
+// allowing sealing or storing, counting each file system once.
+func (b *RepoStorageConnector) FreeSpace() (uint64, error) {
+	paths, err := b.ListStorage()
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	seen := make(map[uint64]bool)
+	for _, p := range paths {
+		if !p.CanSeal && !p.CanStore {
+			continue
+		}
+		dev, err := fsutil.Device(p.Path)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get file system of storage path %s", p.Path)
+		}
+		if seen[dev] {
+			continue
+		}
+		seen[dev] = true
+		free += p.Available
+	}
+	return free, nil
+}
+
+// AttachStorage adds an initialized storage path to the config, to be opened
+// by the sector manager when storage mining is set up.
+func (b *RepoStorageConnector) AttachStorage(path string) error {
+	return b.SetStorage(func(scg *stores.StorageConfig) {
+		scg.StoragePaths = append(scg.StoragePaths, stores.LocalPath{Path: path})
+	})
+}
+
+// DetachStorage removes an attached storage path from the config. A path
+// holding sector files is only removed when forced. A sector manager that
+// opened the path keeps using it, so the path must only be detached while no
+// sector manager runs on the storage.
+func (b *RepoStorageConnector) DetachStorage(path string, force bool) error {
+	paths, err := b.ListStorage()
+	if err != nil {
+		return err
+	}
+	var found bool
+	for _, p := range paths {
+		if p.Path != path {
+			continue
+		}
+		if !p.Attached {
+			return errors.Errorf("storage path %s is not attached and cannot be detached", path)
+		}
+		found = true
+	}
+	if !found {
+		return errors.Errorf("storage path %s is not attached", path)
+	}
+
+	if !force {
+		files := 0
+		for _, dir := range sectorFileDirs {
+			n, err := countFiles(filepath.Join(path, dir))
+			if err != nil {
+				return err
+			}
+			files += n
+		}
+		if files > 0 {
+			return errors.Errorf("storage path %s holds %d sector files, move them or force the detach", path, files)
+		}
+	}
+
+	return b.SetStorage(func(scg *stores.StorageConfig) {
+		kept := scg.StoragePaths[:0]
+		for _, lp := range scg.StoragePaths {
+			if lp.Path != path {
+				kept = append(kept, lp)
+			}
+		}
+		scg.StoragePaths = kept
+	})
+}
+
+func readStorageMeta(path string) (stores.LocalStorageMeta, error) {
+	var meta stores.LocalStorageMeta
+	b, err := ioutil.ReadFile(filepath.Join(path, stores.MetaFile))
+	if err != nil {
+		return meta, errors.Wrapf(err, "failed to read metadata of storage path %s", path)
+	}
+	if err := json.Unmarshal(b, &meta); err != nil {
+		return meta, errors.Wrapf(err, "failed to decode metadata of storage path %s", path)
+	}
+	return meta, nil
+}
+
+// countFiles returns the number of entries in a directory, zero if it does
+// not exist.
+func countFiles(dir string) (int, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), nil
+}
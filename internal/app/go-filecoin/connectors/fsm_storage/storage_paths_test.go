@@ -0,0 +1,119 @@
+package fsmstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestStoragePaths(t *testing.T) {
+	tf.UnitTest(t)
+	dir, err := ioutil.TempDir("", "storage-paths")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	root, extra := filepath.Join(dir, "root"), filepath.Join(dir, "extra")
+	r := repo.NewInMemoryRepo()
+	r.Config().SectorBase.RootDirPath = root
+	require.NoError(t, InitStoragePath(root, 10, true, true))
+	connector := NewRepoStorageConnector(r)
+
+	require.NoError(t, InitStoragePath(extra, 5, false, true))
+	require.NoError(t, connector.AttachStorage(extra))
+	assert.Equal(t, []string{extra}, r.Config().SectorBase.StoragePaths)
+
+	paths, err := connector.ListStorage()
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	assert.Equal(t, root, paths[0].Path)
+	assert.False(t, paths[0].Attached)
+	assert.Equal(t, extra, paths[1].Path)
+	assert.True(t, paths[1].Attached)
+	assert.Equal(t, uint64(5), paths[1].Weight)
+	assert.False(t, paths[1].CanSeal)
+	assert.True(t, paths[1].CanStore)
+	assert.NotEqual(t, paths[0].ID, paths[1].ID)
+	assert.True(t, paths[1].Capacity > 0)
+
+	t.Run("counts the free space of a file system once", func(t *testing.T) {
+		// the sector root and the attached path share the temp file system
+		free, err := connector.FreeSpace()
+		require.NoError(t, err)
+		assert.True(t, free > 0)
+		assert.True(t, free < paths[0].Available+paths[1].Available)
+	})
+
+	t.Run("keeps existing metadata", func(t *testing.T) {
+		require.NoError(t, InitStoragePath(extra, 1, true, false))
+		meta, err := readStorageMeta(extra)
+		require.NoError(t, err)
+		assert.Equal(t, paths[1].ID, meta.ID)
+		assert.Equal(t, uint64(5), meta.Weight)
+	})
+
+	t.Run("detaches only attached paths", func(t *testing.T) {
+		assert.Error(t, connector.DetachStorage(root, true))
+		assert.Error(t, connector.DetachStorage(filepath.Join(dir, "other"), true))
+	})
+
+	t.Run("detaches paths holding sectors when forced", func(t *testing.T) {
+		require.NoError(t, os.MkdirAll(filepath.Join(extra, "sealed"), 0777))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(extra, "sealed", "s-t01000-1"), []byte{}, 0644))
+
+		paths, err := connector.ListStorage()
+		require.NoError(t, err)
+		assert.Equal(t, 1, paths[1].Sectors)
+
+		assert.Error(t, connector.DetachStorage(extra, false))
+		require.NoError(t, connector.DetachStorage(extra, true))
+		assert.Equal(t, []string{}, r.Config().SectorBase.StoragePaths)
+	})
+}
+
+func TestMinerStoragePaths(t *testing.T) {
+	tf.UnitTest(t)
+	dir, err := ioutil.TempDir("", "storage-paths")
+	require.NoError(t, err)
+	defer func() { require.NoError(t, os.RemoveAll(dir)) }()
+
+	addrs := vmaddr.NewForTestGetter()
+	minerAddr := addrs()
+	root, extra := filepath.Join(dir, "root"), filepath.Join(dir, "extra")
+	r := repo.NewInMemoryRepo()
+	r.Config().SectorBase.RootDirPath = root
+	r.Config().Mining.Miners = []config.MinerConfig{{Address: minerAddr}}
+	require.NoError(t, InitStoragePath(filepath.Join(root, minerAddr.String()), 10, true, true))
+	connector := NewMinerStorageConnector(r, minerAddr)
+
+	require.NoError(t, InitStoragePath(extra, 5, true, false))
+	require.NoError(t, connector.AttachStorage(extra))
+	assert.Equal(t, []string{extra}, r.Config().Mining.Miners[0].StoragePaths)
+	assert.Empty(t, r.Config().SectorBase.StoragePaths)
+
+	paths, err := connector.ListStorage()
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	assert.Equal(t, filepath.Join(root, minerAddr.String()), paths[0].Path)
+	assert.False(t, paths[0].Attached)
+	assert.Equal(t, extra, paths[1].Path)
+	assert.True(t, paths[1].Attached)
+
+	assert.Error(t, connector.DetachStorage(paths[0].Path, true))
+	require.NoError(t, connector.DetachStorage(extra, false))
+	assert.Equal(t, []string{}, r.Config().Mining.Miners[0].StoragePaths)
+
+	t.Run("fails for a miner not configured", func(t *testing.T) {
+		other := NewMinerStorageConnector(r, addrs())
+		_, err := other.GetStorage()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,218 @@
+package storagemarketconnector
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/go-address"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	smvalid "github.com/filecoin-project/go-fil-markets/storagemarket/impl/requestvalidation"
+	smnetwork "github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"golang.org/x/xerrors"
+)
+
+var log = logging.Logger("storagemarketconnector")
+
+// ProviderNetwork shares the storage market network of a host among the
+// storage providers of the miners of a node. The host takes a single receiver
+// of ask and deal streams, so the network reads the miner each request is for
+// and hands the stream to the provider of that miner.
+type ProviderNetwork struct {
+	net smnetwork.StorageMarketNetwork
+
+	lk        sync.Mutex
+	receivers map[address.Address]smnetwork.StorageReceiver
+}
+
+// NewProviderNetwork creates a network shared by the providers on the host of
+// the network.
+func NewProviderNetwork(net smnetwork.StorageMarketNetwork) *ProviderNetwork {
+	return &ProviderNetwork{
+		net:       net,
+		receivers: make(map[address.Address]smnetwork.StorageReceiver),
+	}
+}
+
+// ForMiner returns the network of the provider of a miner.
+func (n *ProviderNetwork) ForMiner(minerAddr address.Address) smnetwork.StorageMarketNetwork {
+	return &minerNetwork{StorageMarketNetwork: n.net, shared: n, miner: minerAddr}
+}
+
+// HandleAskStream hands an ask stream to the provider of the miner asked.
+func (n *ProviderNetwork) HandleAskStream(s smnetwork.StorageAskStream) {
+	req, err := s.ReadAskRequest()
+	if err != nil {
+		log.Warnf("failed to read ask request: %s", err)
+		closeStream(s)
+		return
+	}
+	r := n.receiver(req.Miner)
+	if r == nil {
+		log.Warnf("ask for miner %s not operated by the node", req.Miner)
+		closeStream(s)
+		return
+	}
+	r.HandleAskStream(&askStream{StorageAskStream: s, req: req})
+}
+
+// HandleDealStream hands a deal stream to the provider of the miner the deal
+// is proposed to.
+func (n *ProviderNetwork) HandleDealStream(s smnetwork.StorageDealStream) {
+	proposal, err := s.ReadDealProposal()
+	if err != nil {
+		log.Warnf("failed to read deal proposal: %s", err)
+		closeStream(s)
+		return
+	}
+	if proposal.DealProposal == nil {
+		log.Warnf("deal proposal from %s has no deal", s.RemotePeer())
+		closeStream(s)
+		return
+	}
+	provider := proposal.DealProposal.Proposal.Provider
+	r := n.receiver(provider)
+	if r == nil {
+		log.Warnf("deal proposed to miner %s not operated by the node", provider)
+		closeStream(s)
+		return
+	}
+	r.HandleDealStream(&dealStream{StorageDealStream: s, proposal: proposal})
+}
+
+func (n *ProviderNetwork) receiver(minerAddr address.Address) smnetwork.StorageReceiver {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	return n.receivers[minerAddr]
+}
+
+// register sets the receiver of a miner, taking the streams of the host with
+// the first receiver.
+func (n *ProviderNetwork) register(minerAddr address.Address, r smnetwork.StorageReceiver) error {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	if len(n.receivers) == 0 {
+		if err := n.net.SetDelegate(n); err != nil {
+			return err
+		}
+	}
+	n.receivers[minerAddr] = r
+	return nil
+}
+
+// unregister removes the receiver of a miner, releasing the streams of the
+// host with the last receiver.
+func (n *ProviderNetwork) unregister(minerAddr address.Address) error {
+	n.lk.Lock()
+	defer n.lk.Unlock()
+	if _, ok := n.receivers[minerAddr]; !ok {
+		return nil
+	}
+	delete(n.receivers, minerAddr)
+	if len(n.receivers) == 0 {
+		return n.net.StopHandlingRequests()
+	}
+	return nil
+}
+
+// minerNetwork is the network of the provider of one miner.
+type minerNetwork struct {
+	smnetwork.StorageMarketNetwork
+	shared *ProviderNetwork
+	miner  address.Address
+}
+
+func (m *minerNetwork) SetDelegate(r smnetwork.StorageReceiver) error {
+	return m.shared.register(m.miner, r)
+}
+
+func (m *minerNetwork) StopHandlingRequests() error {
+	return m.shared.unregister(m.miner)
+}
+
+// askStream replays the ask request read to find the miner asked.
+type askStream struct {
+	smnetwork.StorageAskStream
+	req smnetwork.AskRequest
+}
+
+func (s *askStream) ReadAskRequest() (smnetwork.AskRequest, error) {
+	return s.req, nil
+}
+
+// dealStream replays the deal proposal read to find the miner it is proposed
+// to.
+type dealStream struct {
+	smnetwork.StorageDealStream
+	proposal smnetwork.Proposal
+}
+
+func (s *dealStream) ReadDealProposal() (smnetwork.Proposal, error) {
+	return s.proposal, nil
+}
+
+func closeStream(s interface{ Close() error }) {
+	if err := s.Close(); err != nil {
+		log.Warnf("failed to close stream: %s", err)
+	}
+}
+
+// ProviderValidator validates the data transfers of the deals of the storage
+// providers of the miners of a node, which share the data transfer of the
+// host. Each provider validates the transfers of the deals it keeps.
+type ProviderValidator struct {
+	lk         sync.Mutex
+	validators map[address.Address]datatransfer.RequestValidator
+}
+
+var _ datatransfer.RequestValidator = new(ProviderValidator)
+
+// NewProviderValidator creates a validator with no providers.
+func NewProviderValidator() *ProviderValidator {
+	return &ProviderValidator{validators: make(map[address.Address]datatransfer.RequestValidator)}
+}
+
+// Add sets the validator of the deals of a miner's provider.
+func (v *ProviderValidator) Add(minerAddr address.Address, validator datatransfer.RequestValidator) {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	v.validators[minerAddr] = validator
+}
+
+// ValidatePush accepts a push the provider of some miner accepts.
+func (v *ProviderValidator) ValidatePush(sender peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
+	return v.validate(func(validator datatransfer.RequestValidator) error {
+		return validator.ValidatePush(sender, voucher, baseCid, selector)
+	})
+}
+
+// ValidatePull accepts a pull the provider of some miner accepts.
+func (v *ProviderValidator) ValidatePull(receiver peer.ID, voucher datatransfer.Voucher, baseCid cid.Cid, selector ipld.Node) error {
+	return v.validate(func(validator datatransfer.RequestValidator) error {
+		return validator.ValidatePull(receiver, voucher, baseCid, selector)
+	})
+}
+
+// validate returns nil if a provider accepts the transfer, otherwise the
+// rejection of the provider keeping the deal, if one does.
+func (v *ProviderValidator) validate(f func(datatransfer.RequestValidator) error) error {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	var rejected error
+	for _, validator := range v.validators {
+		err := f(validator)
+		if err == nil {
+			return nil
+		}
+		if rejected == nil || !xerrors.Is(err, smvalid.ErrNoDeal) {
+			rejected = err
+		}
+	}
+	if rejected == nil {
+		return errors.New("no storage provider validates transfers")
+	}
+	return rejected
+}
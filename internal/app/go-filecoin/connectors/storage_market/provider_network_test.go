@@ -0,0 +1,161 @@
+package storagemarketconnector
+
+import (
+	"testing"
+
+	datatransfer "github.com/filecoin-project/go-data-transfer"
+	smvalid "github.com/filecoin-project/go-fil-markets/storagemarket/impl/requestvalidation"
+	smnetwork "github.com/filecoin-project/go-fil-markets/storagemarket/network"
+	"github.com/filecoin-project/specs-actors/actors/builtin/market"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestProviderNetwork(t *testing.T) {
+	tf.UnitTest(t)
+	addrs := vmaddr.NewForTestGetter()
+	minerA, minerB, other := addrs(), addrs(), addrs()
+
+	net := &fakeMarketNetwork{}
+	shared := NewProviderNetwork(net)
+	receiverA, receiverB := &fakeReceiver{}, &fakeReceiver{}
+	require.NoError(t, shared.ForMiner(minerA).SetDelegate(receiverA))
+	require.NoError(t, shared.ForMiner(minerB).SetDelegate(receiverB))
+	assert.Equal(t, shared, net.delegate)
+
+	t.Run("hands asks to the provider of the miner asked", func(t *testing.T) {
+		net.delegate.HandleAskStream(&fakeAskStream{req: smnetwork.AskRequest{Miner: minerB}})
+		require.Len(t, receiverB.asks, 1)
+		assert.Empty(t, receiverA.asks)
+		req, err := receiverB.asks[0].ReadAskRequest()
+		require.NoError(t, err)
+		assert.Equal(t, minerB, req.Miner)
+
+		stream := &fakeAskStream{req: smnetwork.AskRequest{Miner: other}}
+		net.delegate.HandleAskStream(stream)
+		assert.True(t, stream.closed)
+	})
+
+	t.Run("hands deals to the provider of the miner proposed to", func(t *testing.T) {
+		proposal := smnetwork.Proposal{DealProposal: &market.ClientDealProposal{Proposal: market.DealProposal{Provider: minerA}}}
+		net.delegate.HandleDealStream(&fakeDealStream{proposal: proposal})
+		require.Len(t, receiverA.deals, 1)
+		read, err := receiverA.deals[0].ReadDealProposal()
+		require.NoError(t, err)
+		assert.Equal(t, minerA, read.DealProposal.Proposal.Provider)
+
+		stream := &fakeDealStream{proposal: smnetwork.Proposal{}}
+		net.delegate.HandleDealStream(stream)
+		assert.True(t, stream.closed)
+	})
+
+	t.Run("releases the host with the last provider", func(t *testing.T) {
+		require.NoError(t, shared.ForMiner(minerA).StopHandlingRequests())
+		assert.False(t, net.stopped)
+		require.NoError(t, shared.ForMiner(minerB).StopHandlingRequests())
+		assert.True(t, net.stopped)
+	})
+}
+
+func TestProviderValidator(t *testing.T) {
+	tf.UnitTest(t)
+	addrs := vmaddr.NewForTestGetter()
+	validator := NewProviderValidator()
+	assert.Error(t, validator.ValidatePush("", nil, cid.Undef, nil))
+
+	noDeal := fakeValidator{err: smvalid.ErrNoDeal}
+	validator.Add(addrs(), noDeal)
+	assert.True(t, xerrors.Is(validator.ValidatePush("", nil, cid.Undef, nil), smvalid.ErrNoDeal))
+
+	// the provider keeping the deal rejects it for another reason
+	rejected := errors.New("deal not accepting data")
+	validator.Add(addrs(), fakeValidator{err: rejected})
+	assert.Equal(t, rejected, validator.ValidatePull("", nil, cid.Undef, nil))
+
+	validator.Add(addrs(), fakeValidator{})
+	assert.NoError(t, validator.ValidatePush("", nil, cid.Undef, nil))
+	assert.NoError(t, validator.ValidatePull("", nil, cid.Undef, nil))
+}
+
+type fakeMarketNetwork struct {
+	smnetwork.StorageMarketNetwork
+	delegate smnetwork.StorageReceiver
+	stopped  bool
+}
+
+func (n *fakeMarketNetwork) SetDelegate(r smnetwork.StorageReceiver) error {
+	n.delegate = r
+	return nil
+}
+
+func (n *fakeMarketNetwork) StopHandlingRequests() error {
+	n.stopped = true
+	return nil
+}
+
+type fakeReceiver struct {
+	asks  []smnetwork.StorageAskStream
+	deals []smnetwork.StorageDealStream
+}
+
+func (r *fakeReceiver) HandleAskStream(s smnetwork.StorageAskStream) {
+	r.asks = append(r.asks, s)
+}
+
+func (r *fakeReceiver) HandleDealStream(s smnetwork.StorageDealStream) {
+	r.deals = append(r.deals, s)
+}
+
+type fakeAskStream struct {
+	smnetwork.StorageAskStream
+	req    smnetwork.AskRequest
+	closed bool
+}
+
+func (s *fakeAskStream) ReadAskRequest() (smnetwork.AskRequest, error) {
+	return s.req, nil
+}
+
+func (s *fakeAskStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+type fakeDealStream struct {
+	smnetwork.StorageDealStream
+	proposal smnetwork.Proposal
+	closed   bool
+}
+
+func (s *fakeDealStream) ReadDealProposal() (smnetwork.Proposal, error) {
+	return s.proposal, nil
+}
+
+func (s *fakeDealStream) RemotePeer() peer.ID {
+	return ""
+}
+
+func (s *fakeDealStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+type fakeValidator struct {
+	err error
+}
+
+func (v fakeValidator) ValidatePush(peer.ID, datatransfer.Voucher, cid.Cid, ipld.Node) error {
+	return v.err
+}
+
+func (v fakeValidator) ValidatePull(peer.ID, datatransfer.Voucher, cid.Cid, ipld.Node) error {
+	return v.err
+}
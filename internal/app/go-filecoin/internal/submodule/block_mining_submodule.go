@@ -4,6 +4,11 @@ import (
 	"context"
 	"sync"
 
+	"github.com/filecoin-project/go-address"
+	ds "github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/journal"
 	"github.com/sbwtw/go-filecoin/internal/pkg/mining"
 	"github.com/sbwtw/go-filecoin/internal/pkg/postgenerator"
 	mining_protocol "github.com/sbwtw/go-filecoin/internal/pkg/protocol/mining"
@@ -25,14 +30,35 @@ type BlockMiningSubmodule struct {
 	}
 	MiningDoneWg *sync.WaitGroup
 
+	// MinerWorkers mine for the miner actors of the node besides the one
+	// MiningWorker mines for, by address.
+	MinerWorkers map[address.Address]*mining.DefaultWorker
+
+	// History records the reports of the epochs the workers mine in.
+	History *mining.History
+	// Journal records the tipsets the scheduler selects to mine on.
+	Journal journal.Writer
+
 	// Inject non-default post generator here or leave nil for default
 	PoStGenerator postgenerator.PoStGenerator
 }
 
 type newBlockFunc func(context.Context, mining.Output)
 
+type blockMiningConfig interface {
+	Journal() journal.Journal
+}
+
+type blockMiningRepo interface {
+	Datastore() ds.Batching
+}
+
 // NewBlockMiningSubmodule creates a new block mining submodule.
-func NewBlockMiningSubmodule(ctx context.Context, gen postgenerator.PoStGenerator) (BlockMiningSubmodule, error) {
+func NewBlockMiningSubmodule(ctx context.Context, config blockMiningConfig, repo blockMiningRepo, chain *ChainSubmodule, gen postgenerator.PoStGenerator) (BlockMiningSubmodule, error) {
+	history, err := mining.NewHistory(repo.Datastore(), chain.ChainReader)
+	if err != nil {
+		return BlockMiningSubmodule{}, errors.Wrap(err, "failed to load mining history")
+	}
 	return BlockMiningSubmodule{
 		// BlockMiningAPI:     nil,
 		// AddNewlyMinedBlock: nil,
@@ -43,5 +69,7 @@ func NewBlockMiningSubmodule(ctx context.Context, gen postgenerator.PoStGenerato
 		// miningDoneWg: nil,
 		// MessageSub:   nil,
 		PoStGenerator: gen,
+		History:       history,
+		Journal:       config.Journal().Topic("mining"),
 	}, nil
 }
@@ -46,7 +46,9 @@ func NewMessagingSubmodule(ctx context.Context, config messagingConfig, repo mes
 
 	// setup messaging topic.
 	// register block validation on pubsub
-	mtv := msgsub.NewMessageTopicValidator(msgSyntaxValidator, msgSignatureValidator)
+	mpoolCfg := repo.Config().Mpool
+	mtv := msgsub.NewMessageTopicValidator(msgSyntaxValidator, msgSignatureValidator, consensus.NewMessagePenaltyChecker(chain.State),
+		network.PeerScores, mpoolCfg.MaxNonceGap, mpoolCfg.MinGossipGasPrice)
 	if err := network.pubsub.RegisterTopicValidator(mtv.Topic(network.NetworkName), mtv.Validator(), mtv.Opts()...); err != nil {
 		return MessagingSubmodule{}, errors.Wrap(err, "failed to register message validator")
 	}
@@ -30,9 +30,11 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
 	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/discovery"
 	"github.com/sbwtw/go-filecoin/internal/pkg/net"
+	"github.com/sbwtw/go-filecoin/internal/pkg/net/pubsub"
 	appstate "github.com/sbwtw/go-filecoin/internal/pkg/state"
 )
 
@@ -47,6 +49,9 @@ type NetworkSubmodule struct {
 
 	pubsub *libp2pps.PubSub
 
+	// PeerScores penalizes peers relaying invalid pubsub messages.
+	PeerScores *pubsub.PeerScores
+
 	// TODO: split chain bitswap from storage bitswap (issue: ???)
 	Bitswap exchange.Interface
 
@@ -55,6 +60,15 @@ type NetworkSubmodule struct {
 	GraphExchange graphsync.GraphExchange
 }
 
+// pubsubBlacklistThreshold is the number of invalid pubsub messages a peer may
+// relay before it is blacklisted. A peer is forgiven one message per
+// pubsubScoreDecay, and blacklisted for pubsubBlacklistTTL.
+const (
+	pubsubBlacklistThreshold = 20
+	pubsubScoreDecay         = time.Minute
+	pubsubBlacklistTTL       = time.Hour
+)
+
 type blankValidator struct{}
 
 func (blankValidator) Validate(_ string, _ []byte) error        { return nil }
@@ -121,7 +135,8 @@ func NewNetworkSubmodule(ctx context.Context, config networkConfig, repo network
 	// to enable publishing on first connection.  The default of one
 	// second is not acceptable for tests.
 	libp2pps.GossipSubHeartbeatInterval = 100 * time.Millisecond
-	gsub, err := libp2pps.NewGossipSub(ctx, peerHost, libp2pps.WithMessageSigning(pubsubMessageSigning), libp2pps.WithDiscovery(&discovery.NoopDiscovery{}))
+	blacklist := pubsub.NewTimedBlacklist(pubsubBlacklistTTL, clock.NewSystemClock())
+	gsub, err := libp2pps.NewGossipSub(ctx, peerHost, libp2pps.WithMessageSigning(pubsubMessageSigning), libp2pps.WithDiscovery(&discovery.NoopDiscovery{}), libp2pps.WithBlacklist(blacklist))
 	if err != nil {
 		return NetworkSubmodule{}, errors.Wrap(err, "failed to set up network")
 	}
@@ -148,6 +163,7 @@ func NewNetworkSubmodule(ctx context.Context, config networkConfig, repo network
 		Host:          peerHost,
 		Router:        router,
 		pubsub:        gsub,
+		PeerScores:    pubsub.NewPeerScores(pubsubBlacklistThreshold, pubsubScoreDecay, clock.NewSystemClock(), gsub.BlacklistPeer),
 		Bitswap:       bswap,
 		GraphExchange: gsync,
 		Network:       network,
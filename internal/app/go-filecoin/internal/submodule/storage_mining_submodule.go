@@ -16,18 +16,26 @@ import (
 	fsmchain "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_chain"
 	fsmeventsconnector "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_events"
 	fsmnodeconnector "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_node"
-	fsmstorage "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_storage"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/sectors"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsampler"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/piecemanager"
+	"github.com/sbwtw/go-filecoin/internal/pkg/pledger"
 	"github.com/sbwtw/go-filecoin/internal/pkg/poster"
 	"github.com/sbwtw/go-filecoin/internal/pkg/postgenerator"
-	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
+	"github.com/sbwtw/go-filecoin/internal/pkg/sectorstatus"
 	appstate "github.com/sbwtw/go-filecoin/internal/pkg/state"
 )
 
+// SectorStorage is the local storage of a miner's sectors.
+type SectorStorage interface {
+	stores.LocalStorage
+	// FreeSpace returns the bytes free for sectors across the storage paths.
+	FreeSpace() (uint64, error)
+}
+
 // StorageMiningSubmodule enhances the `Node` with storage mining capabilities.
 type StorageMiningSubmodule struct {
 	started   bool
@@ -39,9 +47,18 @@ type StorageMiningSubmodule struct {
 	// PoStGenerator generates election PoSts
 	PoStGenerator postgenerator.PoStGenerator
 
-	hs     *chainsampler.HeightThresholdScheduler
-	fsm    *fsm.Sealing
-	poster *poster.Poster
+	// Poster submits window PoSts and declares faults and recoveries
+	Poster *poster.Poster
+
+	// Sectors reports the miner's sectors and moves stuck ones along
+	Sectors *sectorstatus.Inspector
+
+	// Pledger pledges sectors following the pledge policy
+	Pledger *pledger.Pledger
+
+	hs  *chainsampler.HeightThresholdScheduler
+	fsm *fsm.Sealing
+	mgr *sectorstorage.Manager
 }
 
 // NewStorageMiningSubmodule creates a new storage mining submodule.
@@ -53,7 +70,9 @@ func NewStorageMiningSubmodule(
 	mw *msg.Waiter,
 	stateViewer *appstate.Viewer,
 	sealProofType abi.RegisteredProof,
-	r repo.Repo,
+	localStorage SectorStorage,
+	miningConfig func() *config.MiningConfig,
+	gasEstimator poster.GasEstimator,
 	postGeneratorOverride postgenerator.PoStGenerator,
 ) (*StorageMiningSubmodule, error) {
 	chainThresholdScheduler := chainsampler.NewHeightThresholdScheduler(c.ChainReader)
@@ -68,7 +87,7 @@ func NewStorageMiningSubmodule(
 
 	scg := sectorstorage.SealerConfig{AllowPreCommit1: true, AllowPreCommit2: true, AllowCommit: true}
 
-	mgr, err := sectorstorage.New(context.TODO(), fsmstorage.NewRepoStorageConnector(r), sdx, &fcg, scg, []string{}, nil)
+	mgr, err := sectorstorage.New(context.TODO(), localStorage, sdx, &fcg, scg, []string{}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -93,7 +112,10 @@ func NewStorageMiningSubmodule(
 		PieceManager: &bke,
 		hs:           chainThresholdScheduler,
 		fsm:          fsm,
-		poster:       poster.NewPoster(minerAddr, m.Outbox, mgr, c.State, stateViewer, mw),
+		mgr:          mgr,
+		Poster:       poster.NewPoster(minerAddr, m.Outbox, mgr, poster.NewIndexSectorChecker(sdx), c.State, stateViewer, mw, gasEstimator, ds),
+		Sectors:      sectorstatus.NewInspector(minerAddr, fsm, c.State, stateViewer),
+		Pledger:      pledger.NewPledger(minerAddr, fsm, miningConfig, localStorage.FreeSpace, c.State, stateViewer),
 	}
 
 	// allow the caller to provide a thing which generates fake PoSts
@@ -138,11 +160,17 @@ func (s *StorageMiningSubmodule) Stop(ctx context.Context) error {
 		return err
 	}
 
-	s.poster.StopPoSting()
+	s.Poster.StopPoSting()
 	s.started = false
 	return nil
 }
 
+// AttachStorage opens an initialized storage path for the sector manager to
+// seal and store sectors in, persisting it with the local storage.
+func (s *StorageMiningSubmodule) AttachStorage(ctx context.Context, path string) error {
+	return s.mgr.AddLocalStorage(ctx, path)
+}
+
 // HandleNewHead submits a new chain head for possible fallback PoSt.
 func (s *StorageMiningSubmodule) HandleNewHead(ctx context.Context, newHead block.TipSet) error {
 	s.startedLk.RLock()
@@ -157,7 +185,12 @@ func (s *StorageMiningSubmodule) HandleNewHead(ctx context.Context, newHead bloc
 		return err
 	}
 
-	return s.poster.HandleNewHead(ctx, newHead)
+	err = s.Poster.HandleNewHead(ctx, newHead)
+	if err != nil {
+		return err
+	}
+
+	return s.Pledger.HandleNewHead(ctx, newHead)
 }
 
 func getMinerProvingPeriod(c *ChainSubmodule, minerAddr address.Address, viewer *appstate.Viewer) (abi.ChainEpoch, error) {
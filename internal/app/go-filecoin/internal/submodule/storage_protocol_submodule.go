@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/go-storedcounter"
 
 	"github.com/filecoin-project/go-address"
+	datatransfer "github.com/filecoin-project/go-data-transfer"
 	graphsyncimpl "github.com/filecoin-project/go-data-transfer/impl/graphsync"
 	"github.com/filecoin-project/go-fil-markets/filestore"
 	"github.com/filecoin-project/go-fil-markets/piecestore"
@@ -36,9 +37,19 @@ import (
 // StorageProtocolSubmodule enhances the node with storage protocol
 // capabilities.
 type StorageProtocolSubmodule struct {
-	StorageClient   iface.StorageClient
+	StorageClient iface.StorageClient
+	// StorageProvider is the storage provider of the node's primary miner.
 	StorageProvider iface.StorageProvider
-	pieceManager    piecemanager.PieceManager
+	providerAddr    address.Address
+	// MinerProviders are the storage providers of the further miners of the
+	// node.
+	MinerProviders map[address.Address]iface.StorageProvider
+	pieceManager   piecemanager.PieceManager
+
+	// the providers share the market network and data transfer of the host
+	providerNetwork   *storagemarketconnector.ProviderNetwork
+	providerTransfer  datatransfer.Manager
+	providerValidator *storagemarketconnector.ProviderValidator
 }
 
 // NewStorageProtocolSubmodule creates a new storage protocol submodule.
@@ -73,6 +84,8 @@ func NewStorageProtocolSubmodule(
 	}, nil
 }
 
+// AddStorageProvider adds the storage provider of the node's primary miner,
+// keeping its deals and ask in the datastore.
 func (sm *StorageProtocolSubmodule) AddStorageProvider(
 	ctx context.Context,
 	minerAddr address.Address,
@@ -89,35 +102,94 @@ func (sm *StorageProtocolSubmodule) AddStorageProvider(
 	sealProofType abi.RegisteredProof,
 	stateViewer *appstate.Viewer,
 ) error {
+	provider, err := sm.newProvider(minerAddr, c, m, mw, pm, s, h, ds, bs, gsync, repoPath, sealProofType, stateViewer)
+	if err != nil {
+		return err
+	}
 	sm.pieceManager = pm
+	sm.StorageProvider = provider
+	sm.providerAddr = minerAddr
+	return nil
+}
 
+// AddMinerStorageProvider adds the storage provider of a further miner of the
+// node, with its own ask and deals kept in its datastore and sealing the deals
+// into its own sectors.
+func (sm *StorageProtocolSubmodule) AddMinerStorageProvider(
+	minerAddr address.Address,
+	c *ChainSubmodule,
+	m *MessagingSubmodule,
+	mw *msg.Waiter,
+	pm piecemanager.PieceManager,
+	s types.Signer,
+	h host.Host,
+	ds datastore.Batching,
+	bs blockstore.Blockstore,
+	gsync graphsync.GraphExchange,
+	repoPath string,
+	sealProofType abi.RegisteredProof,
+	stateViewer *appstate.Viewer,
+) error {
+	provider, err := sm.newProvider(minerAddr, c, m, mw, pm, s, h, ds, bs, gsync, repoPath, sealProofType, stateViewer)
+	if err != nil {
+		return err
+	}
+	if sm.MinerProviders == nil {
+		sm.MinerProviders = make(map[address.Address]iface.StorageProvider)
+	}
+	sm.MinerProviders[minerAddr] = provider
+	return nil
+}
+
+func (sm *StorageProtocolSubmodule) newProvider(
+	minerAddr address.Address,
+	c *ChainSubmodule,
+	m *MessagingSubmodule,
+	mw *msg.Waiter,
+	pm piecemanager.PieceManager,
+	s types.Signer,
+	h host.Host,
+	ds datastore.Batching,
+	bs blockstore.Blockstore,
+	gsync graphsync.GraphExchange,
+	repoPath string,
+	sealProofType abi.RegisteredProof,
+	stateViewer *appstate.Viewer,
+) (iface.StorageProvider, error) {
 	pnode := storagemarketconnector.NewStorageProviderNodeConnector(minerAddr, c.State, m.Outbox, mw, pm, s, stateViewer)
 
 	pieceStagingPath, err := paths.PieceStagingDir(repoPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// ensure pieces directory exists
 	err = os.MkdirAll(pieceStagingPath, 0700)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	fs, err := filestore.NewLocalFileStore(filestore.OsPath(pieceStagingPath))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	dtStoredCounter := storedcounter.New(ds, datastore.NewKey("datatransfer/provider/counter"))
-	dt := graphsyncimpl.NewGraphSyncDataTransfer(h, gsync, dtStoredCounter)
-	err = dt.RegisterVoucherType(reflect.TypeOf(&smvalid.StorageDataTransferVoucher{}), smvalid.NewProviderRequestValidator(statestore.New(ds)))
-	if err != nil {
-		return err
+	if sm.providerTransfer == nil {
+		// the counter is kept with the datastore of the first provider, the
+		// primary miner's
+		dtStoredCounter := storedcounter.New(ds, datastore.NewKey("datatransfer/provider/counter"))
+		dt := graphsyncimpl.NewGraphSyncDataTransfer(h, gsync, dtStoredCounter)
+		validator := storagemarketconnector.NewProviderValidator()
+		if err := dt.RegisterVoucherType(reflect.TypeOf(&smvalid.StorageDataTransferVoucher{}), validator); err != nil {
+			return nil, err
+		}
+		sm.providerTransfer = dt
+		sm.providerValidator = validator
+		sm.providerNetwork = storagemarketconnector.NewProviderNetwork(smnetwork.NewFromLibp2pHost(h))
 	}
+	sm.providerValidator.Add(minerAddr, smvalid.NewProviderRequestValidator(statestore.New(ds)))
 
-	sm.StorageProvider, err = impl.NewProvider(smnetwork.NewFromLibp2pHost(h), ds, bs, fs, piecestore.NewPieceStore(ds), dt, pnode, minerAddr, sealProofType)
-	return err
+	return impl.NewProvider(sm.providerNetwork.ForMiner(minerAddr), ds, bs, fs, piecestore.NewPieceStore(ds), sm.providerTransfer, pnode, minerAddr, sealProofType)
 }
 
 func (sm *StorageProtocolSubmodule) Provider() (iface.StorageProvider, error) {
@@ -127,6 +199,22 @@ func (sm *StorageProtocolSubmodule) Provider() (iface.StorageProvider, error) {
 	return sm.StorageProvider, nil
 }
 
+// MinerProvider returns the storage provider of a miner of the node, the
+// primary miner's if the address is undefined.
+func (sm *StorageProtocolSubmodule) MinerProvider(minerAddr address.Address) (iface.StorageProvider, error) {
+	primary, err := sm.Provider()
+	if err != nil {
+		return nil, err
+	}
+	if minerAddr == address.Undef || minerAddr == sm.providerAddr {
+		return primary, nil
+	}
+	if provider, ok := sm.MinerProviders[minerAddr]; ok {
+		return provider, nil
+	}
+	return nil, errors.Errorf("miner %s has no storage provider on the node", minerAddr)
+}
+
 func (sm *StorageProtocolSubmodule) Client() iface.StorageClient {
 	return sm.StorageClient
 }
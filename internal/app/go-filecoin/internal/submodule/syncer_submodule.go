@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-graphsync"
@@ -13,8 +14,10 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/exchange"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/fetcher"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/drand"
 	"github.com/sbwtw/go-filecoin/internal/pkg/net/blocksub"
@@ -33,12 +36,19 @@ type SyncerSubmodule struct {
 	ChainSyncManager *chainsync.Manager
 	Drand            drand.IFace
 
+	// ExchangeServer serves the chain to peers over the chain exchange protocol.
+	ExchangeServer *exchange.Server
+
 	// cancelChainSync cancels the context for chain sync subscriptions and handlers.
 	CancelChainSync context.CancelFunc
 	// faultCh receives detected consensus faults
 	faultCh chan slashing.ConsensusFault
 }
 
+// blockGossipValidationBudget bounds the time spent validating a gossiped
+// block header before relaying it.
+const blockGossipValidationBudget = 2 * time.Second
+
 type syncerConfig interface {
 	GenesisCid() cid.Cid
 	BlockTime() time.Duration
@@ -52,24 +62,12 @@ type nodeChainSelector interface {
 }
 
 // NewSyncerSubmodule creates a new chain submodule.
-func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *BlockstoreSubmodule, network *NetworkSubmodule,
+func NewSyncerSubmodule(ctx context.Context, config syncerConfig, repo chainRepo, syncCfg *config.SyncConfig, blockstore *BlockstoreSubmodule, network *NetworkSubmodule,
 	discovery *DiscoverySubmodule, chn *ChainSubmodule, postVerifier consensus.EPoStVerifier) (SyncerSubmodule, error) {
 	// setup block validation
 	// TODO when #2961 is resolved do the needful here.
 	blkValid := consensus.NewDefaultBlockValidator(config.ChainClock())
 
-	// register block validation on pubsub
-	btv := blocksub.NewBlockTopicValidator(blkValid)
-	if err := network.pubsub.RegisterTopicValidator(btv.Topic(network.NetworkName), btv.Validator(), btv.Opts()...); err != nil {
-		return SyncerSubmodule{}, errors.Wrap(err, "failed to register block validator")
-	}
-
-	// setup topic.
-	topic, err := network.pubsub.Join(blocksub.Topic(network.NetworkName))
-	if err != nil {
-		return SyncerSubmodule{}, err
-	}
-
 	genBlk, err := chn.ChainReader.GetGenesisBlock(ctx)
 	if err != nil {
 		return SyncerSubmodule{}, errors.Wrap(err, "failed to locate genesis block during node build")
@@ -87,6 +85,18 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 		config.BlockTime(), elections, tickets, postVerifier, chn.ChainReader, config.ChainClock(), d)
 	nodeChainSelector := consensus.NewChainSelector(blockstore.CborStore, &stateViewer, config.GenesisCid())
 
+	// register block validation on pubsub
+	btv := blocksub.NewBlockTopicValidator(blkValid, nodeConsensus, network.PeerScores, blockGossipValidationBudget)
+	if err := network.pubsub.RegisterTopicValidator(btv.Topic(network.NetworkName), btv.Validator(), btv.Opts()...); err != nil {
+		return SyncerSubmodule{}, errors.Wrap(err, "failed to register block validator")
+	}
+
+	// setup topic.
+	topic, err := network.pubsub.Join(blocksub.Topic(network.NetworkName))
+	if err != nil {
+		return SyncerSubmodule{}, err
+	}
+
 	// setup fecher
 	network.GraphExchange.RegisterIncomingRequestHook(func(p peer.ID, requestData graphsync.RequestData, hookActions graphsync.IncomingRequestHookActions) {
 		_, has := requestData.Extension(fetcher.ChainsyncProtocolExtension)
@@ -96,11 +106,25 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 			hookActions.ValidateRequest()
 		}
 	})
-	fetcher := fetcher.NewGraphSyncFetcher(ctx, network.GraphExchange, blockstore.Blockstore, blkValid, config.ChainClock(), discovery.PeerTracker)
+	gsFetcher := fetcher.NewGraphSyncFetcher(ctx, network.GraphExchange, blockstore.Blockstore, blkValid, config.ChainClock(), discovery.PeerTracker)
+
+	// serve the chain exchange protocol, and fetch with it
+	exchangeServer := exchange.NewServer(chn.ChainReader, chn.MessageStore, exchange.Limits{
+		MaxRequestLength:      syncCfg.ExchangeMaxRequestLength,
+		MaxConcurrentRequests: syncCfg.ExchangeMaxConcurrentRequests,
+	})
+	exchangeServer.Register(network.Host)
+	exchangeFetcher := exchange.NewFetcher(network.Host, blockstore.Blockstore, chn.MessageStore, blkValid, discovery.PeerTracker, exchangeServer)
+	fetcher := selectFetcher(syncCfg, gsFetcher, exchangeFetcher)
 	faultCh := make(chan slashing.ConsensusFault)
 	faultDetector := slashing.NewConsensusFaultDetector(faultCh)
 
-	chainSyncManager, err := chainsync.NewManager(nodeConsensus, blkValid, nodeChainSelector, chn.ChainReader, chn.MessageStore, fetcher, config.ChainClock(), faultDetector)
+	badTipSetExpiry, err := time.ParseDuration(syncCfg.BadTipSetExpiry)
+	if err != nil {
+		return SyncerSubmodule{}, errors.Wrap(err, "invalid sync.badTipSetExpiry")
+	}
+	chainSyncManager, err := chainsync.NewManager(nodeConsensus, blkValid, nodeChainSelector, chn.ChainReader, chn.MessageStore, fetcher, config.ChainClock(), faultDetector, discovery.PeerTracker,
+		abi.ChainEpoch(syncCfg.MaxReorgDepth), repo.ChainDatastore(), badTipSetExpiry)
 	if err != nil {
 		return SyncerSubmodule{}, err
 	}
@@ -112,11 +136,31 @@ func NewSyncerSubmodule(ctx context.Context, config syncerConfig, blockstore *Bl
 		ChainSelector:    nodeChainSelector,
 		ChainSyncManager: &chainSyncManager,
 		Drand:            d,
+		ExchangeServer:   exchangeServer,
 		// cancelChainSync: nil,
 		faultCh: faultCh,
 	}, nil
 }
 
+// chainFetcher fetches chains from the network.
+type chainFetcher interface {
+	FetchTipSets(context.Context, block.TipSetKey, peer.ID, func(block.TipSet) (bool, error)) ([]block.TipSet, error)
+	FetchTipSetHeaders(context.Context, block.TipSetKey, peer.ID, func(block.TipSet) (bool, error)) ([]block.TipSet, error)
+}
+
+// selectFetcher returns the fetcher for the configured protocol, falling back
+// to the other protocol if configured to.
+func selectFetcher(syncCfg *config.SyncConfig, gsFetcher, exchangeFetcher chainFetcher) chainFetcher {
+	primary, secondary := gsFetcher, exchangeFetcher
+	if syncCfg.Fetcher == config.SyncFetcherExchange {
+		primary, secondary = exchangeFetcher, gsFetcher
+	}
+	if !syncCfg.Fallback {
+		return primary
+	}
+	return fetcher.NewFallbackFetcher(primary, secondary)
+}
+
 type syncerNode interface {
 }
 
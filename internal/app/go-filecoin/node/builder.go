@@ -40,6 +40,7 @@ type Builder struct {
 	journal     journal.Journal
 	isRelay     bool
 	chainClock  clock.ChainEpochClock
+	mockClock   bool
 	genCid      cid.Cid
 	drand       drand.IFace
 }
@@ -108,6 +109,15 @@ func ChainClockConfigOption(clk clock.ChainEpochClock) BuilderOpt {
 	}
 }
 
+// MockClockOption makes the node's chain clock a fake which starts at the genesis
+// time and only moves forward when advanced through the API.
+func MockClockOption() BuilderOpt {
+	return func(c *Builder) error {
+		c.mockClock = true
+		return nil
+	}
+}
+
 // DrandConfigOption returns a function that sets the node's drand interface
 func DrandConfigOption(d drand.IFace) BuilderOpt {
 	return func(c *Builder) error {
@@ -211,20 +221,25 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		if err != nil {
 			return nil, err
 		}
-		b.drand = dGRPC
+		b.drand = drand.NewCache(dGRPC, b.repo.ChainDatastore(), b.repo.Config().Drand.Offline)
 	}
 
+	var mockClock clock.Fake
 	if b.chainClock == nil {
 		// get the genesis block time from the chainsubmodule
 		geneBlk, err := nd.chain.ChainReader.GetGenesisBlock(ctx)
 		if err != nil {
 			return nil, err
 		}
-		b.chainClock = clock.NewChainClock(geneBlk.Timestamp, b.blockTime)
+		if b.mockClock {
+			mockClock, b.chainClock = clock.NewFakeChain(geneBlk.Timestamp, b.blockTime, int64(geneBlk.Timestamp))
+		} else {
+			b.chainClock = clock.NewChainClock(geneBlk.Timestamp, b.blockTime)
+		}
 	}
 	nd.ChainClock = b.chainClock
 
-	nd.syncer, err = submodule.NewSyncerSubmodule(ctx, (*builder)(b), &nd.Blockstore, &nd.network, &nd.Discovery, &nd.chain, nd.ProofVerification.ProofVerifier)
+	nd.syncer, err = submodule.NewSyncerSubmodule(ctx, (*builder)(b), b.repo, b.repo.Config().Sync, &nd.Blockstore, &nd.network, &nd.Discovery, &nd.chain, nd.ProofVerification.ProofVerifier)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.Syncer")
 	}
@@ -244,21 +259,25 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, errors.Wrap(err, "failed to build node.StorageNetworking")
 	}
 
-	nd.BlockMining, err = submodule.NewBlockMiningSubmodule(ctx, b.postGen)
+	nd.BlockMining, err = submodule.NewBlockMiningSubmodule(ctx, (*builder)(b), b.repo, &nd.chain, b.postGen)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to build node.BlockMining")
 	}
 
 	waiter := msg.NewWaiter(nd.chain.ChainReader, nd.chain.MessageStore, nd.Blockstore.Blockstore, nd.Blockstore.CborStore)
+	gasEstimator := msg.NewGasEstimator(nd.chain.ChainReader, nd.chain.State, nd.chain.MessageStore, nd.Blockstore.Blockstore,
+		nd.chain.Processor, nd.Messaging.MsgPool, nd.Messaging.Outbox.Queue(), b.repo)
 
 	nd.PorcelainAPI = porcelain.New(plumbing.New(&plumbing.APIDeps{
 		Chain:        nd.chain.State,
+		Clock:        nd.ChainClock,
+		MockClock:    mockClock,
 		Sync:         cst.NewChainSyncProvider(nd.syncer.ChainSyncManager),
 		Config:       cfg.NewConfig(b.repo),
 		DAG:          dag.NewDAG(merkledag.NewDAGService(nd.Blockservice.Blockservice)),
 		Expected:     nd.syncer.Consensus,
+		GasEstimator: gasEstimator,
 		MsgPool:      nd.Messaging.MsgPool,
-		MsgPreviewer: msg.NewPreviewer(nd.chain.ChainReader, nd.Blockstore.CborStore, nd.Blockstore.Blockstore, nd.chain.Processor),
 		MsgWaiter:    waiter,
 		Network:      nd.network.Network,
 		Outbox:       nd.Messaging.Outbox,
@@ -283,7 +302,7 @@ func (b *Builder) build(ctx context.Context) (*Node, error) {
 		return nil, err
 	}
 
-	nd.StorageAPI = storage.NewAPI(nd.StorageProtocol)
+	nd.StorageAPI = storage.NewAPI(nd.StorageProtocol, nd.Poster, nd.Sectors, nd.Pledger, nd)
 	nd.DrandAPI = drandapi.New(b.drand, nd.PorcelainAPI)
 
 	return nd, nil
@@ -336,6 +355,10 @@ func (b builder) Drand() drand.IFace {
 
 func DefaultDrandIfaceFromConfig(cfg *config.Config, fcGenTS uint64) (drand.IFace, error) {
 	drandConfig := cfg.Drand
+	if drandConfig.Local {
+		return drand.NewLocal(drandConfig.LocalSecret, drandConfig.DistKey, time.Unix(drandConfig.StartTimeUnix, 0),
+			time.Unix(int64(fcGenTS), 0), time.Duration(drandConfig.RoundSeconds)*time.Second)
+	}
 	addrs := make([]drand.Address, len(drandConfig.Addresses))
 	for i, a := range drandConfig.Addresses {
 		addrs[i] = drand.NewAddress(a, drandConfig.Secure)
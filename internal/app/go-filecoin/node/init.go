@@ -2,17 +2,12 @@ package node
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 
 	"github.com/filecoin-project/go-address"
-	"github.com/filecoin-project/sector-storage/stores"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	fsm "github.com/filecoin-project/storage-fsm"
-	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
@@ -20,6 +15,7 @@ import (
 	acrypto "github.com/libp2p/go-libp2p-core/crypto"
 	"github.com/pkg/errors"
 
+	fsmstorage "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_storage"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/sectors"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/paths"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
@@ -188,6 +184,22 @@ func InitSectors(ctx context.Context, rep repo.Repo, genesisBlock *block.Block)
 	return nil
 }
 
+// InitMinerSectors creates the sector directory of a further miner actor of
+// the node, under the sector root.
+func InitMinerSectors(rep repo.Repo, minerAddr address.Address) error {
+	rpt, err := rep.Path()
+	if err != nil {
+		return err
+	}
+
+	spt, err := paths.GetSectorPath(rep.Config().SectorBase.RootDirPath, rpt)
+	if err != nil {
+		return err
+	}
+
+	return ensureSectorDirAndMetadata(false, filepath.Join(spt, minerAddr.String()))
+}
+
 // Save the provided slice of sector metadata (corresponding to pre-sealed
 // sectors) to the keyspace used by the finite-state machine.
 func persistGenesisFSMState(rep repo.Repo, info []fsm.SectorInfo) error {
@@ -243,38 +255,10 @@ func importPreSealedSectorMetadata(ctx context.Context, rep repo.Repo, genesisBl
 }
 
 func ensureSectorDirAndMetadata(containsPreSealedSectors bool, dirPath string) error {
-	_, err := os.Stat(filepath.Join(dirPath, stores.MetaFile))
-	if os.IsNotExist(err) {
-		// TODO: Set the appropriate permissions.
-		_ = os.MkdirAll(dirPath, 0777)
-
-		dirMeta := stores.LocalStorageMeta{
-			ID:       stores.ID(uuid.New().String()),
-			Weight:   10,
-			CanSeal:  true,
-			CanStore: true,
-		}
-
-		if containsPreSealedSectors {
-			dirMeta.CanSeal = false
-			dirMeta.CanStore = false
-			dirMeta.Weight = 0
-		}
-
-		b, err := json.MarshalIndent(&dirMeta, "", "  ")
-		if err != nil {
-			return err
-		}
-
-		// TODO: Set the appropriate permissions.
-		if err := ioutil.WriteFile(filepath.Join(dirPath, stores.MetaFile), b, 0777); err != nil {
-			return err
-		}
-	} else if err != nil {
-		return err
+	if containsPreSealedSectors {
+		return fsmstorage.InitStoragePath(dirPath, 0, false, false)
 	}
-
-	return nil
+	return fsmstorage.InitStoragePath(dirPath, 10, true, true)
 }
 
 // Produce a slice of fsm.SectorInfo (used to seed the storage finite-state
@@ -4,17 +4,24 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
+	"time"
 
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	bserv "github.com/ipfs/go-blockservice"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/namespace"
 	logging "github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/mitchellh/go-homedir"
 	"github.com/pkg/errors"
 
+	fsmstorage "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_storage"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/internal/submodule"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/paymentchannel"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/msg"
@@ -30,10 +37,14 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/pkg/mining"
 	"github.com/sbwtw/go-filecoin/internal/pkg/net/pubsub"
 	"github.com/sbwtw/go-filecoin/internal/pkg/piecemanager"
+	"github.com/sbwtw/go-filecoin/internal/pkg/pledger"
+	"github.com/sbwtw/go-filecoin/internal/pkg/poster"
+	"github.com/sbwtw/go-filecoin/internal/pkg/postgenerator"
 	"github.com/sbwtw/go-filecoin/internal/pkg/protocol/drand"
 	mining_protocol "github.com/sbwtw/go-filecoin/internal/pkg/protocol/mining"
 	"github.com/sbwtw/go-filecoin/internal/pkg/protocol/storage"
 	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
+	"github.com/sbwtw/go-filecoin/internal/pkg/sectorstatus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/state"
 	"github.com/sbwtw/go-filecoin/internal/pkg/version"
 )
@@ -45,6 +56,14 @@ var (
 	ErrNoMinerAddress = errors.New("no miner addresses configured")
 )
 
+// minerDatastorePrefix is the datastore namespace under which the further
+// miner actors of the node keep their sealing and PoSt state.
+var minerDatastorePrefix = datastore.NewKey("/miners")
+
+// minerMarketPrefix is the namespace of the datastore of a further miner
+// under which its storage provider keeps its ask and deals.
+var minerMarketPrefix = datastore.NewKey("/market")
+
 // Node represents a full Filecoin node.
 type Node struct {
 	// OfflineMode, when true, disables libp2p.
@@ -79,6 +98,9 @@ type Node struct {
 	syncer        submodule.SyncerSubmodule
 	BlockMining   submodule.BlockMiningSubmodule
 	StorageMining *submodule.StorageMiningSubmodule
+	// MinerStorageMining are the storage mining submodules of the miner
+	// actors the node operates besides its primary one, by address.
+	MinerStorageMining map[address.Address]*submodule.StorageMiningSubmodule
 
 	//
 	// Supporting services
@@ -282,6 +304,16 @@ func (node *Node) handleNewChainHeads(ctx context.Context, firstHead block.TipSe
 					log.Error(err)
 				}
 			}
+			for minerAddr, sm := range node.MinerStorageMining {
+				if err := sm.HandleNewHead(ctx, newHead); err != nil {
+					log.Errorf("storage mining of miner %s handling new head: %s", minerAddr, err)
+				}
+			}
+			if node.BlockMining.History != nil {
+				if err := node.BlockMining.History.HandleNewHead(ctx, newHead); err != nil {
+					log.Errorf("mining history handling new head: %s", err)
+				}
+			}
 
 			log.Debugf("message pool handling new head")
 			if err := handler.HandleNewHead(ctx, newHead); err != nil {
@@ -322,6 +354,12 @@ func (node *Node) Stop(ctx context.Context) {
 		}
 		node.StorageMining = nil
 	}
+	for minerAddr, sm := range node.MinerStorageMining {
+		if err := sm.Stop(ctx); err != nil {
+			fmt.Printf("error stopping storage miner %s: %s\n", minerAddr, err)
+		}
+	}
+	node.MinerStorageMining = nil
 
 	if err := node.Host().Close(); err != nil {
 		fmt.Printf("error closing host: %s\n", err)
@@ -364,6 +402,16 @@ func (node *Node) MiningAddress() (address.Address, error) {
 	return addr, nil
 }
 
+// MinerAddresses returns the addresses of the further miner actors the node
+// operates besides the one at MiningAddress.
+func (node *Node) MinerAddresses() []address.Address {
+	var addrs []address.Address
+	for _, m := range node.Repo.Config().Mining.Miners {
+		addrs = append(addrs, m.Address)
+	}
+	return addrs
+}
+
 // SetupMining initializes all the functionality the node needs to start mining.
 // This method is idempotent.
 func (node *Node) SetupMining(ctx context.Context) error {
@@ -400,14 +448,37 @@ func (node *Node) SetupMining(ctx context.Context) error {
 			return err
 		}
 	}
+	if node.BlockMining.MinerWorkers == nil {
+		node.BlockMining.MinerWorkers = make(map[address.Address]*mining.DefaultWorker)
+	}
+	for minerAddr, sm := range node.MinerStorageMining {
+		if _, ok := node.BlockMining.MinerWorkers[minerAddr]; ok {
+			continue
+		}
+		worker, err := node.createMiningWorker(ctx, minerAddr, sm.PoStGenerator)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create mining worker of miner %s", minerAddr)
+		}
+		node.BlockMining.MinerWorkers[minerAddr] = worker
+	}
 
 	if err := node.StorageMining.Start(ctx); err != nil {
 		fmt.Printf("error starting storage miner: %s\n", err)
 	}
+	for minerAddr, sm := range node.MinerStorageMining {
+		if err := sm.Start(ctx); err != nil {
+			fmt.Printf("error starting storage miner %s: %s\n", minerAddr, err)
+		}
+	}
 
 	if err := node.StorageProtocol.StorageProvider.Start(ctx); err != nil {
 		fmt.Printf("error starting storage provider: %s\n", err)
 	}
+	for minerAddr, provider := range node.StorageProtocol.MinerProviders {
+		if err := provider.Start(ctx); err != nil {
+			fmt.Printf("error starting storage provider %s: %s\n", minerAddr, err)
+		}
+	}
 
 	return nil
 }
@@ -442,12 +513,12 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 	// TODO: rework these modules so they can be at least partially constructed during the building phase #3738
 	stateViewer := state.NewViewer(cborStore)
 
-	node.StorageMining, err = submodule.NewStorageMiningSubmodule(minerAddr, node.Repo.Datastore(), &node.chain, &node.Messaging, waiter, stateViewer, sealProofType, node.Repo, node.BlockMining.PoStGenerator)
+	node.StorageMining, err = submodule.NewStorageMiningSubmodule(minerAddr, node.Repo.Datastore(), &node.chain, &node.Messaging, waiter, stateViewer, sealProofType, fsmstorage.NewRepoStorageConnector(node.Repo), node.miningConfig, node.PorcelainAPI, node.BlockMining.PoStGenerator)
 	if err != nil {
 		return err
 	}
 
-	return node.StorageProtocol.AddStorageProvider(
+	err = node.StorageProtocol.AddStorageProvider(
 		ctx,
 		minerAddr,
 		&node.chain,
@@ -463,6 +534,62 @@ func (node *Node) setupStorageMining(ctx context.Context) error {
 		sealProofType,
 		stateViewer,
 	)
+	if err != nil {
+		return err
+	}
+
+	return node.setupMinerStorageMining(ctx, waiter, stateViewer, repoPath)
+}
+
+// miningConfig returns the current mining config of the node.
+func (node *Node) miningConfig() *config.MiningConfig {
+	return node.Repo.Config().Mining
+}
+
+// setupMinerStorageMining sets up storage mining for the further miner actors
+// of the node. Each seals into its own sector directory, keeps its sealing and
+// PoSt state in its own datastore namespace and makes storage deals at its own
+// ask, sharing the market network of the host with the primary miner.
+// Retrieval deals are made by the primary miner only.
+func (node *Node) setupMinerStorageMining(ctx context.Context, waiter *msg.Waiter, stateViewer *state.Viewer, repoPath string) error {
+	node.MinerStorageMining = make(map[address.Address]*submodule.StorageMiningSubmodule)
+	head := node.Chain().ChainReader.GetHead()
+	for _, minerAddr := range node.MinerAddresses() {
+		status, err := node.PorcelainAPI.MinerGetStatus(ctx, minerAddr, head)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get status of miner %s", minerAddr)
+		}
+		if err := InitMinerSectors(node.Repo, minerAddr); err != nil {
+			return err
+		}
+
+		ds := namespace.Wrap(node.Repo.Datastore(), minerDatastorePrefix.ChildString(minerAddr.String()))
+		sm, err := submodule.NewStorageMiningSubmodule(minerAddr, ds, &node.chain, &node.Messaging, waiter, stateViewer, status.SectorConfiguration.SealProofType, fsmstorage.NewMinerStorageConnector(node.Repo, minerAddr), node.miningConfig, node.PorcelainAPI, node.BlockMining.PoStGenerator)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set up storage mining of miner %s", minerAddr)
+		}
+		node.MinerStorageMining[minerAddr] = sm
+
+		err = node.StorageProtocol.AddMinerStorageProvider(
+			minerAddr,
+			&node.chain,
+			&node.Messaging,
+			waiter,
+			sm.PieceManager,
+			node.Wallet.Signer,
+			node.Host(),
+			namespace.Wrap(ds, minerMarketPrefix),
+			node.Blockstore.Blockstore,
+			node.network.GraphExchange,
+			repoPath,
+			status.SectorConfiguration.SealProofType,
+			stateViewer,
+		)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set up storage provider of miner %s", minerAddr)
+		}
+	}
+	return nil
 }
 
 func (node *Node) setupRetrievalMining(ctx context.Context) error {
@@ -539,7 +666,15 @@ func (node *Node) StartMining(ctx context.Context) error {
 	}
 
 	if node.BlockMining.MiningScheduler == nil {
-		node.BlockMining.MiningScheduler = mining.NewScheduler(node.BlockMining.MiningWorker, node.PorcelainAPI.ChainHead, node.ChainClock)
+		var worker mining.Worker = node.BlockMining.MiningWorker
+		if len(node.BlockMining.MinerWorkers) > 0 {
+			workers := []mining.Worker{node.BlockMining.MiningWorker}
+			for _, w := range node.BlockMining.MinerWorkers {
+				workers = append(workers, w)
+			}
+			worker = mining.NewMultiWorker(workers...)
+		}
+		node.BlockMining.MiningScheduler = mining.NewScheduler(worker, node.PorcelainAPI.ChainHead, node.siblingTipSets, node.propagationDelay, node.ChainClock, node.BlockMining.Journal)
 	} else if node.BlockMining.MiningScheduler.IsStarted() {
 		return fmt.Errorf("miner scheduler already started")
 	}
@@ -582,6 +717,11 @@ func (node *Node) StopMining(ctx context.Context) {
 			log.Warn("Error stopping storage miner", err)
 		}
 	}
+	for minerAddr, sm := range node.MinerStorageMining {
+		if err := sm.Stop(ctx); err != nil {
+			log.Warnf("Error stopping storage miner %s: %s", minerAddr, err)
+		}
+	}
 }
 
 func (node *Node) handleSubscription(ctx context.Context, sub pubsub.Subscription, handler pubSubHandler) {
@@ -616,12 +756,35 @@ func (node *Node) setupProtocols() error {
 		node.StopMining,
 		node.GetMiningWorker,
 		node.ChainClock,
+		node.BlockMining.History,
 	)
 
 	node.BlockMining.BlockMiningAPI = &blockMiningAPI
 	return nil
 }
 
+// siblingTipSets returns the validated tipsets with the input parents and
+// height.
+func (node *Node) siblingTipSets(parents block.TipSetKey, h abi.ChainEpoch) ([]block.TipSet, error) {
+	if !node.chain.ChainReader.HasTipSetAndStatesWithParentsAndHeight(parents, h) {
+		return nil, nil
+	}
+	tsas, err := node.chain.ChainReader.GetTipSetAndStatesByParentsAndHeight(parents, h)
+	if err != nil {
+		return nil, err
+	}
+	siblings := make([]block.TipSet, len(tsas))
+	for i, tsa := range tsas {
+		siblings[i] = tsa.TipSet
+	}
+	return siblings, nil
+}
+
+// propagationDelay is how long into an epoch block mining waits for blocks.
+func (node *Node) propagationDelay() time.Duration {
+	return time.Duration(node.Repo.Config().Mining.PropagationDelaySeconds) * time.Second
+}
+
 // GetMiningWorker ensures mining is setup and then returns the worker
 func (node *Node) GetMiningWorker(ctx context.Context) (*mining.DefaultWorker, error) {
 	if err := node.SetupMining(ctx); err != nil {
@@ -638,6 +801,16 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		return nil, errors.Wrap(err, "failed to get mining address")
 	}
 
+	poster := node.BlockMining.PoStGenerator
+	if poster == nil {
+		poster = node.StorageMining.PoStGenerator
+	}
+	return node.createMiningWorker(ctx, minerAddr, poster)
+}
+
+// createMiningWorker creates a mining.Worker running the elections of the
+// miner actor, proving with poster.
+func (node *Node) createMiningWorker(ctx context.Context, minerAddr address.Address, poster postgenerator.PoStGenerator) (*mining.DefaultWorker, error) {
 	head := node.PorcelainAPI.ChainHeadKey()
 	view, err := node.PorcelainAPI.MinerStateView(head)
 	if err != nil {
@@ -648,10 +821,6 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		return nil, errors.Wrapf(err, "failed to read miner control addresses")
 	}
 
-	poster := node.BlockMining.PoStGenerator
-	if poster == nil {
-		poster = node.StorageMining.PoStGenerator
-	}
 	genBlk, err := node.Chain().ChainReader.GetGenesisBlock(ctx)
 	if err != nil {
 		return nil, err
@@ -679,6 +848,7 @@ func (node *Node) CreateMiningWorker(ctx context.Context) (*mining.DefaultWorker
 		Poster:           poster,
 		ChainState:       node.chain.ChainReader,
 		Drand:            node.Syncer().Drand,
+		History:          node.BlockMining.History,
 	}), nil
 }
 
@@ -713,6 +883,121 @@ func (node *Node) PieceManager() piecemanager.PieceManager {
 	return node.StorageMining.PieceManager
 }
 
+// Poster returns the node's Poster, or an error if the node is not set up for
+// storage mining.
+func (node *Node) Poster() (*poster.Poster, error) {
+	if node.StorageMining == nil {
+		return nil, errors.New("node is not set up for storage mining")
+	}
+	return node.StorageMining.Poster, nil
+}
+
+// Pledger returns the pledger of the node's miner sectors, or an error if the
+// node is not set up for storage mining.
+func (node *Node) Pledger() (*pledger.Pledger, error) {
+	if node.StorageMining == nil {
+		return nil, errors.New("node is not set up for storage mining")
+	}
+	return node.StorageMining.Pledger, nil
+}
+
+// AttachStorage initializes a local storage path with the weight and uses
+// given, unless it holds storage metadata already, and attaches it to a miner
+// of the node, the primary miner if the address is undefined. The sector
+// manager opens it at once if the miner is set up for storage mining, and when
+// it is set up otherwise.
+func (node *Node) AttachStorage(ctx context.Context, minerAddr address.Address, path string, weight uint64, canSeal, canStore bool) error {
+	path, err := storagePath(path)
+	if err != nil {
+		return err
+	}
+	connector, sm, err := node.minerStorage(minerAddr)
+	if err != nil {
+		return err
+	}
+	existing, err := connector.ListStorage()
+	if err != nil {
+		return err
+	}
+	for _, p := range existing {
+		if p.Path == path {
+			return errors.Errorf("storage path %s is already attached", path)
+		}
+	}
+
+	if err := fsmstorage.InitStoragePath(path, weight, canSeal, canStore); err != nil {
+		return errors.Wrapf(err, "failed to initialize storage path %s", path)
+	}
+	if sm != nil {
+		return sm.AttachStorage(ctx, path)
+	}
+	return connector.AttachStorage(path)
+}
+
+// DetachStorage detaches a storage path from a miner of the node, the primary
+// miner if the address is undefined. The sector manager cannot close a path it
+// opened, so a path is only detached while the miner is not set up for storage
+// mining.
+func (node *Node) DetachStorage(minerAddr address.Address, path string, force bool) error {
+	path, err := storagePath(path)
+	if err != nil {
+		return err
+	}
+	connector, sm, err := node.minerStorage(minerAddr)
+	if err != nil {
+		return err
+	}
+	if sm != nil {
+		return errors.Errorf("storage path %s is in use by the sector manager, detach it before mining starts", path)
+	}
+	return connector.DetachStorage(path, force)
+}
+
+// ListStorage returns the local storage paths of a miner of the node, the
+// primary miner if the address is undefined.
+func (node *Node) ListStorage(minerAddr address.Address) ([]fsmstorage.StoragePath, error) {
+	connector, _, err := node.minerStorage(minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	return connector.ListStorage()
+}
+
+// minerStorage returns the storage connector of a miner of the node, with its
+// storage mining submodule if it is set up.
+func (node *Node) minerStorage(minerAddr address.Address) (*fsmstorage.RepoStorageConnector, *submodule.StorageMiningSubmodule, error) {
+	if minerAddr == address.Undef || minerAddr == node.Repo.Config().Mining.MinerAddress {
+		return fsmstorage.NewRepoStorageConnector(node.Repo), node.StorageMining, nil
+	}
+	for _, addr := range node.MinerAddresses() {
+		if addr != minerAddr {
+			continue
+		}
+		if err := InitMinerSectors(node.Repo, minerAddr); err != nil {
+			return nil, nil, err
+		}
+		return fsmstorage.NewMinerStorageConnector(node.Repo, minerAddr), node.MinerStorageMining[minerAddr], nil
+	}
+	return nil, nil, errors.Errorf("miner %s is not operated by the node", minerAddr)
+}
+
+func storagePath(path string) (string, error) {
+	expanded, err := homedir.Expand(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(expanded)
+}
+
+// Sectors returns the inspector of the node's miner sectors, or an error if
+// the node is not set up for storage mining.
+func (node *Node) Sectors() (*sectorstatus.Inspector, error) {
+	if node.StorageMining == nil {
+		return nil, errors.New("node is not set up for storage mining")
+	}
+	return node.StorageMining.Sectors, nil
+}
+
 // BlockService returns the nodes blockservice.
 func (node *Node) BlockService() bserv.BlockService {
 	return node.Blockservice.Blockservice
@@ -15,6 +15,7 @@ import (
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p/p2p/protocol/ping"
 	ma "github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/cst"
@@ -22,7 +23,9 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/status"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
 	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/crypto"
 	"github.com/sbwtw/go-filecoin/internal/pkg/message"
@@ -46,12 +49,14 @@ type API struct {
 	logger logging.EventLogger
 
 	chain        *cst.ChainStateReadWriter
+	clock        clock.ChainEpochClock
+	mockClock    clock.Fake
 	syncer       *cst.ChainSyncProvider
 	config       *cfg.Config
 	dag          *dag.DAG
 	expected     consensus.Protocol
+	gasEstimator *msg.GasEstimator
 	msgPool      *message.Pool
-	msgPreviewer *msg.Previewer
 	msgWaiter    *msg.Waiter
 	network      *net.Network
 	outbox       *message.Outbox
@@ -62,12 +67,14 @@ type API struct {
 // APIDeps contains all the API's dependencies
 type APIDeps struct {
 	Chain        *cst.ChainStateReadWriter
+	Clock        clock.ChainEpochClock
+	MockClock    clock.Fake // nil unless the node runs with a mock clock
 	Sync         *cst.ChainSyncProvider
 	Config       *cfg.Config
 	DAG          *dag.DAG
 	Expected     consensus.Protocol
+	GasEstimator *msg.GasEstimator
 	MsgPool      *message.Pool
-	MsgPreviewer *msg.Previewer
 	MsgWaiter    *msg.Waiter
 	Network      *net.Network
 	Outbox       *message.Outbox
@@ -80,12 +87,14 @@ func New(deps *APIDeps) *API {
 	return &API{
 		logger:       logging.Logger("porcelain"),
 		chain:        deps.Chain,
+		clock:        deps.Clock,
+		mockClock:    deps.MockClock,
 		syncer:       deps.Sync,
 		config:       deps.Config,
 		dag:          deps.DAG,
 		expected:     deps.Expected,
+		gasEstimator: deps.GasEstimator,
 		msgPool:      deps.MsgPool,
-		msgPreviewer: deps.MsgPreviewer,
 		msgWaiter:    deps.MsgWaiter,
 		network:      deps.Network,
 		outbox:       deps.Outbox,
@@ -116,6 +125,29 @@ func (api *API) BlockTime() time.Duration {
 	return api.expected.BlockTime()
 }
 
+// ErrNoMockClock is returned when controlling the clock of a node which does not run with a mock clock.
+var ErrNoMockClock = errors.New("node is not running with a mock clock, start the daemon with --mock-clock")
+
+// ClockNow returns the node's current time and the chain epoch it falls in.
+func (api *API) ClockNow() (time.Time, abi.ChainEpoch) {
+	now := api.clock.Now()
+	return now, api.clock.EpochAtTime(now)
+}
+
+// ClockAdvance moves the node's mock clock forward by the given number of epochs
+// and returns the new current epoch. It fails if the node runs with a real clock.
+func (api *API) ClockAdvance(epochs abi.ChainEpoch) (abi.ChainEpoch, error) {
+	if api.mockClock == nil {
+		return 0, ErrNoMockClock
+	}
+	if epochs < 0 {
+		return 0, errors.New("cannot move the clock backwards")
+	}
+	api.mockClock.Advance(time.Duration(epochs) * api.clock.EpochDuration())
+	_, epoch := api.ClockNow()
+	return epoch, nil
+}
+
 // ConfigSet sets the given parameters at the given path in the local config.
 // The given path may be either a single field name, or a dotted path to a field.
 // The JSON value may be either a single value or a whole data structure to be replace.
@@ -157,6 +189,25 @@ func (api *API) ChainSetHead(ctx context.Context, key block.TipSetKey) error {
 	return api.chain.SetHead(ctx, key)
 }
 
+// ChainCheckpoint pins the tipset at `key`, on the chain of the current head,
+// so that chains not including it are refused. An empty key clears it.
+func (api *API) ChainCheckpoint(ctx context.Context, key block.TipSetKey) error {
+	return api.chain.SetCheckpoint(ctx, key)
+}
+
+// ChainGetCheckpoint returns the key of the checkpoint tipset, empty if none is set.
+func (api *API) ChainGetCheckpoint() block.TipSetKey {
+	return api.chain.GetCheckpoint()
+}
+
+// ChainNotify returns a channel of batches of tipsets reverted and applied as
+// the head changes, until ctx is done. The first batch brings a client that
+// last saw the tipset at `from` to the current head. With an empty `from`, it
+// holds the current head.
+func (api *API) ChainNotify(ctx context.Context, from block.TipSetKey) (<-chan []*chain.HeadChange, error) {
+	return api.chain.HeadChanges(ctx, from)
+}
+
 // ChainTipSet returns the tipset at the given key
 func (api *API) ChainTipSet(key block.TipSetKey) (block.TipSet, error) {
 	return api.chain.GetTipSet(key)
@@ -177,6 +228,27 @@ func (api *API) SyncerStatus() status.Status {
 	return api.syncer.Status()
 }
 
+// SyncerQuarantined returns the heavier forks the syncer refused to adopt for
+// forking deeper than the maximum reorg depth.
+func (api *API) SyncerQuarantined() []chainsync.QuarantinedFork {
+	return api.syncer.Quarantined()
+}
+
+// SyncerBadTipSets returns the tipsets the syncer refuses for failing validation.
+func (api *API) SyncerBadTipSets() []chainsync.BadTipSet {
+	return api.syncer.BadTipSets()
+}
+
+// SyncerRemoveBadTipSet forgets that the tipset at `key` failed validation.
+func (api *API) SyncerRemoveBadTipSet(key block.TipSetKey) error {
+	return api.syncer.RemoveBadTipSet(key)
+}
+
+// SyncerClearBadTipSets forgets every tipset that failed validation.
+func (api *API) SyncerClearBadTipSets() error {
+	return api.syncer.ClearBadTipSets()
+}
+
 // ChainSyncHandleNewTipSet submits a chain head to the syncer for processing.
 func (api *API) ChainSyncHandleNewTipSet(ci *block.ChainInfo) error {
 	return api.syncer.HandleNewTipSet(ci)
@@ -207,6 +279,12 @@ func (api *API) OutboxQueueClear(ctx context.Context, sender address.Address) {
 	api.outbox.Queue().Clear(ctx, sender)
 }
 
+// OutboxNextNonce returns the nonce of the next message from the sender,
+// following the messages in its outbox queue.
+func (api *API) OutboxNextNonce(ctx context.Context, sender address.Address) (uint64, error) {
+	return api.outbox.NextNonce(ctx, sender)
+}
+
 // MessagePoolPending lists messages un-mined in the pool
 func (api *API) MessagePoolPending() []*types.SignedMessage {
 	return api.msgPool.Pending()
@@ -223,9 +301,25 @@ func (api *API) MessagePoolRemove(cid cid.Cid) {
 }
 
 // MessagePreview previews the Gas cost of a message by running it locally on the client and
-// recording the amount of Gas used.
-func (api *API) MessagePreview(ctx context.Context, from, to address.Address, method abi.MethodNum, params ...interface{}) (gas.Unit, error) {
-	return api.msgPreviewer.Preview(ctx, from, to, method, params...)
+// recording the amount of Gas used. Messages from the same sender still pending in the message
+// pool or outbox are applied first.
+func (api *API) MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (gas.Unit, error) {
+	receipt, err := api.gasEstimator.Preview(ctx, from, to, value, method, params)
+	if err != nil {
+		return gas.Zero, err
+	}
+	return receipt.GasUsed, nil
+}
+
+// MessageEstimateGasLimit estimates the gas limit for a message, including the configured
+// safety margin. It returns an error if the message would fail to execute.
+func (api *API) MessageEstimateGasLimit(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (gas.Unit, error) {
+	return api.gasEstimator.EstimateGasLimit(ctx, from, to, value, method, params)
+}
+
+// MessageSuggestGasPrice suggests a gas price based on the prices of messages recently included on chain.
+func (api *API) MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	return api.gasEstimator.SuggestGasPrice(ctx)
 }
 
 // StateView loads the state view for a tipset, i.e. the state *after* the application of the tipset's messages.
@@ -257,6 +351,28 @@ func (api *API) MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*block.
 	return api.msgWaiter.Wait(ctx, msgCid, msg.DefaultMessageWaitLookback, cb)
 }
 
+// MessageWaitConfirmed invokes the callback once the message with the given cid
+// is on chain with at least confidence tipsets on top of its inclusion. The
+// reverted callback, which may be nil, is invoked each time a reorg removes
+// the inclusion while waiting.
+func (api *API) MessageWaitConfirmed(ctx context.Context, msgCid cid.Cid, confidence uint64, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error, reverted func(*block.Block, *types.SignedMessage) error) error {
+	return api.msgWaiter.WaitConfirmed(ctx, msgCid, msg.DefaultMessageWaitLookback, confidence, cb, reverted)
+}
+
+// MessageFind searches the lookback most recent tipsets of the chain for the
+// message with the given cid, without waiting.
+func (api *API) MessageFind(ctx context.Context, msgCid cid.Cid, lookback uint64) (*msg.ChainMessage, bool, error) {
+	return api.msgWaiter.Find(ctx, lookback, func(_ *types.SignedMessage, c cid.Cid) bool {
+		return c.Equals(msgCid)
+	})
+}
+
+// MessageDepth returns the number of tipsets on top of the tipset including
+// blk on the current chain.
+func (api *API) MessageDepth(ctx context.Context, blk *block.Block) (uint64, error) {
+	return api.msgWaiter.Depth(ctx, blk)
+}
+
 // NetworkGetBandwidthStats gets stats on the current bandwidth usage of the network
 func (api *API) NetworkGetBandwidthStats() metrics.Stats {
 	return api.network.GetBandwidthStats()
@@ -43,6 +43,9 @@ type chainReadWriter interface {
 	GetTipSetState(context.Context, block.TipSetKey) (vmstate.Tree, error)
 	GetTipSetStateRoot(block.TipSetKey) (cid.Cid, error)
 	SetHead(context.Context, block.TipSet) error
+	GetCheckpoint() block.TipSetKey
+	SetCheckpoint(block.TipSetKey) error
+	HeadChanges(context.Context, block.TipSetKey) (<-chan []*chain.HeadChange, error)
 	ReadOnlyStateStore() cborutil.ReadOnlyIpldStore
 }
 
@@ -294,6 +297,43 @@ func (chn *ChainStateReadWriter) SetHead(ctx context.Context, key block.TipSetKe
 	return chn.readWriter.SetHead(ctx, headTs)
 }
 
+// SetCheckpoint pins the tipset at key, which must be on the chain of the
+// head, so that the syncer refuses chains that do not include it. An empty
+// key clears the checkpoint.
+func (chn *ChainStateReadWriter) SetCheckpoint(ctx context.Context, key block.TipSetKey) error {
+	if key.Empty() {
+		return chn.readWriter.SetCheckpoint(key)
+	}
+	checkpoint, err := chn.readWriter.GetTipSet(key)
+	if err != nil {
+		return err
+	}
+	head, err := chn.readWriter.GetTipSet(chn.readWriter.GetHead())
+	if err != nil {
+		return err
+	}
+	ancestor, err := chain.FindTipsetAtEpoch(ctx, head, checkpoint.At(0).Height, chn.readWriter)
+	if err != nil {
+		return err
+	}
+	if !ancestor.Equals(checkpoint) {
+		return errors.Errorf("tipset %s is not on the chain of head %s", key, head.Key())
+	}
+	return chn.readWriter.SetCheckpoint(key)
+}
+
+// GetCheckpoint returns the key of the checkpoint tipset, empty if none is set.
+func (chn *ChainStateReadWriter) GetCheckpoint() block.TipSetKey {
+	return chn.readWriter.GetCheckpoint()
+}
+
+// HeadChanges returns a channel of batches of tipsets reverted and applied as
+// the head changes, starting with the changes from `from` to the current
+// head, or the current head if `from` is empty.
+func (chn *ChainStateReadWriter) HeadChanges(ctx context.Context, from block.TipSetKey) (<-chan []*chain.HeadChange, error) {
+	return chn.readWriter.HeadChanges(ctx, from)
+}
+
 // ReadOnlyStateStore returns a read-only state store.
 func (chn *ChainStateReadWriter) ReadOnlyStateStore() cborutil.ReadOnlyIpldStore {
 	return chn.readWriter.ReadOnlyStateStore()
@@ -9,6 +9,10 @@ import (
 type chainSync interface {
 	BlockProposer() chainsync.BlockProposer
 	Status() status.Status
+	Quarantined() []chainsync.QuarantinedFork
+	BadTipSets() []chainsync.BadTipSet
+	RemoveBadTipSet(block.TipSetKey) error
+	ClearBadTipSets() error
 }
 
 // ChainSyncProvider provides access to chain sync operations and their status.
@@ -29,6 +33,28 @@ func (chs *ChainSyncProvider) Status() status.Status {
 	return chs.sync.Status()
 }
 
+// Quarantined returns the heavier forks the syncer refused to adopt for
+// forking deeper than the maximum reorg depth.
+func (chs *ChainSyncProvider) Quarantined() []chainsync.QuarantinedFork {
+	return chs.sync.Quarantined()
+}
+
+// BadTipSets returns the tipsets the syncer refuses for failing validation.
+func (chs *ChainSyncProvider) BadTipSets() []chainsync.BadTipSet {
+	return chs.sync.BadTipSets()
+}
+
+// RemoveBadTipSet forgets that the tipset at key failed validation, so that
+// it is validated again when next seen.
+func (chs *ChainSyncProvider) RemoveBadTipSet(key block.TipSetKey) error {
+	return chs.sync.RemoveBadTipSet(key)
+}
+
+// ClearBadTipSets forgets every tipset that failed validation.
+func (chs *ChainSyncProvider) ClearBadTipSets() error {
+	return chs.sync.ClearBadTipSets()
+}
+
 // HandleNewTipSet extends the Syncer's chain store with the given tipset if they
 // represent a valid extension. It limits the length of new chains it will
 // attempt to validate and caches invalid blocks it has encountered to
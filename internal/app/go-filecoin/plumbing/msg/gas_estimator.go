@@ -0,0 +1,259 @@
+package msg
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+	"github.com/sbwtw/go-filecoin/internal/pkg/message"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/actor"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/state"
+)
+
+// Abstracts over a store of blockchain state.
+type estimatorChainReader interface {
+	GetHead() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error)
+}
+
+// Abstracts over the actor lookup with address resolution.
+type estimatorActorProvider interface {
+	GetActorAt(ctx context.Context, tipKey block.TipSetKey, addr address.Address) (*actor.Actor, error)
+}
+
+// Applies messages on top of a state tree without persisting the result.
+type estimatorProcessor interface {
+	ApplyMessagesForEstimation(ctx context.Context, st state.Tree, vms vm.Storage, head block.TipSet, msgs []*types.SignedMessage) ([]vm.MessageReceipt, error)
+}
+
+// Provides the messages waiting in the message pool.
+type estimatorPool interface {
+	Pending() []*types.SignedMessage
+}
+
+// Provides the messages waiting in the outbound queue.
+type estimatorQueue interface {
+	List(sender address.Address) []*message.Queued
+}
+
+// Provides the current node configuration.
+type estimatorConfig interface {
+	Config() *config.Config
+}
+
+// GasEstimator estimates the gas limit of a message and suggests a gas price for it.
+//
+// Messages from the same sender which are still pending in the message pool or in the
+// outbox are applied before the estimated message, so that the estimate reflects the
+// state the message will actually execute against.
+type GasEstimator struct {
+	chainReader estimatorChainReader
+	actors      estimatorActorProvider
+	messages    chain.MessageProvider
+	bs          bstore.Blockstore
+	processor   estimatorProcessor
+	pool        estimatorPool
+	queue       estimatorQueue
+	config      estimatorConfig
+}
+
+// NewGasEstimator constructs a GasEstimator.
+func NewGasEstimator(chainReader estimatorChainReader, actors estimatorActorProvider, messages chain.MessageProvider, bs bstore.Blockstore,
+	processor estimatorProcessor, pool estimatorPool, queue estimatorQueue, config estimatorConfig) *GasEstimator {
+	return &GasEstimator{
+		chainReader: chainReader,
+		actors:      actors,
+		messages:    messages,
+		bs:          bs,
+		processor:   processor,
+		pool:        pool,
+		queue:       queue,
+		config:      config,
+	}
+}
+
+// Preview returns the gas used by the message when applied after the sender's pending messages.
+// The receipt of the message is returned alongside so callers can inspect its exit code.
+func (e *GasEstimator) Preview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (*vm.MessageReceipt, error) {
+	encodedParams, err := encoding.Encode(params)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode message params")
+	}
+	// The spec's message syntax validation rules restricts empty parameters
+	// to be encoded as an empty byte string not cbor null
+	if encodedParams == nil {
+		encodedParams = []byte{}
+	}
+
+	head, err := e.chainReader.GetTipSet(e.chainReader.GetHead())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get head tipset")
+	}
+	st, err := e.chainReader.GetTipSetState(ctx, head.Key())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load tree for latest state root")
+	}
+	fromNonce, err := e.senderNonce(ctx, head.Key(), from)
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := pendingFromSender(from, fromNonce, e.pool.Pending(), e.queue.List(from))
+	nonce := fromNonce + uint64(len(msgs))
+
+	// The estimated message is run with a zero price and the block limit so that the
+	// measurement is bounded by the execution itself and not by the sender's funds.
+	unsigned := types.NewMeteredMessage(from, to, nonce, value, method, encodedParams, types.ZeroAttoFIL, types.BlockGasLimit)
+	msgs = append(msgs, &types.SignedMessage{Message: *unsigned})
+
+	receipts, err := e.processor.ApplyMessagesForEstimation(ctx, st, vm.NewStorage(e.bs), head, msgs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to apply messages")
+	}
+	return &receipts[len(receipts)-1], nil
+}
+
+// EstimateGasLimit returns a gas limit for the message with the configured safety margin applied.
+// An error is returned if the message would fail to execute.
+func (e *GasEstimator) EstimateGasLimit(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (gas.Unit, error) {
+	receipt, err := e.Preview(ctx, from, to, value, method, params)
+	if err != nil {
+		return gas.Zero, err
+	}
+	if receipt.ExitCode != exitcode.Ok {
+		return gas.Zero, errors.Errorf("message execution failed with exit code %d", receipt.ExitCode)
+	}
+	return applyGasMargin(receipt.GasUsed, e.config.Config().Message.GasLimitMarginPercent), nil
+}
+
+// SuggestGasPrice returns the median gas price of messages included in the configured
+// number of tipsets at the top of the chain, or the configured default if there are none.
+func (e *GasEstimator) SuggestGasPrice(ctx context.Context) (types.AttoFIL, error) {
+	cfg := e.config.Config().Message
+
+	var prices []types.AttoFIL
+	seen := make(map[cid.Cid]struct{})
+	ts, err := e.chainReader.GetTipSet(e.chainReader.GetHead())
+	if err != nil {
+		return types.ZeroAttoFIL, errors.Wrap(err, "failed to get head tipset")
+	}
+	for i := uint(0); i < cfg.GasPriceLookback; i++ {
+		for j := 0; j < ts.Len(); j++ {
+			secpMsgs, blsMsgs, err := e.messages.LoadMessages(ctx, ts.At(j).Messages.Cid)
+			if err != nil {
+				return types.ZeroAttoFIL, errors.Wrapf(err, "failed to load messages for block %s", ts.At(j).Cid())
+			}
+			for _, m := range secpMsgs {
+				prices = appendUnseenPrice(prices, seen, &m.Message)
+			}
+			for _, m := range blsMsgs {
+				prices = appendUnseenPrice(prices, seen, m)
+			}
+		}
+
+		parents, err := ts.Parents()
+		if err != nil {
+			return types.ZeroAttoFIL, err
+		}
+		if parents.Empty() {
+			break
+		}
+		if ts, err = e.chainReader.GetTipSet(parents); err != nil {
+			return types.ZeroAttoFIL, errors.Wrapf(err, "failed to get tipset %s", parents)
+		}
+	}
+
+	if len(prices) == 0 {
+		return cfg.DefaultGasPrice, nil
+	}
+	return medianPrice(prices), nil
+}
+
+// senderNonce returns the nonce of the sender at the tipset. A sender that does not exist in the
+// state yet is treated as a fresh account with nonce zero (and no balance, as the VM will find).
+func (e *GasEstimator) senderNonce(ctx context.Context, key block.TipSetKey, from address.Address) (uint64, error) {
+	fromActor, err := e.actors.GetActorAt(ctx, key, from)
+	if cause := errors.Cause(err); cause == types.ErrNotFound || cause == init_.ErrAddressNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to load sender actor %s", from)
+	}
+	return fromActor.CallSeqNum, nil
+}
+
+// pendingFromSender returns the contiguous run of pending messages from `from` starting at `nonce`,
+// drawn from both the message pool and the outbox.
+func pendingFromSender(from address.Address, nonce uint64, pooled []*types.SignedMessage, queued []*message.Queued) []*types.SignedMessage {
+	byNonce := make(map[uint64]*types.SignedMessage)
+	for _, m := range pooled {
+		if m.Message.From == from && m.Message.CallSeqNum >= nonce {
+			byNonce[m.Message.CallSeqNum] = m
+		}
+	}
+	// Prefer the outbox copy, it is the one this node signed and published.
+	for _, q := range queued {
+		if q.Msg.Message.CallSeqNum >= nonce {
+			byNonce[q.Msg.Message.CallSeqNum] = q.Msg
+		}
+	}
+
+	var msgs []*types.SignedMessage
+	for {
+		m, ok := byNonce[nonce]
+		if !ok {
+			return msgs
+		}
+		msgs = append(msgs, m)
+		nonce++
+	}
+}
+
+// applyGasMargin adds `marginPercent` to `used`, without exceeding the block gas limit.
+func applyGasMargin(used gas.Unit, marginPercent uint64) gas.Unit {
+	limit := used + used*gas.Unit(marginPercent)/100
+	if limit > types.BlockGasLimit {
+		return types.BlockGasLimit
+	}
+	return limit
+}
+
+func appendUnseenPrice(prices []types.AttoFIL, seen map[cid.Cid]struct{}, m *types.UnsignedMessage) []types.AttoFIL {
+	// The same message may be included in more than one block of a tipset.
+	c, err := m.Cid()
+	if err != nil {
+		return prices
+	}
+	if _, ok := seen[c]; ok {
+		return prices
+	}
+	seen[c] = struct{}{}
+	return append(prices, m.GasPrice)
+}
+
+func medianPrice(prices []types.AttoFIL) types.AttoFIL {
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].LessThan(prices[j])
+	})
+	mid := len(prices) / 2
+	if len(prices)%2 == 1 {
+		return prices[mid]
+	}
+	return big.Div(big.Add(prices[mid-1], prices[mid]), big.NewInt(2))
+}
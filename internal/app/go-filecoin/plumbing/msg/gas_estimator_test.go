@@ -0,0 +1,189 @@
+package msg
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	"github.com/sbwtw/go-filecoin/internal/pkg/message"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestEstimateAgainstPendingMessages(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	sender, other, target := vmaddr.RequireIDAddress(t, 100), vmaddr.RequireIDAddress(t, 101), vmaddr.RequireIDAddress(t, 102)
+	msgWithNonce := func(from address.Address, nonce uint64) *types.SignedMessage {
+		unsigned := types.NewUnsignedMessage(from, target, nonce, types.ZeroAttoFIL, builtin.MethodSend, []byte{})
+		return &types.SignedMessage{Message: *unsigned}
+	}
+
+	newEstimator := func(t *testing.T, pooled []*types.SignedMessage, queued []*types.SignedMessage) (*GasEstimator, *fakeEstimatorProcessor) {
+		builder := chain.NewBuilder(t, address.Undef)
+		chainReader := &fakeEstimatorChain{
+			Builder: builder,
+			head:    builder.NewGenesis().Key(),
+			actors:  map[address.Address]*actor.Actor{sender: {CallSeqNum: 3, Balance: abi.NewTokenAmount(100)}},
+		}
+
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, message.FakeValidator{})
+		for _, m := range pooled {
+			_, err := pool.Add(ctx, m, 0)
+			require.NoError(t, err)
+		}
+		queue := message.NewQueue()
+		for _, m := range queued {
+			require.NoError(t, queue.Enqueue(ctx, m, 0))
+		}
+
+		processor := &fakeEstimatorProcessor{gasUsed: gas.NewGas(1000)}
+		cfg := config.NewDefaultConfig()
+		cfg.Message.GasLimitMarginPercent = 25
+		return NewGasEstimator(chainReader, chainReader, builder, nil, processor, pool, queue, fakeEstimatorConfig{cfg}), processor
+	}
+
+	t.Run("applies the sender's pending messages first", func(t *testing.T) {
+		pooled := []*types.SignedMessage{msgWithNonce(sender, 3), msgWithNonce(sender, 4), msgWithNonce(other, 3), msgWithNonce(sender, 2)}
+		queued := []*types.SignedMessage{msgWithNonce(sender, 5)}
+		estimator, processor := newEstimator(t, pooled, queued)
+
+		limit, err := estimator.EstimateGasLimit(ctx, sender, target, abi.NewTokenAmount(7), builtin.MethodSend, nil)
+		require.NoError(t, err)
+		assert.Equal(t, gas.NewGas(1250), limit)
+
+		require.Len(t, processor.applied, 4)
+		for i, m := range processor.applied {
+			assert.Equal(t, sender, m.Message.From)
+			assert.Equal(t, uint64(3+i), m.Message.CallSeqNum)
+		}
+		estimated := processor.applied[3].Message
+		assert.Equal(t, abi.NewTokenAmount(7), estimated.Value)
+		assert.Equal(t, types.ZeroAttoFIL, estimated.GasPrice)
+	})
+
+	t.Run("a sender missing from state starts at nonce zero", func(t *testing.T) {
+		estimator, processor := newEstimator(t, []*types.SignedMessage{msgWithNonce(other, 0)}, nil)
+
+		receipt, err := estimator.Preview(ctx, other, target, types.ZeroAttoFIL, builtin.MethodSend, nil)
+		require.NoError(t, err)
+		assert.Equal(t, gas.NewGas(1000), receipt.GasUsed)
+
+		require.Len(t, processor.applied, 2)
+		assert.Equal(t, uint64(1), processor.applied[1].Message.CallSeqNum)
+	})
+
+	t.Run("fails the estimate when the message fails", func(t *testing.T) {
+		estimator, processor := newEstimator(t, nil, nil)
+		processor.exitCode = exitcode.SysErrSenderStateInvalid
+
+		_, err := estimator.EstimateGasLimit(ctx, sender, target, abi.NewTokenAmount(7), builtin.MethodSend, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestPendingFromSender(t *testing.T) {
+	tf.UnitTest(t)
+
+	addrGetter := vmaddr.NewForTestGetter()
+	sender, other := addrGetter(), addrGetter()
+
+	msgWithNonce := func(from address.Address, nonce uint64) *types.SignedMessage {
+		unsigned := types.NewUnsignedMessage(from, other, nonce, types.ZeroAttoFIL, 0, []byte{})
+		return &types.SignedMessage{Message: *unsigned}
+	}
+
+	t.Run("combines pool and outbox in nonce order", func(t *testing.T) {
+		pooled := []*types.SignedMessage{msgWithNonce(sender, 6), msgWithNonce(other, 5), msgWithNonce(sender, 5)}
+		queued := []*message.Queued{{Msg: msgWithNonce(sender, 7)}}
+
+		msgs := pendingFromSender(sender, 5, pooled, queued)
+		assert.Len(t, msgs, 3)
+		for i, m := range msgs {
+			assert.Equal(t, sender, m.Message.From)
+			assert.Equal(t, uint64(5+i), m.Message.CallSeqNum)
+		}
+	})
+
+	t.Run("stops at a nonce gap and ignores stale nonces", func(t *testing.T) {
+		pooled := []*types.SignedMessage{msgWithNonce(sender, 4), msgWithNonce(sender, 5), msgWithNonce(sender, 7)}
+
+		msgs := pendingFromSender(sender, 5, pooled, nil)
+		assert.Len(t, msgs, 1)
+		assert.Equal(t, uint64(5), msgs[0].Message.CallSeqNum)
+	})
+}
+
+func TestApplyGasMargin(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.Equal(t, gas.NewGas(1250), applyGasMargin(gas.NewGas(1000), 25))
+	assert.Equal(t, gas.NewGas(1000), applyGasMargin(gas.NewGas(1000), 0))
+	assert.Equal(t, types.BlockGasLimit, applyGasMargin(types.BlockGasLimit, 25))
+}
+
+func TestMedianPrice(t *testing.T) {
+	tf.UnitTest(t)
+
+	assert.Equal(t, types.NewGasPrice(3), medianPrice([]types.AttoFIL{types.NewGasPrice(5), types.NewGasPrice(1), types.NewGasPrice(3)}))
+	assert.Equal(t, types.NewGasPrice(4), medianPrice([]types.AttoFIL{types.NewGasPrice(5), types.NewGasPrice(1), types.NewGasPrice(3), types.NewGasPrice(9)}))
+}
+
+type fakeEstimatorChain struct {
+	*chain.Builder
+	head   block.TipSetKey
+	actors map[address.Address]*actor.Actor
+}
+
+func (f *fakeEstimatorChain) GetHead() block.TipSetKey {
+	return f.head
+}
+
+func (f *fakeEstimatorChain) GetTipSetState(context.Context, block.TipSetKey) (state.Tree, error) {
+	return nil, nil
+}
+
+func (f *fakeEstimatorChain) GetActorAt(_ context.Context, _ block.TipSetKey, addr address.Address) (*actor.Actor, error) {
+	act, ok := f.actors[addr]
+	if !ok {
+		return nil, types.ErrNotFound
+	}
+	return act, nil
+}
+
+// Reports `gasUsed` and `exitCode` for the last message applied, the others succeed.
+type fakeEstimatorProcessor struct {
+	gasUsed  gas.Unit
+	exitCode exitcode.ExitCode
+	applied  []*types.SignedMessage
+}
+
+func (f *fakeEstimatorProcessor) ApplyMessagesForEstimation(_ context.Context, _ state.Tree, _ vm.Storage, _ block.TipSet, msgs []*types.SignedMessage) ([]vm.MessageReceipt, error) {
+	f.applied = msgs
+	receipts := make([]vm.MessageReceipt, len(msgs))
+	receipts[len(msgs)-1] = vm.MessageReceipt{ExitCode: f.exitCode, GasUsed: f.gasUsed}
+	return receipts, nil
+}
+
+type fakeEstimatorConfig struct {
+	cfg *config.Config
+}
+
+func (f fakeEstimatorConfig) Config() *config.Config {
+	return f.cfg
+}
@@ -119,10 +119,152 @@ func (w *Waiter) Wait(ctx context.Context, msgCid cid.Cid, lookback uint64, cb f
 	return w.WaitPredicate(ctx, lookback, pred, cb)
 }
 
+// WaitConfirmed invokes cb once the message with the given cid is on chain
+// with at least confidence tipsets on top of the tipset including it. If a
+// reorg removes the inclusion while waiting, reverted is invoked with the block
+// the message was in, and waiting continues until the message is included
+// again. With a confidence of zero it returns as soon as Wait would.
+func (w *Waiter) WaitConfirmed(ctx context.Context, msgCid cid.Cid, lookback, confidence uint64, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error, reverted func(*block.Block, *types.SignedMessage) error) error {
+	log.Infof("Calling Waiter.WaitConfirmed CID: %s, confidence: %d", msgCid.String(), confidence)
+
+	pred := func(msg *types.SignedMessage, c cid.Cid) bool {
+		return c.Equals(msgCid)
+	}
+
+	ch := w.chainReader.HeadEvents().Sub(chain.NewHeadTopic)
+	defer func() {
+		w.chainReader.HeadEvents().Unsub(ch, chain.NewHeadTopic)
+	}()
+
+	head, err := w.chainReader.GetTipSet(w.chainReader.GetHead())
+	if err != nil {
+		return err
+	}
+
+	// Look back far enough to find a message that is already confirmed.
+	chainMsg, found, err := w.findMessage(ctx, head, lookback+confidence, pred)
+	if err != nil {
+		return err
+	}
+	var depth uint64
+	if found {
+		if depth, err = w.depth(ctx, head, chainMsg.Block); err != nil {
+			return err
+		}
+	}
+
+	for !found || depth < confidence {
+		next, err := w.nextHead(ctx, ch)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			found, err = w.survivesReorg(ctx, head, next, chainMsg.Block)
+			if err != nil {
+				return err
+			}
+			if !found && reverted != nil {
+				if err := reverted(chainMsg.Block, chainMsg.Message); err != nil {
+					return err
+				}
+			}
+		}
+		if !found {
+			chainMsg, found, err = w.receiptForChain(ctx, next, head, pred)
+			if err != nil {
+				return err
+			}
+		}
+		if found {
+			if depth, err = w.depth(ctx, next, chainMsg.Block); err != nil {
+				return err
+			}
+		}
+		head = next
+	}
+	return cb(chainMsg.Block, chainMsg.Message, chainMsg.Receipt)
+}
+
+// Depth returns the number of tipsets on top of the tipset including blk on
+// the chain of the current head. It errors if blk is not on that chain.
+func (w *Waiter) Depth(ctx context.Context, blk *block.Block) (uint64, error) {
+	head, err := w.chainReader.GetTipSet(w.chainReader.GetHead())
+	if err != nil {
+		return 0, err
+	}
+	return w.depth(ctx, head, blk)
+}
+
+// depth counts the tipsets on top of the tipset including blk on the chain of
+// head.
+func (w *Waiter) depth(ctx context.Context, head block.TipSet, blk *block.Block) (uint64, error) {
+	var depth uint64
+	var err error
+	for iterator := chain.IterAncestors(ctx, w.chainReader, head); err == nil && !iterator.Complete(); err = iterator.Next() {
+		ts := iterator.Value()
+		height, err := ts.Height()
+		if err != nil {
+			return 0, err
+		}
+		if height > blk.Height {
+			depth++
+			continue
+		}
+		if height == blk.Height && ts.Key().Has(blk.Cid()) {
+			return depth, nil
+		}
+		break
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, errors.Errorf("block %s is not on the chain of %s", blk.Cid(), head.Key())
+}
+
+// survivesReorg returns whether blk, on the chain of prev, is still on the
+// chain after the head moves from prev to next.
+func (w *Waiter) survivesReorg(ctx context.Context, prev, next block.TipSet, blk *block.Block) (bool, error) {
+	ancestor, err := chain.FindCommonAncestor(chain.IterAncestors(ctx, w.chainReader, prev), chain.IterAncestors(ctx, w.chainReader, next))
+	if err != nil {
+		return false, err
+	}
+	if !chain.IsReorg(prev, next, ancestor) {
+		return true, nil
+	}
+	ancestorHeight, err := ancestor.Height()
+	if err != nil {
+		return false, err
+	}
+	return blk.Height <= ancestorHeight, nil
+}
+
+// nextHead returns the next head published on ch.
+func (w *Waiter) nextHead(ctx context.Context, ch <-chan interface{}) (block.TipSet, error) {
+	select {
+	case <-ctx.Done():
+		return block.UndefTipSet, ctx.Err()
+	case raw, more := <-ch:
+		if !more {
+			return block.UndefTipSet, errors.New("head events closed")
+		}
+		switch raw := raw.(type) {
+		case error:
+			log.Errorf("Waiter.WaitConfirmed: %s", raw)
+			return block.UndefTipSet, raw
+		case block.TipSet:
+			return raw, nil
+		default:
+			return block.UndefTipSet, fmt.Errorf("unexpected type in channel: %T", raw)
+		}
+	}
+}
+
 // findMessage looks for a matching in the chain and returns the message,
 // block and receipt, when it is found. Returns the found message/block or nil
 // if now block with the given CID exists in the chain.
 // The lookback parameter is the number of tipsets in the past this method will check before giving up.
+// It checks at least the head.
 func (w *Waiter) findMessage(ctx context.Context, head block.TipSet, lookback uint64, pred WaitPredicate) (*ChainMessage, bool, error) {
 	var err error
 	for iterator := chain.IterAncestors(ctx, w.chainReader, head); err == nil && !iterator.Complete(); err = iterator.Next() {
@@ -135,10 +277,10 @@ func (w *Waiter) findMessage(ctx context.Context, head block.TipSet, lookback ui
 			return msg, true, nil
 		}
 
-		lookback--
-		if lookback <= 0 {
+		if lookback <= 1 {
 			break
 		}
+		lookback--
 	}
 	return nil, false, err
 }
@@ -110,6 +110,41 @@ func testWaitNew(ctx context.Context, t *testing.T, cst cbor.IpldStore, chainSto
 	wg.Wait()
 }
 
+func TestFindLookback(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, chainStore, msgStore, waiter := setupTest(t)
+	m1 := newSignedMessage()
+	m1Cid, err := m1.Cid()
+	require.NoError(t, err)
+	headTipSet, err := chainStore.GetTipSet(chainStore.GetHead())
+	require.NoError(t, err)
+
+	// The message is one tipset below the head.
+	for _, ts := range newChainWithMessages(cst, msgStore, headTipSet, smsgsSet{smsgs{m1}}, smsgsSet{smsgs{}}) {
+		require.NoError(t, chainStore.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot.Cid,
+			TipSetReceipts:  ts.At(0).MessageReceipts.Cid,
+		}))
+		require.NoError(t, chainStore.SetHead(ctx, ts))
+	}
+	pred := func(_ *types.SignedMessage, c cid.Cid) bool {
+		return c.Equals(m1Cid)
+	}
+
+	// A zero lookback checks only the head, as does a lookback of one.
+	for _, lookback := range []uint64{0, 1} {
+		_, found, err := waiter.Find(ctx, lookback, pred)
+		require.NoError(t, err)
+		assert.False(t, found)
+	}
+	_, found, err := waiter.Find(ctx, 2, pred)
+	require.NoError(t, err)
+	assert.True(t, found)
+}
+
 func TestWaitError(t *testing.T) {
 	tf.UnitTest(t)
 
@@ -161,6 +196,72 @@ func TestWaitRespectsContextCancel(t *testing.T) {
 	}
 }
 
+func TestWaitConfirmed(t *testing.T) {
+	tf.UnitTest(t)
+
+	ctx := context.Background()
+	cst, chainStore, msgStore, waiter := setupTest(t)
+
+	m1, m2, m3, m4, m5 := newSignedMessage(), newSignedMessage(), newSignedMessage(), newSignedMessage(), newSignedMessage()
+	m1Cid, err := m1.Cid()
+	require.NoError(t, err)
+	head, err := chainStore.GetTipSet(chainStore.GetHead())
+	require.NoError(t, err)
+
+	// included[1] holds m1, the fork replacing it does not.
+	included := newChainWithMessages(cst, msgStore, head, smsgsSet{smsgs{m1}}, smsgsSet{smsgs{m3}}, smsgsSet{smsgs{m4}})
+	fork := newChainWithMessages(cst, msgStore, head, smsgsSet{smsgs{m2}}, smsgsSet{smsgs{m5}})
+	for _, ts := range append(included[1:], fork[1:]...) {
+		require.NoError(t, chainStore.PutTipSetMetadata(ctx, &chain.TipSetMetadata{
+			TipSet:          ts,
+			TipSetStateRoot: ts.At(0).StateRoot.Cid,
+			TipSetReceipts:  ts.At(0).MessageReceipts.Cid,
+		}))
+	}
+	require.NoError(t, chainStore.SetHead(ctx, included[1]))
+
+	reverts := make(chan *types.SignedMessage, 1)
+	done := make(chan error, 1)
+	var receipt *vm.MessageReceipt
+	go func() {
+		done <- waiter.WaitConfirmed(ctx, m1Cid, DefaultMessageWaitLookback, 2, func(_ *block.Block, _ *types.SignedMessage, rcpt *vm.MessageReceipt) error {
+			receipt = rcpt
+			return nil
+		}, func(_ *block.Block, msg *types.SignedMessage) error {
+			reverts <- msg
+			return nil
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	// A reorg to the fork undoes the inclusion.
+	require.NoError(t, chainStore.SetHead(ctx, fork[2]))
+	select {
+	case msg := <-reverts:
+		assert.True(t, types.SmsgCidsEqual(m1, msg))
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the revert")
+	}
+
+	// Back on the original chain, the message needs two tipsets on top.
+	require.NoError(t, chainStore.SetHead(ctx, included[2]))
+	require.NoError(t, chainStore.SetHead(ctx, included[3]))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+		assert.NotNil(t, receipt)
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for confirmation")
+	}
+	assert.Empty(t, reverts)
+
+	depth, err := waiter.Depth(ctx, included[1].At(0))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), depth)
+	_, err = waiter.Depth(ctx, fork[1].At(0))
+	assert.Error(t, err)
+}
+
 // NewChainWithMessages creates a chain of tipsets containing the given messages
 // and stores them in the given store.  Note the msg arguments are slices of
 // slices of messages -- each slice of slices goes into a successive tipset,
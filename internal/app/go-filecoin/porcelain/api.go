@@ -12,6 +12,7 @@ import (
 
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
@@ -66,23 +67,57 @@ func (a *API) MessagePoolWait(ctx context.Context, messageCount uint) ([]*types.
 func (a *API) MinerCreate(
 	ctx context.Context,
 	accountAddr address.Address,
+	workerAddr address.Address,
 	gasPrice types.AttoFIL,
 	gasLimit gas.Unit,
 	sealProofType abi.RegisteredProof,
 	pid peer.ID,
 	collateral types.AttoFIL,
 ) (_ address.Address, err error) {
-	return MinerCreate(ctx, a, accountAddr, gasPrice, gasLimit, sealProofType, pid, collateral)
+	return MinerCreate(ctx, a, accountAddr, workerAddr, gasPrice, gasLimit, sealProofType, pid, collateral)
+}
+
+// MinerCreateUnsigned builds an unsigned message creating a miner, for an
+// owner to sign offline
+func (a *API) MinerCreateUnsigned(
+	ctx context.Context,
+	ownerAddr address.Address,
+	workerAddr address.Address,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	sealProofType abi.RegisteredProof,
+	pid peer.ID,
+	collateral types.AttoFIL,
+) (*types.UnsignedMessage, error) {
+	return MinerCreateUnsigned(ctx, a, ownerAddr, workerAddr, gasPrice, gasLimit, sealProofType, pid, collateral)
+}
+
+// MinerNewWorker generates and funds the worker of a new miner
+func (a *API) MinerNewWorker(
+	ctx context.Context,
+	ownerAddr address.Address,
+	funds types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+) (address.Address, error) {
+	return MinerNewWorker(ctx, a, ownerAddr, funds, gasPrice, gasLimit)
+}
+
+// MinerAwaitCreate waits for a create miner message sent outside the node and
+// records the miner
+func (a *API) MinerAwaitCreate(ctx context.Context, msgCid cid.Cid) (address.Address, error) {
+	return MinerAwaitCreate(ctx, a, msgCid)
 }
 
 // MinerPreviewCreate previews the Gas cost of creating a miner
 func (a *API) MinerPreviewCreate(
 	ctx context.Context,
 	fromAddr address.Address,
+	workerAddr address.Address,
 	sectorSize abi.SectorSize,
 	pid peer.ID,
 ) (usedGas gas.Unit, err error) {
-	return MinerPreviewCreate(ctx, a, fromAddr, sectorSize, pid)
+	return MinerPreviewCreate(ctx, a, fromAddr, workerAddr, sectorSize, pid)
 }
 
 // MinerGetStatus queries for status of a miner.
@@ -121,9 +156,44 @@ func (a *API) PingMinerWithTimeout(
 	return PingMinerWithTimeout(ctx, minerPID, timeout, a)
 }
 
-// MinerSetWorkerAddress sets the miner worker address to the provided address
-func (a *API) MinerSetWorkerAddress(ctx context.Context, toAddr address.Address, gasPrice types.AttoFIL, gasLimit gas.Unit) (cid.Cid, error) {
-	return MinerSetWorkerAddress(ctx, a, toAddr, gasPrice, gasLimit)
+// MinerSetWorkerAddress sets the worker address of the miner to the provided address
+func (a *API) MinerSetWorkerAddress(ctx context.Context, minerAddr address.Address, toAddr address.Address, gasPrice types.AttoFIL, gasLimit gas.Unit) (cid.Cid, error) {
+	return MinerSetWorkerAddress(ctx, a, minerAddr, toAddr, gasPrice, gasLimit)
+}
+
+// NodeMiners returns the addresses of the miner actors the node operates
+func (a *API) NodeMiners() ([]address.Address, error) {
+	return NodeMiners(a)
+}
+
+// PledgePolicy returns the policy the node pledges sectors by
+func (a *API) PledgePolicy() (config.PledgePolicyConfig, error) {
+	return PledgePolicy(a)
+}
+
+// SetPledgePolicy sets the policy the node pledges sectors by
+func (a *API) SetPledgePolicy(policy config.PledgePolicyConfig) error {
+	return SetPledgePolicy(a, policy)
+}
+
+// MinerPreviewSetWorkerAddress previews the Gas cost of setting the worker address of the miner
+func (a *API) MinerPreviewSetWorkerAddress(ctx context.Context, minerAddr address.Address, toAddr address.Address) (gas.Unit, error) {
+	return MinerPreviewSetWorkerAddress(ctx, a, minerAddr, toAddr)
+}
+
+// MinerWithdrawBalance withdraws available balance of the miner to its owner
+func (a *API) MinerWithdrawBalance(ctx context.Context, minerAddr address.Address, amount types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, preview bool) (MinerMessageResult, error) {
+	return MinerWithdrawBalance(ctx, a, minerAddr, amount, gasPrice, gasLimit, preview)
+}
+
+// MinerExtendSectorExpiration extends the expiration of a sector of the miner
+func (a *API) MinerExtendSectorExpiration(ctx context.Context, minerAddr address.Address, sector abi.SectorNumber, expiration abi.ChainEpoch, gasPrice types.AttoFIL, gasLimit gas.Unit, preview bool) (MinerMessageResult, error) {
+	return MinerExtendSectorExpiration(ctx, a, minerAddr, sector, expiration, gasPrice, gasLimit, preview)
+}
+
+// MinerTerminateSectors terminates sectors of the miner
+func (a *API) MinerTerminateSectors(ctx context.Context, minerAddr address.Address, sectors []abi.SectorNumber, gasPrice types.AttoFIL, gasLimit gas.Unit, preview bool) (MinerMessageResult, error) {
+	return MinerTerminateSectors(ctx, a, minerAddr, sectors, gasPrice, gasLimit, preview)
 }
 
 // MessageWaitDone blocks until the message is on chain
@@ -131,6 +201,12 @@ func (a *API) MessageWaitDone(ctx context.Context, msgCid cid.Cid) (*vm.MessageR
 	return MessageWaitDone(ctx, a, msgCid)
 }
 
+// MessageWaitDoneConfirmed blocks until the message is on chain with at least
+// confidence tipsets on top of its inclusion.
+func (a *API) MessageWaitDoneConfirmed(ctx context.Context, msgCid cid.Cid, confidence uint64) (*vm.MessageReceipt, error) {
+	return MessageWaitDoneConfirmed(ctx, a, msgCid, confidence)
+}
+
 func (a *API) PowerStateView(baseKey block.TipSetKey) (consensus.PowerStateView, error) {
 	return a.StateView(baseKey)
 }
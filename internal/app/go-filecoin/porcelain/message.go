@@ -15,6 +15,10 @@ type waitPlumbing interface {
 	MessageWait(context.Context, cid.Cid, func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error
 }
 
+type confirmedWaitPlumbing interface {
+	MessageWaitConfirmed(context.Context, cid.Cid, uint64, func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error, func(*block.Block, *types.SignedMessage) error) error
+}
+
 // MessageWaitDone blocks until the given message cid appears on chain
 func MessageWaitDone(ctx context.Context, plumbing waitPlumbing, msgCid cid.Cid) (*vm.MessageReceipt, error) {
 	l := moresync.NewLatch(1)
@@ -30,3 +34,18 @@ func MessageWaitDone(ctx context.Context, plumbing waitPlumbing, msgCid cid.Cid)
 	l.Wait()
 	return ret, nil
 }
+
+// MessageWaitDoneConfirmed blocks until the given message cid is on chain with
+// at least confidence tipsets on top of its inclusion. Reverted inclusions are
+// waited out.
+func MessageWaitDoneConfirmed(ctx context.Context, plumbing confirmedWaitPlumbing, msgCid cid.Cid, confidence uint64) (*vm.MessageReceipt, error) {
+	var ret *vm.MessageReceipt
+	err := plumbing.MessageWaitConfirmed(ctx, msgCid, confidence, func(_ *block.Block, _ *types.SignedMessage, rcpt *vm.MessageReceipt) error {
+		ret = rcpt
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
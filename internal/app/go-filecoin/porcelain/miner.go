@@ -2,11 +2,14 @@ package porcelain
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 
 	address "github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
@@ -16,6 +19,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
 	"github.com/sbwtw/go-filecoin/internal/pkg/state"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
@@ -41,16 +45,20 @@ type MinerStateView interface {
 	PowerNetworkTotal(ctx context.Context) (*state.NetworkPower, error)
 	MinerClaimedPower(ctx context.Context, miner address.Address) (raw, qa abi.StoragePower, err error)
 	MinerInfo(ctx context.Context, maddr address.Address) (miner.MinerInfo, error)
+	MinerBalances(ctx context.Context, maddr address.Address) (state.MinerBalances, error)
+	MinerSectorExpiration(ctx context.Context, maddr address.Address, sectorNum abi.SectorNumber) (abi.ChainEpoch, bool, error)
 }
 
-// MinerCreate creates a new miner actor for the given account and returns its address.
-// It will wait for the the actor to appear on-chain and add set the address to mining.minerAddress in the config.
+// MinerCreate creates a new miner actor for the given owner and worker accounts and returns its address.
+// It will wait for the the actor to appear on-chain and set the miner, owner and worker addresses
+// in the mining section of the config. The worker defaults to the owner.
 // TODO: add ability to pass in a KeyInfo to store for signing blocks.
 //       See https://github.com/sbwtw/go-filecoin/issues/1843
 func MinerCreate(
 	ctx context.Context,
 	plumbing mcAPI,
 	minerOwnerAddr address.Address,
+	minerWorkerAddr address.Address,
 	gasPrice types.AttoFIL,
 	gasLimit gas.Unit,
 	sealProofType abi.RegisteredProof,
@@ -63,17 +71,12 @@ func MinerCreate(
 			return address.Undef, err
 		}
 	}
-
-	addr, err := plumbing.ConfigGet("mining.minerAddress")
-	if err != nil {
-		return address.Undef, err
-	}
-	if addr != address.Undef {
-		return address.Undef, fmt.Errorf("can only have one miner per node")
+	if minerWorkerAddr == (address.Address{}) {
+		minerWorkerAddr = minerOwnerAddr
 	}
 
 	params := power.CreateMinerParams{
-		Worker:        minerOwnerAddr,
+		Worker:        minerWorkerAddr,
 		Owner:         minerOwnerAddr,
 		Peer:          pid,
 		SealProofType: sealProofType,
@@ -93,19 +96,245 @@ func MinerCreate(
 		return address.Undef, err
 	}
 
+	return waitMinerCreated(ctx, plumbing, smsgCid)
+}
+
+// mcuAPI is the subset of the plumbing.API that MinerCreateUnsigned uses.
+type mcuAPI interface {
+	OutboxNextNonce(ctx context.Context, sender address.Address) (uint64, error)
+}
+
+// MinerCreateUnsigned builds the message creating a new miner actor for an
+// owner whose key is not in the node, for the owner to sign offline and send
+// with `message sendsigned`. MinerAwaitCreate records the miner once the
+// message is sent.
+func MinerCreateUnsigned(
+	ctx context.Context,
+	plumbing mcuAPI,
+	minerOwnerAddr address.Address,
+	minerWorkerAddr address.Address,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	sealProofType abi.RegisteredProof,
+	pid peer.ID,
+	collateral types.AttoFIL,
+) (*types.UnsignedMessage, error) {
+	if minerOwnerAddr.Empty() {
+		return nil, errors.New("owner address required")
+	}
+	if minerWorkerAddr.Empty() {
+		return nil, errors.New("worker address required")
+	}
+
+	// Messages from the owner sent with `message sendsigned` are queued in
+	// the outbox, the message follows them.
+	nonce, err := plumbing.OutboxNextNonce(ctx, minerOwnerAddr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get nonce of owner %s", minerOwnerAddr)
+	}
+
+	params, err := encoding.Encode(&power.CreateMinerParams{
+		Worker:        minerWorkerAddr,
+		Owner:         minerOwnerAddr,
+		Peer:          pid,
+		SealProofType: sealProofType,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return types.NewMeteredMessage(
+		minerOwnerAddr,
+		builtin.StoragePowerActorAddr,
+		nonce,
+		collateral,
+		builtin.MethodsPower.CreateMiner,
+		params,
+		gasPrice,
+		gasLimit,
+	), nil
+}
+
+// mnwAPI is the subset of the plumbing.API that MinerNewWorker uses.
+type mnwAPI interface {
+	WalletNewAddress(protocol address.Protocol) (address.Address, error)
+	MessageSend(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error
+}
+
+// MinerNewWorker generates a new BLS key in the wallet for the worker of a
+// miner about to be created, and sends it funds from the owner, creating its
+// account actor. It returns once the funds are on chain: a miner cannot be
+// created with a worker that has no account actor.
+func MinerNewWorker(
+	ctx context.Context,
+	plumbing mnwAPI,
+	ownerAddr address.Address,
+	funds types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+) (address.Address, error) {
+	workerAddr, err := plumbing.WalletNewAddress(address.BLS)
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "could not generate worker key")
+	}
+
+	msgCid, _, err := plumbing.MessageSend(ctx, ownerAddr, workerAddr, funds, gasPrice, gasLimit, builtin.MethodSend, nil)
+	if err != nil {
+		return address.Undef, errors.Wrapf(err, "failed to fund worker %s", workerAddr)
+	}
+	err = plumbing.MessageWait(ctx, msgCid, func(_ *block.Block, _ *types.SignedMessage, receipt *vm.MessageReceipt) error {
+		if receipt.ExitCode != exitcode.Ok {
+			return errors.Errorf("funding worker %s failed with exit code %d", workerAddr, receipt.ExitCode)
+		}
+		return nil
+	})
+	if err != nil {
+		return address.Undef, err
+	}
+	return workerAddr, nil
+}
+
+// mawAPI is the subset of the plumbing.API that MinerAwaitCreate uses.
+type mawAPI interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ConfigSet(dottedPath string, paramJSON string) error
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error
+}
+
+// MinerAwaitCreate waits for a message creating a miner actor sent outside the
+// node, such as one built by MinerCreateUnsigned and signed offline, and
+// records the miner in the config as MinerCreate does.
+func MinerAwaitCreate(ctx context.Context, plumbing mawAPI, msgCid cid.Cid) (address.Address, error) {
+	return waitMinerCreated(ctx, plumbing, msgCid)
+}
+
+type configGetter interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+}
+
+// NodeMiners returns the addresses of the miner actors the node operates, the
+// one at mining.minerAddress first.
+func NodeMiners(plumbing configGetter) ([]address.Address, error) {
+	primary, miners, err := configuredMiners(plumbing)
+	if err != nil {
+		return nil, err
+	}
+	if primary.Empty() {
+		return nil, nil
+	}
+	addrs := []address.Address{primary}
+	for _, m := range miners {
+		addrs = append(addrs, m.Address)
+	}
+	return addrs, nil
+}
+
+func configuredMiners(plumbing configGetter) (address.Address, []config.MinerConfig, error) {
+	primary, err := plumbing.ConfigGet("mining.minerAddress")
+	if err != nil {
+		return address.Undef, nil, err
+	}
+	primaryAddr, ok := primary.(address.Address)
+	if !ok {
+		return address.Undef, nil, errors.New("problem converting miner address")
+	}
+	miners, err := plumbing.ConfigGet("mining.miners")
+	if err != nil {
+		return address.Undef, nil, err
+	}
+	minerConfigs, ok := miners.([]config.MinerConfig)
+	if !ok {
+		return address.Undef, nil, errors.New("problem converting miners")
+	}
+	return primaryAddr, minerConfigs, nil
+}
+
+type ppAPI interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ConfigSet(dottedPath string, paramJSON string) error
+}
+
+// PledgePolicy returns the policy the node pledges committed-capacity sectors
+// by.
+func PledgePolicy(plumbing configGetter) (config.PledgePolicyConfig, error) {
+	policy, err := plumbing.ConfigGet("mining.pledgePolicy")
+	if err != nil {
+		return config.PledgePolicyConfig{}, err
+	}
+	pledgePolicy, ok := policy.(config.PledgePolicyConfig)
+	if !ok {
+		return config.PledgePolicyConfig{}, errors.New("problem converting pledge policy")
+	}
+	return pledgePolicy, nil
+}
+
+// SetPledgePolicy sets the policy the node pledges committed-capacity sectors
+// by. It takes effect at the next check of the policy.
+func SetPledgePolicy(plumbing ppAPI, policy config.PledgePolicyConfig) error {
+	if policy.Enabled && policy.MaxSealing == 0 {
+		return errors.New("pledge policy must allow at least one sector sealing")
+	}
+	if policy.Deposit.LessThan(types.ZeroAttoFIL) {
+		return errors.New("pledge policy deposit must not be negative")
+	}
+
+	policyJSON, err := json.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return plumbing.ConfigSet("mining.pledgePolicy", string(policyJSON))
+}
+
+// recordMiner records a created miner in the config, as the node's miner if
+// it has none and as a further miner otherwise.
+func recordMiner(plumbing mawAPI, minerAddr, owner, worker address.Address) error {
+	primary, miners, err := configuredMiners(plumbing)
+	if err != nil {
+		return err
+	}
+
+	if primary.Empty() {
+		if err := plumbing.ConfigSet("mining.minerAddress", minerAddr.String()); err != nil {
+			return err
+		}
+		if err := plumbing.ConfigSet("mining.ownerAddress", owner.String()); err != nil {
+			return err
+		}
+		return plumbing.ConfigSet("mining.workerAddress", worker.String())
+	}
+
+	miners = append(miners, config.MinerConfig{Address: minerAddr, Owner: owner, Worker: worker})
+	minersJSON, err := json.Marshal(miners)
+	if err != nil {
+		return err
+	}
+	return plumbing.ConfigSet("mining.miners", string(minersJSON))
+}
+
+// waitMinerCreated waits for the create miner message and records the
+// created miner with its owner and worker in the config.
+func waitMinerCreated(ctx context.Context, plumbing mawAPI, msgCid cid.Cid) (address.Address, error) {
+	var params power.CreateMinerParams
 	var result power.CreateMinerReturn
-	err = plumbing.MessageWait(ctx, smsgCid, func(blk *block.Block, smsg *types.SignedMessage, receipt *vm.MessageReceipt) (err error) {
+	err := plumbing.MessageWait(ctx, msgCid, func(blk *block.Block, smsg *types.SignedMessage, receipt *vm.MessageReceipt) (err error) {
 		if receipt.ExitCode != exitcode.Ok {
 			// Dragons: do we want to have this back?
 			return fmt.Errorf("Error executing actor code (exitcode: %d)", receipt.ExitCode)
 		}
+		if smsg.Message.To != builtin.StoragePowerActorAddr || smsg.Message.Method != builtin.MethodsPower.CreateMiner {
+			return errors.Errorf("message %s does not create a miner", msgCid)
+		}
+		if err := encoding.Decode(smsg.Message.Params, &params); err != nil {
+			return err
+		}
 		return encoding.Decode(receipt.ReturnValue, &result)
 	})
 	if err != nil {
 		return address.Undef, err
 	}
 
-	if err = plumbing.ConfigSet("mining.minerAddress", result.RobustAddress.String()); err != nil {
+	if err = recordMiner(plumbing, result.RobustAddress, params.Owner, params.Worker); err != nil {
 		return address.Undef, err
 	}
 
@@ -115,7 +344,7 @@ func MinerCreate(
 // mpcAPI is the subset of the plumbing.API that MinerPreviewCreate uses.
 type mpcAPI interface {
 	ConfigGet(dottedPath string) (interface{}, error)
-	MessagePreview(ctx context.Context, from, to address.Address, method abi.MethodNum, params ...interface{}) (gas.Unit, error)
+	MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (gas.Unit, error)
 	NetworkGetPeerID() peer.ID
 	WalletDefaultAddress() (address.Address, error)
 }
@@ -125,6 +354,7 @@ func MinerPreviewCreate(
 	ctx context.Context,
 	plumbing mpcAPI,
 	fromAddr address.Address,
+	workerAddr address.Address,
 	sectorSize abi.SectorSize,
 	pid peer.ID,
 ) (usedGas gas.Unit, err error) {
@@ -135,21 +365,33 @@ func MinerPreviewCreate(
 		}
 	}
 
+	if workerAddr.Empty() {
+		workerAddr = fromAddr
+	}
+
 	if pid == "" {
 		pid = plumbing.NetworkGetPeerID()
 	}
 
-	if _, err := plumbing.ConfigGet("mining.minerAddress"); err != nil {
-		return gas.NewGas(0), fmt.Errorf("can only have one miner per node")
+	sealProofType, err := ffiwrapper.SealProofTypeFromSectorSize(sectorSize)
+	if err != nil {
+		return gas.NewGas(0), err
+	}
+
+	params := power.CreateMinerParams{
+		Worker:        workerAddr,
+		Owner:         fromAddr,
+		Peer:          pid,
+		SealProofType: sealProofType,
 	}
 
 	usedGas, err = plumbing.MessagePreview(
 		ctx,
 		fromAddr,
-		builtin.StorageMarketActorAddr,
+		builtin.StoragePowerActorAddr,
+		types.ZeroAttoFIL,
 		builtin.MethodsPower.CreateMiner,
-		sectorSize,
-		pid,
+		&params,
 	)
 	if err != nil {
 		return gas.NewGas(0), errors.Wrap(err, "Could not create miner. Please consult the documentation to setup your wallet and genesis block correctly")
@@ -264,30 +506,20 @@ type mwapi interface {
 	MessageSend(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
 }
 
-// MinerSetWorkerAddress sets the worker address of the miner actor to the provided new address,
-// waits for the message to appear on chain and then sets miner.workerAddr config to the new address.
+// MinerSetWorkerAddress sets the worker address of a miner actor of the node
+// to the provided new address. An empty minerAddr is the node's primary miner.
 func MinerSetWorkerAddress(
 	ctx context.Context,
 	plumbing mwapi,
+	minerAddr address.Address,
 	workerAddr address.Address,
 	gasPrice types.AttoFIL,
 	gasLimit gas.Unit,
 ) (cid.Cid, error) {
-
-	retVal, err := plumbing.ConfigGet("mining.minerAddress")
+	minerAddr, state, err := nodeMinerStateView(plumbing, minerAddr)
 	if err != nil {
 		return cid.Undef, err
 	}
-	minerAddr, ok := retVal.(address.Address)
-	if !ok {
-		return cid.Undef, errors.New("problem converting miner address")
-	}
-
-	head := plumbing.ChainHeadKey()
-	state, err := plumbing.MinerStateView(head)
-	if err != nil {
-		return cid.Undef, errors.Wrap(err, "could not get miner owner address")
-	}
 
 	owner, _, err := state.MinerControlAddresses(ctx, minerAddr)
 	if err != nil {
@@ -305,3 +537,253 @@ func MinerSetWorkerAddress(
 		&workerAddr)
 	return c, err
 }
+
+// MinerPreviewSetWorkerAddress previews the Gas cost of setting the worker
+// address of a miner actor of the node. An empty minerAddr is the node's
+// primary miner.
+func MinerPreviewSetWorkerAddress(ctx context.Context, plumbing mlcAPI, minerAddr address.Address, workerAddr address.Address) (gas.Unit, error) {
+	minerAddr, view, err := nodeMinerStateView(plumbing, minerAddr)
+	if err != nil {
+		return gas.NewGas(0), err
+	}
+	owner, _, err := view.MinerControlAddresses(ctx, minerAddr)
+	if err != nil {
+		return gas.NewGas(0), errors.Wrap(err, "could not get miner owner address")
+	}
+	return plumbing.MessagePreview(ctx, owner, minerAddr, types.ZeroAttoFIL, builtin.MethodsMiner.ChangeWorkerAddress, &workerAddr)
+}
+
+// mlcAPI is the subset of the plumbing.API that the miner lifecycle functions use.
+type mlcAPI interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ChainHeadKey() block.TipSetKey
+	MinerStateView(baseKey block.TipSetKey) (MinerStateView, error)
+	MessagePreview(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (gas.Unit, error)
+	MessageSend(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
+	MessageWait(ctx context.Context, msgCid cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error
+}
+
+// MinerMessageResult is the outcome of a message to a miner actor of the node,
+// or of its preview.
+type MinerMessageResult struct {
+	Cid     cid.Cid
+	GasUsed gas.Unit
+	Preview bool
+	// Balances are the balances of the miner once the message is on chain,
+	// or at the head when previewing.
+	Balances state.MinerBalances
+}
+
+// MinerWithdrawBalance withdraws amount from the available balance of a
+// miner actor of the node to its owner. With preview set it only previews the
+// Gas cost of the withdrawal. An empty minerAddr is the node's primary miner.
+func MinerWithdrawBalance(
+	ctx context.Context,
+	plumbing mlcAPI,
+	minerAddr address.Address,
+	amount types.AttoFIL,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	preview bool,
+) (MinerMessageResult, error) {
+	minerAddr, view, err := nodeMinerStateView(plumbing, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, err
+	}
+	owner, _, err := view.MinerControlAddresses(ctx, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner owner address")
+	}
+	balances, err := view.MinerBalances(ctx, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner balances")
+	}
+
+	if !amount.GreaterThan(types.ZeroAttoFIL) {
+		return MinerMessageResult{}, errors.New("amount to withdraw must be positive")
+	}
+	if amount.GreaterThan(balances.Available) {
+		return MinerMessageResult{}, errors.Errorf("amount %s exceeds available balance %s of miner %s", amount, balances.Available, minerAddr)
+	}
+
+	params := miner.WithdrawBalanceParams{AmountRequested: amount}
+	return sendToMiner(ctx, plumbing, owner, minerAddr, gasPrice, gasLimit, preview, builtin.MethodsMiner.WithdrawBalance, &params, balances)
+}
+
+// MinerExtendSectorExpiration extends the expiration of a sector of a miner
+// actor of the node to the given epoch. With preview set it only previews the
+// Gas cost of the extension. An empty minerAddr is the node's primary miner.
+func MinerExtendSectorExpiration(
+	ctx context.Context,
+	plumbing mlcAPI,
+	minerAddr address.Address,
+	sector abi.SectorNumber,
+	expiration abi.ChainEpoch,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	preview bool,
+) (MinerMessageResult, error) {
+	minerAddr, view, err := nodeMinerStateView(plumbing, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, err
+	}
+	_, worker, err := view.MinerControlAddresses(ctx, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner worker address")
+	}
+	balances, err := view.MinerBalances(ctx, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner balances")
+	}
+
+	current, found, err := view.MinerSectorExpiration(ctx, minerAddr, sector)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrapf(err, "could not get expiration of sector %d", sector)
+	}
+	if !found {
+		return MinerMessageResult{}, errors.Errorf("sector %d of miner %s is not on chain", sector, minerAddr)
+	}
+	if expiration <= current {
+		return MinerMessageResult{}, errors.Errorf("new expiration %d of sector %d must be after its current expiration %d", expiration, sector, current)
+	}
+
+	params := miner.ExtendSectorExpirationParams{SectorNumber: sector, NewExpiration: expiration}
+	return sendToMiner(ctx, plumbing, worker, minerAddr, gasPrice, gasLimit, preview, builtin.MethodsMiner.ExtendSectorExpiration, &params, balances)
+}
+
+// MinerTerminateSectors terminates sectors of a miner actor of the node. With
+// preview set it only previews the Gas cost of the termination. An empty
+// minerAddr is the node's primary miner.
+func MinerTerminateSectors(
+	ctx context.Context,
+	plumbing mlcAPI,
+	minerAddr address.Address,
+	sectors []abi.SectorNumber,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	preview bool,
+) (MinerMessageResult, error) {
+	minerAddr, view, err := nodeMinerStateView(plumbing, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, err
+	}
+	_, worker, err := view.MinerControlAddresses(ctx, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner worker address")
+	}
+	balances, err := view.MinerBalances(ctx, minerAddr)
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner balances")
+	}
+
+	if len(sectors) == 0 {
+		return MinerMessageResult{}, errors.New("no sectors to terminate")
+	}
+	numbers := make([]uint64, len(sectors))
+	for i, sector := range sectors {
+		_, found, err := view.MinerSectorExpiration(ctx, minerAddr, sector)
+		if err != nil {
+			return MinerMessageResult{}, errors.Wrapf(err, "could not get sector %d", sector)
+		}
+		if !found {
+			return MinerMessageResult{}, errors.Errorf("sector %d of miner %s is not on chain", sector, minerAddr)
+		}
+		numbers[i] = uint64(sector)
+	}
+	bf, err := bitfield.NewFromSet(numbers)
+	if err != nil {
+		return MinerMessageResult{}, err
+	}
+
+	params := miner.TerminateSectorsParams{Sectors: bf}
+	return sendToMiner(ctx, plumbing, worker, minerAddr, gasPrice, gasLimit, preview, builtin.MethodsMiner.TerminateSectors, &params, balances)
+}
+
+// nodeMinerStateView returns the address of a miner actor the node operates,
+// the one at mining.minerAddress if minerAddr is empty, and a state view at
+// the head.
+// minerViewAPI is the subset of the plumbing.API that nodeMinerStateView uses.
+type minerViewAPI interface {
+	ConfigGet(dottedPath string) (interface{}, error)
+	ChainHeadKey() block.TipSetKey
+	MinerStateView(baseKey block.TipSetKey) (MinerStateView, error)
+}
+
+func nodeMinerStateView(plumbing minerViewAPI, minerAddr address.Address) (address.Address, MinerStateView, error) {
+	miners, err := NodeMiners(plumbing)
+	if err != nil {
+		return address.Undef, nil, err
+	}
+	if len(miners) == 0 {
+		return address.Undef, nil, errors.New("node has no miner")
+	}
+	if minerAddr.Empty() {
+		minerAddr = miners[0]
+	} else if !containsAddress(miners, minerAddr) {
+		return address.Undef, nil, errors.Errorf("node does not operate miner %s", minerAddr)
+	}
+
+	view, err := plumbing.MinerStateView(plumbing.ChainHeadKey())
+	if err != nil {
+		return address.Undef, nil, errors.Wrap(err, "could not get miner state")
+	}
+	return minerAddr, view, nil
+}
+
+func containsAddress(addrs []address.Address, addr address.Address) bool {
+	for _, a := range addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// sendToMiner previews or sends a message to the miner actor. Sent messages
+// are waited for, and the balances of the miner read once they are on chain.
+// Previews report the balances given.
+func sendToMiner(
+	ctx context.Context,
+	plumbing mlcAPI,
+	from, minerAddr address.Address,
+	gasPrice types.AttoFIL,
+	gasLimit gas.Unit,
+	preview bool,
+	method abi.MethodNum,
+	params interface{},
+	balances state.MinerBalances,
+) (MinerMessageResult, error) {
+	if preview {
+		usedGas, err := plumbing.MessagePreview(ctx, from, minerAddr, types.ZeroAttoFIL, method, params)
+		if err != nil {
+			return MinerMessageResult{}, err
+		}
+		return MinerMessageResult{GasUsed: usedGas, Preview: true, Balances: balances}, nil
+	}
+
+	msgCid, _, err := plumbing.MessageSend(ctx, from, minerAddr, types.ZeroAttoFIL, gasPrice, gasLimit, method, params)
+	if err != nil {
+		return MinerMessageResult{}, err
+	}
+
+	var usedGas gas.Unit
+	err = plumbing.MessageWait(ctx, msgCid, func(_ *block.Block, _ *types.SignedMessage, receipt *vm.MessageReceipt) error {
+		if receipt.ExitCode != exitcode.Ok {
+			return fmt.Errorf("Error executing actor code (exitcode: %d)", receipt.ExitCode)
+		}
+		usedGas = receipt.GasUsed
+		return nil
+	})
+	if err != nil {
+		return MinerMessageResult{}, err
+	}
+
+	view, err := plumbing.MinerStateView(plumbing.ChainHeadKey())
+	if err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner state")
+	}
+	if balances, err = view.MinerBalances(ctx, minerAddr); err != nil {
+		return MinerMessageResult{}, errors.Wrap(err, "could not get miner balances")
+	}
+	return MinerMessageResult{Cid: msgCid, GasUsed: usedGas, Balances: balances}, nil
+}
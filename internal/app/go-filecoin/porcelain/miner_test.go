@@ -9,6 +9,8 @@ import (
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
 	"github.com/ipfs/go-cid"
@@ -18,6 +20,7 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/cfg"
 	. "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/porcelain"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/constants"
 	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
 	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
@@ -37,6 +40,8 @@ type minerCreate struct {
 	wallet  *wallet.Wallet
 	msgCid  cid.Cid
 	msgFail bool
+
+	owner, worker address.Address
 }
 
 func newMinerCreate(t *testing.T, msgFail bool, address address.Address) *minerCreate {
@@ -65,6 +70,8 @@ func (mpc *minerCreate) MessageSend(ctx context.Context, from, to address.Addres
 		return cid.Cid{}, nil, errors.New("test Error")
 	}
 	mpc.msgCid = types.CidFromString(mpc.testing, "somecid")
+	createParams := params.(*power.CreateMinerParams)
+	mpc.owner, mpc.worker = createParams.Owner, createParams.Worker
 
 	return mpc.msgCid, nil, nil
 }
@@ -84,11 +91,19 @@ func (mpc *minerCreate) MessageWait(ctx context.Context, msgCid cid.Cid, cb func
 		return err
 	}
 
+	params, err := encoding.Encode(&power.CreateMinerParams{Owner: mpc.owner, Worker: mpc.worker})
+	if err != nil {
+		return err
+	}
+	smsg := &types.SignedMessage{
+		Message: *types.NewUnsignedMessage(mpc.owner, builtin.StoragePowerActorAddr, 0, types.ZeroAttoFIL, builtin.MethodsPower.CreateMiner, params),
+	}
+
 	receipt := vm.MessageReceipt{
 		ReturnValue: value,
 		ExitCode:    exitcode.Ok,
 	}
-	return cb(nil, nil, &receipt)
+	return cb(nil, smsg, &receipt)
 }
 
 func (mpc *minerCreate) WalletDefaultAddress() (address.Address, error) {
@@ -108,6 +123,7 @@ func TestMinerCreate(t *testing.T) {
 			ctx,
 			plumbing,
 			address.Address{},
+			address.Address{},
 			types.NewGasPrice(0),
 			gas.NewGas(100),
 			constants.DevSealProofType,
@@ -118,6 +134,59 @@ func TestMinerCreate(t *testing.T) {
 		assert.Equal(t, expectedAddress, addr)
 	})
 
+	t.Run("records owner and separate worker", func(t *testing.T) {
+		ctx := context.Background()
+		expectedAddress := vmaddr.NewForTestGetter()()
+		plumbing := newMinerCreate(t, false, expectedAddress)
+		owner := vmaddr.RequireIDAddress(t, 101)
+		worker := vmaddr.RequireIDAddress(t, 102)
+
+		_, err := MinerCreate(
+			ctx,
+			plumbing,
+			owner,
+			worker,
+			types.NewGasPrice(0),
+			gas.NewGas(100),
+			constants.DevSealProofType,
+			"",
+			types.NewAttoFILFromFIL(1),
+		)
+		require.NoError(t, err)
+
+		for key, expected := range map[string]address.Address{
+			"mining.minerAddress":  expectedAddress,
+			"mining.ownerAddress":  owner,
+			"mining.workerAddress": worker,
+		} {
+			recorded, err := plumbing.ConfigGet(key)
+			require.NoError(t, err)
+			assert.Equal(t, expected, recorded, key)
+		}
+	})
+
+	t.Run("records further miners", func(t *testing.T) {
+		ctx := context.Background()
+		first := vmaddr.RequireIDAddress(t, 103)
+		second := vmaddr.RequireIDAddress(t, 104)
+		owner := vmaddr.RequireIDAddress(t, 101)
+		plumbing := newMinerCreate(t, false, first)
+
+		_, err := MinerCreate(ctx, plumbing, owner, address.Undef, types.NewGasPrice(0), gas.NewGas(100), constants.DevSealProofType, "", types.NewAttoFILFromFIL(1))
+		require.NoError(t, err)
+		plumbing.address = second
+		_, err = MinerCreate(ctx, plumbing, owner, address.Undef, types.NewGasPrice(0), gas.NewGas(100), constants.DevSealProofType, "", types.NewAttoFILFromFIL(1))
+		require.NoError(t, err)
+
+		miners, err := NodeMiners(plumbing)
+		require.NoError(t, err)
+		assert.Equal(t, []address.Address{first, second}, miners)
+
+		recorded, err := plumbing.ConfigGet("mining.miners")
+		require.NoError(t, err)
+		assert.Equal(t, []config.MinerConfig{{Address: second, Owner: owner, Worker: owner}}, recorded)
+	})
+
 	t.Run("failure to send", func(t *testing.T) {
 		ctx := context.Background()
 		plumbing := newMinerCreate(t, true, address.Address{})
@@ -127,6 +196,7 @@ func TestMinerCreate(t *testing.T) {
 			ctx,
 			plumbing,
 			address.Address{},
+			address.Address{},
 			types.NewGasPrice(0),
 			gas.NewGas(100),
 			constants.DevSealProofType,
@@ -137,6 +207,83 @@ func TestMinerCreate(t *testing.T) {
 	})
 }
 
+type mCreateUnsignedPlumbing struct {
+	nonce uint64
+}
+
+func (p *mCreateUnsignedPlumbing) OutboxNextNonce(_ context.Context, _ address.Address) (uint64, error) {
+	return p.nonce, nil
+}
+
+func TestMinerCreateUnsigned(t *testing.T) {
+	tf.UnitTest(t)
+	owner := vmaddr.RequireIDAddress(t, 101)
+	worker := vmaddr.RequireIDAddress(t, 102)
+	plumbing := &mCreateUnsignedPlumbing{nonce: 7}
+
+	msg, err := MinerCreateUnsigned(context.Background(), plumbing, owner, worker, types.NewGasPrice(1), gas.NewGas(100), constants.DevSealProofType, "", types.NewAttoFILFromFIL(1))
+	require.NoError(t, err)
+	assert.Equal(t, owner, msg.From)
+	assert.Equal(t, builtin.StoragePowerActorAddr, msg.To)
+	assert.Equal(t, uint64(7), msg.CallSeqNum)
+	assert.Equal(t, builtin.MethodsPower.CreateMiner, msg.Method)
+	assert.Equal(t, types.NewAttoFILFromFIL(1), msg.Value)
+
+	var params power.CreateMinerParams
+	require.NoError(t, encoding.Decode(msg.Params, &params))
+	assert.Equal(t, owner, params.Owner)
+	assert.Equal(t, worker, params.Worker)
+}
+
+type mNewWorkerPlumbing struct {
+	exitCode exitcode.ExitCode
+	worker   address.Address
+
+	sentFrom, sentTo address.Address
+	sentValue        types.AttoFIL
+	sentMethod       abi.MethodNum
+}
+
+func (p *mNewWorkerPlumbing) WalletNewAddress(protocol address.Protocol) (address.Address, error) {
+	if protocol != address.BLS {
+		return address.Undef, errors.New("worker key must be BLS")
+	}
+	return p.worker, nil
+}
+
+func (p *mNewWorkerPlumbing) MessageSend(_ context.Context, from, to address.Address, value types.AttoFIL, _ types.AttoFIL, _ gas.Unit, method abi.MethodNum, _ interface{}) (cid.Cid, chan error, error) {
+	p.sentFrom, p.sentTo, p.sentValue, p.sentMethod = from, to, value, method
+	return types.NewCidForTestGetter()(), nil, nil
+}
+
+func (p *mNewWorkerPlumbing) MessageWait(_ context.Context, _ cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
+	return cb(nil, nil, &vm.MessageReceipt{ExitCode: p.exitCode})
+}
+
+func TestMinerNewWorker(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	owner := vmaddr.RequireIDAddress(t, 101)
+	funds := types.NewAttoFILFromFIL(2)
+
+	t.Run("funds the new worker from the owner", func(t *testing.T) {
+		plumbing := &mNewWorkerPlumbing{worker: vmaddr.NewForTestGetter()()}
+		worker, err := MinerNewWorker(ctx, plumbing, owner, funds, types.NewGasPrice(1), gas.NewGas(100))
+		require.NoError(t, err)
+		assert.Equal(t, plumbing.worker, worker)
+		assert.Equal(t, owner, plumbing.sentFrom)
+		assert.Equal(t, worker, plumbing.sentTo)
+		assert.Equal(t, funds, plumbing.sentValue)
+		assert.Equal(t, builtin.MethodSend, plumbing.sentMethod)
+	})
+
+	t.Run("fails when the funds are not sent", func(t *testing.T) {
+		plumbing := &mNewWorkerPlumbing{worker: vmaddr.NewForTestGetter()(), exitCode: exitcode.SysErrInsufficientFunds}
+		_, err := MinerNewWorker(ctx, plumbing, owner, funds, types.NewGasPrice(1), gas.NewGas(100))
+		assert.Error(t, err)
+	})
+}
+
 type mStatusPlumbing struct {
 	ts                   block.TipSet
 	head                 block.TipSetKey
@@ -191,6 +338,10 @@ type mSetWorkerPlumbing struct {
 	head                                         block.TipSetKey
 	getStatusFail, msgFail, msgWaitFail, cfgFail bool
 	minerAddr, ownerAddr, workerAddr             address.Address
+	extraMiner                                   address.Address
+
+	sentFrom, sentTo address.Address
+	sentWorker       address.Address
 }
 
 func (p *mSetWorkerPlumbing) ChainHeadKey() block.TipSetKey {
@@ -208,6 +359,10 @@ func (p *mSetWorkerPlumbing) MinerStateView(baseKey block.TipSetKey) (MinerState
 				Owner:  p.ownerAddr,
 				Worker: p.workerAddr,
 			},
+			p.extraMiner: {
+				Owner:  p.ownerAddr,
+				Worker: p.workerAddr,
+			},
 		},
 	}, nil
 }
@@ -217,6 +372,8 @@ func (p *mSetWorkerPlumbing) MessageSend(ctx context.Context, from, to address.A
 	if p.msgFail {
 		return cid.Cid{}, nil, errors.New("MsgFail")
 	}
+	p.sentFrom, p.sentTo = from, to
+	p.sentWorker = *params.(*address.Address)
 	return types.EmptyMessagesCID, nil, nil
 }
 
@@ -231,8 +388,14 @@ func (p *mSetWorkerPlumbing) ConfigGet(dottedKey string) (interface{}, error) {
 	if p.cfgFail {
 		return address.Undef, errors.New("ConfigGet failed")
 	}
-	if dottedKey == "mining.minerAddress" {
+	switch dottedKey {
+	case "mining.minerAddress":
 		return p.minerAddr, nil
+	case "mining.miners":
+		if p.extraMiner.Empty() {
+			return []config.MinerConfig{}, nil
+		}
+		return []config.MinerConfig{{Address: p.extraMiner}}, nil
 	}
 	return address.Undef, fmt.Errorf("unknown config %s", dottedKey)
 }
@@ -253,9 +416,28 @@ func TestMinerSetWorkerAddress(t *testing.T) {
 			minerAddr:  minerAddr,
 		}
 
-		_, err := MinerSetWorkerAddress(context.Background(), plumbing, workerAddr, gprice, glimit)
+		_, err := MinerSetWorkerAddress(context.Background(), plumbing, address.Undef, workerAddr, gprice, glimit)
+		assert.NoError(t, err)
+		assert.Equal(t, minerOwner, plumbing.sentFrom)
+		assert.Equal(t, minerAddr, plumbing.sentTo)
+		assert.Equal(t, workerAddr, plumbing.sentWorker)
+	})
+
+	t.Run("Sets the worker address of a further miner of the node", func(t *testing.T) {
+		extraMiner := vmaddr.RequireIDAddress(t, 103)
+		plumbing := &mSetWorkerPlumbing{
+			workerAddr: workerAddr,
+			ownerAddr:  minerOwner,
+			minerAddr:  minerAddr,
+			extraMiner: extraMiner,
+		}
+
+		_, err := MinerSetWorkerAddress(context.Background(), plumbing, extraMiner, workerAddr, gprice, glimit)
 		assert.NoError(t, err)
-		assert.Equal(t, workerAddr, plumbing.workerAddr)
+		assert.Equal(t, extraMiner, plumbing.sentTo)
+
+		_, err = MinerSetWorkerAddress(context.Background(), plumbing, vmaddr.RequireIDAddress(t, 104), workerAddr, gprice, glimit)
+		assert.Error(t, err)
 	})
 
 	testCases := []struct {
@@ -282,9 +464,152 @@ func TestMinerSetWorkerAddress(t *testing.T) {
 
 	for _, test := range testCases {
 		t.Run(test.name, func(t *testing.T) {
-			_, err := MinerSetWorkerAddress(context.Background(), test.plumbing, workerAddr, gprice, glimit)
+			_, err := MinerSetWorkerAddress(context.Background(), test.plumbing, address.Undef, workerAddr, gprice, glimit)
 			assert.Error(t, err, test.error)
-			assert.Empty(t, test.plumbing.workerAddr)
+			assert.Empty(t, test.plumbing.sentWorker)
 		})
 	}
 }
+
+type mLifecyclePlumbing struct {
+	minerAddr, ownerAddr, workerAddr address.Address
+	miner                            *state.FakeMinerState
+
+	sentFrom    address.Address
+	sentMethod  abi.MethodNum
+	previewed   bool
+	receiptCode exitcode.ExitCode
+}
+
+func (p *mLifecyclePlumbing) ConfigGet(dottedKey string) (interface{}, error) {
+	switch dottedKey {
+	case "mining.minerAddress":
+		return p.minerAddr, nil
+	case "mining.miners":
+		return []config.MinerConfig{}, nil
+	}
+	return nil, fmt.Errorf("unknown config %s", dottedKey)
+}
+
+func (p *mLifecyclePlumbing) ChainHeadKey() block.TipSetKey {
+	return block.NewTipSetKey()
+}
+
+func (p *mLifecyclePlumbing) MinerStateView(baseKey block.TipSetKey) (MinerStateView, error) {
+	p.miner.Owner = p.ownerAddr
+	p.miner.Worker = p.workerAddr
+	return &state.FakeStateView{
+		Miners: map[address.Address]*state.FakeMinerState{p.minerAddr: p.miner},
+	}, nil
+}
+
+func (p *mLifecyclePlumbing) MessagePreview(_ context.Context, from, _ address.Address, _ types.AttoFIL, method abi.MethodNum, _ interface{}) (gas.Unit, error) {
+	p.sentFrom, p.sentMethod, p.previewed = from, method, true
+	return gas.NewGas(7), nil
+}
+
+func (p *mLifecyclePlumbing) MessageSend(_ context.Context, from, _ address.Address, _ types.AttoFIL, _ types.AttoFIL, _ gas.Unit, method abi.MethodNum, _ interface{}) (cid.Cid, chan error, error) {
+	p.sentFrom, p.sentMethod = from, method
+	return types.EmptyMessagesCID, nil, nil
+}
+
+func (p *mLifecyclePlumbing) MessageWait(_ context.Context, _ cid.Cid, cb func(*block.Block, *types.SignedMessage, *vm.MessageReceipt) error) error {
+	// the withdrawal lands on chain
+	p.miner.Balances.Available = big.Zero()
+	return cb(&block.Block{}, &types.SignedMessage{}, &vm.MessageReceipt{ExitCode: p.receiptCode, GasUsed: gas.NewGas(5)})
+}
+
+func TestMinerLifecycleMessages(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	newPlumbing := func() *mLifecyclePlumbing {
+		return &mLifecyclePlumbing{
+			minerAddr:  vmaddr.RequireIDAddress(t, 100),
+			ownerAddr:  vmaddr.RequireIDAddress(t, 101),
+			workerAddr: vmaddr.RequireIDAddress(t, 102),
+			miner: &state.FakeMinerState{
+				Balances: state.MinerBalances{Available: abi.NewTokenAmount(10)},
+				Sectors: []miner.SectorOnChainInfo{
+					{Info: miner.SectorPreCommitInfo{SectorNumber: 1, Expiration: 1000}},
+				},
+			},
+		}
+	}
+
+	t.Run("withdraw is sent from the owner and reports resulting balances", func(t *testing.T) {
+		plumbing := newPlumbing()
+		res, err := MinerWithdrawBalance(ctx, plumbing, address.Undef, abi.NewTokenAmount(10), types.ZeroAttoFIL, gas.NewGas(100), false)
+		require.NoError(t, err)
+		assert.Equal(t, plumbing.ownerAddr, plumbing.sentFrom)
+		assert.Equal(t, builtin.MethodsMiner.WithdrawBalance, plumbing.sentMethod)
+		assert.Equal(t, types.EmptyMessagesCID, res.Cid)
+		assert.Equal(t, gas.NewGas(5), res.GasUsed)
+		assert.Equal(t, big.Zero(), res.Balances.Available)
+	})
+
+	t.Run("withdraw of more than available is rejected", func(t *testing.T) {
+		plumbing := newPlumbing()
+		_, err := MinerWithdrawBalance(ctx, plumbing, address.Undef, abi.NewTokenAmount(11), types.ZeroAttoFIL, gas.NewGas(100), false)
+		assert.Error(t, err)
+		assert.Equal(t, abi.MethodNum(0), plumbing.sentMethod)
+	})
+
+	t.Run("failed receipt is an error", func(t *testing.T) {
+		plumbing := newPlumbing()
+		plumbing.receiptCode = exitcode.ErrForbidden
+		_, err := MinerWithdrawBalance(ctx, plumbing, address.Undef, abi.NewTokenAmount(1), types.ZeroAttoFIL, gas.NewGas(100), false)
+		assert.Error(t, err)
+	})
+
+	t.Run("extend previews from the worker", func(t *testing.T) {
+		plumbing := newPlumbing()
+		res, err := MinerExtendSectorExpiration(ctx, plumbing, address.Undef, 1, 2000, types.ZeroAttoFIL, gas.NewGas(100), true)
+		require.NoError(t, err)
+		assert.True(t, plumbing.previewed)
+		assert.Equal(t, plumbing.workerAddr, plumbing.sentFrom)
+		assert.Equal(t, builtin.MethodsMiner.ExtendSectorExpiration, plumbing.sentMethod)
+		assert.True(t, res.Preview)
+		assert.Equal(t, gas.NewGas(7), res.GasUsed)
+		assert.Equal(t, abi.NewTokenAmount(10), res.Balances.Available)
+	})
+
+	t.Run("extend requires a later expiration of a sector on chain", func(t *testing.T) {
+		plumbing := newPlumbing()
+		_, err := MinerExtendSectorExpiration(ctx, plumbing, address.Undef, 1, 1000, types.ZeroAttoFIL, gas.NewGas(100), true)
+		assert.Error(t, err)
+		_, err = MinerExtendSectorExpiration(ctx, plumbing, address.Undef, 2, 2000, types.ZeroAttoFIL, gas.NewGas(100), true)
+		assert.Error(t, err)
+		assert.False(t, plumbing.previewed)
+	})
+
+	t.Run("terminate requires sectors on chain", func(t *testing.T) {
+		plumbing := newPlumbing()
+		_, err := MinerTerminateSectors(ctx, plumbing, address.Undef, []abi.SectorNumber{1, 2}, types.ZeroAttoFIL, gas.NewGas(100), true)
+		assert.Error(t, err)
+
+		_, err = MinerTerminateSectors(ctx, plumbing, address.Undef, []abi.SectorNumber{1}, types.ZeroAttoFIL, gas.NewGas(100), true)
+		require.NoError(t, err)
+		assert.Equal(t, builtin.MethodsMiner.TerminateSectors, plumbing.sentMethod)
+	})
+}
+
+func TestPledgePolicy(t *testing.T) {
+	tf.UnitTest(t)
+	plumbing := newMinerCreate(t, false, address.Undef)
+
+	policy, err := PledgePolicy(plumbing)
+	require.NoError(t, err)
+	assert.False(t, policy.Enabled)
+
+	policy.Enabled = true
+	policy.MaxSealing = 0
+	assert.Error(t, SetPledgePolicy(plumbing, policy))
+
+	policy.MaxSealing = 4
+	policy.Deposit = types.NewAttoFILFromFIL(2)
+	require.NoError(t, SetPledgePolicy(plumbing, policy))
+
+	recorded, err := PledgePolicy(plumbing)
+	require.NoError(t, err)
+	assert.Equal(t, config.PledgePolicyConfig{Enabled: true, MaxSealing: 4, Deposit: types.NewAttoFILFromFIL(2)}, recorded)
+}
@@ -0,0 +1,204 @@
+package chain
+
+import (
+	"context"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+)
+
+// Types of head changes.
+const (
+	// HeadChangeCurrent reports the head at the time of subscription.
+	HeadChangeCurrent = "current"
+	// HeadChangeRevert reports a tipset removed from the chain by a reorg.
+	HeadChangeRevert = "revert"
+	// HeadChangeApply reports a tipset added to the chain.
+	HeadChangeApply = "apply"
+)
+
+// HeadChange is a tipset added to or removed from the chain as its head
+// changes.
+type HeadChange struct {
+	Type   string
+	Key    block.TipSetKey
+	Height abi.ChainEpoch
+	Blocks []*block.Block
+}
+
+func newHeadChange(changeType string, ts block.TipSet) *HeadChange {
+	return &HeadChange{
+		Type:   changeType,
+		Key:    ts.Key(),
+		Height: ts.At(0).Height,
+		Blocks: ts.ToSlice(),
+	}
+}
+
+// TipSet returns the tipset of the change.
+func (hc *HeadChange) TipSet() (block.TipSet, error) {
+	return block.NewTipSet(hc.Blocks...)
+}
+
+// HeadChangesMaxResume is the largest number of epochs below the head a
+// client may resume head changes from.
+const HeadChangesMaxResume = miner.ChainFinalityish
+
+// HeadChanges returns a channel of the changes to the head of the chain,
+// batched by head update. A batch reverts the tipsets leaving the chain,
+// highest first, then applies the tipsets joining it, lowest first.
+//
+// The first batch brings a client that last saw the tipset at `from` to the
+// current head, so a client may resume after a disconnect, as long as the
+// chain from `from` to the head forks no more than HeadChangesMaxResume
+// epochs below the head. With an empty `from` it holds just the current head.
+// Heads set while the client is slow to read are merged into the next batch.
+// The channel is closed when ctx is done or the store stops.
+func (store *Store) HeadChanges(ctx context.Context, from block.TipSetKey) (<-chan []*HeadChange, error) {
+	head, err := store.GetTipSet(store.GetHead())
+	if err != nil {
+		return nil, err
+	}
+	first := []*HeadChange{newHeadChange(HeadChangeCurrent, head)}
+	if !from.Empty() {
+		if first, err = store.resumeHeadChanges(ctx, from, head); err != nil {
+			return nil, err
+		}
+	}
+
+	// Head events only signal that the head moved. The head is read from the
+	// store when handling them, so that events published before the snapshot
+	// above, or many at once, result in no change or a single one. A signal
+	// is pending from the start for a head set before subscribing.
+	sub := store.headEvents.Sub(NewHeadTopic)
+	unsub := func() { store.headEvents.Unsub(sub, NewHeadTopic) }
+	moved := make(chan struct{}, 1)
+	moved <- struct{}{}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for range sub {
+			select {
+			case moved <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	out := make(chan []*HeadChange)
+	go func() {
+		defer close(out)
+		prev := head
+		pending := first
+		for {
+			if len(pending) > 0 {
+				select {
+				case out <- pending:
+					pending = nil
+				case <-ctx.Done():
+					unsub()
+					return
+				}
+			}
+
+			select {
+			case <-moved:
+				next, err := store.GetTipSet(store.GetHead())
+				if err != nil {
+					logStore.Errorf("failed to read head for head changes: %s", err)
+					unsub()
+					return
+				}
+				changes, err := collectHeadChanges(ctx, store, prev, next)
+				if err != nil {
+					logStore.Errorf("failed to collect head changes from %s to %s: %s", prev.Key(), next.Key(), err)
+					unsub()
+					return
+				}
+				pending = changes
+				prev = next
+			case <-stopped:
+				// The store stopped.
+				return
+			case <-ctx.Done():
+				unsub()
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// resumeHeadChanges returns the changes bringing a client that last saw the
+// tipset at from to the head, reading no tipset more than
+// HeadChangesMaxResume epochs below the head.
+func (store *Store) resumeHeadChanges(ctx context.Context, from block.TipSetKey, head block.TipSet) ([]*HeadChange, error) {
+	prev, err := store.GetTipSet(from)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resume from tipset %s", from)
+	}
+	headHeight, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+	bounded := &boundedTipSetProvider{store: store, floor: headHeight - HeadChangesMaxResume}
+	if err := bounded.check(prev); err != nil {
+		return nil, errors.Wrapf(err, "failed to resume from tipset %s", from)
+	}
+	changes, err := collectHeadChanges(ctx, bounded, prev, head)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resume from tipset %s", from)
+	}
+	return changes, nil
+}
+
+// boundedTipSetProvider refuses to provide tipsets below the floor height.
+type boundedTipSetProvider struct {
+	store TipSetProvider
+	floor abi.ChainEpoch
+}
+
+func (p *boundedTipSetProvider) GetTipSet(key block.TipSetKey) (block.TipSet, error) {
+	ts, err := p.store.GetTipSet(key)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	if err := p.check(ts); err != nil {
+		return block.UndefTipSet, err
+	}
+	return ts, nil
+}
+
+func (p *boundedTipSetProvider) check(ts block.TipSet) error {
+	h, err := ts.Height()
+	if err != nil {
+		return err
+	}
+	if h < p.floor {
+		return errors.Errorf("tipset %s at height %d is more than %d epochs below the head", ts.Key(), h, HeadChangesMaxResume)
+	}
+	return nil
+}
+
+// collectHeadChanges returns the changes moving the head from prev to next,
+// reading tipsets from provider.
+func collectHeadChanges(ctx context.Context, provider TipSetProvider, prev, next block.TipSet) ([]*HeadChange, error) {
+	if prev.Equals(next) {
+		return nil, nil
+	}
+	reverted, applied, err := CollectTipsToCommonAncestor(ctx, provider, prev, next)
+	if err != nil {
+		return nil, err
+	}
+	changes := make([]*HeadChange, 0, len(reverted)+len(applied))
+	for _, ts := range reverted {
+		changes = append(changes, newHeadChange(HeadChangeRevert, ts))
+	}
+	for i := len(applied) - 1; i >= 0; i-- {
+		changes = append(changes, newHeadChange(HeadChangeApply, applied[i]))
+	}
+	return changes, nil
+}
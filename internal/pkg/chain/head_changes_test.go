@@ -0,0 +1,118 @@
+package chain_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestHeadChanges(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	chainStore := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+	defer chainStore.Stop()
+
+	link1 := builder.AppendOn(genTS, 1)
+	link2 := builder.AppendOn(link1, 1)
+	link3 := builder.AppendOn(link2, 1)
+	fork2 := builder.AppendOn(link1, 2)
+	requirePutTestChain(ctx, t, chainStore, link3.Key(), builder, 4)
+	requirePutTestChain(ctx, t, chainStore, fork2.Key(), builder, 1)
+	assertSetHead(t, chainStore, link1)
+
+	changes, err := chainStore.HeadChanges(ctx, block.TipSetKey{})
+	require.NoError(t, err)
+	assertHeadChanges(t, changes, []string{chain.HeadChangeCurrent}, []block.TipSet{link1})
+
+	assertSetHead(t, chainStore, link3)
+	assertHeadChanges(t, changes, []string{chain.HeadChangeApply, chain.HeadChangeApply}, []block.TipSet{link2, link3})
+
+	// A reorg reverts the old chain, highest first, before applying the new one.
+	assertSetHead(t, chainStore, fork2)
+	assertHeadChanges(t, changes, []string{chain.HeadChangeRevert, chain.HeadChangeRevert, chain.HeadChangeApply}, []block.TipSet{link3, link2, fork2})
+
+	// A client resuming from an abandoned tipset is brought to the head.
+	resumed, err := chainStore.HeadChanges(ctx, link3.Key())
+	require.NoError(t, err)
+	assertHeadChanges(t, resumed, []string{chain.HeadChangeRevert, chain.HeadChangeRevert, chain.HeadChangeApply}, []block.TipSet{link3, link2, fork2})
+
+	cancel()
+	for range changes {
+	}
+	for range resumed {
+	}
+}
+
+func TestHeadChangesUnknownTipSet(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	chainStore := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+	defer chainStore.Stop()
+	requirePutTestChain(ctx, t, chainStore, genTS.Key(), builder, 1)
+	assertSetHead(t, chainStore, genTS)
+
+	_, err := chainStore.HeadChanges(ctx, builder.AppendOn(genTS, 1).Key())
+	assert.Error(t, err)
+}
+
+func TestHeadChangesResumeDepth(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	builder := chain.NewBuilder(t, address.Undef)
+	genTS := builder.NewGenesis()
+	chainStore := newChainStore(repo.NewInMemoryRepo(), genTS.At(0).Cid())
+	defer chainStore.Stop()
+
+	depth := int(chain.HeadChangesMaxResume)
+	old := builder.AppendOn(genTS, 1)
+	head := builder.AppendManyOn(depth+1, old)
+	requirePutTestChain(ctx, t, chainStore, head.Key(), builder, depth+3)
+	assertSetHead(t, chainStore, head)
+
+	// Resuming from further below the head is refused.
+	_, err := chainStore.HeadChanges(ctx, old.Key())
+	assert.Error(t, err)
+
+	recent := builder.RequireTipSets(head.Key(), 3)
+	changes, err := chainStore.HeadChanges(ctx, recent[2].Key())
+	require.NoError(t, err)
+	assertHeadChanges(t, changes, []string{chain.HeadChangeApply, chain.HeadChangeApply}, []block.TipSet{recent[1], recent[0]})
+
+	cancel()
+	for range changes {
+	}
+}
+
+func assertHeadChanges(t *testing.T, changes <-chan []*chain.HeadChange, types []string, tipsets []block.TipSet) {
+	select {
+	case batch := <-changes:
+		require.Len(t, batch, len(types))
+		for i, change := range batch {
+			assert.Equal(t, types[i], change.Type)
+			assert.Equal(t, tipsets[i].Key(), change.Key)
+			ts, err := change.TipSet()
+			require.NoError(t, err)
+			assert.Equal(t, tipsets[i], ts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for head changes")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/filecoin-project/go-amt-ipld/v2"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/pkg/errors"
 	cbg "github.com/whyrusleeping/cbor-gen"
@@ -128,6 +129,21 @@ func (ms *MessageStore) StoreMessages(ctx context.Context, secpMessages []*types
 	return ms.StoreTxMeta(ctx, ret)
 }
 
+// ComputeMessagesCid returns the cid of the collection of the messages with
+// the given cids, as StoreMessages would store it, without storing anything.
+func ComputeMessagesCid(ctx context.Context, secpCids, blsCids []cid.Cid) (cid.Cid, error) {
+	scratch := NewMessageStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	secpRaw, err := scratch.storeAMTCids(ctx, secpCids)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "could not compute secp cids AMT")
+	}
+	blsRaw, err := scratch.storeAMTCids(ctx, blsCids)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "could not compute bls cids AMT")
+	}
+	return scratch.StoreTxMeta(ctx, types.TxMeta{SecpRoot: e.NewCid(secpRaw), BLSRoot: e.NewCid(blsRaw)})
+}
+
 // LoadReceipts loads the signed messages in the collection with cid c from ipld
 // storage and returns the slice implied by the collection
 func (ms *MessageStore) LoadReceipts(ctx context.Context, c cid.Cid) ([]vm.MessageReceipt, error) {
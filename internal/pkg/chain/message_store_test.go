@@ -4,9 +4,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
@@ -41,6 +43,16 @@ func TestMessageStoreMessagesHappy(t *testing.T) {
 	assert.NoError(t, err)
 
 	assert.Equal(t, msgs, rtMsgs)
+
+	// The cid can be computed from the message cids alone.
+	msgCids := make([]cid.Cid, len(msgs))
+	for i, msg := range msgs {
+		msgCids[i], err = msg.Cid()
+		require.NoError(t, err)
+	}
+	computed, err := chain.ComputeMessagesCid(ctx, msgCids, []cid.Cid{})
+	require.NoError(t, err)
+	assert.Equal(t, msgsCid, computed)
 }
 
 func TestMessageStoreReceiptsHappy(t *testing.T) {
@@ -34,6 +34,9 @@ var logStore = logging.Logger("chain.store")
 // HeadKey is the key at which the head tipset cid's are written in the datastore.
 var HeadKey = datastore.NewKey("/chain/heaviestTipSet")
 
+// CheckpointKey is the key at which the checkpoint tipset cids are written in the datastore.
+var CheckpointKey = datastore.NewKey("/chain/checkpoint")
+
 type ipldSource struct {
 	// cst is a store allowing access
 	// (un)marshalling and interop with go-ipld-hamt.
@@ -78,7 +81,10 @@ type Store struct {
 	genesis cid.Cid
 	// head is the tipset at the head of the best known chain.
 	head block.TipSet
-	// Protects head and genesisCid.
+	// checkpoint is the key of a tipset every accepted chain must include,
+	// empty if none is set.
+	checkpoint block.TipSetKey
+	// Protects head, checkpoint and genesisCid.
 	mu sync.RWMutex
 
 	// headEvents is a pubsub channel that publishes an event every time the head changes.
@@ -188,6 +194,14 @@ func (store *Store) Load(ctx context.Context) (err error) {
 	}
 
 	logStore.Infof("finished loading %d tipsets from %s", startHeight, headTs.String())
+	checkpoint, err := store.loadCheckpoint()
+	if err != nil {
+		return err
+	}
+	store.mu.Lock()
+	store.checkpoint = checkpoint
+	store.mu.Unlock()
+
 	// Set actual head.
 	return store.SetHead(ctx, headTs)
 }
@@ -209,6 +223,24 @@ func (store *Store) loadHead() (block.TipSetKey, error) {
 	return cids, nil
 }
 
+// loadCheckpoint loads the checkpoint from disk, if one was set.
+func (store *Store) loadCheckpoint() (block.TipSetKey, error) {
+	bb, err := store.ds.Get(CheckpointKey)
+	if err == datastore.ErrNotFound {
+		return block.TipSetKey{}, nil
+	}
+	if err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to read CheckpointKey")
+	}
+
+	var cids block.TipSetKey
+	err = encoding.Decode(bb, &cids)
+	if err != nil {
+		return block.TipSetKey{}, errors.Wrap(err, "failed to cast checkpoint cids")
+	}
+	return cids, nil
+}
+
 func (store *Store) loadStateRootAndReceipts(ts block.TipSet) (cid.Cid, cid.Cid, error) {
 	h, err := ts.Height()
 	if err != nil {
@@ -415,6 +447,38 @@ func (store *Store) GetHead() block.TipSetKey {
 	return store.head.Key()
 }
 
+// SetCheckpoint sets the tipset identified by key as the checkpoint, which
+// the syncer requires every chain it accepts to include. An empty key clears
+// the checkpoint. The checkpoint is persisted across restarts.
+func (store *Store) SetCheckpoint(key block.TipSetKey) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if key.Empty() {
+		if err := store.ds.Delete(CheckpointKey); err != nil {
+			return errors.Wrap(err, "failed to clear checkpoint")
+		}
+	} else {
+		val, err := encoding.Encode(key)
+		if err != nil {
+			return err
+		}
+		if err := store.ds.Put(CheckpointKey, val); err != nil {
+			return errors.Wrap(err, "failed to write checkpoint to datastore")
+		}
+	}
+	store.checkpoint = key
+	return nil
+}
+
+// GetCheckpoint returns the checkpoint tipset cids, which are empty if no
+// checkpoint is set.
+func (store *Store) GetCheckpoint() block.TipSetKey {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+	return store.checkpoint
+}
+
 // GenesisCid returns the genesis cid of the chain tracked by the default store.
 func (store *Store) GenesisCid() cid.Cid {
 	store.mu.Lock()
@@ -392,6 +392,7 @@ func TestLoadAndReboot(t *testing.T) {
 	assertSetHead(t, chainStore, genTS) // set the genesis block
 
 	assertSetHead(t, chainStore, link4)
+	require.NoError(t, chainStore.SetCheckpoint(link2.Key()))
 	chainStore.Stop()
 
 	// rebuild chain with same datastore and cborstore
@@ -413,6 +414,14 @@ func TestLoadAndReboot(t *testing.T) {
 
 	// Check the head
 	assert.Equal(t, link4.Key(), rebootChain.GetHead())
+
+	// The checkpoint survives the reboot, and clearing it does too.
+	assert.Equal(t, link2.Key(), rebootChain.GetCheckpoint())
+	require.NoError(t, rebootChain.SetCheckpoint(block.TipSetKey{}))
+	rebootChain.Stop()
+	clearedChain := chain.NewStore(ds, cst, chain.NewStatusReporter(), genTS.At(0).Cid())
+	require.NoError(t, clearedChain.Load(ctx))
+	assert.True(t, clearedChain.GetCheckpoint().Empty())
 }
 
 type tipSetGetter interface {
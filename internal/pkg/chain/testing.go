@@ -306,6 +306,11 @@ func (bb *BlockBuilder) SetTicket(raw []byte) {
 	bb.block.Ticket = block.Ticket{VRFProof: crypto.VRFPi(raw)}
 }
 
+// SetMiner sets the block's miner.
+func (bb *BlockBuilder) SetMiner(miner address.Address) {
+	bb.block.Miner = miner
+}
+
 // SetTimestamp sets the block's timestamp.
 func (bb *BlockBuilder) SetTimestamp(timestamp uint64) {
 	bb.block.Timestamp = timestamp
@@ -587,6 +592,11 @@ func (f *Builder) LoadMessages(ctx context.Context, metaCid cid.Cid) ([]*types.S
 	return f.messages.LoadMessages(ctx, metaCid)
 }
 
+// MessageStore returns the store holding the messages of the built blocks.
+func (f *Builder) MessageStore() *MessageStore {
+	return f.messages
+}
+
 // LoadReceipts returns the message collections tracked by the builder.
 func (f *Builder) LoadReceipts(ctx context.Context, c cid.Cid) ([]vm.MessageReceipt, error) {
 	return f.messages.LoadReceipts(ctx, c)
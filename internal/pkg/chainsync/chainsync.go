@@ -2,6 +2,10 @@ package chainsync
 
 import (
 	"context"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-datastore"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
@@ -27,9 +31,25 @@ type Manager struct {
 	transitionCh chan bool
 }
 
-// NewManager creates a new chain sync manager.
-func NewManager(fv syncer.FullBlockValidator, hv syncer.HeaderValidator, cs syncer.ChainSelector, s syncer.ChainReaderWriter, m *chain.MessageStore, f syncer.Fetcher, c clock.Clock, detector *slashing.ConsensusFaultDetector) (Manager, error) {
-	syncer, err := syncer.NewSyncer(fv, hv, cs, s, m, f, status.NewReporter(), c, detector)
+// QuarantinedFork is a heavier chain that was not adopted for forking deeper
+// than the maximum reorg depth.
+type QuarantinedFork = syncer.QuarantinedFork
+
+// BadTipSet is a tipset that failed validation, or descends from one that did.
+type BadTipSet = syncer.BadTipSet
+
+// NewManager creates a new chain sync manager. Catch-up sync fetches chains
+// from the peers listed by peers, which may be nil. Heavier forks dropping
+// more than maxReorgDepth epochs are quarantined, unless it is zero.
+// Tipsets failing validation are persisted to badTipSetDs, and refused until
+// badTipSetExpiry has passed, or forever if it is zero.
+func NewManager(fv syncer.FullBlockValidator, hv syncer.HeaderValidator, cs syncer.ChainSelector, s syncer.ChainReaderWriter, m *chain.MessageStore, f syncer.Fetcher, c clock.Clock, detector *slashing.ConsensusFaultDetector, peers syncer.PeerLister,
+	maxReorgDepth abi.ChainEpoch, badTipSetDs datastore.Datastore, badTipSetExpiry time.Duration) (Manager, error) {
+	bad, err := syncer.NewBadTipSetCache(badTipSetDs, badTipSetExpiry, c)
+	if err != nil {
+		return Manager{}, err
+	}
+	syncer, err := syncer.NewSyncer(fv, hv, cs, s, m, f, status.NewReporter(), c, detector, peers, maxReorgDepth, bad)
 	if err != nil {
 		return Manager{}, err
 	}
@@ -58,6 +78,32 @@ func (m *Manager) TransitionChannel() chan bool {
 	return m.transitionCh
 }
 
+// IsBadTipSet returns true if the syncer has marked the tipset as invalid.
+func (m *Manager) IsBadTipSet(key block.TipSetKey) bool {
+	return m.syncer.IsBadTipSet(key)
+}
+
+// Quarantined returns the heavier forks the syncer refused for forking deeper
+// than the maximum reorg depth.
+func (m *Manager) Quarantined() []QuarantinedFork {
+	return m.syncer.Quarantined()
+}
+
+// BadTipSets returns the tipsets the syncer refuses for failing validation.
+func (m *Manager) BadTipSets() []BadTipSet {
+	return m.syncer.BadTipSets()
+}
+
+// RemoveBadTipSet forgets that the tipset at key failed validation.
+func (m *Manager) RemoveBadTipSet(key block.TipSetKey) error {
+	return m.syncer.RemoveBadTipSet(key)
+}
+
+// ClearBadTipSets forgets every tipset that failed validation.
+func (m *Manager) ClearBadTipSets() error {
+	return m.syncer.ClearBadTipSets()
+}
+
 // Status returns the block proposer.
 func (m *Manager) Status() status.Status {
 	return m.syncer.Status()
@@ -0,0 +1,290 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/cborutil"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+)
+
+// The fetcher starts by requesting a single tipset, as most fetches follow
+// the head, and then requests more at a time, up to maxRequestLength, so
+// that long chains take few round trips.
+const maxRequestLength = 200
+const requestLengthMultiplier = 4
+
+type peerTracker interface {
+	List() []*block.ChainInfo
+	Self() peer.ID
+}
+
+type messageStorer interface {
+	StoreMessages(ctx context.Context, secpMessages []*types.SignedMessage, blsMessages []*types.UnsignedMessage) (cid.Cid, error)
+}
+
+// Fetcher fetches chains from peers with the chain exchange protocol. Fetched
+// blocks and messages are checked against the requested keys and written to
+// the blockstore.
+type Fetcher struct {
+	host      host.Host
+	store     bstore.Blockstore
+	messages  messageStorer
+	validator consensus.SyntaxValidator
+	peers     peerTracker
+	// local serves requests for chains announced by this node.
+	local *Server
+}
+
+// NewFetcher creates a fetcher requesting chains from the peers of the
+// tracker. Chains announced by this node itself are read through local.
+func NewFetcher(h host.Host, store bstore.Blockstore, messages messageStorer, validator consensus.SyntaxValidator, peers peerTracker, local *Server) *Fetcher {
+	return &Fetcher{
+		host:      h,
+		store:     store,
+		messages:  messages,
+		validator: validator,
+		peers:     peers,
+		local:     local,
+	}
+}
+
+// FetchTipSets fetches the tipsets, with their messages, from the tipset at
+// key down to the first one for which done returns true.
+func (f *Fetcher) FetchTipSets(ctx context.Context, key block.TipSetKey, originatingPeer peer.ID, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	return f.fetch(ctx, key, originatingPeer, done, Headers|Messages)
+}
+
+// FetchTipSetHeaders behaves as FetchTipSets but only fetches block headers.
+func (f *Fetcher) FetchTipSetHeaders(ctx context.Context, key block.TipSetKey, originatingPeer peer.ID, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	return f.fetch(ctx, key, originatingPeer, done, Headers)
+}
+
+func (f *Fetcher) fetch(ctx context.Context, key block.TipSetKey, originatingPeer peer.ID, done func(block.TipSet) (bool, error), opts Options) ([]block.TipSet, error) {
+	peers := f.requestPeers(originatingPeer)
+	length := uint64(1)
+	var out []block.TipSet
+	for {
+		resp, from, err := f.request(ctx, peers, &Request{Head: key, Length: length, Options: opts})
+		if err != nil {
+			return nil, errors.Wrapf(err, "fetching tipset %s", key)
+		}
+
+		for _, bundle := range resp.Chain {
+			ts, err := f.storeBundle(ctx, key, bundle, opts)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid response from %s", from)
+			}
+			out = append(out, ts)
+
+			finished, err := done(ts)
+			if err != nil {
+				return nil, err
+			}
+			if finished {
+				return out, nil
+			}
+
+			if key, err = ts.Parents(); err != nil {
+				return nil, err
+			}
+			if key.Empty() {
+				return nil, errors.Errorf("fetching tipset %s: reached genesis", ts.Key())
+			}
+		}
+
+		length *= requestLengthMultiplier
+		if length > maxRequestLength {
+			length = maxRequestLength
+		}
+	}
+}
+
+// request sends the request to each of the peers in turn until one answers
+// with tipsets.
+func (f *Fetcher) request(ctx context.Context, peers []peer.ID, req *Request) (*Response, peer.ID, error) {
+	for _, p := range peers {
+		resp, err := f.roundTrip(ctx, p, req)
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+		if err != nil {
+			log.Debugf("chain exchange request to %s failed: %s", p, err)
+			continue
+		}
+		if resp.Status != StatusOK && resp.Status != StatusPartial {
+			log.Debugf("chain exchange request to %s failed: %s: %s", p, resp.Status, resp.ErrorMessage)
+			continue
+		}
+		if len(resp.Chain) == 0 {
+			continue
+		}
+		return resp, p, nil
+	}
+	return nil, "", fmt.Errorf("no peer among %d served the request", len(peers))
+}
+
+func (f *Fetcher) roundTrip(ctx context.Context, p peer.ID, req *Request) (*Response, error) {
+	if p == f.peers.Self() {
+		return f.local.serve(ctx, req), nil
+	}
+
+	stream, err := f.host.NewStream(ctx, p, ProtocolID)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close() // nolint: errcheck
+	deadline := time.Now().Add(streamTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = stream.SetDeadline(deadline)
+
+	raw, err := encoding.Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stream.Write(raw); err != nil {
+		return nil, err
+	}
+
+	var resp Response
+	if err := cborutil.NewMsgReader(stream).ReadMsg(&resp); err != nil {
+		_ = stream.Reset()
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// requestPeers returns the peers to request a chain from: this node if it
+// announced the chain, else the announcing peer if connected, followed by
+// every other tracked peer.
+func (f *Fetcher) requestPeers(originatingPeer peer.ID) []peer.ID {
+	self := f.peers.Self()
+	var peers []peer.ID
+	if originatingPeer == self && f.local != nil {
+		peers = append(peers, self)
+	}
+	var others []peer.ID
+	for _, ci := range f.peers.List() {
+		if ci.Sender == self {
+			continue
+		}
+		if ci.Sender == originatingPeer {
+			peers = append(peers, ci.Sender)
+		} else {
+			others = append(others, ci.Sender)
+		}
+	}
+	return append(peers, others...)
+}
+
+// storeBundle checks that the bundle holds the tipset at key and writes its blocks
+// and messages to the blockstore. A bundle without headers is checked against
+// the headers already in the blockstore.
+func (f *Fetcher) storeBundle(ctx context.Context, key block.TipSetKey, bundle *TipSetBundle, opts Options) (block.TipSet, error) {
+	if !bundle.Key.Equals(key) {
+		return block.UndefTipSet, errors.Errorf("got tipset %s, expected %s", bundle.Key, key)
+	}
+	blocks := bundle.Blocks
+	if opts&Headers == 0 {
+		var err error
+		if blocks, err = f.storedHeaders(key); err != nil {
+			return block.UndefTipSet, err
+		}
+	} else {
+		if len(blocks) != key.Len() {
+			return block.UndefTipSet, errors.Errorf("got %d blocks for tipset %s", len(blocks), key)
+		}
+		for _, blk := range blocks {
+			if err := f.validator.ValidateSyntax(ctx, blk); err != nil {
+				return block.UndefTipSet, errors.Wrapf(err, "invalid block %s", blk.Cid())
+			}
+		}
+	}
+	ts, err := block.NewTipSet(blocks...)
+	if err != nil {
+		return block.UndefTipSet, err
+	}
+	if !ts.Key().Equals(key) {
+		return block.UndefTipSet, errors.Errorf("got blocks of tipset %s, expected %s", ts.Key(), key)
+	}
+
+	if opts&Messages != 0 {
+		if len(bundle.Messages) != ts.Len() {
+			return block.UndefTipSet, errors.Errorf("got messages for %d blocks of tipset %s", len(bundle.Messages), key)
+		}
+		// Check every block's messages before storing any.
+		for i := 0; i < ts.Len(); i++ {
+			blk := ts.At(i)
+			meta, err := messagesCid(ctx, bundle.Messages[i])
+			if err != nil {
+				return block.UndefTipSet, err
+			}
+			if !meta.Equals(blk.Messages.Cid) {
+				return block.UndefTipSet, errors.Errorf("messages of block %s do not match its header", blk.Cid())
+			}
+		}
+		for _, msgs := range bundle.Messages {
+			if _, err := f.messages.StoreMessages(ctx, msgs.SecpMessages, msgs.BLSMessages); err != nil {
+				return block.UndefTipSet, err
+			}
+		}
+	}
+
+	for i := 0; i < ts.Len(); i++ {
+		if err := f.store.Put(ts.At(i).ToNode()); err != nil {
+			return block.UndefTipSet, err
+		}
+	}
+	return ts, nil
+}
+
+// messagesCid computes the cid of the block's messages as stored.
+func messagesCid(ctx context.Context, msgs *BlockMessages) (cid.Cid, error) {
+	secpCids := make([]cid.Cid, len(msgs.SecpMessages))
+	for i, msg := range msgs.SecpMessages {
+		c, err := msg.Cid()
+		if err != nil {
+			return cid.Undef, err
+		}
+		secpCids[i] = c
+	}
+	blsCids := make([]cid.Cid, len(msgs.BLSMessages))
+	for i, msg := range msgs.BLSMessages {
+		c, err := msg.Cid()
+		if err != nil {
+			return cid.Undef, err
+		}
+		blsCids[i] = c
+	}
+	return chain.ComputeMessagesCid(ctx, secpCids, blsCids)
+}
+
+// storedHeaders reads the headers of the tipset at key from the blockstore.
+func (f *Fetcher) storedHeaders(key block.TipSetKey) ([]*block.Block, error) {
+	var blocks []*block.Block
+	for _, c := range key.ToSlice() {
+		raw, err := f.store.Get(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "header of block %s is not stored", c)
+		}
+		blk, err := block.DecodeBlock(raw.RawData())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode block %s", c)
+		}
+		blocks = append(blocks, blk)
+	}
+	return blocks, nil
+}
@@ -0,0 +1,113 @@
+// Package exchange implements the chain exchange protocol, a request/response
+// libp2p protocol for fetching a range of tipsets back from a key, with their
+// headers, their messages or both.
+//
+// Unlike graphsync, which traverses arbitrary selectors over the blockstore,
+// every request names a single walk down the chain, which lets servers cap
+// the work they do per request and lets clients ask for just the part of the
+// chain they are missing.
+package exchange
+
+import (
+	"fmt"
+
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/protocol"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+)
+
+var log = logging.Logger("chainsync.exchange")
+
+// ProtocolID is the libp2p protocol identifier for the chain exchange protocol.
+const ProtocolID = protocol.ID("/fil/chain/xchg/0.0.1")
+
+// Options select the parts of each tipset a response holds.
+type Options uint64
+
+const (
+	// Headers requests the block headers of each tipset.
+	Headers Options = 1 << iota
+	// Messages requests the messages of each block of each tipset.
+	Messages
+)
+
+// Request asks for Length tipsets walking down the chain from Head, which
+// comes first in the response. Alternatively it asks for the tipsets at Keys,
+// in that order, which need not form a chain; Head and Length are then unset.
+type Request struct {
+	_       struct{} `cbor:",toarray"`
+	Head    block.TipSetKey
+	Length  uint64
+	Options Options
+	Keys    []block.TipSetKey
+}
+
+// Status is the outcome of a request.
+type Status uint64
+
+const (
+	// StatusOK means the response holds every tipset requested.
+	StatusOK Status = iota
+	// StatusPartial means the response holds fewer tipsets than requested,
+	// because the chain ended, a requested key is unknown to the server or
+	// the request was over the server's limit.
+	StatusPartial
+	// StatusNotFound means the server does not have the head tipset, or the
+	// first of the requested keys.
+	StatusNotFound
+	// StatusBadRequest means the request was malformed.
+	StatusBadRequest
+	// StatusBusy means the server is serving too many requests to take
+	// another. It may be retried later.
+	StatusBusy
+	// StatusInternalError means the server failed to read its chain.
+	StatusInternalError
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusPartial:
+		return "partial"
+	case StatusNotFound:
+		return "not found"
+	case StatusBadRequest:
+		return "bad request"
+	case StatusBusy:
+		return "busy"
+	case StatusInternalError:
+		return "internal error"
+	default:
+		return fmt.Sprintf("unknown status %d", uint64(s))
+	}
+}
+
+// Response answers a request with tipsets from the requested head down, or
+// with the tipsets at the requested keys.
+type Response struct {
+	_            struct{} `cbor:",toarray"`
+	Status       Status
+	ErrorMessage string
+	Chain        []*TipSetBundle
+}
+
+// TipSetBundle holds the parts of a tipset selected by the request options.
+// Blocks is set if headers were requested. Messages is set if messages were
+// requested and holds the messages of each block, in the order of the blocks
+// in the tipset.
+type TipSetBundle struct {
+	_        struct{} `cbor:",toarray"`
+	Key      block.TipSetKey
+	Blocks   []*block.Block
+	Messages []*BlockMessages
+}
+
+// BlockMessages holds the messages of a block.
+type BlockMessages struct {
+	_            struct{} `cbor:",toarray"`
+	BLSMessages  []*types.UnsignedMessage
+	SecpMessages []*types.SignedMessage
+}
@@ -0,0 +1,263 @@
+package exchange_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	dss "github.com/ipfs/go-datastore/sync"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/cborutil"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/exchange"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
+)
+
+type testNet struct {
+	builder *chain.Builder
+	genesis block.TipSet
+	head    block.TipSet
+	server  host.Host
+	client  host.Host
+	// Client side stores.
+	bs       bstore.Blockstore
+	messages *chain.MessageStore
+	bv       consensus.SyntaxValidator
+}
+
+// newTestNet builds a chain with messages in every block, served by one host
+// to another.
+func newTestNet(ctx context.Context, t *testing.T, length int, limits exchange.Limits) *testNet {
+	_, chainClock := clock.NewFakeChain(1234567890, 5*time.Second, time.Now().Unix())
+	builder := chain.NewBuilderWithDeps(t, address.Undef, &chain.FakeStateBuilder{}, chain.NewClockTimestamper(chainClock))
+	mm := vm.NewMessageMaker(t, types.MustGenerateKeyInfo(2, 42))
+	alice, bob := mm.Addresses()[0], mm.Addresses()[1]
+
+	genesis := builder.NewGenesis()
+	nonce := uint64(0)
+	head := builder.BuildManyOn(length, genesis, func(b *chain.BlockBuilder) {
+		b.AddMessages(
+			[]*types.SignedMessage{mm.NewSignedMessage(alice, nonce)},
+			[]*types.UnsignedMessage{&mm.NewSignedMessage(bob, nonce).Message},
+		)
+		nonce++
+	})
+
+	mn, err := mocknet.WithNPeers(ctx, 2)
+	require.NoError(t, err)
+	require.NoError(t, mn.LinkAll())
+	server, client := mn.Hosts()[0], mn.Hosts()[1]
+	exchange.NewServer(builder, builder, limits).Register(server)
+
+	bs := bstore.NewBlockstore(dss.MutexWrap(datastore.NewMapDatastore()))
+	return &testNet{
+		builder:  builder,
+		genesis:  genesis,
+		head:     head,
+		server:   server,
+		client:   client,
+		bs:       bs,
+		messages: chain.NewMessageStore(bs),
+		bv:       consensus.NewDefaultBlockValidator(chainClock),
+	}
+}
+
+func (n *testNet) fetcher(peers ...peer.ID) *exchange.Fetcher {
+	tracker := &fakePeerTracker{self: n.client.ID()}
+	for _, p := range peers {
+		tracker.peers = append(tracker.peers, block.NewChainInfo(p, p, n.head.Key(), n.head.At(0).Height))
+	}
+	return exchange.NewFetcher(n.client, n.bs, n.messages, n.bv, tracker, nil)
+}
+
+func TestFetchTipSets(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// A low limit makes the fetch take several requests.
+	n := newTestNet(ctx, t, 10, exchange.Limits{MaxRequestLength: 3, MaxConcurrentRequests: 1})
+
+	tipsets, err := n.fetcher(n.server.ID()).FetchTipSets(ctx, n.head.Key(), n.server.ID(), doneAt(n.genesis.Key()))
+	require.NoError(t, err)
+	require.Len(t, tipsets, 11)
+	assert.Equal(t, n.head, tipsets[0])
+	assert.Equal(t, n.genesis, tipsets[10])
+
+	// Blocks and messages are in the client's stores.
+	for _, ts := range tipsets {
+		blk := ts.At(0)
+		has, err := n.bs.Has(blk.Cid())
+		require.NoError(t, err)
+		assert.True(t, has)
+
+		secp, bls, err := n.messages.LoadMessages(ctx, blk.Messages.Cid)
+		require.NoError(t, err)
+		expectedSecp, expectedBLS, err := n.builder.LoadMessages(ctx, blk.Messages.Cid)
+		require.NoError(t, err)
+		assert.Equal(t, expectedSecp, secp)
+		assert.Equal(t, expectedBLS, bls)
+	}
+}
+
+func TestFetchTipSetHeaders(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := newTestNet(ctx, t, 5, exchange.Limits{MaxRequestLength: 10, MaxConcurrentRequests: 1})
+	stop := n.builder.RequireTipSets(n.head.Key(), 3)[2]
+
+	tipsets, err := n.fetcher(n.server.ID()).FetchTipSetHeaders(ctx, n.head.Key(), n.server.ID(), doneAt(stop.Key()))
+	require.NoError(t, err)
+	require.Len(t, tipsets, 3)
+	assert.Equal(t, stop, tipsets[2])
+
+	// Only headers were fetched.
+	_, _, err = n.messages.LoadMessages(ctx, n.head.At(0).Messages.Cid)
+	assert.Error(t, err)
+}
+
+func TestFetchTriesOtherPeers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := newTestNet(ctx, t, 3, exchange.Limits{MaxRequestLength: 10, MaxConcurrentRequests: 1})
+
+	// The first peer does not speak the protocol.
+	mute := peer.ID("mute")
+	tipsets, err := n.fetcher(mute, n.server.ID()).FetchTipSets(ctx, n.head.Key(), mute, doneAt(n.genesis.Key()))
+	require.NoError(t, err)
+	assert.Len(t, tipsets, 4)
+
+	_, err = n.fetcher(mute).FetchTipSets(ctx, n.head.Key(), mute, doneAt(n.genesis.Key()))
+	assert.Error(t, err)
+}
+
+func TestServerLimits(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := newTestNet(ctx, t, 10, exchange.Limits{MaxRequestLength: 3, MaxConcurrentRequests: 1})
+
+	resp := roundTrip(ctx, t, n, &exchange.Request{Head: n.head.Key(), Length: 10, Options: exchange.Headers | exchange.Messages})
+	assert.Equal(t, exchange.StatusPartial, resp.Status)
+	require.Len(t, resp.Chain, 3)
+	assert.Equal(t, n.head.Key(), resp.Chain[0].Key)
+	assert.Len(t, resp.Chain[0].Blocks, 1)
+	assert.Len(t, resp.Chain[0].Messages, 1)
+
+	resp = roundTrip(ctx, t, n, &exchange.Request{Head: n.head.Key(), Length: 2, Options: exchange.Messages})
+	assert.Equal(t, exchange.StatusOK, resp.Status)
+	require.Len(t, resp.Chain, 2)
+	assert.Empty(t, resp.Chain[0].Blocks)
+	assert.Len(t, resp.Chain[0].Messages, 1)
+
+	resp = roundTrip(ctx, t, n, &exchange.Request{Head: n.head.Key(), Length: 0, Options: exchange.Headers})
+	assert.Equal(t, exchange.StatusBadRequest, resp.Status)
+
+	unknown := block.NewTipSetKey(types.NewCidForTestGetter()())
+	resp = roundTrip(ctx, t, n, &exchange.Request{Head: unknown, Length: 1, Options: exchange.Headers})
+	assert.Equal(t, exchange.StatusNotFound, resp.Status)
+
+	t.Run("by keys", func(t *testing.T) {
+		tipsets := n.builder.RequireTipSets(n.head.Key(), 5)
+
+		resp := roundTrip(ctx, t, n, &exchange.Request{Keys: []block.TipSetKey{tipsets[4].Key(), tipsets[1].Key()}, Options: exchange.Headers})
+		assert.Equal(t, exchange.StatusOK, resp.Status)
+		require.Len(t, resp.Chain, 2)
+		assert.Equal(t, tipsets[4].Key(), resp.Chain[0].Key)
+		assert.Equal(t, tipsets[1].Key(), resp.Chain[1].Key)
+
+		// Over the limit, and up to the first unknown key.
+		resp = roundTrip(ctx, t, n, &exchange.Request{Keys: []block.TipSetKey{tipsets[0].Key(), tipsets[1].Key(), tipsets[2].Key(), tipsets[3].Key()}, Options: exchange.Headers})
+		assert.Equal(t, exchange.StatusPartial, resp.Status)
+		assert.Len(t, resp.Chain, 3)
+		resp = roundTrip(ctx, t, n, &exchange.Request{Keys: []block.TipSetKey{tipsets[0].Key(), unknown}, Options: exchange.Headers})
+		assert.Equal(t, exchange.StatusPartial, resp.Status)
+		assert.Len(t, resp.Chain, 1)
+
+		resp = roundTrip(ctx, t, n, &exchange.Request{Keys: []block.TipSetKey{unknown}, Options: exchange.Headers})
+		assert.Equal(t, exchange.StatusNotFound, resp.Status)
+		resp = roundTrip(ctx, t, n, &exchange.Request{Head: n.head.Key(), Length: 1, Keys: []block.TipSetKey{unknown}, Options: exchange.Headers})
+		assert.Equal(t, exchange.StatusBadRequest, resp.Status)
+	})
+}
+
+func TestServerWithoutConcurrencyLimit(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := newTestNet(ctx, t, 3, exchange.Limits{MaxRequestLength: 10})
+
+	resp := roundTrip(ctx, t, n, &exchange.Request{Head: n.head.Key(), Length: 2, Options: exchange.Headers})
+	assert.Equal(t, exchange.StatusOK, resp.Status)
+	assert.Len(t, resp.Chain, 2)
+}
+
+func TestServerDefaultRequestLength(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n := newTestNet(ctx, t, exchange.DefaultMaxRequestLength+5, exchange.Limits{})
+	tipsets := n.builder.RequireTipSets(n.head.Key(), exchange.DefaultMaxRequestLength+1)
+
+	// Without a configured limit, requests by head and by keys share the default.
+	resp := roundTrip(ctx, t, n, &exchange.Request{Head: n.head.Key(), Length: exchange.DefaultMaxRequestLength + 5, Options: exchange.Headers})
+	assert.Equal(t, exchange.StatusPartial, resp.Status)
+	assert.Len(t, resp.Chain, exchange.DefaultMaxRequestLength)
+
+	keys := make([]block.TipSetKey, len(tipsets))
+	for i, ts := range tipsets {
+		keys[i] = ts.Key()
+	}
+	resp = roundTrip(ctx, t, n, &exchange.Request{Keys: keys, Options: exchange.Headers})
+	assert.Equal(t, exchange.StatusPartial, resp.Status)
+	assert.Len(t, resp.Chain, exchange.DefaultMaxRequestLength)
+}
+
+func roundTrip(ctx context.Context, t *testing.T, n *testNet, req *exchange.Request) *exchange.Response {
+	s, err := n.client.NewStream(ctx, n.server.ID(), exchange.ProtocolID)
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	raw, err := encoding.Encode(req)
+	require.NoError(t, err)
+	_, err = s.Write(raw)
+	require.NoError(t, err)
+
+	var resp exchange.Response
+	require.NoError(t, cborutil.NewMsgReader(s).ReadMsg(&resp))
+	return &resp
+}
+
+func doneAt(key block.TipSetKey) func(block.TipSet) (bool, error) {
+	return func(ts block.TipSet) (bool, error) {
+		return ts.Key().Equals(key), nil
+	}
+}
+
+type fakePeerTracker struct {
+	self  peer.ID
+	peers []*block.ChainInfo
+}
+
+func (pt *fakePeerTracker) List() []*block.ChainInfo {
+	return pt.peers
+}
+
+func (pt *fakePeerTracker) Self() peer.ID {
+	return pt.self
+}
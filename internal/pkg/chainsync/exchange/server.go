@@ -0,0 +1,213 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/host"
+	net "github.com/libp2p/go-libp2p-core/network"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/cborutil"
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+)
+
+// streamTimeout bounds the time to read a request and write its response.
+const streamTimeout = time.Minute
+
+// DefaultMaxRequestLength is the largest number of tipsets served per request
+// when the limits set none.
+const DefaultMaxRequestLength = 500
+
+// Limits bound the work a server does for its peers.
+type Limits struct {
+	// MaxRequestLength is the largest number of tipsets served per request.
+	// Longer requests are answered with a partial response. Zero serves up to
+	// DefaultMaxRequestLength.
+	MaxRequestLength uint64
+	// MaxConcurrentRequests is the number of requests served at once. Requests
+	// beyond it are answered as busy. Zero places no limit.
+	MaxConcurrentRequests uint
+}
+
+type chainReader interface {
+	GetTipSet(key block.TipSetKey) (block.TipSet, error)
+}
+
+type messageLoader interface {
+	LoadMessages(ctx context.Context, metaCid cid.Cid) ([]*types.SignedMessage, []*types.UnsignedMessage, error)
+}
+
+// Server answers chain exchange requests from a node's validated chain.
+type Server struct {
+	chain    chainReader
+	messages messageLoader
+	limits   Limits
+	// slots holds a token for every request being served, nil when the
+	// number of requests is not limited.
+	slots chan struct{}
+}
+
+// NewServer creates a server reading tipsets from chain and their messages from
+// messages.
+func NewServer(chain chainReader, messages messageLoader, limits Limits) *Server {
+	if limits.MaxRequestLength == 0 {
+		limits.MaxRequestLength = DefaultMaxRequestLength
+	}
+	s := &Server{
+		chain:    chain,
+		messages: messages,
+		limits:   limits,
+	}
+	if limits.MaxConcurrentRequests > 0 {
+		s.slots = make(chan struct{}, limits.MaxConcurrentRequests)
+	}
+	return s
+}
+
+// Register sets the server as the host's handler for the protocol.
+func (s *Server) Register(h host.Host) {
+	h.SetStreamHandler(ProtocolID, s.handleStream)
+}
+
+func (s *Server) handleStream(stream net.Stream) {
+	defer stream.Close() // nolint: errcheck
+	_ = stream.SetDeadline(time.Now().Add(streamTimeout))
+
+	var req Request
+	var resp *Response
+	if err := cborutil.NewMsgReader(stream).ReadMsg(&req); err != nil {
+		log.Debugf("failed to read chain exchange request from %s: %s", stream.Conn().RemotePeer(), err)
+		resp = &Response{Status: StatusBadRequest, ErrorMessage: "malformed request"}
+	} else {
+		resp = s.serve(context.Background(), &req)
+	}
+
+	raw, err := encoding.Encode(resp)
+	if err != nil {
+		log.Errorf("failed to encode chain exchange response: %s", err)
+		return
+	}
+	if _, err := stream.Write(raw); err != nil {
+		log.Debugf("failed to write chain exchange response to %s: %s", stream.Conn().RemotePeer(), err)
+	}
+}
+
+// serve answers a request within the server's limits.
+func (s *Server) serve(ctx context.Context, req *Request) *Response {
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		default:
+			return &Response{Status: StatusBusy, ErrorMessage: "too many concurrent requests"}
+		}
+	}
+
+	if req.Options&(Headers|Messages) == 0 {
+		return &Response{Status: StatusBadRequest, ErrorMessage: "no tipset parts requested"}
+	}
+	if len(req.Keys) > 0 {
+		if req.Length != 0 || !req.Head.Empty() {
+			return &Response{Status: StatusBadRequest, ErrorMessage: "request has both a head and keys"}
+		}
+		return s.serveKeys(ctx, req)
+	}
+	if req.Length == 0 || req.Head.Empty() {
+		return &Response{Status: StatusBadRequest, ErrorMessage: "empty request"}
+	}
+	length := req.Length
+	if length > s.limits.MaxRequestLength {
+		length = s.limits.MaxRequestLength
+	}
+
+	ts, err := s.chain.GetTipSet(req.Head)
+	if err != nil {
+		return &Response{Status: StatusNotFound, ErrorMessage: err.Error()}
+	}
+
+	var chain []*TipSetBundle
+	for {
+		bundle, err := s.bundle(ctx, ts, req.Options)
+		if err != nil {
+			log.Errorf("failed to serve tipset %s: %s", ts.Key(), err)
+			return &Response{Status: StatusInternalError, ErrorMessage: "failed to read chain"}
+		}
+		chain = append(chain, bundle)
+		if uint64(len(chain)) == length {
+			break
+		}
+
+		parents, err := ts.Parents()
+		if err != nil {
+			return &Response{Status: StatusInternalError, ErrorMessage: "failed to read chain"}
+		}
+		if parents.Empty() {
+			break
+		}
+		if ts, err = s.chain.GetTipSet(parents); err != nil {
+			// The rest of the chain is not validated by this node.
+			break
+		}
+	}
+
+	status := StatusOK
+	if uint64(len(chain)) < req.Length {
+		status = StatusPartial
+	}
+	return &Response{Status: status, Chain: chain}
+}
+
+// serveKeys answers a request for the tipsets at the request's keys, up to the
+// first one the server does not have.
+func (s *Server) serveKeys(ctx context.Context, req *Request) *Response {
+	keys := req.Keys
+	if uint64(len(keys)) > s.limits.MaxRequestLength {
+		keys = keys[:s.limits.MaxRequestLength]
+	}
+
+	var chain []*TipSetBundle
+	for _, key := range keys {
+		ts, err := s.chain.GetTipSet(key)
+		if err != nil {
+			break
+		}
+		bundle, err := s.bundle(ctx, ts, req.Options)
+		if err != nil {
+			log.Errorf("failed to serve tipset %s: %s", ts.Key(), err)
+			return &Response{Status: StatusInternalError, ErrorMessage: "failed to read chain"}
+		}
+		chain = append(chain, bundle)
+	}
+
+	switch {
+	case len(chain) == 0:
+		return &Response{Status: StatusNotFound, ErrorMessage: fmt.Sprintf("tipset %s not found", keys[0])}
+	case len(chain) < len(req.Keys):
+		return &Response{Status: StatusPartial, Chain: chain}
+	default:
+		return &Response{Status: StatusOK, Chain: chain}
+	}
+}
+
+func (s *Server) bundle(ctx context.Context, ts block.TipSet, opts Options) (*TipSetBundle, error) {
+	bundle := &TipSetBundle{Key: ts.Key()}
+	for i := 0; i < ts.Len(); i++ {
+		blk := ts.At(i)
+		if opts&Headers != 0 {
+			bundle.Blocks = append(bundle.Blocks, blk)
+		}
+		if opts&Messages != 0 {
+			secp, bls, err := s.messages.LoadMessages(ctx, blk.Messages.Cid)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to load messages of block %s", blk.Cid())
+			}
+			bundle.Messages = append(bundle.Messages, &BlockMessages{BLSMessages: bls, SecpMessages: secp})
+		}
+	}
+	return bundle, nil
+}
@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"context"
+
+	logging "github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/internal/syncer"
+)
+
+var logFallbackFetcher = logging.Logger("chainsync.fetcher.fallback")
+
+// FallbackFetcher fetches with a primary fetcher, and retries fetches it
+// fails with a fallback fetcher, such as one using another protocol.
+// Tipsets passed to done by the failed fetch may be passed to it again.
+type FallbackFetcher struct {
+	primary  syncer.Fetcher
+	fallback syncer.Fetcher
+}
+
+// NewFallbackFetcher creates a fetcher falling back from primary to fallback.
+func NewFallbackFetcher(primary, fallback syncer.Fetcher) *FallbackFetcher {
+	return &FallbackFetcher{primary: primary, fallback: fallback}
+}
+
+// FetchTipSets fetches tipsets with the primary fetcher, then the fallback.
+func (f *FallbackFetcher) FetchTipSets(ctx context.Context, key block.TipSetKey, from peer.ID, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	tipsets, err := f.primary.FetchTipSets(ctx, key, from, done)
+	if err == nil || ctx.Err() != nil {
+		return tipsets, err
+	}
+	logFallbackFetcher.Infof("fetching tipset %s failed, falling back: %s", key, err)
+	tipsets, fallbackErr := f.fallback.FetchTipSets(ctx, key, from, done)
+	if fallbackErr != nil {
+		return nil, errors.Wrapf(fallbackErr, "fallback failed after: %s", err)
+	}
+	return tipsets, nil
+}
+
+// FetchTipSetHeaders fetches headers with the primary fetcher, then the fallback.
+func (f *FallbackFetcher) FetchTipSetHeaders(ctx context.Context, key block.TipSetKey, from peer.ID, done func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	tipsets, err := f.primary.FetchTipSetHeaders(ctx, key, from, done)
+	if err == nil || ctx.Err() != nil {
+		return tipsets, err
+	}
+	logFallbackFetcher.Infof("fetching headers of tipset %s failed, falling back: %s", key, err)
+	tipsets, fallbackErr := f.fallback.FetchTipSetHeaders(ctx, key, from, done)
+	if fallbackErr != nil {
+		return nil, errors.Wrapf(fallbackErr, "fallback failed after: %s", err)
+	}
+	return tipsets, nil
+}
@@ -0,0 +1,66 @@
+package fetcher_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/fetcher"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+type failingFetcher struct {
+	calls int
+}
+
+func (f *failingFetcher) FetchTipSets(context.Context, block.TipSetKey, peer.ID, func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	f.calls++
+	return nil, errors.New("unreachable")
+}
+
+func (f *failingFetcher) FetchTipSetHeaders(context.Context, block.TipSetKey, peer.ID, func(block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	f.calls++
+	return nil, errors.New("unreachable")
+}
+
+func TestFallbackFetcher(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	gen := builder.NewGenesis()
+	head := builder.AppendManyOn(3, gen)
+	done := func(ts block.TipSet) (bool, error) { return ts.Equals(gen), nil }
+
+	t.Run("primary succeeds", func(t *testing.T) {
+		fallback := &failingFetcher{}
+		tipsets, err := fetcher.NewFallbackFetcher(builder, fallback).FetchTipSets(ctx, head.Key(), "", done)
+		require.NoError(t, err)
+		assert.Len(t, tipsets, 4)
+		assert.Equal(t, 0, fallback.calls)
+	})
+
+	t.Run("falls back when primary fails", func(t *testing.T) {
+		primary := &failingFetcher{}
+		f := fetcher.NewFallbackFetcher(primary, builder)
+		tipsets, err := f.FetchTipSets(ctx, head.Key(), "", done)
+		require.NoError(t, err)
+		assert.Len(t, tipsets, 4)
+
+		headers, err := f.FetchTipSetHeaders(ctx, head.Key(), "", done)
+		require.NoError(t, err)
+		assert.Len(t, headers, 4)
+		assert.Equal(t, 2, primary.calls)
+	})
+
+	t.Run("both fail", func(t *testing.T) {
+		_, err := fetcher.NewFallbackFetcher(&failingFetcher{}, &failingFetcher{}).FetchTipSets(ctx, head.Key(), "", done)
+		assert.Error(t, err)
+	})
+}
@@ -137,11 +137,11 @@ func (gsf *GraphSyncFetcher) FetchTipSetHeaders(ctx context.Context, tsKey block
 
 func (gsf *GraphSyncFetcher) fetchTipSetsCommon(ctx context.Context, tsKey block.TipSetKey, originatingPeer peer.ID, done func(block.TipSet) (bool, error), loadAndVerify func(context.Context, block.TipSetKey) (block.TipSet, []cid.Cid, error), selGen func() ipld.Node, recSelGen func(int) ipld.Node) ([]block.TipSet, error) {
 	// We can run into issues if we fetch from an originatingPeer that we
-	// are not already connected to so we usually ignore this value.
+	// are not already connected to so we only start with it if it is
+	// tracked, otherwise it is ignored.
 	// However if the originator is our own peer ID (i.e. this node mined
 	// the block) then we need to fetch from ourselves to retrieve it
-	fetchFromSelf := originatingPeer == gsf.peerTracker.Self()
-	rpf, err := newRequestPeerFinder(gsf.peerTracker, fetchFromSelf)
+	rpf, err := newRequestPeerFinder(gsf.peerTracker, originatingPeer)
 	if err != nil {
 		return nil, err
 	}
@@ -600,7 +600,7 @@ type requestPeerFinder struct {
 	triedPeers  map[peer.ID]struct{}
 }
 
-func newRequestPeerFinder(peerTracker graphsyncFallbackPeerTracker, fetchFromSelf bool) (*requestPeerFinder, error) {
+func newRequestPeerFinder(peerTracker graphsyncFallbackPeerTracker, originatingPeer peer.ID) (*requestPeerFinder, error) {
 	pri := &requestPeerFinder{
 		peerTracker: peerTracker,
 		triedPeers:  make(map[peer.ID]struct{}),
@@ -608,12 +608,23 @@ func newRequestPeerFinder(peerTracker graphsyncFallbackPeerTracker, fetchFromSel
 
 	// If the new cid triggering this request came from ourselves then
 	// the first peer to request from should be ourselves.
-	if fetchFromSelf {
+	if originatingPeer == peerTracker.Self() {
 		pri.triedPeers[peerTracker.Self()] = struct{}{}
 		pri.currentPeer = peerTracker.Self()
 		return pri, nil
 	}
 
+	// Otherwise start with the originating peer if we are connected to it,
+	// so that requests for different ranges of a chain can be spread over
+	// peers.
+	for _, chain := range peerTracker.List() {
+		if chain.Sender == originatingPeer {
+			pri.triedPeers[originatingPeer] = struct{}{}
+			pri.currentPeer = originatingPeer
+			return pri, nil
+		}
+	}
+
 	// Get a peer ID from the peer tracker
 	err := pri.FindNextPeer()
 	if err != nil {
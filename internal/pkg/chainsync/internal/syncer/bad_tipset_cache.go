@@ -1,42 +1,228 @@
 package syncer
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
 )
 
+// badTipSetPrefix is the datastore namespace bad tipsets are persisted under.
+var badTipSetPrefix = datastore.NewKey("/chain/badTipSets")
+
+// BadTipSet is a tipset that failed validation, or descends from one that did.
+type BadTipSet struct {
+	Key    block.TipSetKey
+	Height abi.ChainEpoch
+	// Reason is the error the tipset was rejected with.
+	Reason string
+	// Rejected is the time the tipset was marked bad.
+	Rejected time.Time
+}
+
+// badTipSetRecord is the persisted form of a BadTipSet.
+type badTipSetRecord struct {
+	_        struct{} `cbor:",toarray"`
+	Key      block.TipSetKey
+	Height   abi.ChainEpoch
+	Reason   string
+	Rejected int64
+}
+
 // BadTipSetCache keeps track of bad tipsets that the syncer should not try to
 // download. Readers and writers grab a lock. The purpose of this cache is to
 // prevent a node from having to repeatedly invalidate a block (and its children)
-// in the event that the tipset does not conform to the rules of consensus. Note
-// that the cache is only in-memory, so it is reset whenever the node is restarted.
-// TODO: this needs to be limited.
+// in the event that the tipset does not conform to the rules of consensus.
+// Entries for tipsets failing consensus are written to the datastore as they
+// are added so that a restarted node keeps refusing the chains it rejected,
+// until the entries expire.
 type BadTipSetCache struct {
 	mu  sync.Mutex
-	bad map[string]struct{}
+	bad map[string]BadTipSet
+
+	ds    datastore.Datastore
+	clock clock.Clock
+	// expiry is the time an entry is kept. Zero keeps entries forever.
+	expiry time.Duration
 }
 
-// AddChain adds the chain of tipsets to the BadTipSetCache.  For now it just
-// does the simplest thing and adds all blocks of the chain to the cache.
-// TODO: might want to cache a random subset once cache size is limited.
-func (cache *BadTipSetCache) AddChain(chain []block.TipSet) {
-	for _, ts := range chain {
-		cache.Add(ts.String())
+// NewBadTipSetCache creates a cache persisting entries to ds and loads the
+// entries persisted before. Entries older than expiry are dropped, unless
+// expiry is zero.
+func NewBadTipSetCache(ds datastore.Datastore, expiry time.Duration, c clock.Clock) (*BadTipSetCache, error) {
+	cache := &BadTipSetCache{
+		bad:    make(map[string]BadTipSet),
+		ds:     ds,
+		clock:  c,
+		expiry: expiry,
+	}
+
+	results, err := ds.Query(query.Query{Prefix: badTipSetPrefix.String()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query bad tipsets")
+	}
+	defer results.Close() // nolint: errcheck
+	var expired []datastore.Key
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, errors.Wrap(entry.Error, "failed to read bad tipsets")
+		}
+		var record badTipSetRecord
+		if err := encoding.Decode(entry.Value, &record); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode bad tipset %s", entry.Key)
+		}
+		bad := BadTipSet{
+			Key:      record.Key,
+			Height:   record.Height,
+			Reason:   record.Reason,
+			Rejected: time.Unix(record.Rejected, 0),
+		}
+		if cache.expired(bad) {
+			expired = append(expired, datastore.NewKey(entry.Key))
+			continue
+		}
+		cache.bad[bad.Key.String()] = bad
+	}
+
+	for _, key := range expired {
+		if err := ds.Delete(key); err != nil {
+			return nil, errors.Wrapf(err, "failed to delete expired bad tipset %s", key)
+		}
 	}
+	return cache, nil
 }
 
-// Add adds a single tipset key to the BadTipSetCache.
-func (cache *BadTipSetCache) Add(tsKey string) {
+// AddChain marks the first tipset of the chain bad for the reason given, and
+// the tipsets built on it for descending from it. The entries are written to
+// the datastore if persist is true.
+func (cache *BadTipSetCache) AddChain(chain []block.TipSet, reason string, persist bool) {
+	for i, ts := range chain {
+		if i > 0 {
+			reason = fmt.Sprintf("descends from bad tipset %s", chain[0].Key())
+		}
+		cache.Add(ts, reason, persist)
+	}
+}
+
+// Add marks a single tipset bad, and writes it to the datastore if persist is
+// true. Failing to persist it is logged, the tipset is refused until restart
+// regardless.
+func (cache *BadTipSetCache) Add(ts block.TipSet, reason string, persist bool) {
+	bad := BadTipSet{
+		Key:      ts.Key(),
+		Height:   ts.At(0).Height,
+		Reason:   reason,
+		Rejected: cache.clock.Now(),
+	}
+
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	cache.bad[tsKey] = struct{}{}
+	cache.bad[bad.Key.String()] = bad
+	if !persist {
+		return
+	}
+
+	val, err := encoding.Encode(badTipSetRecord{
+		Key:      bad.Key,
+		Height:   bad.Height,
+		Reason:   bad.Reason,
+		Rejected: bad.Rejected.Unix(),
+	})
+	if err == nil {
+		err = cache.ds.Put(badTipSetDatastoreKey(bad.Key), val)
+	}
+	if err != nil {
+		logSyncer.Errorf("failed to persist bad tipset %s: %s", bad.Key, err)
+	}
 }
 
 // Has checks for membership in the BadTipSetCache.
 func (cache *BadTipSetCache) Has(tsKey string) bool {
 	cache.mu.Lock()
 	defer cache.mu.Unlock()
-	_, ok := cache.bad[tsKey]
-	return ok
+	bad, ok := cache.bad[tsKey]
+	if !ok {
+		return false
+	}
+	if cache.expired(bad) {
+		_ = cache.remove(bad.Key)
+		return false
+	}
+	return true
+}
+
+// List returns the bad tipsets that have not expired, highest first.
+func (cache *BadTipSetCache) List() []BadTipSet {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	out := make([]BadTipSet, 0, len(cache.bad))
+	for _, bad := range cache.bad {
+		if cache.expired(bad) {
+			_ = cache.remove(bad.Key)
+			continue
+		}
+		out = append(out, bad)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Height != out[j].Height {
+			return out[i].Height > out[j].Height
+		}
+		return out[i].Key.String() < out[j].Key.String()
+	})
+	return out
+}
+
+// Remove forgets that the tipset at key is bad, so that it is validated again
+// when next seen.
+func (cache *BadTipSetCache) Remove(key block.TipSetKey) error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	if _, ok := cache.bad[key.String()]; !ok {
+		return errors.Errorf("tipset %s is not marked bad", key)
+	}
+	return cache.remove(key)
+}
+
+// Clear forgets every bad tipset.
+func (cache *BadTipSetCache) Clear() error {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	for _, bad := range cache.bad {
+		if err := cache.remove(bad.Key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes the entry for key. The caller must hold the lock.
+func (cache *BadTipSetCache) remove(key block.TipSetKey) error {
+	if err := cache.ds.Delete(badTipSetDatastoreKey(key)); err != nil && err != datastore.ErrNotFound {
+		return errors.Wrapf(err, "failed to delete bad tipset %s", key)
+	}
+	delete(cache.bad, key.String())
+	return nil
+}
+
+func (cache *BadTipSetCache) expired(bad BadTipSet) bool {
+	return cache.expiry != 0 && cache.clock.Now().Sub(bad.Rejected) > cache.expiry
+}
+
+func badTipSetDatastoreKey(key block.TipSetKey) datastore.Key {
+	cids := key.ToSlice()
+	names := make([]string, len(cids))
+	for i, c := range cids {
+		names[i] = c.String()
+	}
+	return badTipSetPrefix.ChildString(strings.Join(names, "-"))
 }
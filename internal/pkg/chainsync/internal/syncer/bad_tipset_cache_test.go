@@ -0,0 +1,88 @@
+package syncer_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/internal/syncer"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestBadTipSetCachePersists(t *testing.T) {
+	tf.UnitTest(t)
+	builder := chain.NewBuilder(t, address.Undef)
+	bad := builder.AppendOn(builder.NewGenesis(), 1)
+	child := builder.AppendOn(bad, 2)
+	ds := datastore.NewMapDatastore()
+
+	cache := newBadTipSetCache(t, ds)
+	cache.AddChain([]block.TipSet{bad, child}, "invalid state transition", true)
+
+	// A cache reloaded from the datastore, as after a restart, refuses the chain.
+	reloaded := newBadTipSetCache(t, ds)
+	assert.True(t, reloaded.Has(bad.String()))
+	assert.True(t, reloaded.Has(child.String()))
+	list := reloaded.List()
+	require.Len(t, list, 2)
+	assert.Equal(t, child.Key(), list[0].Key)
+	assert.Equal(t, child.At(0).Height, list[0].Height)
+	assert.Contains(t, list[0].Reason, bad.Key().String())
+	assert.Equal(t, bad.Key(), list[1].Key)
+	assert.Equal(t, "invalid state transition", list[1].Reason)
+
+	require.NoError(t, reloaded.Remove(bad.Key()))
+	assert.Error(t, reloaded.Remove(bad.Key()))
+	assert.False(t, newBadTipSetCache(t, ds).Has(bad.String()))
+	assert.True(t, newBadTipSetCache(t, ds).Has(child.String()))
+
+	require.NoError(t, reloaded.Clear())
+	assert.Empty(t, reloaded.List())
+	assert.Empty(t, newBadTipSetCache(t, ds).List())
+}
+
+func TestBadTipSetCacheKeepsTransientEntriesInMemory(t *testing.T) {
+	tf.UnitTest(t)
+	builder := chain.NewBuilder(t, address.Undef)
+	bad := builder.AppendOn(builder.NewGenesis(), 1)
+	ds := datastore.NewMapDatastore()
+
+	cache := newBadTipSetCache(t, ds)
+	cache.Add(bad, "failed to load messages", false)
+	assert.True(t, cache.Has(bad.String()))
+	assert.Len(t, cache.List(), 1)
+
+	assert.False(t, newBadTipSetCache(t, ds).Has(bad.String()))
+	require.NoError(t, cache.Remove(bad.Key()))
+	assert.False(t, cache.Has(bad.String()))
+}
+
+func TestBadTipSetCacheExpiry(t *testing.T) {
+	tf.UnitTest(t)
+	builder := chain.NewBuilder(t, address.Undef)
+	bad := builder.AppendOn(builder.NewGenesis(), 1)
+	ds := datastore.NewMapDatastore()
+	fc := clock.NewFake(time.Unix(1234567890, 0))
+
+	cache, err := syncer.NewBadTipSetCache(ds, time.Hour, fc)
+	require.NoError(t, err)
+	cache.Add(bad, "invalid state transition", true)
+
+	fc.Advance(30 * time.Minute)
+	assert.True(t, cache.Has(bad.String()))
+
+	// Expired entries are dropped from the datastore when loading.
+	fc.Advance(31 * time.Minute)
+	reloaded, err := syncer.NewBadTipSetCache(ds, time.Hour, fc)
+	require.NoError(t, err)
+	assert.Empty(t, reloaded.List())
+	assert.False(t, cache.Has(bad.String()))
+	assert.Empty(t, newBadTipSetCache(t, ds).List())
+}
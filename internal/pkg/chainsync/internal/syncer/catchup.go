@@ -0,0 +1,194 @@
+package syncer
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/status"
+)
+
+// CatchupRangeSize is the number of tipsets fetched from a single peer at a
+// time during catch-up. Shorter chains are fetched in one request.
+const CatchupRangeSize = 100
+
+// CatchupFetchers is the number of ranges fetched in parallel during catch-up.
+const CatchupFetchers = 4
+
+// PeerLister lists known peers along with the heads they claim.
+type PeerLister interface {
+	List() []*block.ChainInfo
+}
+
+// chainFetch tracks the fetching of full blocks for a chain of validated
+// headers, split into height ordered ranges. A tipset can be validated as soon
+// as the range holding it has arrived.
+type chainFetch struct {
+	rangeSize int
+	ranges    []*fetchRange
+	cancel    context.CancelFunc
+}
+
+type fetchRange struct {
+	// lo and hi are the indices of the first and one past the last tipset of
+	// the range in the chain.
+	lo, hi int
+	done   chan struct{}
+	err    error
+}
+
+func newChainFetch(length, rangeSize int, cancel context.CancelFunc) *chainFetch {
+	f := &chainFetch{rangeSize: rangeSize, cancel: cancel}
+	for lo := 0; lo < length; lo += rangeSize {
+		hi := lo + rangeSize
+		if hi > length {
+			hi = length
+		}
+		f.ranges = append(f.ranges, &fetchRange{lo: lo, hi: hi, done: make(chan struct{})})
+	}
+	return f
+}
+
+// wait blocks until the range holding the i-th tipset of the chain has been
+// fetched and returns the error fetching it, if any.
+func (f *chainFetch) wait(ctx context.Context, i int) error {
+	r := f.ranges[i/f.rangeSize]
+	select {
+	case <-r.done:
+		return r.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *fetchRange) finish(err error) {
+	r.err = err
+	close(r.done)
+}
+
+// fetchFullBlocks fetches the messages of a chain of validated headers, in
+// height order. Outside of catch-up, or for short chains, the whole chain is
+// fetched from the peer that announced it before returning. During catch-up
+// the chain is split into ranges which are fetched from several peers in
+// parallel, lowest first, so that validation can run behind the fetch.
+func (syncer *Syncer) fetchFullBlocks(ctx context.Context, ci *block.ChainInfo, tipsets []block.TipSet, catchup bool) *chainFetch {
+	if !catchup || len(tipsets) <= CatchupRangeSize {
+		f := newChainFetch(len(tipsets), len(tipsets), func() {})
+		_, err := syncer.fetcher.FetchTipSets(ctx, ci.Head, ci.Sender, func(t block.TipSet) (bool, error) {
+			parents, err := t.Parents()
+			if err != nil {
+				return true, err
+			}
+			height, err := t.Height()
+			if err != nil {
+				return false, err
+			}
+
+			// update status with latest fetched head and height
+			syncer.reporter.UpdateStatus(status.FetchHead(t.Key()), status.FetchHeight(height))
+			return syncer.chainStore.HasTipSetAndState(ctx, parents), nil
+		})
+		if err == nil {
+			syncer.reporter.UpdateStatus(status.SyncFetchComplete(true))
+		}
+		f.ranges[0].finish(err)
+		return f
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	f := newChainFetch(len(tipsets), CatchupRangeSize, cancel)
+	peers := syncer.catchupPeers(ci)
+	logSyncer.Infof("catching up %d tipsets to %s in %d ranges from %d peers", len(tipsets), ci.Head, len(f.ranges), len(peers))
+
+	queue := make(chan int, len(f.ranges))
+	for i := range f.ranges {
+		queue <- i
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for w := 0; w < CatchupFetchers && w < len(f.ranges); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				r := f.ranges[i]
+				r.finish(syncer.fetchRange(ctx, tipsets[r.lo], tipsets[r.hi-1], peers[i%len(peers)], ci.Sender))
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		// The sync is over, and another may have started, if it was cancelled.
+		if ctx.Err() == nil {
+			syncer.reporter.UpdateStatus(status.SyncFetchComplete(true))
+		}
+	}()
+	return f
+}
+
+// fetchRange fetches the full blocks of the tipsets from top down to bottom
+// from a peer, retrying with the peer that announced the chain on failure.
+func (syncer *Syncer) fetchRange(ctx context.Context, bottom, top block.TipSet, from, announcer peer.ID) error {
+	fetch := func(p peer.ID) error {
+		_, err := syncer.fetcher.FetchTipSets(ctx, top.Key(), p, func(t block.TipSet) (bool, error) {
+			return t.Equals(bottom), nil
+		})
+		return err
+	}
+
+	err := fetch(from)
+	if err != nil && from != announcer && ctx.Err() == nil {
+		logSyncer.Infof("failed to fetch tipsets %d to %d from %s, retrying from %s: %s", bottom.At(0).Height, top.At(0).Height, from, announcer, err)
+		err = fetch(announcer)
+	}
+	if err != nil {
+		return err
+	}
+	syncer.reporter.UpdateStatus(status.FetchHead(top.Key()), status.FetchHeight(top.At(0).Height))
+	return nil
+}
+
+// catchupPeers returns the peers to fetch a chain from: the peer that
+// announced it, followed by every other peer claiming a head at least as high.
+func (syncer *Syncer) catchupPeers(ci *block.ChainInfo) []peer.ID {
+	peers := []peer.ID{ci.Sender}
+	if syncer.peers == nil {
+		return peers
+	}
+	for _, p := range syncer.peers.List() {
+		if p.Sender != ci.Sender && p.Height >= ci.Height {
+			peers = append(peers, p.Sender)
+		}
+	}
+	return peers
+}
+
+// syncProgress estimates the validation rate of a chain and the time left to
+// reach its head.
+type syncProgress struct {
+	start  time.Time
+	base   abi.ChainEpoch
+	target abi.ChainEpoch
+}
+
+func newSyncProgress(start time.Time, base, target abi.ChainEpoch) *syncProgress {
+	return &syncProgress{start: start, base: base, target: target}
+}
+
+// update returns the status updates for a chain validated up to height validated.
+func (p *syncProgress) update(now time.Time, validated abi.ChainEpoch) []status.UpdateFn {
+	rate := 0.0
+	remaining := time.Duration(0)
+	if elapsed := now.Sub(p.start).Seconds(); elapsed > 0 && validated > p.base {
+		rate = float64(validated-p.base) / elapsed
+		if validated < p.target {
+			remaining = time.Duration(float64(p.target-validated) / rate * float64(time.Second))
+		}
+	}
+	return []status.UpdateFn{status.SyncValidatedHeight(validated), status.SyncRate(rate), status.SyncTimeRemaining(remaining)}
+}
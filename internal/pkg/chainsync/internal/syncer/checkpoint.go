@@ -0,0 +1,139 @@
+package syncer
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+)
+
+var (
+	// ErrChainMissesCheckpoint is returned when syncing a chain that does not include the checkpoint tipset.
+	ErrChainMissesCheckpoint = errors.New("input chain does not include the checkpoint")
+	// ErrReorgTooDeep is returned when a heavier chain forked from the staged chain past the maximum reorg depth.
+	ErrReorgTooDeep = errors.New("input chain forked from best chain past the maximum reorg depth")
+)
+
+// QuarantinedFork is a heavier chain the syncer did not adopt because
+// switching to it would have reorganized more tipsets than allowed.
+type QuarantinedFork struct {
+	// Head is the heaviest tipset seen on the fork.
+	Head block.TipSetKey
+	// Height is the height of Head.
+	Height abi.ChainEpoch
+	// CommonAncestor is the tipset at which the fork left the staged chain.
+	CommonAncestor block.TipSetKey
+	// Depth is the number of epochs of the staged chain the fork would drop.
+	Depth abi.ChainEpoch
+	// Received is the time Head was quarantined.
+	Received time.Time
+}
+
+// quarantine holds the forks refused for being too deep, keyed by the
+// ancestor they forked from, so a growing fork is listed once.
+type quarantine struct {
+	mu    sync.Mutex
+	forks map[string]QuarantinedFork
+}
+
+func (q *quarantine) add(fork QuarantinedFork) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.forks[fork.CommonAncestor.String()] = fork
+}
+
+// list returns the quarantined forks, most recent first.
+func (q *quarantine) list() []QuarantinedFork {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]QuarantinedFork, 0, len(q.forks))
+	for _, fork := range q.forks {
+		out = append(out, fork)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Received.After(out[j].Received) })
+	return out
+}
+
+// checkCheckpoint returns ErrChainMissesCheckpoint if the chain ending in
+// tipsets, which are ordered by height and not yet in the store, does not
+// include the checkpoint tipset.
+func (syncer *Syncer) checkCheckpoint(ctx context.Context, tipsets []block.TipSet) error {
+	key := syncer.chainStore.GetCheckpoint()
+	if key.Empty() {
+		return nil
+	}
+	checkpoint, err := syncer.chainStore.GetTipSet(key)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load checkpoint %s", key)
+	}
+	checkpointHeight, err := checkpoint.Height()
+	if err != nil {
+		return err
+	}
+
+	// The checkpoint is in the store and the new tipsets are not, so the
+	// checkpoint must be an ancestor of the first of them.
+	if tipsets[0].At(0).Height <= checkpointHeight {
+		return errors.Wrapf(ErrChainMissesCheckpoint, "chain forks at height %d, below checkpoint %s at %d", tipsets[0].At(0).Height, key, checkpointHeight)
+	}
+	parent, _, err := syncer.ancestorsFromStore(tipsets[0])
+	if err != nil {
+		return err
+	}
+	ancestor, err := chain.FindTipsetAtEpoch(ctx, parent, checkpointHeight, syncer.chainStore)
+	if err != nil {
+		return err
+	}
+	if !ancestor.Equals(checkpoint) {
+		return errors.Wrapf(ErrChainMissesCheckpoint, "chain holds %s at checkpoint height %d", ancestor.Key(), checkpointHeight)
+	}
+	return nil
+}
+
+// checkReorgDepth returns ErrReorgTooDeep, and quarantines the candidate, if
+// staging the candidate would drop more than the maximum reorg depth from the
+// staged chain.
+func (syncer *Syncer) checkReorgDepth(ctx context.Context, candidate block.TipSet) error {
+	if syncer.maxReorgDepth == 0 {
+		return nil
+	}
+	stagedIter := chain.IterAncestors(ctx, syncer.chainStore, syncer.staged)
+	candidateIter := chain.IterAncestors(ctx, syncer.chainStore, candidate)
+	commonAncestor, err := chain.FindCommonAncestor(stagedIter, candidateIter)
+	if err != nil {
+		return err
+	}
+	dropped, _, err := chain.ReorgDiff(syncer.staged, candidate, commonAncestor)
+	if err != nil {
+		return err
+	}
+	if dropped <= syncer.maxReorgDepth {
+		return nil
+	}
+
+	syncer.quarantine.add(QuarantinedFork{
+		Head:           candidate.Key(),
+		Height:         candidate.At(0).Height,
+		CommonAncestor: commonAncestor.Key(),
+		Depth:          dropped,
+		Received:       syncer.clock.Now(),
+	})
+	logSyncer.With(
+		"currentHead", syncer.staged,
+		"newHead", candidate,
+		"commonAncestor", commonAncestor,
+	).Warnf("quarantining heavier fork that would drop %d epochs, more than the maximum reorg depth of %d", dropped, syncer.maxReorgDepth)
+	return errors.Wrapf(ErrReorgTooDeep, "fork would drop %d epochs", dropped)
+}
+
+// Quarantined returns the heavier forks that were not adopted for being
+// deeper than the maximum reorg depth, most recent first.
+func (syncer *Syncer) Quarantined() []QuarantinedFork {
+	return syncer.quarantine.list()
+}
@@ -17,6 +17,7 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/status"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/metrics"
 	"github.com/sbwtw/go-filecoin/internal/pkg/metrics/tracing"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
@@ -63,6 +64,15 @@ type Syncer struct {
 
 	// Reporter is used by the syncer to update the current status of the chain.
 	reporter status.Reporter
+
+	// peers lists the peers catch-up sync may fetch chain ranges from.
+	peers PeerLister
+
+	// maxReorgDepth is the number of epochs of the staged chain a heavier
+	// fork may drop before it is quarantined rather than staged. Zero
+	// leaves forks bounded only by the finality limit.
+	maxReorgDepth abi.ChainEpoch
+	quarantine    *quarantine
 }
 
 // Fetcher defines an interface that may be used to fetch data from the network.
@@ -80,6 +90,7 @@ type Fetcher interface {
 // ChainReaderWriter reads and writes the chain store.
 type ChainReaderWriter interface {
 	GetHead() block.TipSetKey
+	GetCheckpoint() block.TipSetKey
 	GetTipSet(tsKey block.TipSetKey) (block.TipSet, error)
 	GetTipSetStateRoot(tsKey block.TipSetKey) (cid.Cid, error)
 	GetTipSetReceiptsRoot(tsKey block.TipSetKey) (cid.Cid, error)
@@ -150,12 +161,13 @@ var logSyncer = logging.Logger("chainsync.syncer")
 
 // NewSyncer constructs a Syncer ready for use.  The chain reader must have a
 // head tipset to initialize the staging field.
-func NewSyncer(fv FullBlockValidator, hv HeaderValidator, cs ChainSelector, s ChainReaderWriter, m messageStore, f Fetcher, sr status.Reporter, c clock.Clock, fd faultDetector) (*Syncer, error) {
+// Peers may be nil, in which case chains are only fetched from the peer that
+// announced them. A zero maxReorgDepth disables the reorg depth limit.
+// Tipsets failing validation are recorded in bad.
+func NewSyncer(fv FullBlockValidator, hv HeaderValidator, cs ChainSelector, s ChainReaderWriter, m messageStore, f Fetcher, sr status.Reporter, c clock.Clock, fd faultDetector, peers PeerLister, maxReorgDepth abi.ChainEpoch, bad *BadTipSetCache) (*Syncer, error) {
 	return &Syncer{
-		fetcher: f,
-		badTipSets: &BadTipSetCache{
-			bad: make(map[string]struct{}),
-		},
+		fetcher:         f,
+		badTipSets:      bad,
 		fullValidator:   fv,
 		headerValidator: hv,
 		chainSelector:   cs,
@@ -164,6 +176,11 @@ func NewSyncer(fv FullBlockValidator, hv HeaderValidator, cs ChainSelector, s Ch
 		clock:           c,
 		faultDetector:   fd,
 		reporter:        sr,
+		peers:           peers,
+		maxReorgDepth:   maxReorgDepth,
+		quarantine: &quarantine{
+			forks: make(map[string]QuarantinedFork),
+		},
 	}, nil
 }
 
@@ -195,6 +212,9 @@ func (syncer *Syncer) fetchAndValidateHeaders(ctx context.Context, ci *block.Cha
 		return nil, err
 	}
 	headers, err := syncer.fetcher.FetchTipSetHeaders(ctx, ci.Head, ci.Sender, func(t block.TipSet) (bool, error) {
+		if syncer.badTipSets.Has(t.String()) {
+			return true, ErrChainHasBadTipSet
+		}
 		h, err := t.Height()
 		if err != nil {
 			return true, err
@@ -285,7 +305,7 @@ func (syncer *Syncer) syncOne(ctx context.Context, grandParent, parent, next blo
 	// a new state to add to the store.
 	root, receipts, err := syncer.fullValidator.RunStateTransition(ctx, next, nextBlsMessages, nextSecpMessages, parentWeight, stateRoot, parentReceiptRoot)
 	if err != nil {
-		return err
+		return errors.Wrapf(err, "failed to validate tipset %s", next.Key())
 	}
 
 	// Now that the tipset is validated preconditions are satisfied to check
@@ -293,7 +313,7 @@ func (syncer *Syncer) syncOne(ctx context.Context, grandParent, parent, next blo
 	for i := 0; i < next.Len(); i++ {
 		err := syncer.faultDetector.CheckBlock(next.At(i), parent)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "failed to check block %s for consensus faults", next.At(i).Cid())
 		}
 	}
 
@@ -451,7 +471,7 @@ func (syncer *Syncer) widen(ctx context.Context, ts block.TipSet) (block.TipSet,
 // HandleNewTipSet validates and syncs the chain rooted at the provided tipset
 // to a chain store.  Iff catchup is false then the syncer will set the head.
 func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo, catchup bool) error {
-	err := syncer.handleNewTipSet(ctx, ci)
+	err := syncer.handleNewTipSet(ctx, ci, catchup)
 	if err != nil {
 		return err
 	}
@@ -461,7 +481,7 @@ func (syncer *Syncer) HandleNewTipSet(ctx context.Context, ci *block.ChainInfo,
 	return syncer.SetStagedHead(ctx)
 }
 
-func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo) (err error) {
+func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo, catchup bool) (err error) {
 	// handleNewTipSet extends the Syncer's chain store with the given tipset if
 	// the chain is a valid extension.  It stages new heaviest tipsets for later
 	// setting the chain head
@@ -483,36 +503,36 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 	if err != nil {
 		return errors.Wrapf(err, "failure fetching or validating headers")
 	}
-
-	// Once headers check out, fetch messages
-	_, err = syncer.fetcher.FetchTipSets(ctx, ci.Head, ci.Sender, func(t block.TipSet) (bool, error) {
-		parents, err := t.Parents()
-		if err != nil {
-			return true, err
-		}
-		height, err := t.Height()
-		if err != nil {
-			return false, err
-		}
-
-		// update status with latest fetched head and height
-		syncer.reporter.UpdateStatus(status.FetchHead(t.Key()), status.FetchHeight(height))
-		return syncer.chainStore.HasTipSetAndState(ctx, parents), nil
-	})
-	if err != nil {
-		return errors.Wrapf(err, "failure fetching full blocks")
+	if err := syncer.checkCheckpoint(ctx, tipsets); err != nil {
+		return err
 	}
 
-	syncer.reporter.UpdateStatus(status.SyncFetchComplete(true))
+	// Once headers check out, fetch messages. Tipsets are validated as soon
+	// as their messages arrive.
+	fetch := syncer.fetchFullBlocks(ctx, ci, tipsets, catchup)
+	defer fetch.cancel()
 
 	parent, grandParent, err := syncer.ancestorsFromStore(tipsets[0])
 	if err != nil {
 		return err
 	}
 
+	progress := newSyncProgress(syncer.clock.Now(), parent.At(0).Height, ci.Height)
+	syncer.reporter.UpdateStatus(progress.update(syncer.clock.Now(), parent.At(0).Height)...)
+
 	// Try adding the tipsets of the chain to the store, checking for new
 	// heaviest tipsets.
 	for i, ts := range tipsets {
+		if fetchErr := fetch.wait(ctx, i); fetchErr != nil {
+			// Keep what has been validated so far, it need not be fetched again.
+			if i > 0 {
+				if err := syncer.stageIfHeaviest(ctx, parent); err != nil {
+					logSyncer.Warnf("failed to stage partially synced chain: %s", err)
+				}
+			}
+			return errors.Wrapf(fetchErr, "failure fetching full blocks")
+		}
+
 		// TODO: this "i==0" leaks EC specifics into syncer abstraction
 		// for the sake of efficiency, consider plugging up this leak.
 		var wts block.TipSet
@@ -542,16 +562,16 @@ func (syncer *Syncer) handleNewTipSet(ctx context.Context, ci *block.ChainInfo)
 		if !wts.Defined() || len(tipsets) > 1 {
 			err = syncer.syncOne(ctx, grandParent, parent, ts)
 			if err != nil {
-				// While `syncOne` can indeed fail for reasons other than consensus,
-				// adding to the badTipSets at this point is the simplest, since we
-				// have access to the chain. If syncOne fails for non-consensus reasons,
-				// there is no assumption that the running node's data is valid at all,
-				// so we don't really lose anything with this simplification.
-				syncer.badTipSets.AddChain(tipsets[i:])
+				// `syncOne` can fail for reasons other than consensus, such as a
+				// missing message or a failing store. The chain is then refused
+				// until restart only, while chains failing consensus validation
+				// stay refused across restarts.
+				syncer.badTipSets.AddChain(tipsets[i:], err.Error(), consensus.IsInvalid(err))
 				return errors.Wrapf(err, "failed to sync tipset %s, number %d of %d in chain", ts.Key(), i, len(tipsets))
 			}
 		}
 
+		syncer.reporter.UpdateStatus(progress.update(syncer.clock.Now(), ts.At(0).Height)...)
 		if i%500 == 0 {
 			logSyncer.Infof("processing block %d of %v for chain with head at %v", i, len(tipsets), ci.Head.String())
 		}
@@ -594,6 +614,9 @@ func (syncer *Syncer) stageIfHeaviest(ctx context.Context, candidate block.TipSe
 
 	// If it is the heaviest update the chainStore.
 	if heavier {
+		if err := syncer.checkReorgDepth(ctx, candidate); err != nil {
+			return err
+		}
 		// Gather the entire new chain for reorg comparison and logging.
 		syncer.logReorg(ctx, syncer.staged, candidate)
 		syncer.staged = candidate
@@ -602,6 +625,28 @@ func (syncer *Syncer) stageIfHeaviest(ctx context.Context, candidate block.TipSe
 	return nil
 }
 
+// IsBadTipSet returns true if the tipset failed validation, or belongs to a chain
+// that did.
+func (syncer *Syncer) IsBadTipSet(key block.TipSetKey) bool {
+	return syncer.badTipSets.Has(key.String())
+}
+
+// BadTipSets returns the tipsets the syncer refuses for failing validation.
+func (syncer *Syncer) BadTipSets() []BadTipSet {
+	return syncer.badTipSets.List()
+}
+
+// RemoveBadTipSet forgets that the tipset at key failed validation, so that
+// it is validated again when next seen.
+func (syncer *Syncer) RemoveBadTipSet(key block.TipSetKey) error {
+	return syncer.badTipSets.Remove(key)
+}
+
+// ClearBadTipSets forgets every tipset that failed validation.
+func (syncer *Syncer) ClearBadTipSets() error {
+	return syncer.badTipSets.Clear()
+}
+
 // Status returns the current syncer status.
 func (syncer *Syncer) Status() status.Status {
 	return syncer.reporter.Status()
@@ -2,6 +2,7 @@ package syncer_test
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	bstore "github.com/ipfs/go-ipfs-blockstore"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/pkg/errors"
@@ -22,6 +24,7 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/internal/syncer"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/status"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
 	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
@@ -225,6 +228,62 @@ func TestRejectFinalityFork(t *testing.T) {
 	assert.Error(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", forkFinalityHead.Key(), heightFromTip(t, forkFinalityHead)), false))
 }
 
+func TestRejectChainMissingCheckpoint(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, s := setup(ctx, t)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	forkbase := builder.AppendOn(genesis, 1)
+	main2 := builder.AppendManyOn(2, forkbase)
+	main4 := builder.AppendManyOn(2, main2)
+	fork := builder.AppendManyOn(3, builder.AppendOn(forkbase, 3))
+
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", main4.Key(), heightFromTip(t, main4)), false))
+	require.NoError(t, store.SetCheckpoint(main2.Key()))
+
+	// The heavier fork left the chain below the checkpoint.
+	err := s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", fork.Key(), heightFromTip(t, fork)), false)
+	assert.Equal(t, syncer.ErrChainMissesCheckpoint, errors.Cause(err))
+	verifyHead(t, store, main4)
+
+	// Extensions of the checkpointed chain are accepted.
+	main5 := builder.AppendOn(main4, 1)
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", main5.Key(), heightFromTip(t, main5)), false))
+	verifyHead(t, store, main5)
+	assert.False(t, s.IsBadTipSet(fork.Key()))
+}
+
+func TestQuarantineDeepReorg(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder, store, s := setupWithFetcher(ctx, t, &chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{},
+		func(b *chain.Builder) syncer.Fetcher { return b }, nil, 2)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	forkbase := builder.AppendOn(genesis, 1)
+	main3 := builder.AppendManyOn(3, forkbase)
+	main4 := builder.AppendOn(main3, 1)
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", main4.Key(), heightFromTip(t, main4)), false))
+
+	// Adopting the heavier fork would drop four epochs.
+	deep := builder.AppendOn(builder.AppendOn(forkbase, 4), 1)
+	err := s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", deep.Key(), heightFromTip(t, deep)), false)
+	assert.Equal(t, syncer.ErrReorgTooDeep, errors.Cause(err))
+	verifyHead(t, store, main4)
+
+	quarantined := s.Quarantined()
+	require.Len(t, quarantined, 1)
+	assert.Equal(t, deep.Key(), quarantined[0].Head)
+	assert.Equal(t, forkbase.Key(), quarantined[0].CommonAncestor)
+	assert.Equal(t, abi.ChainEpoch(4), quarantined[0].Depth)
+
+	// A heavier fork dropping a single epoch is adopted.
+	shallow := builder.AppendOn(main3, 3)
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", shallow.Key(), heightFromTip(t, shallow)), false))
+	verifyHead(t, store, shallow)
+}
+
 func TestNoUncessesaryFetch(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -237,7 +296,7 @@ func TestNoUncessesaryFetch(t *testing.T) {
 	// A new syncer unable to fetch blocks from the network can handle a tipset that's already
 	// in the store and linked to genesis.
 	emptyFetcher := chain.NewBuilder(t, address.Undef)
-	newSyncer, err := syncer.NewSyncer(&chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{}, &chain.FakeChainSelector{}, store, builder, emptyFetcher, status.NewReporter(), clock.NewFake(time.Unix(1234567890, 0)), &noopFaultDetector{})
+	newSyncer, err := syncer.NewSyncer(&chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{}, &chain.FakeChainSelector{}, store, builder, emptyFetcher, status.NewReporter(), clock.NewFake(time.Unix(1234567890, 0)), &noopFaultDetector{}, nil, 0, newBadTipSetCache(t, datastore.NewMapDatastore()))
 	require.NoError(t, err)
 	require.NoError(t, newSyncer.InitStaged())
 	assert.NoError(t, newSyncer.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", head.Key(), heightFromTip(t, head)), false))
@@ -421,7 +480,7 @@ func (pv *poisonValidator) RunStateTransition(_ context.Context, ts block.TipSet
 	_ fbig.Int, _ cid.Cid, _ cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
 	stamp := ts.At(0).Timestamp
 	if pv.fullFailureTS == stamp {
-		return cid.Undef, nil, errors.New("run state transition fails on poison timestamp")
+		return cid.Undef, nil, consensus.Invalid(errors.New("run state transition fails on poison timestamp"))
 	}
 	return cid.Undef, nil, nil
 }
@@ -462,6 +521,29 @@ func TestSemanticallyBadTipSetFails(t *testing.T) {
 	assert.Contains(t, err.Error(), "val semantic fails")
 }
 
+func TestChainWithBadTipSetRejected(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	eval := newPoisonValidator(t, 98, 99)
+	builder, store, s := setupWithValidator(ctx, t, eval, eval)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	bad := builder.BuildOneOn(genesis, func(bb *chain.BlockBuilder) {
+		bb.SetTimestamp(99) // poison state transition
+	})
+	err := s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", bad.Key(), heightFromTip(t, bad)), false)
+	require.Error(t, err)
+	assert.True(t, consensus.IsInvalid(err))
+	assert.True(t, s.IsBadTipSet(bad.Key()))
+
+	// Descendants of the bad tipset are rejected without being validated again.
+	child := builder.AppendOn(bad, 1)
+	err = s.HandleNewTipSet(ctx, block.NewChainInfo(peer.ID(""), "", child.Key(), heightFromTip(t, child)), false)
+	require.Error(t, err)
+	assert.Equal(t, syncer.ErrChainHasBadTipSet, errors.Cause(err))
+	verifyHead(t, store, genesis)
+}
+
 func TestSyncerStatus(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -514,6 +596,63 @@ func TestSyncerStatus(t *testing.T) {
 	assert.Equal(t, true, s2.SyncingComplete)
 }
 
+func TestCatchupFetchesRangesFromPeers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	var fetcher *rangeFetcher
+	peers := &fakePeerLister{}
+	builder, store, s := setupWithFetcher(ctx, t, &chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{}, func(b *chain.Builder) syncer.Fetcher {
+		fetcher = newRangeFetcher(b)
+		return fetcher
+	}, peers, 0)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	head := builder.AppendManyOn(2*syncer.CatchupRangeSize+50, genesis)
+	height := heightFromTip(t, head)
+	peers.chains = []*block.ChainInfo{
+		block.NewChainInfo("ahead", "", head.Key(), height+1),
+		block.NewChainInfo("behind", "", genesis.Key(), 0),
+	}
+
+	require.NoError(t, s.HandleNewTipSet(ctx, block.NewChainInfo("sender", "", head.Key(), height), true))
+	verifyTip(t, store, head, builder.StateForKey(head.Key()))
+	require.NoError(t, s.SetStagedHead(ctx))
+	verifyHead(t, store, head)
+
+	// The chain is fetched in three ranges, from the sender and the peer ahead of it.
+	assert.Equal(t, 3, fetcher.requests())
+	assert.ElementsMatch(t, []peer.ID{"sender", "ahead"}, fetcher.peers())
+
+	st := s.Status()
+	assert.True(t, st.SyncingFetchComplete)
+	assert.Equal(t, height, st.SyncingValidatedHeight)
+	assert.Equal(t, time.Duration(0), st.SyncingTimeRemaining)
+}
+
+func TestCatchupKeepsProgressOnFetchFailure(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	var fetcher *rangeFetcher
+	builder, store, s := setupWithFetcher(ctx, t, &chain.FakeStateEvaluator{}, &chain.FakeStateEvaluator{}, func(b *chain.Builder) syncer.Fetcher {
+		fetcher = newRangeFetcher(b)
+		return fetcher
+	}, nil, 0)
+	genesis := builder.RequireTipSet(store.GetHead())
+
+	synced := builder.AppendManyOn(2*syncer.CatchupRangeSize, genesis)
+	head := builder.AppendManyOn(50, synced)
+	fetcher.fail(head.Key())
+
+	err := s.HandleNewTipSet(ctx, block.NewChainInfo("sender", "", head.Key(), heightFromTip(t, head)), true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failure fetching full blocks")
+
+	// The ranges fetched before the failure are validated and kept.
+	require.NoError(t, s.SetStagedHead(ctx))
+	verifyHead(t, store, synced)
+	assert.False(t, s.IsBadTipSet(head.Key()))
+}
+
 func TestStoresMessageReceipts(t *testing.T) {
 	tf.UnitTest(t)
 	ctx := context.Background()
@@ -547,6 +686,13 @@ func setup(ctx context.Context, t *testing.T) (*chain.Builder, *chain.Store, *sy
 }
 
 func setupWithValidator(ctx context.Context, t *testing.T, fullVal syncer.FullBlockValidator, headerVal syncer.HeaderValidator) (*chain.Builder, *chain.Store, *syncer.Syncer) {
+	return setupWithFetcher(ctx, t, fullVal, headerVal, func(b *chain.Builder) syncer.Fetcher { return b }, nil, 0)
+}
+
+// Initializes a syncer fetching through the fetcher wrapping the chain builder,
+// catching up from the peers listed by peers and limiting reorgs to maxReorgDepth.
+func setupWithFetcher(ctx context.Context, t *testing.T, fullVal syncer.FullBlockValidator, headerVal syncer.HeaderValidator,
+	fetcher func(*chain.Builder) syncer.Fetcher, peers syncer.PeerLister, maxReorgDepth abi.ChainEpoch) (*chain.Builder, *chain.Store, *syncer.Syncer) {
 	builder := chain.NewBuilder(t, address.Undef)
 	genesis := builder.NewGenesis()
 	genStateRoot, err := builder.GetTipSetStateRoot(genesis.Key())
@@ -563,13 +709,20 @@ func setupWithValidator(ctx context.Context, t *testing.T, fullVal syncer.FullBl
 	// Note: the chain builder is passed as the fetcher, from which blocks may be requested, but
 	// *not* as the store, to which the syncer must ensure to put blocks.
 	sel := &chain.FakeChainSelector{}
-	syncer, err := syncer.NewSyncer(fullVal, headerVal, sel, store, builder, builder, status.NewReporter(), clock.NewFake(time.Unix(1234567890, 0)), &noopFaultDetector{})
+	syncer, err := syncer.NewSyncer(fullVal, headerVal, sel, store, builder, fetcher(builder), status.NewReporter(), clock.NewFake(time.Unix(1234567890, 0)), &noopFaultDetector{}, peers, maxReorgDepth, newBadTipSetCache(t, ds))
 	require.NoError(t, err)
 	require.NoError(t, syncer.InitStaged())
 
 	return builder, store, syncer
 }
 
+// Initializes a bad tipset cache persisting to ds, whose entries do not expire.
+func newBadTipSetCache(t *testing.T, ds datastore.Datastore) *syncer.BadTipSetCache {
+	bad, err := syncer.NewBadTipSetCache(ds, 0, clock.NewFake(time.Unix(1234567890, 0)))
+	require.NoError(t, err)
+	return bad
+}
+
 ///// Verification helpers /////
 
 // Sub-interface of the store used for verification.
@@ -614,3 +767,60 @@ func containsTipSet(tsasSlice []*chain.TipSetMetadata, ts block.TipSet) bool {
 	}
 	return false
 }
+
+// rangeFetcher fetches from a chain builder, recording the peers full blocks
+// were requested from and failing requests for chosen tipsets.
+type rangeFetcher struct {
+	*chain.Builder
+
+	lk      sync.Mutex
+	from    map[peer.ID]struct{}
+	count   int
+	failing map[string]struct{}
+}
+
+func newRangeFetcher(b *chain.Builder) *rangeFetcher {
+	return &rangeFetcher{Builder: b, from: make(map[peer.ID]struct{}), failing: make(map[string]struct{})}
+}
+
+func (f *rangeFetcher) FetchTipSets(ctx context.Context, key block.TipSetKey, from peer.ID, done func(t block.TipSet) (bool, error)) ([]block.TipSet, error) {
+	f.lk.Lock()
+	f.from[from] = struct{}{}
+	f.count++
+	_, failing := f.failing[key.String()]
+	f.lk.Unlock()
+	if failing {
+		return nil, errors.Errorf("failed to fetch %s", key)
+	}
+	return f.Builder.FetchTipSets(ctx, key, from, done)
+}
+
+func (f *rangeFetcher) fail(key block.TipSetKey) {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	f.failing[key.String()] = struct{}{}
+}
+
+func (f *rangeFetcher) requests() int {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.count
+}
+
+func (f *rangeFetcher) peers() []peer.ID {
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	var peers []peer.ID
+	for p := range f.from {
+		peers = append(peers, p)
+	}
+	return peers
+}
+
+type fakePeerLister struct {
+	chains []*block.ChainInfo
+}
+
+func (l *fakePeerLister) List() []*block.ChainInfo {
+	return l.chains
+}
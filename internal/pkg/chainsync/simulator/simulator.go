@@ -0,0 +1,274 @@
+package simulator
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fbig "github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/cborutil"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
+	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
+	"github.com/sbwtw/go-filecoin/internal/pkg/slashing"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+)
+
+// faultBufferSize is the number of consensus faults a scenario may produce
+// before reading them with Faults. The fault detector blocks once it is full.
+const faultBufferSize = 64
+
+// Simulator drives a chainsync.Manager with blocks produced by scripted miners,
+// so chain selection can be exercised against the fork patterns of a real
+// network: competing forks, late and equivocating blocks, null rounds and
+// invalid blocks. Blocks are built by a chain.Builder, which also serves them to
+// the manager in place of the network.
+// Weights follow chain.FakeChainSelector: every block adds one to the weight of
+// its parent.
+type Simulator struct {
+	t   *testing.T
+	ctx context.Context
+
+	builder   *chain.Builder
+	store     *chain.Store
+	manager   chainsync.Manager
+	validator *Validator
+	genesis   block.TipSet
+
+	faultCh chan slashing.ConsensusFault
+	faults  []slashing.ConsensusFault
+	reorgs  []Reorg
+}
+
+// Reorg records a head change that dropped tipsets of the previous head's chain.
+type Reorg struct {
+	Old            block.TipSet
+	New            block.TipSet
+	CommonAncestor block.TipSet
+	Dropped        abi.ChainEpoch
+	Added          abi.ChainEpoch
+}
+
+// NewSimulator starts a chain sync manager on a store holding only a genesis
+// tipset. The manager runs until ctx is cancelled.
+func NewSimulator(ctx context.Context, t *testing.T) *Simulator {
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	genStateRoot, err := builder.GetTipSetStateRoot(genesis.Key())
+	require.NoError(t, err)
+
+	ds := repo.NewInMemoryRepo().ChainDatastore()
+	cst := cborutil.NewIpldStore(bstore.NewBlockstore(ds))
+	store := chain.NewStore(ds, cst, chain.NewStatusReporter(), genesis.At(0).Cid())
+	require.NoError(t, store.PutTipSetMetadata(ctx, &chain.TipSetMetadata{TipSetStateRoot: genStateRoot, TipSet: genesis, TipSetReceipts: types.EmptyReceiptsCID}))
+	require.NoError(t, store.SetHead(ctx, genesis))
+
+	validator := NewValidator()
+	faultCh := make(chan slashing.ConsensusFault, faultBufferSize)
+	// The builder stands in for the network: it is the fetcher, and its message
+	// store is shared with the manager as the node's blockstore would be.
+	manager, err := chainsync.NewManager(validator, validator, &chain.FakeChainSelector{}, store, builder.MessageStore(), builder,
+		clock.NewFake(time.Unix(1234567890, 0)), slashing.NewConsensusFaultDetector(faultCh), nil, 0, ds, 0)
+	require.NoError(t, err)
+	require.NoError(t, manager.Start(ctx))
+
+	// Switching between catchup and follow blocks until the transition is read.
+	go func() {
+		transitions := manager.TransitionChannel()
+		for {
+			select {
+			case <-transitions:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return &Simulator{
+		t:         t,
+		ctx:       ctx,
+		builder:   builder,
+		store:     store,
+		manager:   manager,
+		validator: validator,
+		genesis:   genesis,
+		faultCh:   faultCh,
+	}
+}
+
+// Genesis returns the genesis tipset.
+func (s *Simulator) Genesis() block.TipSet {
+	return s.genesis
+}
+
+// Miner returns the address of the i-th simulated miner.
+func (s *Simulator) Miner(i int) address.Address {
+	return vmaddr.RequireIDAddress(s.t, 1000+i)
+}
+
+// Mine produces a tipset on parent in the following epoch, holding one block
+// from each of the miners. A miner listed twice equivocates.
+func (s *Simulator) Mine(parent block.TipSet, miners ...address.Address) block.TipSet {
+	return s.MineAfterNulls(parent, 0, miners...)
+}
+
+// MineAfterNulls produces a tipset on parent after `nulls` epochs in which no
+// miner won, holding one block from each of the miners.
+func (s *Simulator) MineAfterNulls(parent block.TipSet, nulls abi.ChainEpoch, miners ...address.Address) block.TipSet {
+	require.NotEmpty(s.t, miners)
+	return s.builder.Build(parent, len(miners), func(bb *chain.BlockBuilder, i int) {
+		bb.SetMiner(miners[i])
+		bb.IncHeight(nulls)
+	})
+}
+
+// MineChain extends parent with n single-block tipsets from miner.
+func (s *Simulator) MineChain(parent block.TipSet, n int, miner address.Address) block.TipSet {
+	for i := 0; i < n; i++ {
+		parent = s.Mine(parent, miner)
+	}
+	return parent
+}
+
+// Invalidate makes every block of the tipset fail validation.
+func (s *Simulator) Invalidate(ts block.TipSet) {
+	for i := 0; i < ts.Len(); i++ {
+		s.validator.Invalidate(ts.At(i))
+	}
+}
+
+// Deliver gossips the tipset to the manager as a peer would and waits until it
+// has been processed, returning the sync error if any. A head change that
+// abandons the previous head's chain is recorded as a reorg.
+// Tipsets more than dispatcher.MaxEpochGap ahead of the head put the manager in
+// catchup, where the head only moves once every pending target is synced, so
+// scenarios should deliver chains in steps smaller than that.
+func (s *Simulator) Deliver(ts block.TipSet) error {
+	prior := s.Head()
+	height, err := ts.Height()
+	require.NoError(s.t, err)
+
+	proposer := s.manager.BlockProposer()
+	wait := proposer.WaiterForTarget(ts.Key())
+	require.NoError(s.t, proposer.SendGossipBlock(block.NewChainInfo(peer.ID(""), "", ts.Key(), height)))
+	syncErr := wait()
+
+	s.recordReorg(prior, s.Head())
+	return syncErr
+}
+
+// RequireDeliver delivers the tipset and fails the test if it does not sync.
+func (s *Simulator) RequireDeliver(ts block.TipSet) {
+	require.NoError(s.t, s.Deliver(ts))
+}
+
+// Head returns the head of the manager's chain store.
+func (s *Simulator) Head() block.TipSet {
+	head, err := s.store.GetTipSet(s.store.GetHead())
+	require.NoError(s.t, err)
+	return head
+}
+
+// AssertHead checks that the manager chose expected as its head.
+func (s *Simulator) AssertHead(expected block.TipSet) {
+	assert.Equal(s.t, expected.Key(), s.store.GetHead())
+}
+
+// AssertStored checks that the tipset was validated and stored, whether or
+// not it is on the head's chain.
+func (s *Simulator) AssertStored(ts block.TipSet) {
+	assert.True(s.t, s.store.HasTipSetAndState(s.ctx, ts.Key()), "tipset %s not stored", ts.Key())
+}
+
+// IsBad returns true if the manager's bad tipset cache holds the tipset.
+func (s *Simulator) IsBad(ts block.TipSet) bool {
+	return s.manager.IsBadTipSet(ts.Key())
+}
+
+// Faults returns the consensus faults detected so far.
+func (s *Simulator) Faults() []slashing.ConsensusFault {
+	for {
+		select {
+		case fault := <-s.faultCh:
+			s.faults = append(s.faults, fault)
+		default:
+			return s.faults
+		}
+	}
+}
+
+// Reorgs returns the reorgs that happened so far, oldest first.
+func (s *Simulator) Reorgs() []Reorg {
+	return s.reorgs
+}
+
+func (s *Simulator) recordReorg(prior, head block.TipSet) {
+	if prior.Equals(head) {
+		return
+	}
+	ancestor, err := chain.FindCommonAncestor(chain.IterAncestors(s.ctx, s.store, prior), chain.IterAncestors(s.ctx, s.store, head))
+	require.NoError(s.t, err)
+	if !chain.IsReorg(prior, head, ancestor) {
+		return
+	}
+	dropped, added, err := chain.ReorgDiff(prior, head, ancestor)
+	require.NoError(s.t, err)
+	s.reorgs = append(s.reorgs, Reorg{
+		Old:            prior,
+		New:            head,
+		CommonAncestor: ancestor,
+		Dropped:        dropped,
+		Added:          added,
+	})
+}
+
+// Validator accepts every block except those marked invalid. Invalid blocks
+// pass header validation but fail the state transition, like a block carrying
+// a bad state root or messages.
+type Validator struct {
+	chain.FakeStateEvaluator
+
+	lk      sync.Mutex
+	invalid map[cid.Cid]struct{}
+}
+
+// NewValidator creates a validator accepting all blocks.
+func NewValidator() *Validator {
+	return &Validator{invalid: make(map[cid.Cid]struct{})}
+}
+
+// Invalidate makes the block fail validation.
+func (v *Validator) Invalidate(blk *block.Block) {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	v.invalid[blk.Cid()] = struct{}{}
+}
+
+// RunStateTransition fails if the tipset holds an invalid block, and otherwise
+// delegates to the fake state evaluator.
+func (v *Validator) RunStateTransition(ctx context.Context, ts block.TipSet, blsMessages [][]*types.UnsignedMessage, secpMessages [][]*types.SignedMessage,
+	parentWeight fbig.Int, stateID cid.Cid, receiptCid cid.Cid) (cid.Cid, []vm.MessageReceipt, error) {
+	v.lk.Lock()
+	defer v.lk.Unlock()
+	for i := 0; i < ts.Len(); i++ {
+		if _, bad := v.invalid[ts.At(i).Cid()]; bad {
+			return cid.Undef, nil, consensus.Invalid(errors.Errorf("block %s is invalid", ts.At(i).Cid()))
+		}
+	}
+	return v.FakeStateEvaluator.RunStateTransition(ctx, ts, blsMessages, secpMessages, parentWeight, stateID, receiptCid)
+}
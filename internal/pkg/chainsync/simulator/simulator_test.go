@@ -0,0 +1,216 @@
+package simulator_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/simulator"
+	"github.com/sbwtw/go-filecoin/internal/pkg/slashing"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestHeavierForkWins(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := simulator.NewSimulator(ctx, t)
+	m0, m1, m2 := sim.Miner(0), sim.Miner(1), sim.Miner(2)
+
+	base := sim.Mine(sim.Genesis(), m0)
+	main := sim.MineChain(base, 3, m0)
+
+	// Two miners cut off from m0 skip an epoch, then out-produce it.
+	fork1 := sim.MineAfterNulls(base, 1, m1, m2)
+	fork2 := sim.Mine(fork1, m1, m2)
+
+	sim.RequireDeliver(main)
+	sim.AssertHead(main)
+
+	// Lighter than main, so it is kept but not chosen.
+	sim.RequireDeliver(fork1)
+	sim.AssertStored(fork1)
+	sim.AssertHead(main)
+
+	sim.RequireDeliver(fork2)
+	sim.AssertHead(fork2)
+
+	require.Len(t, sim.Reorgs(), 1)
+	reorg := sim.Reorgs()[0]
+	assert.Equal(t, main, reorg.Old)
+	assert.Equal(t, fork2, reorg.New)
+	assert.Equal(t, base, reorg.CommonAncestor)
+	assert.Equal(t, abi.ChainEpoch(3), reorg.Dropped)
+	assert.Equal(t, abi.ChainEpoch(3), reorg.Added)
+}
+
+func TestEqualWeightForkKeepsHead(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := simulator.NewSimulator(ctx, t)
+	m0, m1, m2 := sim.Miner(0), sim.Miner(1), sim.Miner(2)
+
+	base := sim.Mine(sim.Genesis(), m0)
+	main := sim.MineChain(base, 3, m0)
+	fork := sim.Mine(sim.MineAfterNulls(base, 1, m1), m1, m2)
+
+	sim.RequireDeliver(main)
+	sim.RequireDeliver(fork)
+	sim.AssertStored(fork)
+	sim.AssertHead(main)
+
+	// One more block breaks the tie.
+	next := sim.Mine(fork, m1)
+	sim.RequireDeliver(next)
+	sim.AssertHead(next)
+	assert.Len(t, sim.Reorgs(), 1)
+}
+
+func TestLateBlocks(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := simulator.NewSimulator(ctx, t)
+	m0, m1, m2 := sim.Miner(0), sim.Miner(1), sim.Miner(2)
+
+	a1 := sim.Mine(sim.Genesis(), m0)
+	a2 := sim.Mine(a1, m0)
+	a3 := sim.Mine(a2, m0)
+	sim.RequireDeliver(a3)
+	sim.AssertHead(a3)
+
+	// A sibling of the head arriving late widens the head without a reorg.
+	late3 := sim.Mine(a2, m1)
+	sim.RequireDeliver(late3)
+	wide3 := block.RequireNewTipSet(t, a3.At(0), late3.At(0))
+	sim.AssertHead(wide3)
+	assert.Empty(t, sim.Reorgs())
+
+	// A block for the first epoch arriving long after it forms a heavier
+	// tipset with a1, which is stored but lighter than the head.
+	late1 := sim.Mine(sim.Genesis(), m1)
+	sim.RequireDeliver(late1)
+	wide1 := block.RequireNewTipSet(t, a1.At(0), late1.At(0))
+	sim.AssertStored(wide1)
+	sim.AssertHead(wide3)
+
+	// Miners that saw the late block build on the wider tipset and overtake
+	// the head.
+	c2 := sim.Mine(wide1, m2)
+	c3 := sim.Mine(c2, m2)
+	c4 := sim.Mine(c3, m2)
+	sim.RequireDeliver(c3)
+	sim.AssertHead(wide3)
+	sim.RequireDeliver(c4)
+	sim.AssertHead(c4)
+
+	require.Len(t, sim.Reorgs(), 1)
+	assert.Equal(t, sim.Genesis(), sim.Reorgs()[0].CommonAncestor)
+}
+
+func TestNullRounds(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := simulator.NewSimulator(ctx, t)
+	m0, m1 := sim.Miner(0), sim.Miner(1)
+
+	a1 := sim.Mine(sim.Genesis(), m0)
+	a4 := sim.MineAfterNulls(a1, 2, m0)
+	a5 := sim.Mine(a4, m0)
+	sim.RequireDeliver(a5)
+	sim.AssertStored(a4)
+	sim.AssertHead(a5)
+
+	// A fork without null rounds is heavier despite ending at a lower height.
+	b4 := sim.MineChain(a1, 3, m1)
+	sim.RequireDeliver(b4)
+	sim.AssertHead(b4)
+
+	require.Len(t, sim.Reorgs(), 1)
+	reorg := sim.Reorgs()[0]
+	assert.Equal(t, a1, reorg.CommonAncestor)
+	assert.Equal(t, abi.ChainEpoch(4), reorg.Dropped)
+	assert.Equal(t, abi.ChainEpoch(3), reorg.Added)
+}
+
+func TestInvalidBlockMidChain(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := simulator.NewSimulator(ctx, t)
+	m0, m1 := sim.Miner(0), sim.Miner(1)
+
+	a1 := sim.Mine(sim.Genesis(), m0)
+	a2 := sim.Mine(a1, m0)
+	a3 := sim.Mine(a2, m0)
+	a4 := sim.Mine(a3, m0)
+	sim.Invalidate(a2)
+
+	require.Error(t, sim.Deliver(a4))
+	sim.AssertHead(sim.Genesis())
+	sim.AssertStored(a1)
+	assert.False(t, sim.IsBad(a1))
+	assert.True(t, sim.IsBad(a2))
+	assert.True(t, sim.IsBad(a3))
+	assert.True(t, sim.IsBad(a4))
+
+	// Extensions of the bad chain are rejected outright.
+	a5 := sim.Mine(a4, m0)
+	err := sim.Deliver(a5)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cached bad tipset")
+	sim.AssertHead(sim.Genesis())
+
+	// An honest chain on the last valid tipset is accepted.
+	b3 := sim.MineChain(a1, 2, m1)
+	sim.RequireDeliver(b3)
+	sim.AssertHead(b3)
+	assert.False(t, sim.IsBad(b3))
+	assert.Empty(t, sim.Reorgs())
+}
+
+func TestEquivocatingMiner(t *testing.T) {
+	tf.UnitTest(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sim := simulator.NewSimulator(ctx, t)
+	m0, m1 := sim.Miner(0), sim.Miner(1)
+
+	// Two blocks on the same parent.
+	a1 := sim.Mine(sim.Genesis(), m0)
+	sim.RequireDeliver(a1)
+	require.Empty(t, sim.Faults())
+
+	dup := sim.Mine(sim.Genesis(), m0)
+	sim.RequireDeliver(dup)
+
+	// The fake weighting does not penalise the equivocation, so both blocks
+	// make up the head, but the fault is reported for slashing.
+	wide := block.RequireNewTipSet(t, a1.At(0), dup.At(0))
+	sim.AssertHead(wide)
+	assertFaultsBetween(t, sim.Faults(), a1.At(0), dup.At(0))
+
+	// Blocks for the same epoch on different forks. The null round on the
+	// second fork spans the epoch m1 already mined in.
+	before := len(sim.Faults())
+	x := sim.Mine(wide, m1)
+	sim.RequireDeliver(x)
+	y := sim.MineAfterNulls(sim.Genesis(), 1, m1)
+	sim.RequireDeliver(y)
+	sim.AssertHead(x)
+	assertFaultsBetween(t, sim.Faults()[before:], x.At(0), y.At(0))
+}
+
+func assertFaultsBetween(t *testing.T, faults []slashing.ConsensusFault, a, b *block.Block) {
+	require.NotEmpty(t, faults)
+	expected := []string{a.Cid().String(), b.Cid().String()}
+	for _, f := range faults {
+		assert.ElementsMatch(t, expected, []string{f.Block1.Cid().String(), f.Block2.Cid().String()})
+	}
+}
@@ -3,6 +3,7 @@ package status
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -29,6 +30,12 @@ type Status struct {
 	SyncingComplete bool
 	// Whether SyncingHead has been fetched.
 	SyncingFetchComplete bool
+	// The height up to which the chain at SyncingHead has been validated.
+	SyncingValidatedHeight abi.ChainEpoch
+	// The number of epochs validated per second since syncing began.
+	SyncingRate float64
+	// The estimated time left to validate up to SyncingHead.
+	SyncingTimeRemaining time.Duration
 
 	// The key of the tipset currently being fetched
 	FetchingHead block.TipSetKey
@@ -56,22 +63,26 @@ func NewReporter() Reporter {
 // NewDefaultChainStatus returns a ChainStaus with the default empty values.
 func NewDefaultChainStatus() *Status {
 	return &Status{
-		SyncingHead:          block.UndefTipSet.Key(),
-		SyncingHeight:        0,
-		SyncingTrusted:       false,
-		SyncingStarted:       0,
-		SyncingComplete:      true,
-		SyncingFetchComplete: true,
-		FetchingHead:         block.UndefTipSet.Key(),
-		FetchingHeight:       0,
+		SyncingHead:            block.UndefTipSet.Key(),
+		SyncingHeight:          0,
+		SyncingTrusted:         false,
+		SyncingStarted:         0,
+		SyncingComplete:        true,
+		SyncingFetchComplete:   true,
+		SyncingValidatedHeight: 0,
+		SyncingRate:            0,
+		SyncingTimeRemaining:   0,
+		FetchingHead:           block.UndefTipSet.Key(),
+		FetchingHeight:         0,
 	}
 }
 
 // String returns the Status as a string
 func (s Status) String() string {
-	return fmt.Sprintf("syncingStarted=%d, syncingHead=%s, syncingHeight=%d, syncingTrusted=%t, syncingComplete=%t syncingFetchComplete=%t fetchingHead=%s, fetchingHeight=%d",
+	return fmt.Sprintf("syncingStarted=%d, syncingHead=%s, syncingHeight=%d, syncingTrusted=%t, syncingComplete=%t syncingFetchComplete=%t syncingValidatedHeight=%d syncingRate=%.2f syncingTimeRemaining=%s fetchingHead=%s, fetchingHeight=%d",
 		s.SyncingStarted,
 		s.SyncingHead, s.SyncingHeight, s.SyncingTrusted, s.SyncingComplete, s.SyncingFetchComplete,
+		s.SyncingValidatedHeight, s.SyncingRate, s.SyncingTimeRemaining,
 		s.FetchingHead, s.FetchingHeight)
 }
 
@@ -136,6 +147,27 @@ func SyncFetchComplete(u bool) UpdateFn {
 	}
 }
 
+// SyncValidatedHeight updates the height validated up to.
+func SyncValidatedHeight(u abi.ChainEpoch) UpdateFn {
+	return func(s *Status) {
+		s.SyncingValidatedHeight = u
+	}
+}
+
+// SyncRate updates the validation rate in epochs per second.
+func SyncRate(u float64) UpdateFn {
+	return func(s *Status) {
+		s.SyncingRate = u
+	}
+}
+
+// SyncTimeRemaining updates the estimated time left to sync.
+func SyncTimeRemaining(u time.Duration) UpdateFn {
+	return func(s *Status) {
+		s.SyncingTimeRemaining = u
+	}
+}
+
 //
 // Fetching Updates
 //
@@ -2,6 +2,7 @@ package status_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chainsync/status"
@@ -27,17 +28,21 @@ func TestStatus(t *testing.T) {
 	t2 := block.NewTipSetKey(cidFn())
 	t3 := block.NewTipSetKey(cidFn())
 	expStatus := status.Status{
-		SyncingHead:          t2,
-		SyncingHeight:        456,
-		SyncingTrusted:       true,
-		SyncingStarted:       123,
-		SyncingComplete:      false,
-		SyncingFetchComplete: true,
-		FetchingHead:         t3,
-		FetchingHeight:       789,
+		SyncingHead:            t2,
+		SyncingHeight:          456,
+		SyncingTrusted:         true,
+		SyncingStarted:         123,
+		SyncingComplete:        false,
+		SyncingFetchComplete:   true,
+		SyncingValidatedHeight: 400,
+		SyncingRate:            2.5,
+		SyncingTimeRemaining:   time.Minute,
+		FetchingHead:           t3,
+		FetchingHeight:         789,
 	}
 	sr.UpdateStatus(status.SyncingStarted(123), status.SyncHead(t2),
 		status.SyncHeight(456), status.SyncTrusted(true), status.SyncComplete(false), status.SyncFetchComplete(true),
+		status.SyncValidatedHeight(400), status.SyncRate(2.5), status.SyncTimeRemaining(time.Minute),
 		status.FetchHead(t3), status.FetchHeight(789))
 	assert.Equal(t, expStatus, sr.Status())
 }
@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/pkg/errors"
@@ -22,12 +23,14 @@ type Config struct {
 	Datastore     *DatastoreConfig     `json:"datastore"`
 	Drand         *DrandConfig         `json:"drand"`
 	Heartbeat     *HeartbeatConfig     `json:"heartbeat"`
+	Message       *MessageConfig       `json:"message"`
 	Mining        *MiningConfig        `json:"mining"`
 	Mpool         *MessagePoolConfig   `json:"mpool"`
 	NetworkParams *NetworkParamsConfig `json:"parameters"`
 	Observability *ObservabilityConfig `json:"observability"`
 	SectorBase    *SectorBaseConfig    `json:"sectorbase"`
 	Swarm         *SwarmConfig         `json:"swarm"`
+	Sync          *SyncConfig          `json:"sync"`
 	Wallet        *WalletConfig        `json:"wallet"`
 }
 
@@ -65,7 +68,9 @@ type DatastoreConfig struct {
 // the given key and value are valid. Validators will only be run if a property
 // being set matches the name given in this map.
 var Validators = map[string]func(string, string) error{
-	"heartbeat.nickname": validateLettersOnly,
+	"heartbeat.nickname":   validateLettersOnly,
+	"sync.fetcher":         validateSyncFetcher,
+	"sync.badTipSetExpiry": validateDuration,
 }
 
 func newDefaultDatastoreConfig() *DatastoreConfig {
@@ -106,15 +111,72 @@ func newDefaultBootstrapConfig() *BootstrapConfig {
 // MiningConfig holds all configuration options related to mining.
 type MiningConfig struct {
 	MinerAddress            address.Address `json:"minerAddress"`
+	OwnerAddress            address.Address `json:"ownerAddress"`
+	WorkerAddress           address.Address `json:"workerAddress"`
 	AutoSealIntervalSeconds uint            `json:"autoSealIntervalSeconds"`
 	StoragePrice            types.AttoFIL   `json:"storagePrice"`
+	// Miners are the miner actors the node operates besides MinerAddress. They
+	// seal, prove, mine blocks and make storage deals at their own asks, but
+	// make no retrieval deals, which are left to MinerAddress.
+	Miners []MinerConfig `json:"miners"`
+	// PledgePolicy configures the sectors the node pledges on its own.
+	PledgePolicy PledgePolicyConfig `json:"pledgePolicy"`
+	// PropagationDelaySeconds is how long into an epoch block mining waits
+	// for the blocks of the previous epoch to arrive before choosing the
+	// tipset to mine on. It is clamped to a quarter of the epoch duration.
+	PropagationDelaySeconds uint `json:"propagationDelaySeconds"`
+}
+
+// PledgePolicyConfig configures the committed-capacity sectors a storage miner
+// pledges on its own. The policy is checked every AutoSealIntervalSeconds.
+type PledgePolicyConfig struct {
+	// Enabled turns automatic pledging on.
+	Enabled bool `json:"enabled"`
+	// MaxSealing is the number of sectors kept in the sealing pipeline at
+	// once, counting those that failed and wait to be retried or removed.
+	MaxSealing uint `json:"maxSealing"`
+	// MinFreeSpace is the number of bytes kept free across the storage paths
+	// sectors are sealed or stored in. No sector is pledged that would take
+	// free space below it.
+	MinFreeSpace uint64 `json:"minFreeSpace"`
+	// Deposit is the least worker balance set aside for the pre-commit
+	// deposit and gas of each sector pledged, when it exceeds the deposit
+	// the miner actor currently requires. Pledging pauses while the worker
+	// cannot cover it, or the miner cannot cover the initial pledge.
+	Deposit types.AttoFIL `json:"deposit"`
+	// TargetRawPower is the raw power in bytes at which pledging stops. Zero
+	// sets no target.
+	TargetRawPower uint64 `json:"targetRawPower"`
+}
+
+// MinerConfig configures a miner actor the node operates besides the one at
+// mining.minerAddress. Its sectors are stored under a directory of the sector
+// root named after its address, and in its own storage paths.
+type MinerConfig struct {
+	Address address.Address `json:"address"`
+	Owner   address.Address `json:"owner"`
+	Worker  address.Address `json:"worker"`
+	// StoragePaths are the absolute paths to further directories the miner
+	// seals and stores sectors in, like sectorbase.storagePaths for the
+	// primary miner.
+	StoragePaths []string `json:"storagePaths"`
 }
 
 func newDefaultMiningConfig() *MiningConfig {
 	return &MiningConfig{
 		MinerAddress:            address.Undef,
+		OwnerAddress:            address.Undef,
+		WorkerAddress:           address.Undef,
 		AutoSealIntervalSeconds: 120,
 		StoragePrice:            types.ZeroAttoFIL,
+		Miners:                  []MinerConfig{},
+		PledgePolicy: PledgePolicyConfig{
+			Enabled:      false,
+			MaxSealing:   1,
+			MinFreeSpace: 0,
+			Deposit:      types.ZeroAttoFIL,
+		},
+		PropagationDelaySeconds: 6,
 	}
 }
 
@@ -139,6 +201,14 @@ type DrandConfig struct {
 	DistKey       [][]byte `json:"distKey"`
 	StartTimeUnix int64    `json:"startTimeUnix"`
 	RoundSeconds  int      `json:"roundSeconds"`
+	// Offline stops the node fetching from the drand servers, serving
+	// randomness only from the entries it has cached and seen on chain.
+	Offline bool `json:"offline"`
+	// Local replaces the drand servers with a deterministic beacon generated
+	// from LocalSecret, for networks without access to drand. Nodes without
+	// the secret verify its entries with DistKey.
+	Local       bool   `json:"local"`
+	LocalSecret string `json:"localSecret"`
 }
 
 func newDefaultDrandConfig() *DrandConfig {
@@ -236,12 +306,35 @@ type MessagePoolConfig struct {
 	MaxPoolSize uint `json:"maxPoolSize"`
 	// MaxNonceGap is the maximum nonce of a message past the last received on chain
 	MaxNonceGap uint64 `json:"maxNonceGap"`
+	// MinGossipGasPrice is the minimum gas price of messages relayed over pubsub
+	MinGossipGasPrice types.AttoFIL `json:"minGossipGasPrice"`
 }
 
 func newDefaultMessagePoolConfig() *MessagePoolConfig {
 	return &MessagePoolConfig{
-		MaxPoolSize: 1000000,
-		MaxNonceGap: 100,
+		MaxPoolSize:       1000000,
+		MaxNonceGap:       100,
+		MinGossipGasPrice: types.ZeroAttoFIL,
+	}
+}
+
+// MessageConfig holds all configuration options related to sending messages.
+type MessageConfig struct {
+	// GasLimitMarginPercent is added on top of the simulated gas usage of a message
+	// when estimating its gas limit.
+	GasLimitMarginPercent uint64 `json:"gasLimitMarginPercent"`
+	// GasPriceLookback is the number of tipsets from the head inspected for included
+	// gas prices when suggesting a gas price.
+	GasPriceLookback uint `json:"gasPriceLookback"`
+	// DefaultGasPrice is suggested when no messages were included in the lookback tipsets.
+	DefaultGasPrice types.AttoFIL `json:"defaultGasPrice"`
+}
+
+func newDefaultMessageConfig() *MessageConfig {
+	return &MessageConfig{
+		GasLimitMarginPercent: 25,
+		GasPriceLookback:      20,
+		DefaultGasPrice:       types.NewGasPrice(1),
 	}
 }
 
@@ -266,12 +359,56 @@ type SectorBaseConfig struct {
 	// pre-sealed sector files and corresponding metadata JSON.
 	// If empty, it is assumed that no pre-sealed sectors exist.
 	PreSealedSectorsDirPath string `json:"preSealedSectorsDir"`
+
+	// StoragePaths are the absolute paths to further directories the node's
+	// miner seals and stores sectors in. Each holds the storage metadata
+	// with its weight and whether sectors may be sealed or stored in it.
+	StoragePaths []string `json:"storagePaths"`
 }
 
 func newDefaultSectorbaseConfig() *SectorBaseConfig {
 	return &SectorBaseConfig{
 		RootDirPath:             "",
 		PreSealedSectorsDirPath: "",
+		StoragePaths:            []string{},
+	}
+}
+
+// Protocols chains may be fetched with.
+const (
+	SyncFetcherGraphsync = "graphsync"
+	SyncFetcherExchange  = "exchange"
+)
+
+// SyncConfig holds all configuration options related to fetching the chain
+// from peers and serving it to them.
+type SyncConfig struct {
+	// Fetcher is the protocol chains are fetched with, "graphsync" or "exchange".
+	Fetcher string `json:"fetcher"`
+	// Fallback retries fetches that fail with the other protocol when true.
+	Fallback bool `json:"fallback"`
+	// ExchangeMaxRequestLength is the largest number of tipsets served to a
+	// peer per chain exchange request. Zero serves the protocol's default.
+	ExchangeMaxRequestLength uint64 `json:"exchangeMaxRequestLength"`
+	// ExchangeMaxConcurrentRequests is the number of chain exchange requests
+	// served at once. Zero places no limit.
+	ExchangeMaxConcurrentRequests uint `json:"exchangeMaxConcurrentRequests"`
+	// MaxReorgDepth is the number of epochs of the current chain a heavier
+	// fork may drop. Deeper forks are logged and quarantined, not adopted.
+	// Zero leaves reorgs bounded only by consensus finality.
+	MaxReorgDepth uint64 `json:"maxReorgDepth"`
+	// BadTipSetExpiry is how long a tipset that failed validation is refused
+	// before it may be validated again. Zero refuses it forever.
+	BadTipSetExpiry string `json:"badTipSetExpiry"`
+}
+
+func newDefaultSyncConfig() *SyncConfig {
+	return &SyncConfig{
+		Fetcher:                       SyncFetcherGraphsync,
+		Fallback:                      true,
+		ExchangeMaxRequestLength:      500,
+		ExchangeMaxConcurrentRequests: 16,
+		BadTipSetExpiry:               "168h",
 	}
 }
 
@@ -284,12 +421,14 @@ func NewDefaultConfig() *Config {
 		Datastore:     newDefaultDatastoreConfig(),
 		Drand:         newDefaultDrandConfig(),
 		Heartbeat:     newDefaultHeartbeatConfig(),
+		Message:       newDefaultMessageConfig(),
 		Mining:        newDefaultMiningConfig(),
 		Mpool:         newDefaultMessagePoolConfig(),
 		NetworkParams: newDefaultNetworkParamsConfig(),
 		Observability: newDefaultObservabilityConfig(),
 		SectorBase:    newDefaultSectorbaseConfig(),
 		Swarm:         newDefaultSwarmConfig(),
+		Sync:          newDefaultSyncConfig(),
 		Wallet:        newDefaultWalletConfig(),
 	}
 }
@@ -423,3 +562,25 @@ func validateLettersOnly(key string, value string) error {
 	}
 	return nil
 }
+
+// validateDuration validates that a given value is a duration string such as
+// "1h30m".
+func validateDuration(key string, value string) error {
+	var s string
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return errors.Errorf(`"%s" must be a duration string`, key)
+	}
+	if _, err := time.ParseDuration(s); err != nil {
+		return errors.Errorf(`"%s" must be a duration: %s`, key, err)
+	}
+	return nil
+}
+
+// validateSyncFetcher validates that a given value names a chain fetching
+// protocol.
+func validateSyncFetcher(key string, value string) error {
+	if value != fmt.Sprintf("%q", SyncFetcherGraphsync) && value != fmt.Sprintf("%q", SyncFetcherExchange) {
+		return errors.Errorf(`"%s" must be %q or %q`, key, SyncFetcherGraphsync, SyncFetcherExchange)
+	}
+	return nil
+}
@@ -65,6 +65,27 @@ func TestSetRejectsInvalidNicks(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSetRejectsUnknownSyncFetcher(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("sync.fetcher", `"exchange"`))
+	assert.Equal(t, SyncFetcherExchange, cfg.Sync.Fetcher)
+	assert.Error(t, cfg.Set("sync.fetcher", `"bitswap"`))
+}
+
+func TestSetRejectsInvalidBadTipSetExpiry(t *testing.T) {
+	tf.UnitTest(t)
+
+	cfg := NewDefaultConfig()
+
+	assert.NoError(t, cfg.Set("sync.badTipSetExpiry", `"24h"`))
+	assert.Equal(t, "24h", cfg.Sync.BadTipSetExpiry)
+	assert.Error(t, cfg.Set("sync.badTipSetExpiry", `"a week"`))
+	assert.Error(t, cfg.Set("sync.badTipSetExpiry", `24`))
+}
+
 func TestConfigRoundtrip(t *testing.T) {
 	tf.UnitTest(t)
 
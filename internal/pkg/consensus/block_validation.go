@@ -4,10 +4,17 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/pkg/errors"
+
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
 )
 
+// ErrBlockTime is returned for a block whose timestamp or epoch is not
+// consistent with the chain clock. Skewed clocks cause it as much as invalid
+// blocks do.
+var ErrBlockTime = errors.New("block time is inconsistent with the chain clock")
+
 // BlockValidator defines an interface used to validate a blocks syntax and
 // semantics.
 type BlockValidator interface {
@@ -51,7 +58,7 @@ func NewDefaultBlockValidator(c clock.ChainEpochClock) *DefaultBlockValidator {
 func (dv *DefaultBlockValidator) NotFutureBlock(b *block.Block) error {
 	currentEpoch := dv.EpochAtTime(dv.Now())
 	if b.Height > currentEpoch {
-		return fmt.Errorf("block %s with timestamp %d generate in future epoch %d", b.Cid().String(), b.Timestamp, b.Height)
+		return errors.Wrapf(ErrBlockTime, "block %s with timestamp %d generate in future epoch %d", b.Cid().String(), b.Timestamp, b.Height)
 	}
 	return nil
 }
@@ -62,7 +69,8 @@ func (dv *DefaultBlockValidator) TimeMatchesEpoch(b *block.Block) error {
 	earliestExpected, latestExpected := dv.EpochRangeAtTimestamp(b.Timestamp)
 	blockEpoch := b.Height
 	if (blockEpoch < earliestExpected) || (blockEpoch > latestExpected) {
-		return fmt.Errorf(
+		return errors.Wrapf(
+			ErrBlockTime,
 			"block %s with timestamp %d generated in wrong epoch %d, expected epoch in range [%d, %d]",
 			b.Cid().String(),
 			b.Timestamp,
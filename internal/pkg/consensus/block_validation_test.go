@@ -7,6 +7,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -68,6 +69,7 @@ func TestMismatchedTime(t *testing.T) {
 	err := validator.TimeMatchesEpoch(c)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "wrong epoch")
+	assert.Equal(t, consensus.ErrBlockTime, errors.Cause(err))
 }
 
 func TestFutureEpoch(t *testing.T) {
@@ -84,6 +86,7 @@ func TestFutureEpoch(t *testing.T) {
 	err := validator.NotFutureBlock(c)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "future epoch")
+	assert.Equal(t, consensus.ErrBlockTime, errors.Cause(err))
 }
 
 func TestBlockValidSyntax(t *testing.T) {
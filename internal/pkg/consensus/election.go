@@ -109,6 +109,20 @@ func (em ElectionMachine) IsWinner(challengeTicket []byte, minerPower, networkPo
 	return big.Cmp(lhs, rhs) < 0
 }
 
+// ElectionThreshold returns the value a miner's challenge ticket must be
+// below for the miner to win the election with the input power. A miner
+// without power, or on a network without power, never wins.
+func ElectionThreshold(minerPower, networkPower abi.StoragePower) big.Int {
+	if !networkPower.GreaterThan(big.Zero()) {
+		return big.Zero()
+	}
+	// The ceiling of ExpectedLeadersPerEpoch * MaxChallengeTicket * (MinerPower / NetworkPower),
+	// so that a ticket below it wins exactly when IsWinner holds.
+	rhs := big.Lsh(minerPower, challengeBits)
+	rhs = big.Mul(rhs, big.NewInt(expectedLeadersPerEpoch))
+	return big.Div(big.Add(rhs, big.Sub(networkPower, big.NewInt(1))), networkPower)
+}
+
 // VerifyWinningPoSt verifies a Winning PoSt proof.
 func (em ElectionMachine) VerifyWinningPoSt(ctx context.Context, ep EPoStVerifier, allSectorInfos []abi.SectorInfo, entry *drand.Entry, epoch abi.ChainEpoch, proofs []block.PoStProof, mIDAddr address.Address) (bool, error) {
 	if len(proofs) == 0 {
@@ -199,7 +213,7 @@ func (tm TicketMachine) IsValidTicket(ctx context.Context, base block.TipSetKey,
 		return errors.Wrap(err, "failed to generate ticket randomness")
 	}
 
-	return crypto.ValidateBlsSignature(randomness, workerSigner, ticket.VRFProof)
+	return Invalid(crypto.ValidateBlsSignature(randomness, workerSigner, ticket.VRFProof))
 }
 
 func (tm TicketMachine) ticketVRFRandomness(ctx context.Context, base block.TipSetKey, entry *drand.Entry, newPeriod bool, miner address.Address, epoch abi.ChainEpoch) (abi.Randomness, error) {
@@ -6,6 +6,7 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -77,6 +78,20 @@ func TestNextTicketFailsWithInvalidSigner(t *testing.T) {
 	assert.Nil(t, badTicket.VRFProof)
 }
 
+func TestElectionThresholdAgreesWithIsWinner(t *testing.T) {
+	tf.UnitTest(t)
+	em := consensus.NewElectionMachine(nil)
+	minerPower, networkPower := abi.NewStoragePower(3), abi.NewStoragePower(7)
+
+	threshold := consensus.ElectionThreshold(minerPower, networkPower)
+	below := big.Sub(threshold, big.NewInt(1))
+	assert.True(t, em.IsWinner(below.Bytes(), minerPower, networkPower))
+	assert.False(t, em.IsWinner(threshold.Bytes(), minerPower, networkPower))
+
+	assert.Equal(t, int64(0), consensus.ElectionThreshold(abi.NewStoragePower(0), networkPower).Int64())
+	assert.Equal(t, int64(0), consensus.ElectionThreshold(minerPower, abi.NewStoragePower(0)).Int64())
+}
+
 func requireAddress(t *testing.T, ki *crypto.KeyInfo) address.Address {
 	addr, err := ki.Address()
 	require.NoError(t, err)
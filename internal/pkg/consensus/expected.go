@@ -13,6 +13,7 @@ import (
 	cbor "github.com/ipfs/go-ipld-cbor"
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
@@ -33,8 +34,50 @@ var (
 	ErrUnorderedTipSets = errors.New("trying to order two identical tipsets")
 	// ErrReceiptRootMismatch is returned when the block's receipt root doesn't match the receipt root computed for the parent tipset.
 	ErrReceiptRootMismatch = errors.New("blocks receipt root does not match parent tip set")
+	// ErrParentStateUnknown is returned when a block header cannot be validated because its parent state is not computed.
+	ErrParentStateUnknown = errors.New("block parent state is unknown")
 )
 
+// invalidError marks its cause as a broken consensus rule, as opposed to a
+// failure of the node to check the rule.
+type invalidError struct {
+	cause error
+}
+
+func (e *invalidError) Error() string {
+	return e.cause.Error()
+}
+
+// Cause returns the error marked invalid.
+func (e *invalidError) Cause() error {
+	return e.cause
+}
+
+// Invalid marks err as the failure of a block or tipset to follow a consensus
+// rule. Errors of the node itself, e.g. a failing store or lookup, must not be
+// marked, so that the blocks are checked again once the node recovers.
+func Invalid(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &invalidError{cause: err}
+}
+
+// IsInvalid returns whether err, or an error it wraps, was marked Invalid.
+func IsInvalid(err error) bool {
+	for err != nil {
+		if _, ok := err.(*invalidError); ok {
+			return true
+		}
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			return false
+		}
+		err = causer.Cause()
+	}
+	return false
+}
+
 // challengeBits is the number of bits in the challenge ticket's domain
 const challengeBits = 256
 
@@ -219,78 +262,60 @@ func (c *Expected) validateMining(ctx context.Context,
 	electionPowerStateView := c.state.PowerStateView(electionPowerStateRoot)
 	electionPowerTable := NewPowerTableView(electionPowerStateView, faultsStateView)
 
+	// Message signatures are verified concurrently with the rest of the
+	// validation, as they make up most of its cost in blocks with many messages.
+	sigCtx, cancelSigs := context.WithCancel(ctx)
+	sigs, sigCtx := errgroup.WithContext(sigCtx)
+	// Checks still running when the validation fails early are cancelled and
+	// waited for, so that none outlive the call.
+	defer func() {
+		cancelSigs()
+		_ = sigs.Wait()
+	}()
 	for i := 0; i < ts.Len(); i++ {
 		blk := ts.At(i)
 
 		// confirm block state root matches parent state root
 		if !parentStateRoot.Equals(blk.StateRoot.Cid) {
-			return ErrStateRootMismatch
+			return Invalid(ErrStateRootMismatch)
 		}
 
 		// confirm block receipts match parent receipts
 		if !parentReceiptRoot.Equals(blk.MessageReceipts.Cid) {
-			return ErrReceiptRootMismatch
+			return Invalid(ErrReceiptRootMismatch)
 		}
 
 		if !parentWeight.Equals(blk.ParentWeight) {
-			return errors.Errorf("block %s has invalid parent weight %d expected %d", blk.Cid().String(), blk.ParentWeight, parentWeight)
-		}
-		workerAddr, err := keyPowerTable.WorkerAddr(ctx, blk.Miner)
-		if err != nil {
-			return errors.Wrap(err, "failed to read worker address of block miner")
+			return Invalid(errors.Errorf("block %s has invalid parent weight %d expected %d", blk.Cid().String(), blk.ParentWeight, parentWeight))
 		}
-		workerSignerAddr, err := keyPowerTable.SignerAddress(ctx, workerAddr)
+		workerSignerAddr, err := validateBlockSignature(ctx, keyPowerTable, blk)
 		if err != nil {
-			return errors.Wrapf(err, "failed to convert address, %s, to a signing address", workerAddr.String())
-		}
-		// Validate block signature
-		if blk.BlockSig == nil {
-			return errors.Errorf("invalid nil block signature")
-		}
-		if err := crypto.ValidateSignature(blk.SignatureData(), workerSignerAddr, *blk.BlockSig); err != nil {
-			return errors.Wrap(err, "block signature invalid")
+			return err
 		}
 
 		// Verify that the BLS signature aggregate is correct
-		if err := sigValidator.ValidateBLSMessageAggregate(ctx, blsMsgs[i], blk.BLSAggregateSig); err != nil {
-			return errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid())
-		}
+		blkBLSMsgs := blsMsgs[i]
+		sigs.Go(func() error {
+			if err := sigValidator.ValidateBLSMessageAggregate(sigCtx, blkBLSMsgs, blk.BLSAggregateSig); err != nil {
+				return invalidUnlessDone(sigCtx, errors.Wrapf(err, "bls message verification failed for block %s", blk.Cid()))
+			}
+			return nil
+		})
 
 		// Verify that all secp message signatures are correct
-		for i, msg := range secpMsgs[i] {
-			if err := sigValidator.ValidateMessageSignature(ctx, msg); err != nil {
-				return errors.Wrapf(err, "invalid signature for secp message %d in block %s", i, blk.Cid())
+		blkSecpMsgs := secpMsgs[i]
+		sigs.Go(func() error {
+			for i, msg := range blkSecpMsgs {
+				if err := sigValidator.ValidateMessageSignature(sigCtx, msg); err != nil {
+					return invalidUnlessDone(sigCtx, errors.Wrapf(err, "invalid signature for secp message %d in block %s", i, blk.Cid()))
+				}
 			}
-		}
-
-		err = c.validateDRANDEntries(ctx, blk)
-		if err != nil {
-			return errors.Wrapf(err, "invalid DRAND entries")
-		}
+			return nil
+		})
 
-		electionEntry, err := c.electionEntry(ctx, blk)
-		if err != nil {
-			return errors.Wrapf(err, "failed to get election entry")
-		}
-		err = c.VerifyElectionProof(ctx, electionEntry, blk.Height, blk.Miner, workerSignerAddr, blk.ElectionProof.VRFProof)
+		electionEntry, err := c.validateElection(ctx, blk, workerSignerAddr, electionPowerTable)
 		if err != nil {
-			return errors.Wrapf(err, "failed to verify election proof")
-		}
-		// TODO this is not using nominal power, which must take into account undeclared faults
-		// TODO the nominal power must be tested against the minimum (power.minerNominalPowerMeetsConsensusMinimum)
-		// See https://github.com/sbwtw/go-filecoin/issues/3958
-		minerPower, err := electionPowerTable.MinerClaimedPower(ctx, blk.Miner)
-		if err != nil {
-			return errors.Wrap(err, "failed to read miner claim from power table")
-		}
-		networkPower, err := electionPowerTable.NetworkTotalPower(ctx)
-		if err != nil {
-			return errors.Wrap(err, "failed to read power table")
-		}
-		electionVRFDigest := blk.ElectionProof.VRFProof.Digest()
-		wins := c.IsWinner(electionVRFDigest[:], minerPower, networkPower)
-		if !wins {
-			return errors.Errorf("Block did not win election")
+			return err
 		}
 
 		allSectorInfos, err := sectorSetPowerTable.SortedSectorInfos(ctx, blk.Miner)
@@ -302,17 +327,126 @@ func (c *Expected) validateMining(ctx context.Context,
 			return errors.Wrapf(err, "failed verifying winning post")
 		}
 		if !valid {
-			return errors.Errorf("Invalid winning post")
+			return Invalid(errors.Errorf("Invalid winning post"))
 		}
+	}
+	return sigs.Wait()
+}
 
-		// Ticket was correctly generated by miner
-		sampleEpoch := blk.Height - miner.ElectionLookback
-		newPeriod := len(blk.BeaconEntries) > 0
-		if err := c.IsValidTicket(ctx, blk.Parents, electionEntry, newPeriod, sampleEpoch, blk.Miner, workerSignerAddr, blk.Ticket); err != nil {
-			return errors.Wrapf(err, "invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid())
-		}
+// invalidUnlessDone marks err Invalid unless it may come from ctx being done.
+func invalidUnlessDone(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return err
 	}
-	return nil
+	return Invalid(err)
+}
+
+// ValidateHeader checks the validity of a block that can be checked without
+// its messages or running its parent: the block signature against the miner's
+// worker key, the drand entries, and the election proof, win and ticket
+// against the lookback state. It returns ErrParentStateUnknown when the parent
+// is not in the chain store with its state computed, and ctx's error once ctx
+// is done, stopping between checks.
+func (c *Expected) ValidateHeader(ctx context.Context, blk *block.Block) error {
+	parent, err := c.chainState.GetTipSet(blk.Parents)
+	if err != nil {
+		return errors.Wrap(ErrParentStateUnknown, err.Error())
+	}
+	parentStateRoot, err := c.chainState.GetTipSetStateRoot(blk.Parents)
+	if err != nil {
+		return errors.Wrap(ErrParentStateUnknown, err.Error())
+	}
+	if !parentStateRoot.Equals(blk.StateRoot.Cid) {
+		return Invalid(ErrStateRootMismatch)
+	}
+	keyPowerTable := NewPowerTableView(c.state.PowerStateView(parentStateRoot), c.state.FaultStateView(parentStateRoot))
+
+	electionPowerAncestor, err := chain.FindTipsetAtEpoch(ctx, parent, blk.Height-ElectionPowerTableLookback, c.chainState)
+	if err != nil {
+		return errors.Wrap(err, "failed to find election power lookback ancestor")
+	}
+	electionPowerStateRoot, err := c.chainState.GetTipSetStateRoot(electionPowerAncestor.Key())
+	if err != nil {
+		return errors.Wrap(err, "failed to get state root for election power ancestor")
+	}
+	electionPowerTable := NewPowerTableView(c.state.PowerStateView(electionPowerStateRoot), c.state.FaultStateView(parentStateRoot))
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	workerSignerAddr, err := validateBlockSignature(ctx, keyPowerTable, blk)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	_, err = c.validateElection(ctx, blk, workerSignerAddr, electionPowerTable)
+	return err
+}
+
+// validateBlockSignature checks the block is signed by the worker of its
+// miner, returning the worker's signing address.
+func validateBlockSignature(ctx context.Context, keyPowerTable PowerTableView, blk *block.Block) (address.Address, error) {
+	workerAddr, err := keyPowerTable.WorkerAddr(ctx, blk.Miner)
+	if err != nil {
+		return address.Undef, errors.Wrap(err, "failed to read worker address of block miner")
+	}
+	workerSignerAddr, err := keyPowerTable.SignerAddress(ctx, workerAddr)
+	if err != nil {
+		return address.Undef, errors.Wrapf(err, "failed to convert address, %s, to a signing address", workerAddr.String())
+	}
+	if blk.BlockSig == nil {
+		return address.Undef, Invalid(errors.Errorf("invalid nil block signature"))
+	}
+	if err := crypto.ValidateSignature(blk.SignatureData(), workerSignerAddr, *blk.BlockSig); err != nil {
+		return address.Undef, Invalid(errors.Wrap(err, "block signature invalid"))
+	}
+	return workerSignerAddr, nil
+}
+
+// validateElection checks the block's drand entries, that its election proof
+// is valid and wins with the miner's power in the election power table, and
+// that its ticket was correctly generated by the miner. It returns the drand
+// entry the election was run with.
+func (c *Expected) validateElection(ctx context.Context, blk *block.Block, workerSignerAddr address.Address, electionPowerTable PowerTableView) (*drand.Entry, error) {
+	err := c.validateDRANDEntries(ctx, blk)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid DRAND entries")
+	}
+
+	electionEntry, err := c.electionEntry(ctx, blk)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get election entry")
+	}
+	err = c.VerifyElectionProof(ctx, electionEntry, blk.Height, blk.Miner, workerSignerAddr, blk.ElectionProof.VRFProof)
+	if err != nil {
+		return nil, invalidUnlessDone(ctx, errors.Wrapf(err, "failed to verify election proof"))
+	}
+	// TODO this is not using nominal power, which must take into account undeclared faults
+	// TODO the nominal power must be tested against the minimum (power.minerNominalPowerMeetsConsensusMinimum)
+	// See https://github.com/sbwtw/go-filecoin/issues/3958
+	minerPower, err := electionPowerTable.MinerClaimedPower(ctx, blk.Miner)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read miner claim from power table")
+	}
+	networkPower, err := electionPowerTable.NetworkTotalPower(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read power table")
+	}
+	electionVRFDigest := blk.ElectionProof.VRFProof.Digest()
+	wins := c.IsWinner(electionVRFDigest[:], minerPower, networkPower)
+	if !wins {
+		return nil, Invalid(errors.Errorf("Block did not win election"))
+	}
+
+	// Ticket was correctly generated by miner
+	sampleEpoch := blk.Height - miner.ElectionLookback
+	newPeriod := len(blk.BeaconEntries) > 0
+	if err := c.IsValidTicket(ctx, blk.Parents, electionEntry, newPeriod, sampleEpoch, blk.Miner, workerSignerAddr, blk.Ticket); err != nil {
+		return nil, errors.Wrapf(err, "invalid ticket: %s in block %s", blk.Ticket.String(), blk.Cid())
+	}
+	return electionEntry, nil
 }
 
 func (c *Expected) validateDRANDEntries(ctx context.Context, blk *block.Block) error {
@@ -333,14 +467,14 @@ func (c *Expected) validateDRANDEntries(ctx context.Context, blk *block.Block) e
 		if c.clock.EpochAtTime(nextDRANDTime) > targetEpoch {
 			return nil
 		}
-		return errors.New("Block missing required DRAND entry")
+		return Invalid(errors.New("Block missing required DRAND entry"))
 	}
 
 	lastRound := blk.BeaconEntries[numEntries-1].Round
 	nextDRANDTime := c.drand.StartTimeOfRound(lastRound + 1)
 
 	if !(c.clock.EpochAtTime(nextDRANDTime) > targetEpoch) {
-		return errors.New("Block does not include all drand entries required")
+		return Invalid(errors.New("Block does not include all drand entries required"))
 	}
 
 	// Validate that DRAND entries link up
@@ -357,19 +491,19 @@ func (c *Expected) validateDRANDEntries(ctx context.Context, blk *block.Block) e
 		}
 		valid, err := c.drand.VerifyEntry(prevEntry, blk.BeaconEntries[0])
 		if err != nil {
-			return err
+			return Invalid(err)
 		}
 		if !valid {
-			return errors.Errorf("invalid DRAND link rounds %d and %d", prevEntry.Round, blk.BeaconEntries[0].Round)
+			return Invalid(errors.Errorf("invalid DRAND link rounds %d and %d", prevEntry.Round, blk.BeaconEntries[0].Round))
 		}
 	}
 	for i := 0; i < numEntries-1; i++ {
 		valid, err := c.drand.VerifyEntry(blk.BeaconEntries[i], blk.BeaconEntries[i+1])
 		if err != nil {
-			return err
+			return Invalid(err)
 		}
 		if !valid {
-			return errors.Errorf("invalid DRAND link rounds %d and %d", blk.BeaconEntries[i].Round, blk.BeaconEntries[i+1].Round)
+			return Invalid(errors.Errorf("invalid DRAND link rounds %d and %d", blk.BeaconEntries[i].Round, blk.BeaconEntries[i+1].Round))
 		}
 	}
 
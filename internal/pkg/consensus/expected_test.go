@@ -14,6 +14,7 @@ import (
 	"github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
 	cbor "github.com/ipfs/go-ipld-cbor"
+	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -210,6 +211,7 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		_, _, err = exp.RunStateTransition(ctx, tipSet, emptyBLSMessages, emptyMessages, nextBlocks[0].ParentWeight, nextBlocks[0].StateRoot.Cid, nextBlocks[0].MessageReceipts.Cid)
 		assert.EqualError(t, err, "block signature invalid")
+		assert.True(t, consensus.IsInvalid(err))
 	})
 
 	t.Run("returns nil + error when parent weight invalid", func(t *testing.T) {
@@ -231,9 +233,25 @@ func TestExpected_RunStateTransition_validateMining(t *testing.T) {
 
 		_, _, err = exp.RunStateTransition(ctx, tipSet, emptyBLSMessages, emptyMessages, invalidParentWeight, nextBlocks[0].StateRoot.Cid, nextBlocks[0].MessageReceipts.Cid)
 		assert.Contains(t, err.Error(), "invalid parent weight")
+		assert.True(t, consensus.IsInvalid(err))
 	})
 }
 
+func TestInvalid(t *testing.T) {
+	tf.UnitTest(t)
+
+	cause := errors.New("bad block")
+	err := consensus.Invalid(cause)
+	assert.True(t, consensus.IsInvalid(err))
+	assert.Equal(t, "bad block", err.Error())
+	assert.Equal(t, cause, pkgerrors.Cause(err))
+	assert.True(t, consensus.IsInvalid(pkgerrors.Wrap(err, "validating tipset")))
+
+	assert.False(t, consensus.IsInvalid(pkgerrors.Wrap(cause, "loading state")))
+	assert.False(t, consensus.IsInvalid(nil))
+	assert.Nil(t, consensus.Invalid(nil))
+}
+
 func emptyMessages(numBlocks int) ([][]*types.UnsignedMessage, [][]*types.SignedMessage) {
 	var emptyBLSMessages [][]*types.UnsignedMessage
 	var emptyMessages [][]*types.SignedMessage
@@ -9,6 +9,7 @@ import (
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/metrics/tracing"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm/state"
 )
@@ -82,6 +83,27 @@ func (p *DefaultProcessor) ProcessTipSet(ctx context.Context, st state.Tree, vms
 	return v.ApplyTipSetMessages(msgs, parent, epoch, &rnd)
 }
 
+// ApplyMessagesForEstimation applies the messages, in order, as if they were included in a block
+// mined on top of `head`. The state tree is left uncommitted so it can be discarded after use.
+func (p *DefaultProcessor) ApplyMessagesForEstimation(ctx context.Context, st state.Tree, vms vm.Storage, head block.TipSet, msgs []*types.SignedMessage) (results []vm.MessageReceipt, err error) {
+	ctx, span := trace.StartSpan(ctx, "DefaultProcessor.ApplyMessagesForEstimation")
+	span.AddAttributes(trace.StringAttribute("head", head.String()))
+	defer tracing.AddErrorEndSpan(ctx, span, &err)
+
+	height, err := head.Height()
+	if err != nil {
+		return nil, err
+	}
+
+	rnd := headRandomness{
+		chain: p.rnd,
+		head:  head.Key(),
+	}
+	v := vm.NewVM(st, &vms, p.syscalls)
+
+	return v.ApplyMessagesForEstimation(msgs, head.Key(), height+1, &rnd), nil
+}
+
 // A chain randomness source with a fixed head tipset key.
 type headRandomness struct {
 	chain ChainRandomness
@@ -57,6 +57,22 @@ func init() {
 	invGasAboveBlockLimitCt = metrics.NewInt64Counter("consensus/msg_gaslimit_max", "Count of invalid messages with gas above block limit")
 }
 
+// Errors classifying why the state of a message's sender does not admit it.
+var (
+	// ErrSenderMissing is returned when the sender actor does not exist or is empty.
+	ErrSenderMissing = errors.New("sender is missing or empty")
+	// ErrSenderNotAccount is returned when the sender is not an account actor.
+	ErrSenderNotAccount = errors.New("sender is not an account actor")
+	// ErrInsufficientFunds is returned when the sender cannot cover the value and gas cost.
+	ErrInsufficientFunds = errors.New("insufficient funds from sender to cover value and gas cost")
+	// ErrNonceTooLow is returned when the sender has already sent a message with the nonce.
+	ErrNonceTooLow = errors.New("nonce too low")
+	// ErrNonceTooHigh is returned when the nonce is further ahead of the sender's than admitted.
+	ErrNonceTooHigh = errors.New("nonce too high")
+	// ErrStateUnavailable is returned when the state to validate a message against fails to load.
+	ErrStateUnavailable = errors.New("state unavailable")
+)
+
 // MessageSelectionChecker checks for miner penalties on signed messages
 type MessagePenaltyChecker struct {
 	api penaltyCheckerAPI
@@ -77,35 +93,54 @@ func NewMessagePenaltyChecker(api penaltyCheckerAPI) *MessagePenaltyChecker {
 // PenaltyCheck checks that a message is semantically valid for processing without
 // causing miner penality.  It treats any miner penalty condition as an error.
 func (v *MessagePenaltyChecker) PenaltyCheck(ctx context.Context, msg *types.UnsignedMessage) error {
+	err := v.SenderCheck(ctx, msg, 0)
+	switch errors.Cause(err) {
+	case ErrSenderNotAccount:
+		dropNonAccountCt.Inc(ctx, 1)
+	case ErrInsufficientFunds:
+		dropInsufficientGasCt.Inc(ctx, 1)
+	case ErrNonceTooLow:
+		dropNonceTooLowCt.Inc(ctx, 1)
+	case ErrNonceTooHigh:
+		dropNonceTooHighCt.Inc(ctx, 1)
+	}
+	return err
+}
+
+// SenderCheck checks a message against the state of its sender at the head:
+// the sender must be an account actor able to pay for the message, and the
+// nonce at most maxNonceGap ahead of the sender's. Failures of the sender
+// state wrap one of ErrSenderMissing, ErrSenderNotAccount,
+// ErrInsufficientFunds, ErrNonceTooLow and ErrNonceTooHigh.
+func (v *MessagePenaltyChecker) SenderCheck(ctx context.Context, msg *types.UnsignedMessage, maxNonceGap uint64) error {
 	fromActor, err := v.api.GetActorAt(ctx, v.api.Head(), msg.From)
+	if errors.Cause(err) == types.ErrNotFound {
+		return errors.Wrapf(ErrSenderMissing, "sender %s: %s", msg.From, msg)
+	}
 	if err != nil {
 		return err
 	}
 	// Sender should not be an empty actor
 	if fromActor == nil || fromActor.Empty() {
-		return fmt.Errorf("sender %s is missing/empty: %s", msg.From, msg)
+		return errors.Wrapf(ErrSenderMissing, "sender %s: %s", msg.From, msg)
 	}
 
 	// Sender must be an account actor.
 	if !(builtin.AccountActorCodeID.Equals(fromActor.Code.Cid)) {
-		dropNonAccountCt.Inc(ctx, 1)
-		return fmt.Errorf("sender %s is non-account actor with code %s: %s", msg.From, fromActor.Code.Cid, msg)
+		return errors.Wrapf(ErrSenderNotAccount, "sender %s has code %s: %s", msg.From, fromActor.Code.Cid, msg)
 	}
 
 	// Avoid processing messages for actors that cannot pay.
 	if !canCoverGasLimit(msg, fromActor) {
-		dropInsufficientGasCt.Inc(ctx, 1)
-		return fmt.Errorf("insufficient funds from sender %s to cover value and gas cost: %s ", msg.From, msg)
+		return errors.Wrapf(ErrInsufficientFunds, "sender %s: %s", msg.From, msg)
 	}
 
 	if msg.CallSeqNum < fromActor.CallSeqNum {
-		dropNonceTooLowCt.Inc(ctx, 1)
-		return fmt.Errorf("nonce %d lower than expected %d: %s", msg.CallSeqNum, fromActor.CallSeqNum, msg)
+		return errors.Wrapf(ErrNonceTooLow, "nonce %d lower than expected %d: %s", msg.CallSeqNum, fromActor.CallSeqNum, msg)
 	}
 
-	if msg.CallSeqNum > fromActor.CallSeqNum {
-		dropNonceTooHighCt.Inc(ctx, 1)
-		return fmt.Errorf("nonce %d greater than expected: %d: %s", msg.CallSeqNum, fromActor.CallSeqNum, msg)
+	if msg.CallSeqNum > fromActor.CallSeqNum+maxNonceGap {
+		return errors.Wrapf(ErrNonceTooHigh, "nonce %d greater than expected %d by more than %d: %s", msg.CallSeqNum, fromActor.CallSeqNum, maxNonceGap, msg)
 	}
 
 	return nil
@@ -213,13 +248,13 @@ func NewMessageSignatureValidator(api signatureValidatorAPI) *MessageSignatureVa
 	}
 }
 
-// Validate validates the signed message signature. Errors probably mean the
-//  validation failed, but possibly indicate a failure to retrieve state.
+// Validate validates the signed message signature. A failure to retrieve state
+// wraps ErrStateUnavailable, other errors mean the validation failed.
 func (v *MessageSignatureValidator) Validate(ctx context.Context, smsg *types.SignedMessage) error {
 	head := v.api.Head()
 	view, err := v.api.AccountStateView(head)
 	if err != nil {
-		return errors.Wrapf(err, "failed to load state at %v", head)
+		return errors.Wrapf(ErrStateUnavailable, "failed to load state at %v: %s", head, err)
 	}
 
 	sigValidator := state.NewSignatureValidator(view)
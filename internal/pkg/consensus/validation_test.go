@@ -9,6 +9,7 @@ import (
 	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
 
 	bls "github.com/sbwtw/filecoin-ffi"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
@@ -82,6 +83,17 @@ func TestMessagePenaltyChecker(t *testing.T) {
 		msg := newMessage(t, alice, bob, 101, 5, 1, 0)
 		assert.Errorf(t, checker.PenaltyCheck(ctx, msg), "too high")
 	})
+
+	t.Run("sender check admits nonce gap", func(t *testing.T) {
+		msg := newMessage(t, alice, bob, 110, 5, 1, 0)
+		assert.NoError(t, checker.SenderCheck(ctx, msg, 10))
+
+		msg = newMessage(t, alice, bob, 111, 5, 1, 0)
+		assert.Equal(t, consensus.ErrNonceTooHigh, errors.Cause(checker.SenderCheck(ctx, msg, 10)))
+
+		msg = newMessage(t, alice, bob, 99, 5, 1, 0)
+		assert.Equal(t, consensus.ErrNonceTooLow, errors.Cause(checker.SenderCheck(ctx, msg, 10)))
+	})
 }
 
 func TestBLSSignatureValidationConfiguration(t *testing.T) {
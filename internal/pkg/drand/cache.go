@@ -0,0 +1,152 @@
+package drand
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+)
+
+// entryPrefix is the datastore namespace beacon entries are persisted under.
+var entryPrefix = datastore.NewKey("/drand/entries")
+
+// Cache is a beacon persisting the entries it fetches, and the entries it
+// verifies while validating blocks, so that they are not fetched again after
+// a restart or during sync. Offline, it never fetches and serves only the
+// entries it has cached.
+type Cache struct {
+	source  IFace
+	ds      datastore.Datastore
+	offline bool
+}
+
+var _ IFace = &Cache{}
+
+// NewCache creates a beacon caching the entries of source in ds.
+func NewCache(source IFace, ds datastore.Datastore, offline bool) *Cache {
+	return &Cache{
+		source:  source,
+		ds:      ds,
+		offline: offline,
+	}
+}
+
+// ReadEntry returns the cached entry for the round, fetching it from the
+// source when it is not cached, unless offline. A fetched entry is verified
+// against the entry of the previous round before it is cached, so that a bad
+// server's entry is never served from the cache.
+func (c *Cache) ReadEntry(ctx context.Context, drandRound Round) (*Entry, error) {
+	entry, err := c.get(drandRound)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		return entry, nil
+	}
+	if c.offline {
+		return nil, errors.Errorf("drand round %d is not cached and the beacon is offline", drandRound)
+	}
+
+	entry, err = c.source.ReadEntry(ctx, drandRound)
+	if err != nil {
+		return nil, err
+	}
+	if drandRound == 0 {
+		// The first round has no previous round to verify it with.
+		return entry, nil
+	}
+	if err := c.verifyFetched(ctx, entry); err != nil {
+		return nil, err
+	}
+	c.put(entry)
+	return entry, nil
+}
+
+// verifyFetched verifies an entry fetched from the source against the entry
+// of the previous round.
+func (c *Cache) verifyFetched(ctx context.Context, entry *Entry) error {
+	parent, err := c.get(entry.Round - 1)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		if parent, err = c.source.ReadEntry(ctx, entry.Round-1); err != nil {
+			return errors.Wrapf(err, "failed to fetch drand round %d to verify round %d", entry.Round-1, entry.Round)
+		}
+	}
+	valid, err := c.source.VerifyEntry(parent, entry)
+	if err != nil {
+		return errors.Wrapf(err, "fetched drand round %d is invalid", entry.Round)
+	}
+	if !valid {
+		return errors.Errorf("fetched drand round %d is invalid", entry.Round)
+	}
+	return nil
+}
+
+// VerifyEntry verifies the child entry with the source, and caches it when
+// valid.
+func (c *Cache) VerifyEntry(parent, child *Entry) (bool, error) {
+	valid, err := c.source.VerifyEntry(parent, child)
+	if err == nil && valid {
+		c.put(child)
+	}
+	return valid, err
+}
+
+// FetchGroupConfig fetches the group configuration with the source.
+func (c *Cache) FetchGroupConfig(addresses []string, secure bool, overrideGroupAddrs bool) ([]string, [][]byte, uint64, int, error) {
+	return c.source.FetchGroupConfig(addresses, secure, overrideGroupAddrs)
+}
+
+// StartTimeOfRound returns the start time of the round in the source.
+func (c *Cache) StartTimeOfRound(round Round) time.Time {
+	return c.source.StartTimeOfRound(round)
+}
+
+// RoundsInInterval returns the rounds of the source in the interval.
+func (c *Cache) RoundsInInterval(startTime, endTime time.Time) []Round {
+	return c.source.RoundsInInterval(startTime, endTime)
+}
+
+// FirstFilecoinRound returns the first round of the source included in the
+// filecoin blockchain.
+func (c *Cache) FirstFilecoinRound() Round {
+	return c.source.FirstFilecoinRound()
+}
+
+// get returns the cached entry for the round, or nil if there is none.
+func (c *Cache) get(round Round) (*Entry, error) {
+	val, err := c.ds.Get(entryKey(round))
+	if err == datastore.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read cached drand round %d", round)
+	}
+	var entry Entry
+	if err := encoding.Decode(val, &entry); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode cached drand round %d", round)
+	}
+	return &entry, nil
+}
+
+// put caches the entry. Failing to is logged, the entry is fetched again when
+// next needed regardless.
+func (c *Cache) put(entry *Entry) {
+	val, err := encoding.Encode(entry)
+	if err == nil {
+		err = c.ds.Put(entryKey(entry.Round), val)
+	}
+	if err != nil {
+		log.Errorf("failed to cache drand round %d: %s", entry.Round, err)
+	}
+}
+
+func entryKey(round Round) datastore.Key {
+	return entryPrefix.ChildString(strconv.FormatUint(uint64(round), 10))
+}
@@ -0,0 +1,85 @@
+package drand_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/drand"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestCacheServesEntriesOffline(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	source := drand.NewFake(time.Unix(1234567890, 0))
+	ds := datastore.NewMapDatastore()
+
+	// Entries fetched online are cached.
+	online := drand.NewCache(source, ds, false)
+	fetched, err := online.ReadEntry(ctx, 5)
+	require.NoError(t, err)
+
+	// Entries verified from blocks are cached.
+	parent := &drand.Entry{Round: 6, Data: []byte{1}}
+	child := &drand.Entry{Round: 7, Data: []byte{2}}
+	valid, err := online.VerifyEntry(parent, child)
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// Offline, a cache over the same datastore, as after a restart, serves
+	// both without fetching, and nothing else.
+	offline := drand.NewCache(source, ds, true)
+	entry, err := offline.ReadEntry(ctx, 5)
+	require.NoError(t, err)
+	assert.Equal(t, fetched, entry)
+	entry, err = offline.ReadEntry(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, child, entry)
+	_, err = offline.ReadEntry(ctx, 6)
+	assert.Error(t, err)
+}
+
+func TestCacheVerifiesFetchedEntries(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	filecoinGenTime := time.Unix(1234567890, 0)
+	drandGenTime := filecoinGenTime.Add(-30 * time.Second)
+	genuine, err := drand.NewLocal("devnet secret", nil, drandGenTime, filecoinGenTime, 30*time.Second)
+	require.NoError(t, err)
+	forger, err := drand.NewLocal("other secret", nil, drandGenTime, filecoinGenTime, 30*time.Second)
+	require.NoError(t, err)
+	ds := datastore.NewMapDatastore()
+
+	online := drand.NewCache(&forgingBeacon{Local: genuine, forger: forger, round: 5}, ds, false)
+	_, err = online.ReadEntry(ctx, 5)
+	assert.Error(t, err)
+	entry, err := online.ReadEntry(ctx, 6)
+	require.NoError(t, err)
+
+	// Only the entry that verified was cached.
+	offline := drand.NewCache(genuine, ds, true)
+	_, err = offline.ReadEntry(ctx, 5)
+	assert.Error(t, err)
+	cached, err := offline.ReadEntry(ctx, 6)
+	require.NoError(t, err)
+	assert.Equal(t, entry, cached)
+}
+
+// forgingBeacon serves the forger's entry for one round.
+type forgingBeacon struct {
+	*drand.Local
+	forger *drand.Local
+	round  drand.Round
+}
+
+func (b *forgingBeacon) ReadEntry(ctx context.Context, round drand.Round) (*drand.Entry, error) {
+	if round == b.round {
+		return b.forger.ReadEntry(ctx, round)
+	}
+	return b.Local.ReadEntry(ctx, round)
+}
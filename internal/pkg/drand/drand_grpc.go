@@ -3,7 +3,8 @@ package drand
 import (
 	"context"
 	"errors"
-	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/drand/drand/beacon"
@@ -29,9 +30,12 @@ func NewAddress(a string, secure bool) Address {
 
 // GRPC is a drand client that can fetch and verify from a public drand network
 type GRPC struct {
-	addresses []Address
-	client    *core.Client
-	key       *key.DistPublic
+	client *core.Client
+	// fetch requests the signature of a round from a server.
+	fetch func(addr Address, distKey *key.DistPublic, round Round) ([]byte, error)
+	// hedgeDelay is the time to wait for a server to answer before racing
+	// the next one.
+	hedgeDelay time.Duration
 
 	// The time of the 0th round of the DRAND chain
 	genesisTime time.Time
@@ -42,11 +46,34 @@ type GRPC struct {
 	// Duration of a round in this DRAND network
 	roundTime time.Duration
 
-	// internal state
-	latestEntry *Entry
-	cache       map[Round]*Entry
+	// mu guards the group's addresses and key, and the health of its
+	// servers, keyed by address
+	mu        sync.Mutex
+	addresses []Address
+	key       *key.DistPublic
+	health    map[string]*serverHealth
 }
 
+// serverHealth tracks the recent outcomes of requests to a drand server.
+type serverHealth struct {
+	// failures counts consecutive failed requests.
+	failures int
+	// retryAt is the time before which the server is only tried once the
+	// healthy servers have failed.
+	retryAt time.Time
+	latency time.Duration
+}
+
+const (
+	// fetchHedgeDelay is the time to wait for a server to answer before racing
+	// the next one.
+	fetchHedgeDelay = 2 * time.Second
+	// serverBackoff is the time a server is demoted for after its first
+	// failure, doubling with each further consecutive failure.
+	serverBackoff    = 10 * time.Second
+	maxServerBackoff = 10 * time.Minute
+)
+
 var _ IFace = &GRPC{}
 
 // NewGRPC creates a client that will draw randomness from the given addresses.
@@ -61,13 +88,15 @@ func NewGRPC(addresses []Address, distKeyCoeff [][]byte, drandGenTime time.Time,
 	grpc := &GRPC{
 		addresses:           addresses,
 		client:              core.NewGrpcClient(),
+		hedgeDelay:          fetchHedgeDelay,
 		key:                 distKey,
 		genesisTime:         drandGenTime,
 		filecoinGenesisTime: filecoinGenTime,
 		// firstFilecoin set in updateFirsFilecoinRound below
 		roundTime: rd,
-		cache:     make(map[Round]*Entry),
+		health:    make(map[string]*serverHealth),
 	}
+	grpc.fetch = grpc.fetchPublic
 	err = grpc.updateFirstFilecoinRound()
 	if err != nil {
 		return nil, err
@@ -75,69 +104,153 @@ func NewGRPC(addresses []Address, distKeyCoeff [][]byte, drandGenTime time.Time,
 	return grpc, nil
 }
 
+// fetchPublic requests the signature of a round from a server with the
+// client.
+func (d *GRPC) fetchPublic(addr Address, distKey *key.DistPublic, round Round) ([]byte, error) {
+	// The drand client doesn't accept a context, so is un-cancellable :-(
+	pub, err := d.client.Public(addr.address, distKey, addr.secure, int(round))
+	if err != nil {
+		return nil, err
+	}
+	return pub.GetSignature(), nil
+}
+
 func (d *GRPC) updateFirstFilecoinRound() error {
 	// First filecoin round is the first drand round before filecoinGenesisTime
-	searchStart := d.filecoinGenesisTime.Add(-1 * d.roundTime)
-	results := d.RoundsInInterval(searchStart, d.filecoinGenesisTime)
-	if len(results) != 1 {
-		return fmt.Errorf("found %d drand rounds between filecoinGenTime and filecoinGenTime - drandRountDuration, expected 1", len(results))
+	first, err := firstFilecoinRound(d.filecoinGenesisTime, d.roundTime, d.RoundsInInterval)
+	if err != nil {
+		return err
 	}
-	d.firstFilecoin = results[0]
+	d.firstFilecoin = first
 	return nil
 }
 
-// ReadEntry fetches an entry from one of the drand servers (trying them sequentially) and returns the result.
+// ReadEntry fetches an entry from the drand servers and returns the result.
+// Servers are tried healthiest first. A server slow to answer is raced
+// against the next one, and a failing one is failed over from.
 func (d *GRPC) ReadEntry(ctx context.Context, drandRound Round) (*Entry, error) {
-	if entry, ok := d.cache[drandRound]; ok {
-		return entry, nil
+	type result struct {
+		addr Address
+		sig  []byte
+		err  error
 	}
 
-	// try each address, stopping when we have a key
-	for _, addr := range d.addresses {
-		if ctx.Err() != nil { // Don't try any more peers after cancellation.
-			return nil, ctx.Err()
+	addrs := d.serversByHealth()
+	groupKey := d.groupKey()
+	// Buffered so that fetches outliving this call do not leak.
+	results := make(chan result, len(addrs))
+	next := 0
+	launch := func() {
+		addr := addrs[next]
+		next++
+		go func() {
+			start := time.Now()
+			sig, err := d.fetch(addr, groupKey, drandRound)
+			d.recordFetch(addr, time.Since(start), err)
+			results <- result{addr: addr, sig: sig, err: err}
+		}()
+	}
+
+	pending := 0
+	for next < len(addrs) || pending > 0 {
+		if pending == 0 {
+			launch()
+			pending++
 		}
-		// The drand client doesn't accept a context, so is un-cancellable :-(
-		pub, err := d.client.Public(addr.address, d.key, addr.secure, int(drandRound))
-		if err != nil {
-			log.Warnf("Error fetching drand randomness from %s: %s", addr.address, err)
-			continue
+		var hedge <-chan time.Time
+		if next < len(addrs) {
+			hedge = time.After(d.hedgeDelay)
 		}
 
-		// Because the client.Public() call can't be cancelled by this context, it can return at any time,
-		// potentially leading to concurrent state updates below racing a new call to into ReadEntry() (because
-		// the caller thought it was cancelled already).
-		// This check will mostly, but not completely securely, avoid this. A robust fix requires avoiding
-		// concurrent calls to here completely, which ultimately arise from the goroutine in the mining scheduler.
-		// https://github.com/sbwtw/go-filecoin/issues/4065
-		if ctx.Err() != nil {
+		select {
+		case <-ctx.Done(): // Don't wait for any more peers after cancellation.
 			return nil, ctx.Err()
+		case <-hedge:
+			launch()
+			pending++
+		case res := <-results:
+			pending--
+			if res.err != nil {
+				log.Warnf("Error fetching drand randomness from %s: %s", res.addr.address, res.err)
+				continue
+			}
+			return &Entry{
+				Round: drandRound,
+				Data:  res.sig,
+			}, nil
 		}
+	}
+	return nil, errors.New("could not retrieve drand randomess from any address")
+}
+
+// groupKey returns the distributed public key of the group.
+func (d *GRPC) groupKey() *key.DistPublic {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.key
+}
 
-		entry := &Entry{
-			Round: drandRound,
-			Data:  pub.GetSignature(),
+// serversByHealth returns the servers ordered for fetching: those not backing
+// off first, fastest first, then those backing off, least failed first.
+func (d *GRPC) serversByHealth() []Address {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	addrs := append([]Address(nil), d.addresses...)
+	health := func(addr Address) serverHealth {
+		if h, ok := d.health[addr.address]; ok {
+			return *h
 		}
-		d.updateLocalState(entry)
-		return entry, nil
+		return serverHealth{}
 	}
-	return nil, errors.New("could not retrieve drand randomess from any address")
+	sort.SliceStable(addrs, func(i, j int) bool {
+		hi, hj := health(addrs[i]), health(addrs[j])
+		backingOffI, backingOffJ := now.Before(hi.retryAt), now.Before(hj.retryAt)
+		if backingOffI != backingOffJ {
+			return backingOffJ
+		}
+		if backingOffI {
+			return hi.failures < hj.failures
+		}
+		return hi.latency < hj.latency
+	})
+	return addrs
 }
 
-func (d *GRPC) updateLocalState(entry *Entry) {
-	if d.latestEntry == nil {
-		d.latestEntry = entry
+// recordFetch updates the health of a server with the outcome of a request.
+func (d *GRPC) recordFetch(addr Address, latency time.Duration, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	h, ok := d.health[addr.address]
+	if !ok {
+		h = &serverHealth{}
+		d.health[addr.address] = h
+	}
+	if err == nil {
+		if h.failures > 0 {
+			log.Infof("drand server %s recovered after %d failures", addr.address, h.failures)
+		}
+		h.failures = 0
+		h.retryAt = time.Time{}
+		h.latency = latency
+		return
 	}
-	if entry.Round > d.latestEntry.Round {
-		d.latestEntry = entry
+
+	h.failures++
+	backoff := maxServerBackoff
+	if h.failures < 16 {
+		backoff = serverBackoff << uint(h.failures-1)
+		if backoff > maxServerBackoff {
+			backoff = maxServerBackoff
+		}
 	}
-	d.cache[entry.Round] = entry
+	h.retryAt = time.Now().Add(backoff)
 }
 
 // VerifyEntry verifies that the child's signature is a valid signature of the previous entry.
 func (d *GRPC) VerifyEntry(parent, child *Entry) (bool, error) {
 	msg := beacon.Message(uint64(child.Round), parent.Data)
-	err := key.Scheme.VerifyRecovered(d.key.Coefficients[0], msg, child.Data)
+	err := key.Scheme.VerifyRecovered(d.groupKey().Coefficients[0], msg, child.Data)
 	if err != nil {
 		return false, err
 	}
@@ -168,13 +281,15 @@ func (d *GRPC) FetchGroupConfig(addresses []string, secure bool, overrideGroupAd
 		if err != nil {
 			return nil, nil, 0, 0, err
 		}
+		d.mu.Lock()
 		d.key = distKey
-
 		if overrideGroupAddrs {
 			d.addresses = drandAddresses(addresses, secure)
 		} else {
 			d.addresses = drandAddresses(groupAddrs, secure)
 		}
+		d.health = make(map[string]*serverHealth)
+		d.mu.Unlock()
 
 		err = d.updateFirstFilecoinRound() // this depends on genesis and round time so recalculate
 		if err != nil {
@@ -0,0 +1,101 @@
+package drand
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drand/drand/key"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestGRPCFailsOverToHealthyServers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	d := newTestGRPC("a", "b")
+	d.fetch = func(addr Address, _ *key.DistPublic, _ Round) ([]byte, error) {
+		if addr.address == "a" {
+			return nil, errors.New("server down")
+		}
+		return []byte(addr.address), nil
+	}
+
+	entry, err := d.ReadEntry(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, &Entry{Round: 3, Data: []byte("b")}, entry)
+
+	// The failed server is tried last until it recovers.
+	assert.Equal(t, []string{"b", "a"}, serverNames(d.serversByHealth()))
+	assert.Equal(t, 1, d.health["a"].failures)
+	d.recordFetch(NewAddress("a", false), time.Millisecond, nil)
+	assert.Equal(t, 0, d.health["a"].failures)
+	assert.True(t, d.health["a"].retryAt.IsZero())
+
+	d.fetch = func(Address, *key.DistPublic, Round) ([]byte, error) {
+		return nil, errors.New("server down")
+	}
+	_, err = d.ReadEntry(ctx, 4)
+	assert.Error(t, err)
+}
+
+func TestGRPCHedgesSlowServers(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	d := newTestGRPC("slow", "fast")
+	release := make(chan struct{})
+	defer close(release)
+	d.fetch = func(addr Address, _ *key.DistPublic, _ Round) ([]byte, error) {
+		if addr.address == "slow" {
+			<-release
+		}
+		return []byte(addr.address), nil
+	}
+
+	entry, err := d.ReadEntry(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("fast"), entry.Data)
+
+	// A fetch still waiting on a slow server gives up with the context.
+	d.fetch = func(Address, *key.DistPublic, Round) ([]byte, error) {
+		<-release
+		return nil, errors.New("too late")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = d.ReadEntry(ctx, 4)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestGRPCRanksServersByHealth(t *testing.T) {
+	tf.UnitTest(t)
+	d := newTestGRPC("slow", "fast", "failed", "failing", "new")
+	d.recordFetch(NewAddress("slow", false), 30*time.Millisecond, nil)
+	d.recordFetch(NewAddress("fast", false), 10*time.Millisecond, nil)
+	d.recordFetch(NewAddress("failing", false), 0, errors.New("down"))
+	d.recordFetch(NewAddress("failing", false), 0, errors.New("down"))
+	d.recordFetch(NewAddress("failed", false), 0, errors.New("down"))
+
+	// Servers not backing off come first, fastest first, then those backing
+	// off, least failed first.
+	assert.Equal(t, []string{"new", "fast", "slow", "failed", "failing"}, serverNames(d.serversByHealth()))
+}
+
+func newTestGRPC(addresses ...string) *GRPC {
+	return &GRPC{
+		addresses:  drandAddresses(addresses, false),
+		hedgeDelay: 10 * time.Millisecond,
+		health:     make(map[string]*serverHealth),
+	}
+}
+
+func serverNames(addrs []Address) []string {
+	names := make([]string, len(addrs))
+	for i, addr := range addrs {
+		names[i] = addr.address
+	}
+	return names
+}
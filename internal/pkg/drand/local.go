@@ -0,0 +1,155 @@
+package drand
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drand/drand/beacon"
+	"github.com/drand/drand/key"
+	"github.com/drand/kyber"
+	"github.com/drand/kyber/share"
+)
+
+// Local is a deterministic stand-in for a drand network, for networks without
+// access to one. Each entry is the BLS signature a drand group with a single
+// member holding the secret would make of the round and a seed derived from
+// the public key. Unlike a drand chain, an entry does not sign the previous
+// one, so any round is generated directly, without replaying the rounds
+// before it. Entries are verified with the public key. Without the secret it
+// can only verify.
+type Local struct {
+	secret kyber.Scalar
+	public kyber.Point
+	// seed is the data of round 0, which every later round signs.
+	seed []byte
+
+	genesisTime   time.Time
+	firstFilecoin Round
+	roundTime     time.Duration
+}
+
+var _ IFace = &Local{}
+
+// NewLocal creates a local beacon generating entries from secret. With an
+// empty secret it only verifies entries, with the public key given as
+// distKeyCoeff. When both are given they must match.
+func NewLocal(secret string, distKeyCoeff [][]byte, drandGenTime time.Time, filecoinGenTime time.Time, rd time.Duration) (*Local, error) {
+	l := &Local{
+		genesisTime: drandGenTime,
+		roundTime:   rd,
+	}
+
+	if secret != "" {
+		digest := sha256.Sum256([]byte(secret))
+		l.secret = key.KeyGroup.Scalar().SetBytes(digest[:])
+		l.public = key.KeyGroup.Point().Mul(l.secret, nil)
+	}
+	if len(distKeyCoeff) > 0 {
+		distKey, err := groupKeycoefficientsToDistPublic(distKeyCoeff)
+		if err != nil {
+			return nil, err
+		}
+		if l.public != nil && !l.public.Equal(distKey.Coefficients[0]) {
+			return nil, errors.New("local drand secret does not match the configured distributed key")
+		}
+		l.public = distKey.Coefficients[0]
+	}
+	if l.public == nil {
+		return nil, errors.New("local drand needs a secret or a distributed key")
+	}
+
+	publicBytes, err := l.public.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	seed := sha256.Sum256(publicBytes)
+	l.seed = seed[:]
+
+	l.firstFilecoin, err = firstFilecoinRound(filecoinGenTime, rd, l.RoundsInInterval)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ReadEntry generates the entry of the round.
+func (l *Local) ReadEntry(_ context.Context, drandRound Round) (*Entry, error) {
+	if l.secret == nil {
+		return nil, errors.New("local drand has no secret to generate entries with")
+	}
+	if drandRound == 0 {
+		return &Entry{Round: 0, Data: l.seed}, nil
+	}
+	sig, err := l.sign(beacon.Message(uint64(drandRound), l.seed))
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Round: drandRound, Data: sig}, nil
+}
+
+// sign signs msg as a single member group would.
+func (l *Local) sign(msg []byte) ([]byte, error) {
+	partial, err := key.Scheme.Sign(&share.PriShare{I: 0, V: l.secret}, msg)
+	if err != nil {
+		return nil, err
+	}
+	pubPoly := share.NewPubPoly(key.KeyGroup, nil, []kyber.Point{l.public})
+	return key.Scheme.Recover(pubPoly, msg, [][]byte{partial}, 1, 1)
+}
+
+// VerifyEntry verifies that the child is the entry of the round following
+// the parent's, signed with the beacon's key.
+func (l *Local) VerifyEntry(parent, child *Entry) (bool, error) {
+	if child.Round != parent.Round+1 {
+		return false, fmt.Errorf("drand round %d does not follow round %d", child.Round, parent.Round)
+	}
+	msg := beacon.Message(uint64(child.Round), l.seed)
+	if err := key.Scheme.VerifyRecovered(l.public, msg, child.Data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DistKey returns the public key of the beacon as distributed key
+// coefficients, as configured for nodes verifying its entries.
+func (l *Local) DistKey() ([][]byte, error) {
+	publicBytes, err := l.public.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return [][]byte{publicBytes}, nil
+}
+
+// FetchGroupConfig errors, the local beacon has no group.
+func (l *Local) FetchGroupConfig(_ []string, _, _ bool) ([]string, [][]byte, uint64, int, error) {
+	return nil, nil, 0, 0, errors.New("local drand has no group to fetch")
+}
+
+// StartTimeOfRound returns the time the given round starts.
+func (l *Local) StartTimeOfRound(round Round) time.Time {
+	return l.genesisTime.Add(l.roundTime * time.Duration(round))
+}
+
+// RoundsInInterval returns all rounds in the given interval.
+func (l *Local) RoundsInInterval(startTime, endTime time.Time) []Round {
+	return roundsInInterval(startTime, endTime, l.StartTimeOfRound, l.roundTime)
+}
+
+// FirstFilecoinRound returns the first round included in the filecoin blockchain.
+func (l *Local) FirstFilecoinRound() Round {
+	return l.firstFilecoin
+}
+
+// firstFilecoinRound returns the first round included in the filecoin
+// blockchain, the round starting in the round time before its genesis.
+func firstFilecoinRound(filecoinGenesisTime time.Time, roundTime time.Duration, roundsInInterval func(time.Time, time.Time) []Round) (Round, error) {
+	searchStart := filecoinGenesisTime.Add(-1 * roundTime)
+	results := roundsInInterval(searchStart, filecoinGenesisTime)
+	if len(results) != 1 {
+		return 0, fmt.Errorf("found %d drand rounds between filecoinGenTime and filecoinGenTime - drandRountDuration, expected 1", len(results))
+	}
+	return results[0], nil
+}
@@ -0,0 +1,75 @@
+package drand_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/drand"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestLocalGeneratesVerifiableChain(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	filecoinGenTime := time.Unix(1234567890, 0)
+	drandGenTime := filecoinGenTime.Add(-30 * time.Second)
+
+	beacon, err := drand.NewLocal("devnet secret", nil, drandGenTime, filecoinGenTime, 30*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, drand.Round(0), beacon.FirstFilecoinRound())
+
+	entries := make([]*drand.Entry, 4)
+	for i := range entries {
+		entries[i], err = beacon.ReadEntry(ctx, drand.Round(i))
+		require.NoError(t, err)
+		assert.Equal(t, drand.Round(i), entries[i].Round)
+	}
+
+	// Another node configured with the same secret generates the same chain.
+	same, err := drand.NewLocal("devnet secret", nil, drandGenTime, filecoinGenTime, 30*time.Second)
+	require.NoError(t, err)
+	entry, err := same.ReadEntry(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, entries[3], entry)
+
+	// Any round is generated directly, without the rounds before it.
+	far, err := same.ReadEntry(ctx, 50000000)
+	require.NoError(t, err)
+	beforeFar, err := beacon.ReadEntry(ctx, 49999999)
+	require.NoError(t, err)
+
+	// A node configured with only the public key verifies the chain.
+	distKey, err := beacon.DistKey()
+	require.NoError(t, err)
+	verifier, err := drand.NewLocal("", distKey, drandGenTime, filecoinGenTime, 30*time.Second)
+	require.NoError(t, err)
+	for i := 1; i < len(entries); i++ {
+		valid, err := verifier.VerifyEntry(entries[i-1], entries[i])
+		require.NoError(t, err)
+		assert.True(t, valid)
+	}
+	valid, err := verifier.VerifyEntry(beforeFar, far)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	_, err = verifier.ReadEntry(ctx, 1)
+	assert.Error(t, err)
+
+	// Entries of another secret, or out of order, do not verify.
+	other, err := drand.NewLocal("other secret", nil, drandGenTime, filecoinGenTime, 30*time.Second)
+	require.NoError(t, err)
+	forged, err := other.ReadEntry(ctx, 1)
+	require.NoError(t, err)
+	valid, err = verifier.VerifyEntry(entries[0], forged)
+	assert.Error(t, err)
+	assert.False(t, valid)
+	valid, err = verifier.VerifyEntry(entries[1], entries[3])
+	assert.Error(t, err)
+	assert.False(t, valid)
+
+	_, err = drand.NewLocal("other secret", distKey, drandGenTime, filecoinGenTime, 30*time.Second)
+	assert.Error(t, err)
+}
@@ -146,6 +146,79 @@ func (ob *Outbox) SendEncoded(ctx context.Context, from, to address.Address, val
 	return sendSignedMsg(ctx, ob, signed, bcast)
 }
 
+// NextNonce returns the nonce of the next message from the sender, following
+// the messages in the outbound queue.
+func (ob *Outbox) NextNonce(ctx context.Context, from address.Address) (uint64, error) {
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	fromActor, err := ob.actors.GetActorAt(ctx, ob.chains.GetHead(), from)
+	if err != nil {
+		return 0, errors.Wrapf(err, "no actor at address %s", from)
+	}
+	return nextNonce(fromActor, ob.queue, from)
+}
+
+// Replace sends the queued message from the sender with the cid again with
+// the same nonce, at a new gas price and limit, so that it replaces the
+// original in message pools. The gas price must exceed the original's.
+// If bcast is true, the publisher broadcasts the message to the network at the current block height.
+func (ob *Outbox) Replace(ctx context.Context, from address.Address, replaced cid.Cid, gasPrice types.AttoFIL, gasLimit gas.Unit, bcast bool) (out cid.Cid, pubErrCh chan error, err error) {
+	defer func() {
+		if err != nil {
+			msgSendErrCt.Inc(ctx, 1)
+		}
+		ob.journal.Write("Replace",
+			"from", from.String(), "replaced", replaced.String(),
+			"gasPrice", gasPrice.Int.Uint64(), "gasLimit", uint64(gasLimit), "bcast", bcast,
+			"error", err, "cid", out.String())
+	}()
+
+	// Lock so the nonce is not taken by a new message meanwhile.
+	ob.nonceLock.Lock()
+	defer ob.nonceLock.Unlock()
+
+	var original *types.SignedMessage
+	for _, qm := range ob.queue.List(from) {
+		c, err := messageCid(qm.Msg)
+		if err != nil {
+			return cid.Undef, nil, err
+		}
+		if c.Equals(replaced) {
+			original = qm.Msg
+			break
+		}
+	}
+	if original == nil {
+		return cid.Undef, nil, errors.Errorf("message %s from %s is not queued", replaced, from)
+	}
+	if !gasPrice.GreaterThan(original.Message.GasPrice) {
+		return cid.Undef, nil, errors.Errorf("gas price %s does not exceed %s of the replaced message", gasPrice, original.Message.GasPrice)
+	}
+
+	rawMsg := original.Message
+	rawMsg.GasPrice = gasPrice
+	rawMsg.GasLimit = gasLimit
+	signed, err := types.NewSignedMessage(ctx, rawMsg, ob.signer)
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "failed to sign message")
+	}
+	err = ob.validator.Validate(ctx, signed)
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "invalid message")
+	}
+
+	head := ob.chains.GetHead()
+	height, err := tipsetHeight(ob.chains, head)
+	if err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "failed to get block height")
+	}
+	if err := ob.queue.Replace(ctx, signed); err != nil {
+		return cid.Undef, nil, errors.Wrap(err, "failed to replace message in outbound queue")
+	}
+	return publishSigned(ctx, ob, signed, height, bcast)
+}
+
 // SignedSend send a signed message, retaining it in the outbound message queue.
 // If bcast is true, the publisher broadcasts the message to the network at the current block height.
 func (ob *Outbox) SignedSend(ctx context.Context, signed *types.SignedMessage, bcast bool) (out cid.Cid, pubErrCh chan error, err error) {
@@ -171,14 +244,13 @@ func sendSignedMsg(ctx context.Context, ob *Outbox, signed *types.SignedMessage,
 	if err := ob.queue.Enqueue(ctx, signed, uint64(height)); err != nil {
 		return cid.Undef, nil, errors.Wrap(err, "failed to add message to outbound queue")
 	}
+	return publishSigned(ctx, ob, signed, height, bcast)
+}
 
-	var c cid.Cid
-	if signed.Message.From.Protocol() == address.BLS {
-		// drop signature before generating Cid to match cid of message retrieved from block.
-		c, err = signed.Message.Cid()
-	} else {
-		c, err = signed.Cid()
-	}
+// publishSigned publishes a queued signed message in the background, returning
+// its cid and a channel receiving the result of publishing.
+func publishSigned(ctx context.Context, ob *Outbox, signed *types.SignedMessage, height abi.ChainEpoch, bcast bool) (cid.Cid, chan error, error) {
+	c, err := messageCid(signed)
 	if err != nil {
 		return cid.Undef, nil, err
 	}
@@ -216,6 +288,15 @@ func nextNonce(act *actor.Actor, queue *Queue, address address.Address) (uint64,
 	return actorNonce, nil
 }
 
+// messageCid returns the cid the message has in blocks.
+func messageCid(signed *types.SignedMessage) (cid.Cid, error) {
+	if signed.Message.From.Protocol() == address.BLS {
+		// drop signature before generating Cid to match cid of message retrieved from block.
+		return signed.Message.Cid()
+	}
+	return signed.Cid()
+}
+
 func tipsetHeight(provider chainProvider, key block.TipSetKey) (abi.ChainEpoch, error) {
 	head, err := provider.GetTipSet(key)
 	if err != nil {
@@ -144,6 +144,75 @@ func TestOutbox(t *testing.T) {
 		}
 	})
 
+	t.Run("replace sends the queued message again with the same nonce", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := vmaddr.NewForTestGetter()()
+		queue := message.NewQueue()
+		publisher := &message.MockPublisher{}
+		provider := message.NewFakeProvider(t)
+
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1000)
+		})
+		actr := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0), cid.Undef)
+		actr.CallSeqNum = 42
+		provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+		original, pubDone, err := ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(1), gas.NewGas(10), true, builtin.MethodSend, adt.Empty)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+
+		_, _, err = ob.Replace(ctx, sender, original, types.NewGasPrice(1), gas.NewGas(20), true)
+		assert.Error(t, err)
+
+		replacement, pubDone, err := ob.Replace(ctx, sender, original, types.NewGasPrice(2), gas.NewGas(20), true)
+		require.NoError(t, err)
+		require.NoError(t, <-pubDone)
+		assert.NotEqual(t, original, replacement)
+
+		queued := queue.List(sender)
+		require.Len(t, queued, 1)
+		assert.Equal(t, actr.CallSeqNum, queued[0].Msg.Message.CallSeqNum)
+		assert.Equal(t, types.NewGasPrice(2), queued[0].Msg.Message.GasPrice)
+		assert.Equal(t, gas.NewGas(20), queued[0].Msg.Message.GasLimit)
+		assert.Equal(t, queued[0].Msg, publisher.Message)
+
+		// the original is no longer queued to be replaced
+		_, _, err = ob.Replace(ctx, sender, original, types.NewGasPrice(4), gas.NewGas(20), true)
+		assert.Error(t, err)
+	})
+
+	t.Run("next nonce follows the queued messages", func(t *testing.T) {
+		ctx := context.Background()
+		w, _ := types.NewMockSignersAndKeyInfo(1)
+		sender := w.Addresses[0]
+		toAddr := vmaddr.NewForTestGetter()()
+		queue := message.NewQueue()
+		publisher := &message.MockPublisher{}
+		provider := message.NewFakeProvider(t)
+
+		head := provider.BuildOneOn(block.UndefTipSet, func(b *chain.BlockBuilder) {
+			b.IncHeight(1000)
+		})
+		actr := actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(0), cid.Undef)
+		actr.CallSeqNum = 42
+		provider.SetHeadAndActor(t, head.Key(), sender, actr)
+
+		ob := message.NewOutbox(w, message.FakeValidator{}, queue, publisher, message.NullPolicy{}, provider, provider, newOutboxTestJournal(t))
+		nonce, err := ob.NextNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, actr.CallSeqNum, nonce)
+
+		_, _, err = ob.Send(ctx, sender, toAddr, types.ZeroAttoFIL, types.NewGasPrice(0), gas.NewGas(0), true, builtin.MethodSend, adt.Empty)
+		require.NoError(t, err)
+		nonce, err = ob.NextNonce(ctx, sender)
+		require.NoError(t, err)
+		assert.Equal(t, actr.CallSeqNum+1, nonce)
+	})
+
 	t.Run("fails with non-account actor", func(t *testing.T) {
 		w, _ := types.NewMockSignersAndKeyInfo(1)
 		sender := w.Addresses[0]
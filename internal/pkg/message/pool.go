@@ -25,7 +25,8 @@ type PoolValidator interface {
 // By 'de-duplicated' we mean that insertion of a message by cid that already
 // exists is a nop. We use a Pool to store all messages received by this node
 // via network or directly created via user command that have yet to be included
-// in a block. Messages are removed as they are processed.
+// in a block. Messages are removed as they are processed. A message with the
+// actor and nonce of a pending one replaces it when it pays a higher gas price.
 //
 // Pool is safe for concurrent access.
 type Pool struct {
@@ -34,7 +35,7 @@ type Pool struct {
 	cfg           *config.MessagePoolConfig
 	validator     PoolValidator
 	pending       map[cid.Cid]*timedmessage // all pending messages
-	addressNonces map[addressNonce]bool     // set of address nonce pairs used to efficiently validate duplicate nonces
+	addressNonces map[addressNonce]cid.Cid  // cids by address nonce pairs used to efficiently validate duplicate nonces
 }
 
 type timedmessage struct {
@@ -57,7 +58,7 @@ func NewPool(cfg *config.MessagePoolConfig, validator PoolValidator) *Pool {
 		cfg:           cfg,
 		validator:     validator,
 		pending:       make(map[cid.Cid]*timedmessage),
-		addressNonces: make(map[addressNonce]bool),
+		addressNonces: make(map[addressNonce]cid.Cid),
 	}
 }
 
@@ -82,8 +83,11 @@ func (pool *Pool) Add(ctx context.Context, msg *types.SignedMessage, height abi.
 		return cid.Undef, errors.Wrap(err, "validation error adding message to pool")
 	}
 
+	if replaced, found := pool.addressNonces[newAddressNonce(msg)]; found {
+		delete(pool.pending, replaced)
+	}
 	pool.pending[c] = &timedmessage{message: msg, addedAt: height}
-	pool.addressNonces[newAddressNonce(msg)] = true
+	pool.addressNonces[newAddressNonce(msg)] = c
 	mpSize.Set(ctx, int64(len(pool.pending)))
 	return c, nil
 }
@@ -158,14 +162,17 @@ func (pool *Pool) PendingBefore(minimumHeight abi.ChainEpoch) []cid.Cid {
 // validateMessage validates that too many messages aren't added to the pool and the ones that are
 // have a high probability of making it through processing.
 func (pool *Pool) validateMessage(ctx context.Context, message *types.SignedMessage) error {
-	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize {
+	_, replacing := pool.addressNonces[newAddressNonce(message)]
+	if uint(len(pool.pending)) >= pool.cfg.MaxPoolSize && !replacing {
 		return errors.Errorf("message pool is full (%d messages)", pool.cfg.MaxPoolSize)
 	}
 
-	// check that message with this nonce does not already exist
-	_, found := pool.addressNonces[newAddressNonce(message)]
-	if found {
-		return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+	// check that message with this nonce does not already exist, unless the
+	// message pays more to replace it
+	if existing, found := pool.addressNonces[newAddressNonce(message)]; found {
+		if !message.Message.GasPrice.GreaterThan(pool.pending[existing].message.Message.GasPrice) {
+			return errors.Errorf("message pool contains message with same actor and nonce but different cid")
+		}
 	}
 
 	// check that the message is likely to succeed in processing
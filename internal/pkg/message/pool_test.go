@@ -100,6 +100,34 @@ func TestMessagePoolValidate(t *testing.T) {
 		assert.Contains(t, err.Error(), "message with same actor and nonce")
 	})
 
+	t.Run("replaces a message with same nonce paying a higher gas price", func(t *testing.T) {
+		ctx := context.Background()
+		pool := message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator())
+
+		smsg1 := newSignedMessage()
+		c1, err := pool.Add(ctx, smsg1, 0)
+		require.NoError(t, err)
+
+		smsg2 := mustResignMessage(mockSigner, smsg1, func(m *types.UnsignedMessage) {
+			m.GasPrice = types.NewGasPrice(1)
+		})
+		c2, err := pool.Add(ctx, smsg2, 0)
+		require.NoError(t, err)
+
+		_, found := pool.Get(c1)
+		assert.False(t, found)
+		_, found = pool.Get(c2)
+		assert.True(t, found)
+		assert.Len(t, pool.Pending(), 1)
+
+		// the replacement is not replaced at the same price
+		smsg3 := mustResignMessage(mockSigner, smsg2, func(m *types.UnsignedMessage) {
+			m.Method++
+		})
+		_, err = pool.Add(ctx, smsg3, 0)
+		assert.Error(t, err)
+	})
+
 	t.Run("validates using supplied validator", func(t *testing.T) {
 		ctx := context.Background()
 		validator := th.NewMockMessagePoolValidator()
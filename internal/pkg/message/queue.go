@@ -89,6 +89,22 @@ func (mq *Queue) Requeue(ctx context.Context, msg *types.SignedMessage, stamp ui
 	return nil
 }
 
+// Replace puts a message in place of the queued message from the same address
+// with the same nonce, keeping its stamp. It returns an error if no message
+// with the nonce is queued.
+func (mq *Queue) Replace(ctx context.Context, msg *types.SignedMessage) error {
+	mq.lk.Lock()
+	defer mq.lk.Unlock()
+
+	for _, qm := range mq.queues[msg.Message.From] {
+		if qm.Msg.Message.CallSeqNum == msg.Message.CallSeqNum {
+			qm.Msg = msg
+			return nil
+		}
+	}
+	return errors.Errorf("no message with nonce %d queued for %s", msg.Message.CallSeqNum, msg.Message.From)
+}
+
 // RemoveNext removes and returns a single message from the queue, if it bears the expected nonce value, with found = true.
 // Returns found = false if the queue is empty or the expected nonce is less than any in the queue for that address
 // (indicating the message had already been removed).
@@ -163,6 +163,22 @@ func TestMessageQueue(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("replace", func(t *testing.T) {
+		q := message.NewQueue()
+		requireEnqueue(q, mm.NewSignedMessage(alice, 0), 100)
+		requireEnqueue(q, mm.NewSignedMessage(alice, 1), 101)
+
+		replacement := mm.NewSignedMessage(alice, 1)
+		require.NoError(t, q.Replace(ctx, replacement))
+		queued := q.List(alice)
+		require.Len(t, queued, 2)
+		assert.Equal(t, replacement, queued[1].Msg)
+		assert.Equal(t, uint64(101), queued[1].Stamp)
+
+		assert.Error(t, q.Replace(ctx, mm.NewSignedMessage(alice, 2)))
+		assert.Error(t, q.Replace(ctx, mm.NewSignedMessage(bob, 0)))
+	})
+
 	t.Run("invalid remove sequence", func(t *testing.T) {
 		msgs := []*types.SignedMessage{
 			mm.NewSignedMessage(alice, 10),
@@ -0,0 +1,364 @@
+package mining
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+	"github.com/sbwtw/go-filecoin/internal/pkg/metrics"
+)
+
+// historyPrefix is the datastore namespace epoch reports are persisted under.
+var historyPrefix = datastore.NewKey("/mining/history")
+
+// historyEpochs is the number of epochs reports are kept for, about a day.
+const historyEpochs = 2880
+
+var (
+	mEpochWon         = metrics.NewInt64Counter("mining/epoch_won", "Number of epochs a block was mined in on time")
+	mEpochLate        = metrics.NewInt64Counter("mining/epoch_late", "Number of epochs a block was mined in after the epoch ended")
+	mEpochLost        = metrics.NewInt64Counter("mining/epoch_lost", "Number of epochs the election was lost in")
+	mEpochNoPower     = metrics.NewInt64Counter("mining/epoch_no_power", "Number of epochs the miner had no power in")
+	mEpochDrandFailed = metrics.NewInt64Counter("mining/epoch_drand_failed", "Number of epochs mining failed to fetch drand entries in")
+	mEpochPoStFailed  = metrics.NewInt64Counter("mining/epoch_winning_post_failed", "Number of epochs a won election failed to generate a winning PoSt in")
+	mEpochFailed      = metrics.NewInt64Counter("mining/epoch_failed", "Number of epochs mining failed in for other reasons")
+	mBlockIncluded    = metrics.NewInt64Counter("mining/block_included", "Number of mined blocks in the chain at finality")
+	mBlockOrphaned    = metrics.NewInt64Counter("mining/block_orphaned", "Number of mined blocks not in the chain at finality")
+
+	mDrandLatency = metrics.NewTimerMs("mining/drand_latency", "Duration of fetching the drand entries of an epoch")
+	// [>=0s, >=1s, >=2s, >=5s, >=10s, >=15s, >=20s, >=25s, >=30s, >=60s]
+	mWinningPoStDuration = metrics.NewTimerWithBuckets("mining/winning_post_duration", "Duration of generating a winning PoSt", stats.UnitMilliseconds,
+		[]float64{1000, 2000, 5000, 10000, 15000, 20000, 25000, 30000, 60000})
+)
+
+// EpochOutcome is the outcome of mining in an epoch.
+type EpochOutcome string
+
+const (
+	// OutcomeWon is the outcome of an epoch a block was mined in on time.
+	OutcomeWon = EpochOutcome("won")
+	// OutcomeLate is the outcome of an epoch a block was mined in, but only
+	// after the epoch ended, when other miners have moved on to the next.
+	OutcomeLate = EpochOutcome("late")
+	// OutcomeLost is the outcome of an epoch whose election ticket was above
+	// the miner's threshold.
+	OutcomeLost = EpochOutcome("lost")
+	// OutcomeNoPower is the outcome of an epoch the miner had no power at
+	// the election's power lookback in.
+	OutcomeNoPower = EpochOutcome("no-power")
+	// OutcomeDrandFailed is the outcome of an epoch whose drand entries could
+	// not be fetched.
+	OutcomeDrandFailed = EpochOutcome("drand-failed")
+	// OutcomePoStFailed is the outcome of an epoch whose election was won but
+	// whose winning PoSt could not be generated.
+	OutcomePoStFailed = EpochOutcome("post-failed")
+	// OutcomeFailed is the outcome of an epoch mining failed in for another
+	// reason.
+	OutcomeFailed = EpochOutcome("failed")
+)
+
+// EpochReport is the report of a miner's attempt to mine a block in an epoch.
+type EpochReport struct {
+	Epoch      abi.ChainEpoch
+	Miner      address.Address
+	Base       block.TipSetKey
+	NullBlocks uint64
+
+	Outcome EpochOutcome
+	// Error is the reason mining failed, if it did.
+	Error string
+
+	// TicketValue is the value of the election ticket, which wins the
+	// election when below Threshold.
+	TicketValue big.Int
+	Threshold   big.Int
+	// MinerPower and NetworkPower are the power the election was run with.
+	MinerPower   abi.StoragePower
+	NetworkPower abi.StoragePower
+
+	DrandLatency time.Duration
+	PoStDuration time.Duration
+
+	// Block is the block mined, if one was.
+	Block cid.Cid
+	// Included is set when the block is in the node's chain.
+	Included bool
+	// Final is set once the block is final and Included no longer changes.
+	Final bool
+}
+
+// recordMetrics counts the report's outcome.
+func recordMetrics(ctx context.Context, report EpochReport) {
+	counter := map[EpochOutcome]*metrics.Int64Counter{
+		OutcomeWon:         mEpochWon,
+		OutcomeLate:        mEpochLate,
+		OutcomeLost:        mEpochLost,
+		OutcomeNoPower:     mEpochNoPower,
+		OutcomeDrandFailed: mEpochDrandFailed,
+		OutcomePoStFailed:  mEpochPoStFailed,
+	}[report.Outcome]
+	if counter == nil {
+		counter = mEpochFailed
+	}
+	counter.Inc(ctx, 1)
+}
+
+// History persists the reports of the node's mining attempts for
+// historyEpochs epochs and tracks whether the blocks mined make it into the
+// chain.
+type History struct {
+	lk    sync.Mutex
+	ds    datastore.Datastore
+	chain chain.TipSetProvider
+	// mined are the reports of blocks mined whose inclusion may still
+	// change, until they are final.
+	mined []EpochReport
+}
+
+// NewHistory creates a history persisting reports to the datastore. The
+// inclusion of blocks mined before the node restarted is tracked until they
+// are final.
+func NewHistory(ds datastore.Datastore, chain chain.TipSetProvider) (*History, error) {
+	h := &History{ds: ds, chain: chain}
+	reports, err := h.List(0)
+	if err != nil {
+		return nil, err
+	}
+	// List returns the most recent first, mined is kept in mining order
+	for i := len(reports) - 1; i >= 0; i-- {
+		if reports[i].Block.Defined() && !reports[i].Final {
+			h.mined = append(h.mined, reports[i])
+		}
+	}
+	return h, nil
+}
+
+// Record persists the report, replacing any of the same miner and epoch, and
+// removes the miner's reports older than historyEpochs.
+func (h *History) Record(report EpochReport) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	if err := h.put(report); err != nil {
+		return err
+	}
+	if report.Block.Defined() {
+		h.mined = append(h.mined, report)
+	}
+	return h.prune(report.Miner, report.Epoch-historyEpochs)
+}
+
+// List returns the most recent reports first, at most limit of them, or all
+// if limit is zero.
+func (h *History) List(limit uint) ([]EpochReport, error) {
+	results, err := h.ds.Query(query.Query{Prefix: historyPrefix.String()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query mining history")
+	}
+	defer results.Close() // nolint: errcheck
+
+	var out []EpochReport
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, errors.Wrap(entry.Error, "failed to read mining history")
+		}
+		var record reportRecord
+		if err := encoding.Decode(entry.Value, &record); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode epoch report %s", entry.Key)
+		}
+		report, err := record.report()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode epoch report %s", entry.Key)
+		}
+		out = append(out, report)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Epoch != out[j].Epoch {
+			return out[i].Epoch > out[j].Epoch
+		}
+		return out[i].Miner.String() < out[j].Miner.String()
+	})
+	if limit > 0 && uint(len(out)) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// HandleNewHead updates whether the blocks mined are in the chain of the new
+// head. A block's inclusion stops being tracked once it is final.
+func (h *History) HandleNewHead(ctx context.Context, newHead block.TipSet) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+	height, err := newHead.Height()
+	if err != nil {
+		return err
+	}
+
+	pending := make([]EpochReport, 0, len(h.mined))
+	for i, report := range h.mined {
+		if report.Epoch > height {
+			pending = append(pending, report)
+			continue
+		}
+		ts, err := chain.FindTipsetAtEpoch(ctx, newHead, report.Epoch, h.chain)
+		if err != nil {
+			h.mined = append(pending, h.mined[i:]...)
+			return errors.Wrapf(err, "failed to find tipset at epoch %d", report.Epoch)
+		}
+		included := ts.Key().Has(report.Block)
+		final := report.Epoch+miner.ChainFinalityish <= height
+		if included != report.Included || final {
+			report.Included = included
+			report.Final = final
+			if err := h.put(report); err != nil {
+				h.mined = append(pending, h.mined[i:]...)
+				return err
+			}
+		}
+
+		if !final {
+			pending = append(pending, report)
+		} else if report.Included {
+			mBlockIncluded.Inc(ctx, 1)
+		} else {
+			mBlockOrphaned.Inc(ctx, 1)
+		}
+	}
+	h.mined = pending
+	return nil
+}
+
+func (h *History) put(report EpochReport) error {
+	val, err := encoding.Encode(newReportRecord(report))
+	if err != nil {
+		return err
+	}
+	return h.ds.Put(reportKey(report.Miner, report.Epoch), val)
+}
+
+// prune removes the miner's reports of epochs before the input epoch.
+func (h *History) prune(minerAddr address.Address, before abi.ChainEpoch) error {
+	if before <= 0 {
+		return nil
+	}
+	prefix := historyPrefix.ChildString(minerAddr.String())
+	results, err := h.ds.Query(query.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return errors.Wrap(err, "failed to query mining history")
+	}
+	entries, err := results.Rest()
+	if err != nil {
+		return errors.Wrap(err, "failed to read mining history")
+	}
+	for _, entry := range entries {
+		epoch, err := strconv.ParseInt(datastore.RawKey(entry.Key).BaseNamespace(), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "malformed epoch report key %s", entry.Key)
+		}
+		if abi.ChainEpoch(epoch) < before {
+			if err := h.ds.Delete(datastore.RawKey(entry.Key)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// reportKey is the key of a miner's report of an epoch. Epochs are padded to
+// keep keys in epoch order.
+func reportKey(minerAddr address.Address, epoch abi.ChainEpoch) datastore.Key {
+	return historyPrefix.ChildString(minerAddr.String()).ChildString(fmt.Sprintf("%020d", epoch))
+}
+
+// reportRecord is the persisted form of an EpochReport.
+type reportRecord struct {
+	_            struct{} `cbor:",toarray"`
+	Epoch        abi.ChainEpoch
+	Miner        address.Address
+	Base         block.TipSetKey
+	NullBlocks   uint64
+	Outcome      string
+	Error        string
+	TicketValue  big.Int
+	Threshold    big.Int
+	MinerPower   abi.StoragePower
+	NetworkPower abi.StoragePower
+	DrandLatency int64
+	PoStDuration int64
+	Block        string
+	Included     bool
+	Final        bool
+}
+
+func newReportRecord(report EpochReport) reportRecord {
+	record := reportRecord{
+		Epoch:        report.Epoch,
+		Miner:        report.Miner,
+		Base:         report.Base,
+		NullBlocks:   report.NullBlocks,
+		Outcome:      string(report.Outcome),
+		Error:        report.Error,
+		TicketValue:  zeroIfNil(report.TicketValue),
+		Threshold:    zeroIfNil(report.Threshold),
+		MinerPower:   zeroIfNil(report.MinerPower),
+		NetworkPower: zeroIfNil(report.NetworkPower),
+		DrandLatency: int64(report.DrandLatency),
+		PoStDuration: int64(report.PoStDuration),
+		Included:     report.Included,
+		Final:        report.Final,
+	}
+	if report.Block.Defined() {
+		record.Block = report.Block.String()
+	}
+	return record
+}
+
+func (r reportRecord) report() (EpochReport, error) {
+	report := EpochReport{
+		Epoch:        r.Epoch,
+		Miner:        r.Miner,
+		Base:         r.Base,
+		NullBlocks:   r.NullBlocks,
+		Outcome:      EpochOutcome(r.Outcome),
+		Error:        r.Error,
+		TicketValue:  r.TicketValue,
+		Threshold:    r.Threshold,
+		MinerPower:   r.MinerPower,
+		NetworkPower: r.NetworkPower,
+		DrandLatency: time.Duration(r.DrandLatency),
+		PoStDuration: time.Duration(r.PoStDuration),
+		Included:     r.Included,
+		Final:        r.Final,
+	}
+	if r.Block != "" {
+		var err error
+		if report.Block, err = cid.Decode(r.Block); err != nil {
+			return EpochReport{}, err
+		}
+	}
+	return report, nil
+}
+
+// zeroIfNil returns zero for the values of reports that mining failed before
+// computing.
+func zeroIfNil(i big.Int) big.Int {
+	if i.Int == nil {
+		return big.Zero()
+	}
+	return i
+}
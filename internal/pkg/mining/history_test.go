@@ -0,0 +1,125 @@
+package mining_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
+	"github.com/sbwtw/go-filecoin/internal/pkg/mining"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestHistoryListsRecentReports(t *testing.T) {
+	tf.UnitTest(t)
+	miner1, miner2 := vmaddr.RequireIDAddress(t, 100), vmaddr.RequireIDAddress(t, 101)
+	history, err := mining.NewHistory(datastore.NewMapDatastore(), chain.NewBuilder(t, address.Undef))
+	require.NoError(t, err)
+
+	require.NoError(t, history.Record(mining.EpochReport{Epoch: 10, Miner: miner1, Outcome: mining.OutcomeLost}))
+	require.NoError(t, history.Record(mining.EpochReport{
+		Epoch:        11,
+		Miner:        miner1,
+		Outcome:      mining.OutcomeNoPower,
+		TicketValue:  big.NewInt(7),
+		Threshold:    big.Zero(),
+		MinerPower:   abi.NewStoragePower(0),
+		NetworkPower: abi.NewStoragePower(2048),
+	}))
+	require.NoError(t, history.Record(mining.EpochReport{Epoch: 11, Miner: miner2, Outcome: mining.OutcomeDrandFailed, Error: "timeout"}))
+
+	reports, err := history.List(0)
+	require.NoError(t, err)
+	require.Len(t, reports, 3)
+	assert.Equal(t, abi.ChainEpoch(11), reports[0].Epoch)
+	assert.Equal(t, miner1, reports[0].Miner)
+	assert.Equal(t, mining.OutcomeNoPower, reports[0].Outcome)
+	assert.Equal(t, int64(7), reports[0].TicketValue.Int64())
+	assert.Equal(t, int64(2048), reports[0].NetworkPower.Int64())
+	assert.Equal(t, "timeout", reports[1].Error)
+	assert.Equal(t, abi.ChainEpoch(10), reports[2].Epoch)
+
+	reports, err = history.List(1)
+	require.NoError(t, err)
+	assert.Len(t, reports, 1)
+
+	t.Run("drops reports a day old", func(t *testing.T) {
+		require.NoError(t, history.Record(mining.EpochReport{Epoch: 2891, Miner: miner1, Outcome: mining.OutcomeLost}))
+
+		reports, err := history.List(0)
+		require.NoError(t, err)
+		require.Len(t, reports, 3)
+		assert.Equal(t, abi.ChainEpoch(2891), reports[0].Epoch)
+		assert.Equal(t, miner2, reports[2].Miner)
+	})
+}
+
+func TestHistoryTracksInclusion(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	head := builder.AppendOn(genesis, 2)
+	fork := builder.AppendOn(genesis, 1)
+
+	minerAddr := vmaddr.RequireIDAddress(t, 100)
+	history, err := mining.NewHistory(datastore.NewMapDatastore(), builder)
+	require.NoError(t, err)
+	require.NoError(t, history.Record(mining.EpochReport{Epoch: 1, Miner: minerAddr, Outcome: mining.OutcomeWon, Block: head.At(1).Cid()}))
+	// mined on the head, not yet in the chain
+	require.NoError(t, history.Record(mining.EpochReport{Epoch: 2, Miner: minerAddr, Outcome: mining.OutcomeWon, Block: builder.AppendBlockOn(head).Cid()}))
+
+	included := func() []bool {
+		reports, err := history.List(0)
+		require.NoError(t, err)
+		out := make([]bool, len(reports))
+		for i, report := range reports {
+			out[i] = report.Included
+		}
+		return out
+	}
+
+	require.NoError(t, history.HandleNewHead(ctx, head))
+	assert.Equal(t, []bool{false, true}, included())
+
+	require.NoError(t, history.HandleNewHead(ctx, fork))
+	assert.Equal(t, []bool{false, false}, included())
+}
+
+func TestHistoryReloadsUnfinalisedBlocks(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	head := builder.AppendOn(genesis, 1)
+	fork := builder.AppendOn(genesis, 1)
+
+	miner1, miner2 := vmaddr.RequireIDAddress(t, 100), vmaddr.RequireIDAddress(t, 101)
+	ds := datastore.NewMapDatastore()
+	history, err := mining.NewHistory(ds, builder)
+	require.NoError(t, err)
+	require.NoError(t, history.Record(mining.EpochReport{Epoch: 1, Miner: miner1, Outcome: mining.OutcomeWon, Block: head.At(0).Cid()}))
+	require.NoError(t, history.Record(mining.EpochReport{Epoch: 1, Miner: miner2, Outcome: mining.OutcomeWon, Block: fork.At(0).Cid(), Included: true, Final: true}))
+
+	// the node restarts
+	restarted, err := mining.NewHistory(ds, builder)
+	require.NoError(t, err)
+	require.NoError(t, restarted.HandleNewHead(ctx, head))
+
+	reports, err := restarted.List(0)
+	require.NoError(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, miner1, reports[0].Miner)
+	assert.True(t, reports[0].Included)
+	assert.False(t, reports[0].Final)
+	// a final block's inclusion is no longer tracked
+	assert.Equal(t, miner2, reports[1].Miner)
+	assert.True(t, reports[1].Included)
+}
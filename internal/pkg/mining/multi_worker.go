@@ -0,0 +1,41 @@
+package mining
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+)
+
+// MultiWorker mines for several miner actors, running an election for each of
+// them on every base it is given.
+type MultiWorker struct {
+	workers []Worker
+}
+
+// NewMultiWorker creates a worker mining with each of the workers.
+func NewMultiWorker(workers ...Worker) *MultiWorker {
+	return &MultiWorker{workers: workers}
+}
+
+// Mine runs the election of each worker on the base concurrently, so that a
+// slow winner does not hold up the others. Each winning worker sends its block
+// to outCh. The returned bool indicates if any worker created a new block.
+func (m *MultiWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCount uint64, outCh chan<- Output) bool {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won := false
+	for _, w := range m.workers {
+		wg.Add(1)
+		go func(w Worker) {
+			defer wg.Done()
+			if w.Mine(ctx, base, nullBlkCount, outCh) {
+				mu.Lock()
+				won = true
+				mu.Unlock()
+			}
+		}(w)
+	}
+	wg.Wait()
+	return won
+}
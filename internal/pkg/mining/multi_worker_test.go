@@ -0,0 +1,36 @@
+package mining_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	. "github.com/sbwtw/go-filecoin/internal/pkg/mining"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestMultiWorkerRunsEachElection(t *testing.T) {
+	tf.UnitTest(t)
+	ts := testHead(t)
+
+	worker := func(wins bool) *TestWorker {
+		return NewTestWorker(t, func(_ context.Context, base block.TipSet, nullBlkCount uint64, outCh chan<- Output) bool {
+			assert.True(t, base.Equals(ts))
+			assert.Equal(t, uint64(2), nullBlkCount)
+			if wins {
+				outCh <- Output{Header: &block.Block{}}
+			}
+			return wins
+		})
+	}
+
+	outCh := make(chan Output, 3)
+	won := NewMultiWorker(worker(true), worker(false), worker(true)).Mine(context.Background(), ts, 2, outCh)
+	assert.True(t, won)
+	assert.Len(t, outCh, 2)
+
+	won = NewMultiWorker(worker(false), worker(false)).Mine(context.Background(), ts, 2, outCh)
+	assert.False(t, won)
+}
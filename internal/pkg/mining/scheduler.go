@@ -8,13 +8,23 @@ package mining
 import (
 	"context"
 	"sync"
+	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/journal"
+	"github.com/sbwtw/go-filecoin/internal/pkg/metrics"
 )
 
+// maxPropagationShare is the inverse of the largest share of an epoch spent
+// waiting for blocks to propagate, leaving the rest of the epoch to mine.
+const maxPropagationShare = 4
+
+var mBaseWidened = metrics.NewInt64Counter("mining/base_widened_blocks", "Number of blocks added to the head to form the tipset mined on")
+
 // Scheduler is the mining interface consumers use.
 type Scheduler interface {
 	Start(miningCtx context.Context) (<-chan Output, *sync.WaitGroup)
@@ -29,8 +39,19 @@ type timingScheduler struct {
 	// pollHeadFunc is the function the scheduler uses to poll for the
 	// current heaviest tipset
 	pollHeadFunc func() (block.TipSet, error)
+	// siblingsFunc returns the validated tipsets with the input parents and
+	// height, used to widen the head with blocks that arrived late
+	siblingsFunc func(parents block.TipSetKey, h abi.ChainEpoch) ([]block.TipSet, error)
+	// propagationDelay returns how long into an epoch the scheduler waits
+	// for blocks before selecting the tipset to mine on
+	propagationDelay func() time.Duration
 	// chainClock measures time and tracks the epoch-time relationship
 	chainClock clock.ChainEpochClock
+	// journal records the tipset selected to mine on each epoch
+	journal journal.Writer
+	// clampedDelay is the last propagation delay warned about being too
+	// long for the epoch, so the warning is not repeated every epoch
+	clampedDelay time.Duration
 
 	// mu protects skipping
 	mu sync.Mutex
@@ -61,8 +82,9 @@ func (s *timingScheduler) Start(miningCtx context.Context) (<-chan Output, *sync
 
 func (s *timingScheduler) mineLoop(miningCtx context.Context, outCh chan Output, doneWg *sync.WaitGroup) {
 	// mineLoop is the main event loop for the timing scheduler.  It waits for
-	// a new epoch to start, polls the heaviest head, includes the correct number
-	// of null blocks and starts a mining job async.
+	// a new epoch to start and for blocks of the last epoch to propagate, polls
+	// the heaviest head, widens it with blocks received with the same parents,
+	// includes the correct number of null blocks and starts a mining job async.
 	//
 	// If the previous epoch's mining job is not finished it is canceled via the context
 	//
@@ -83,11 +105,17 @@ func (s *timingScheduler) mineLoop(miningCtx context.Context, outCh chan Output,
 			continue
 		}
 
+		if !s.waitPropagation(miningCtx, currEpoch) {
+			s.isStarted = false
+			return // nolint:govet
+		}
+
 		workContext, workCancel = context.WithCancel(miningCtx) // nolint: govet
-		base, err := s.pollHeadFunc()
+		head, err := s.pollHeadFunc()
 		if err != nil {
 			log.Errorf("error polling head from mining scheduler %s", err)
 		}
+		base := s.widen(miningCtx, head, currEpoch)
 		h, err := base.Height()
 		if err != nil {
 			log.Errorf("error getting height from base", err)
@@ -104,6 +132,92 @@ func (s *timingScheduler) mineLoop(miningCtx context.Context, outCh chan Output,
 	}
 }
 
+// waitPropagation waits until the propagation delay into the epoch has passed,
+// for blocks of the last epoch sent late to arrive. The delay is clamped to a
+// share of the epoch so there is time left to mine. It returns false if the
+// context is done first.
+func (s *timingScheduler) waitPropagation(ctx context.Context, epoch abi.ChainEpoch) bool {
+	wait := s.chainClock.StartTimeOfEpoch(epoch).Add(s.clampPropagationDelay()).Sub(s.chainClock.Now())
+	if wait <= 0 {
+		return true
+	}
+	select {
+	case <-s.chainClock.After(wait):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// clampPropagationDelay returns the propagation delay, at most a share of the
+// epoch duration. It warns once for each delay clamped.
+func (s *timingScheduler) clampPropagationDelay() time.Duration {
+	delay := s.propagationDelay()
+	limit := s.chainClock.EpochDuration() / maxPropagationShare
+	if delay <= limit {
+		return delay
+	}
+	if delay != s.clampedDelay {
+		log.Warnf("propagation delay %s is too long for epochs of %s, waiting %s", delay, s.chainClock.EpochDuration(), limit)
+		s.clampedDelay = delay
+	}
+	return limit
+}
+
+// widen returns the largest validated tipset holding all the blocks of the
+// head with the same parents, so that blocks of the head's epoch validated
+// after the head was set are mined on. It records the tipset selected and the
+// blocks it adds to the head.
+func (s *timingScheduler) widen(ctx context.Context, head block.TipSet, epoch abi.ChainEpoch) block.TipSet {
+	if !head.Defined() {
+		return head
+	}
+	parents, err := head.Parents()
+	if err != nil {
+		log.Errorf("error getting parents of mining base %s", err)
+		return head
+	}
+	h, err := head.Height()
+	if err != nil {
+		log.Errorf("error getting height of mining base %s", err)
+		return head
+	}
+	siblings, err := s.siblingsFunc(parents, h)
+	if err != nil {
+		log.Warnf("failed to find tipsets to widen mining base %s: %s", head.Key(), err)
+		return head
+	}
+
+	base := head
+	for _, ts := range siblings {
+		if ts.Len() > base.Len() && holdsAll(ts, head) {
+			base = ts
+		}
+	}
+	var added []string
+	for i := 0; i < base.Len(); i++ {
+		if c := base.At(i).Cid(); !head.Key().Has(c) {
+			added = append(added, c.String())
+		}
+	}
+	if len(added) > 0 {
+		log.Infof("widened mining base %s with %d blocks received late", head.Key(), len(added))
+		mBaseWidened.Inc(ctx, int64(len(added)))
+	}
+	s.journal.Write("SelectBase", "epoch", epoch, "head", head.Key().String(), "base", base.Key().String(), "added", added)
+	return base
+}
+
+// holdsAll returns whether ts holds all the blocks of other.
+func holdsAll(ts, other block.TipSet) bool {
+	for i := 0; i < other.Len(); i++ {
+		if !ts.Key().Has(other.At(i).Cid()) {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *timingScheduler) isSkipping() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -131,12 +245,17 @@ func (s *timingScheduler) Continue() {
 }
 
 // NewScheduler returns a new timingScheduler to schedule mining work on the
-// input worker.
-func NewScheduler(w Worker, f func() (block.TipSet, error), c clock.ChainEpochClock) Scheduler {
+// input worker. Each epoch it waits for the propagation delay, then mines on
+// the head widened with the sibling tipsets holding it.
+func NewScheduler(w Worker, f func() (block.TipSet, error), siblings func(block.TipSetKey, abi.ChainEpoch) ([]block.TipSet, error),
+	delay func() time.Duration, c clock.ChainEpochClock, jw journal.Writer) Scheduler {
 	return &timingScheduler{
-		worker:       w,
-		pollHeadFunc: f,
-		chainClock:   c,
+		worker:           w,
+		pollHeadFunc:     f,
+		siblingsFunc:     siblings,
+		propagationDelay: delay,
+		chainClock:       c,
+		journal:          jw,
 	}
 }
 
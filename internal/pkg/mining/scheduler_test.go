@@ -6,12 +6,15 @@ import (
 	"testing"
 	"time"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
 	e "github.com/sbwtw/go-filecoin/internal/pkg/enccid"
+	"github.com/sbwtw/go-filecoin/internal/pkg/journal"
 	. "github.com/sbwtw/go-filecoin/internal/pkg/mining"
 	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
@@ -37,7 +40,7 @@ func TestWorkerCalled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, noDelay, chainClock, noJournal)
 	scheduler.Start(ctx)
 	fakeClock.BlockUntil(1)
 	fakeClock.Advance(blockTime)
@@ -69,7 +72,7 @@ func TestCorrectNullBlocksGivenEpoch(t *testing.T) {
 		return true
 	})
 
-	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, noDelay, chainClock, noJournal)
 	scheduler.Start(ctx)
 	fakeClock.BlockUntil(1)
 	// Move forward 1 epoch for a total of 21
@@ -104,7 +107,7 @@ func TestWaitsForEpochStart(t *testing.T) {
 		return true
 	})
 
-	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, noDelay, chainClock, noJournal)
 	scheduler.Start(ctx)
 	fakeClock.BlockUntil(1)
 	fakeClock.Advance(blockTime / time.Duration(2)) // advance half a blocktime
@@ -144,7 +147,7 @@ func TestCancelsLateWork(t *testing.T) {
 		}
 	})
 
-	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, noDelay, chainClock, noJournal)
 	scheduler.Start(ctx)
 	fakeClock.BlockUntil(1)
 	fakeClock.Advance(blockTime) // schedule first work item
@@ -177,7 +180,7 @@ func TestShutdownWaitgroup(t *testing.T) {
 		}
 	})
 
-	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, noDelay, chainClock, noJournal)
 	_, wg := scheduler.Start(ctx)
 	time.Sleep(600 * time.Millisecond) // run through some epochs
 	cancel()
@@ -212,7 +215,7 @@ func TestSkips(t *testing.T) {
 		return true
 	})
 
-	scheduler := NewScheduler(w, headFunc(ts), chainClock)
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, noDelay, chainClock, noJournal)
 	scheduler.Pause()
 	scheduler.Start(ctx)
 	fakeClock.BlockUntil(1)
@@ -223,8 +226,119 @@ func TestSkips(t *testing.T) {
 	wg.Wait()
 }
 
+func TestWaitsForPropagationAndWidensBase(t *testing.T) {
+	tf.UnitTest(t)
+	stateRoot := e.NewCid(types.CidFromString(t, "somecid"))
+	early := &block.Block{StateRoot: stateRoot, Ticket: NthTicket(1)}
+	late := &block.Block{StateRoot: stateRoot, Ticket: NthTicket(2)}
+	other := &block.Block{StateRoot: stateRoot, Ticket: NthTicket(3)}
+	head := block.RequireNewTipSet(t, early)
+	widened := block.RequireNewTipSet(t, early, late)
+	siblings := func(block.TipSetKey, abi.ChainEpoch) ([]block.TipSet, error) {
+		// only tipsets holding the head widen it
+		return []block.TipSet{block.RequireNewTipSet(t, late, other), widened}, nil
+	}
+
+	fakeClock, chainClock, blockTime := testClock(t)
+	delay := 300 * time.Millisecond
+	var minedAt time.Time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	w := NewTestWorker(t, func(_ context.Context, workHead block.TipSet, _ uint64, _ chan<- Output) bool {
+		minedAt = fakeClock.Now()
+		assert.True(t, workHead.Equals(widened))
+		wg.Done()
+		return true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	jrl := journal.NewInMemoryJournal(t, fakeClock)
+	scheduler := NewScheduler(w, headFunc(head), siblings, func() time.Duration { return delay }, chainClock, jrl.Topic("mining"))
+	scheduler.Start(ctx)
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(blockTime)
+	epochStart := fakeClock.Now()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(delay)
+
+	wg.Wait()
+	assert.Equal(t, epochStart.Add(delay), minedAt)
+}
+
+func TestClampsPropagationDelay(t *testing.T) {
+	tf.UnitTest(t)
+	ts := testHead(t)
+
+	fakeClock, chainClock, blockTime := testClock(t)
+	var minedAt time.Time
+	var wg sync.WaitGroup
+	wg.Add(1)
+	w := NewTestWorker(t, func(_ context.Context, _ block.TipSet, _ uint64, _ chan<- Output) bool {
+		minedAt = fakeClock.Now()
+		wg.Done()
+		return true
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// a delay longer than the epoch waits a quarter of it
+	scheduler := NewScheduler(w, headFunc(ts), noSiblings, func() time.Duration { return 2 * blockTime }, chainClock, noJournal)
+	scheduler.Start(ctx)
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(blockTime)
+	epochStart := fakeClock.Now()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(blockTime / 4)
+
+	wg.Wait()
+	assert.Equal(t, epochStart.Add(blockTime/4), minedAt)
+}
+
+func TestMinesOnHeadWhenNotWidened(t *testing.T) {
+	tf.UnitTest(t)
+	stateRoot := e.NewCid(types.CidFromString(t, "somecid"))
+	head := block.RequireNewTipSet(t, &block.Block{StateRoot: stateRoot, Ticket: NthTicket(1)})
+
+	for name, siblings := range map[string]func(block.TipSetKey, abi.ChainEpoch) ([]block.TipSet, error){
+		"no siblings": noSiblings,
+		"sibling error": func(block.TipSetKey, abi.ChainEpoch) ([]block.TipSet, error) {
+			return nil, errors.New("boom")
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fakeClock, chainClock, blockTime := testClock(t)
+			var wg sync.WaitGroup
+			wg.Add(1)
+			w := NewTestWorker(t, func(_ context.Context, workHead block.TipSet, _ uint64, _ chan<- Output) bool {
+				assert.True(t, workHead.Equals(head))
+				wg.Done()
+				return true
+			})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			scheduler := NewScheduler(w, headFunc(head), siblings, noDelay, chainClock, noJournal)
+			scheduler.Start(ctx)
+			fakeClock.BlockUntil(1)
+			fakeClock.Advance(blockTime)
+			wg.Wait()
+		})
+	}
+}
+
 // Helper functions
 
+var noJournal = journal.NewNoopJournal().Topic("mining")
+
+func noSiblings(block.TipSetKey, abi.ChainEpoch) ([]block.TipSet, error) {
+	return nil, nil
+}
+
+func noDelay() time.Duration {
+	return 0
+}
+
 func testHead(t *testing.T) block.TipSet {
 	baseBlock := &block.Block{StateRoot: e.NewCid(types.CidFromString(t, "somecid"))}
 	ts, err := block.NewTipSet(baseBlock)
@@ -126,6 +126,7 @@ type DefaultWorker struct {
 	poster         postgenerator.PoStGenerator
 	chainState     chain.TipSetProvider
 	drand          drand.IFace
+	history        *History
 }
 
 // WorkerParameters use for NewDefaultWorker parameters
@@ -152,6 +153,8 @@ type WorkerParameters struct {
 	Clock         clock.ChainEpochClock
 	Poster        postgenerator.PoStGenerator
 	ChainState    chain.TipSetProvider
+	// History records a report of each epoch mined in, if set.
+	History *History
 }
 
 // NewDefaultWorker instantiates a new Worker.
@@ -174,6 +177,7 @@ func NewDefaultWorker(parameters WorkerParameters) *DefaultWorker {
 		poster:         parameters.Poster,
 		chainState:     parameters.ChainState,
 		drand:          parameters.Drand,
+		history:        parameters.History,
 	}
 }
 
@@ -194,21 +198,35 @@ func (w *DefaultWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCoun
 	}
 	currEpoch := baseEpoch + abi.ChainEpoch(1) + abi.ChainEpoch(nullBlkCount)
 
+	report := EpochReport{
+		Epoch:      currEpoch,
+		Miner:      w.minerAddr,
+		Base:       base.Key(),
+		NullBlocks: nullBlkCount,
+		Outcome:    OutcomeFailed,
+	}
+	defer func() { w.report(ctx, report) }()
+	fail := func(err error) {
+		report.Error = err.Error()
+		outCh <- NewOutputErr(err)
+	}
+
 	log.Debugf("Mining on tipset %s, at epoch %d with %d null blocks.", base.String(), baseEpoch, nullBlkCount)
 	if ctx.Err() != nil {
 		log.Warnf("Worker.Mine returning with ctx error %s", ctx.Err().Error())
+		report.Error = ctx.Err().Error()
 		return
 	}
 
 	// Read uncached worker address
 	keyView, err := w.api.PowerStateView(base.Key())
 	if err != nil {
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 	_, workerAddr, err := keyView.MinerControlAddresses(ctx, w.minerAddr)
 	if err != nil {
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 
@@ -220,22 +238,27 @@ func (w *DefaultWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCoun
 
 	workerSignerAddr, err := keyView.AccountSignerAddress(ctx, workerAddr)
 	if err != nil {
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 
+	drandTimer := mDrandLatency.Start(ctx)
 	drandEntries, err := w.drandEntriesForEpoch(ctx, base, nullBlkCount)
 	if err != nil {
 		log.Errorf("Worker.Mine failed to collect drand entries for block %s", err)
-		outCh <- NewOutputErr(err)
+		report.DrandLatency = drandTimer.Stop(ctx)
+		report.Outcome = OutcomeDrandFailed
+		fail(err)
 		return
 	}
 
 	// Determine if we've won election
 	electionEntry, err := w.electionEntry(ctx, base, drandEntries)
+	report.DrandLatency = drandTimer.Stop(ctx)
 	if err != nil {
 		log.Errorf("Worker.Mine failed to calculate drand entry for election randomness %s", err)
-		outCh <- NewOutputErr(err)
+		report.Outcome = OutcomeDrandFailed
+		fail(err)
 		return
 	}
 
@@ -243,7 +266,7 @@ func (w *DefaultWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCoun
 	nextTicket, err := w.ticketGen.MakeTicket(ctx, base.Key(), lookbackEpoch, w.minerAddr, electionEntry, newPeriod, workerSignerAddr, w.workerSigner)
 	if err != nil {
 		log.Warnf("Worker.Mine couldn't generate next ticket %s", err)
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 
@@ -255,30 +278,38 @@ func (w *DefaultWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCoun
 	electionPowerAncestor, err := w.lookbackTipset(ctx, base, nullBlkCount, consensus.ElectionPowerTableLookback)
 	if err != nil {
 		log.Errorf("Worker.Mine couldn't get ancestor tipset: %s", err.Error())
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 	electionPowerTable, err := w.getPowerTable(electionPowerAncestor.Key(), base.Key())
 	if err != nil {
 		log.Errorf("Worker.Mine couldn't get snapshot for tipset: %s", err.Error())
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 	networkPower, err := electionPowerTable.NetworkTotalPower(ctx)
 	if err != nil {
 		log.Errorf("failed to get network power: %s", err)
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 	minerPower, err := electionPowerTable.MinerClaimedPower(ctx, w.minerAddr)
 	if err != nil {
 		log.Errorf("failed to get power claim for miner: %s", err)
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
+	report.MinerPower = minerPower
+	report.NetworkPower = networkPower
+	report.TicketValue = big.PositiveFromUnsignedBytes(electionVRFDigest[:])
+	report.Threshold = consensus.ElectionThreshold(minerPower, networkPower)
 	wins := w.election.IsWinner(electionVRFDigest[:], minerPower, networkPower)
 	if !wins {
 		// no winners we are done
+		report.Outcome = OutcomeLost
+		if !minerPower.GreaterThan(big.Zero()) {
+			report.Outcome = OutcomeNoPower
+		}
 		won = false
 		return
 	}
@@ -287,38 +318,61 @@ func (w *DefaultWorker) Mine(ctx context.Context, base block.TipSet, nullBlkCoun
 	sectorSetAncestor, err := w.lookbackTipset(ctx, base, nullBlkCount, consensus.WinningPoStSectorSetLookback)
 	if err != nil {
 		log.Errorf("Worker.Mine couldn't get ancestor tipset: %s", err.Error())
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 	winningPoStSectorSetView, err := w.getPowerTable(sectorSetAncestor.Key(), base.Key())
 	if err != nil {
 		log.Errorf("Worker.Mine couldn't get snapshot for tipset: %s", err.Error())
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 	sortedSectorInfos, err := winningPoStSectorSetView.SortedSectorInfos(ctx, w.minerAddr)
 	if err != nil {
 		log.Warnf("Worker.Mine failed to get ssi for %s", w.minerAddr)
-		outCh <- NewOutputErr(err)
+		fail(err)
 		return
 	}
 
+	postTimer := mWinningPoStDuration.Start(ctx)
 	posts, err := w.election.GenerateWinningPoSt(ctx, sortedSectorInfos, electionEntry, currEpoch, w.poster, w.minerAddr)
+	report.PoStDuration = postTimer.Stop(ctx)
 	if err != nil {
 		log.Warnf("Worker.Mine failed to generate post")
-		outCh <- NewOutputErr(err)
+		report.Outcome = OutcomePoStFailed
+		fail(err)
 		return
 	}
 
 	next := w.Generate(ctx, base, nextTicket, electionVRFProof, abi.ChainEpoch(nullBlkCount), posts, drandEntries)
 	if next.Err == nil {
 		log.Debugf("Worker.Mine generates new winning block! %s", next.Header.Cid().String())
+		report.Block = next.Header.Cid()
+		report.Outcome = OutcomeWon
+		// other miners start on the next epoch without a block finished after
+		// this one ends
+		if w.clock.Now().After(w.clock.StartTimeOfEpoch(currEpoch + 1)) {
+			report.Outcome = OutcomeLate
+		}
+	} else {
+		report.Error = next.Err.Error()
 	}
 	outCh <- next
 	won = true
 	return
 }
 
+// report records the report of an epoch mined in.
+func (w *DefaultWorker) report(ctx context.Context, report EpochReport) {
+	recordMetrics(ctx, report)
+	if w.history == nil {
+		return
+	}
+	if err := w.history.Record(report); err != nil {
+		log.Warnf("failed to record mining report of epoch %d: %s", report.Epoch, err)
+	}
+}
+
 func (w *DefaultWorker) getPowerTable(powerKey, faultsKey block.TipSetKey) (consensus.PowerTableView, error) {
 	powerView, err := w.api.PowerStateView(powerKey)
 	if err != nil {
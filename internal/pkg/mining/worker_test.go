@@ -27,16 +27,21 @@ import (
 	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
 	"github.com/sbwtw/go-filecoin/internal/pkg/config"
 	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
+	"github.com/sbwtw/go-filecoin/internal/pkg/crypto"
+	"github.com/sbwtw/go-filecoin/internal/pkg/drand"
 	e "github.com/sbwtw/go-filecoin/internal/pkg/enccid"
 	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
 	"github.com/sbwtw/go-filecoin/internal/pkg/message"
 	"github.com/sbwtw/go-filecoin/internal/pkg/mining"
+	"github.com/sbwtw/go-filecoin/internal/pkg/postgenerator"
 	"github.com/sbwtw/go-filecoin/internal/pkg/repo"
+	appstate "github.com/sbwtw/go-filecoin/internal/pkg/state"
 	th "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers"
 	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
 	"github.com/sbwtw/go-filecoin/internal/pkg/types"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
 	"github.com/sbwtw/go-filecoin/internal/pkg/vm/state"
 )
@@ -215,6 +220,131 @@ func Test_Mine(t *testing.T) {
 	})
 }
 
+func TestMineRecordsEpochReport(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	mockSigner, workerAddr := setupSigner()
+	minerAddr := vmaddr.RequireIDAddress(t, 100)
+	builder := chain.NewBuilder(t, address.Undef)
+	genesis := builder.NewGenesis()
+	genesisTime := time.Unix(1234567890, 0)
+	blockTime := 30 * time.Second
+
+	digest := crypto.VRFPi(consensus.MakeFakeVRFProofForTest()).Digest()
+	ticketValue := fbig.PositiveFromUnsignedBytes(digest[:])
+	networkPower := abi.NewStoragePower(4096)
+
+	mine := func(t *testing.T, minerPower int64, election *fakeElection, drnd drand.IFace, now time.Time) (mining.EpochReport, mining.Output) {
+		view := &appstate.FakeStateView{
+			Power: &appstate.NetworkPower{RawBytePower: networkPower, QualityAdjustedPower: networkPower},
+			Miners: map[address.Address]*appstate.FakeMinerState{minerAddr: {
+				Owner:           workerAddr,
+				Worker:          workerAddr,
+				ClaimedRawPower: abi.NewStoragePower(minerPower),
+				ClaimedQAPower:  abi.NewStoragePower(minerPower),
+			}},
+		}
+		history, err := mining.NewHistory(datastore.NewMapDatastore(), builder)
+		require.NoError(t, err)
+		_, chainClock := clock.NewFakeChain(uint64(genesisTime.Unix()), blockTime, now.Unix())
+		bs := blockstore.NewBlockstore(datastore.NewMapDatastore())
+		worker := mining.NewDefaultWorker(mining.WorkerParameters{
+			API: &fakeViewAPI{ChainRandomness: &consensus.FakeChainRandomness{Seed: 0}, view: view},
+
+			MinerAddr:      minerAddr,
+			MinerOwnerAddr: workerAddr,
+			WorkerSigner:   mockSigner,
+
+			TipSetMetadata: fakeTSMetadata{},
+			GetWeight:      getWeightTest,
+			Election:       election,
+			TicketGen:      &consensus.FakeTicketMachine{},
+			Drand:          drnd,
+
+			MessageSource:    message.NewPool(config.NewDefaultConfig().Mpool, th.NewMockMessagePoolValidator()),
+			MessageQualifier: &mining.NoMessageQualifier{},
+			Blockstore:       bs,
+			MessageStore:     chain.NewMessageStore(bs),
+			Clock:            chainClock,
+			ChainState:       builder,
+			History:          history,
+		})
+
+		outCh := make(chan mining.Output, 1)
+		worker.Mine(ctx, genesis, 0, outCh)
+		var out mining.Output
+		select {
+		case out = <-outCh:
+		default:
+		}
+		reports, err := history.List(0)
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		assert.Equal(t, abi.ChainEpoch(1), reports[0].Epoch)
+		assert.Equal(t, minerAddr, reports[0].Miner)
+		assert.Equal(t, genesis.Key(), reports[0].Base)
+		return reports[0], out
+	}
+	assertSnapshot := func(t *testing.T, report mining.EpochReport, minerPower int64) {
+		assert.Equal(t, minerPower, report.MinerPower.Int64())
+		assert.Equal(t, networkPower, report.NetworkPower)
+		assert.Equal(t, ticketValue, report.TicketValue)
+		assert.Equal(t, consensus.ElectionThreshold(abi.NewStoragePower(minerPower), networkPower), report.Threshold)
+	}
+	fake := drand.NewFake(genesisTime)
+
+	t.Run("lost", func(t *testing.T) {
+		report, out := mine(t, 1024, &fakeElection{}, fake, genesisTime)
+		assert.Equal(t, mining.OutcomeLost, report.Outcome)
+		assertSnapshot(t, report, 1024)
+		assert.False(t, report.Block.Defined())
+		assert.Nil(t, out.Header)
+	})
+
+	t.Run("no power", func(t *testing.T) {
+		report, _ := mine(t, 0, &fakeElection{}, fake, genesisTime)
+		assert.Equal(t, mining.OutcomeNoPower, report.Outcome)
+		assertSnapshot(t, report, 0)
+	})
+
+	t.Run("drand failed", func(t *testing.T) {
+		report, out := mine(t, 1024, &fakeElection{wins: true}, failingDrand{fake}, genesisTime)
+		assert.Equal(t, mining.OutcomeDrandFailed, report.Outcome)
+		assert.Equal(t, "drand timeout", report.Error)
+		assert.Error(t, out.Err)
+		// the election was not run
+		assert.Equal(t, int64(0), report.TicketValue.Int64())
+		assert.Equal(t, int64(0), report.NetworkPower.Int64())
+	})
+
+	t.Run("winning PoSt failed", func(t *testing.T) {
+		report, out := mine(t, 1024, &fakeElection{wins: true, postErr: errors.New("no sectors")}, fake, genesisTime)
+		assert.Equal(t, mining.OutcomePoStFailed, report.Outcome)
+		assert.Equal(t, "no sectors", report.Error)
+		assert.Error(t, out.Err)
+		assertSnapshot(t, report, 1024)
+		assert.False(t, report.Block.Defined())
+	})
+
+	t.Run("won", func(t *testing.T) {
+		report, out := mine(t, 1024, &fakeElection{wins: true}, fake, genesisTime)
+		require.NoError(t, out.Err)
+		assert.Equal(t, mining.OutcomeWon, report.Outcome)
+		assert.Empty(t, report.Error)
+		assertSnapshot(t, report, 1024)
+		assert.Equal(t, out.Header.Cid(), report.Block)
+	})
+
+	t.Run("late", func(t *testing.T) {
+		// the block of epoch 1 is finished after epoch 2 starts
+		report, out := mine(t, 1024, &fakeElection{wins: true}, fake, genesisTime.Add(2*blockTime+time.Second))
+		require.NoError(t, out.Err)
+		assert.Equal(t, mining.OutcomeLate, report.Outcome)
+		assertSnapshot(t, report, 1024)
+		assert.Equal(t, out.Header.Cid(), report.Block)
+	})
+}
+
 func sharedSetupInitial() (cbor.IpldStore, *message.Pool, cid.Cid) {
 	r := repo.NewInMemoryRepo()
 	bs := blockstore.NewBlockstore(r.Datastore())
@@ -797,3 +927,48 @@ func (tm fakeTSMetadata) GetTipSetStateRoot(key block.TipSetKey) (cid.Cid, error
 func (tm fakeTSMetadata) GetTipSetReceiptsRoot(key block.TipSetKey) (cid.Cid, error) {
 	return dag.NewRawNode([]byte("receipt root")).Cid(), nil
 }
+
+// fakeViewAPI serves a fake state view for every tipset.
+type fakeViewAPI struct {
+	consensus.ChainRandomness
+	view *appstate.FakeStateView
+}
+
+func (a *fakeViewAPI) BlockTime() time.Duration {
+	return th.BlockTimeTest
+}
+
+func (a *fakeViewAPI) PowerStateView(_ block.TipSetKey) (consensus.PowerStateView, error) {
+	return a.view, nil
+}
+
+func (a *fakeViewAPI) FaultsStateView(_ block.TipSetKey) (consensus.FaultStateView, error) {
+	return a.view, nil
+}
+
+// fakeElection runs fake elections, won or lost as set.
+type fakeElection struct {
+	consensus.FakeElectionMachine
+	wins    bool
+	postErr error
+}
+
+func (e *fakeElection) IsWinner(_ []byte, _, _ abi.StoragePower) bool {
+	return e.wins
+}
+
+func (e *fakeElection) GenerateWinningPoSt(ctx context.Context, allSectorInfos []abi.SectorInfo, entry *drand.Entry, epoch abi.ChainEpoch, ep postgenerator.PoStGenerator, maddr address.Address) ([]block.PoStProof, error) {
+	if e.postErr != nil {
+		return nil, e.postErr
+	}
+	return e.FakeElectionMachine.GenerateWinningPoSt(ctx, allSectorInfos, entry, epoch, ep, maddr)
+}
+
+// failingDrand fails to read drand entries.
+type failingDrand struct {
+	*drand.Fake
+}
+
+func (d failingDrand) ReadEntry(_ context.Context, _ drand.Round) (*drand.Entry, error) {
+	return nil, errors.New("drand timeout")
+}
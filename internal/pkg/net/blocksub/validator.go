@@ -2,12 +2,18 @@ package blocksub
 
 import (
 	"context"
+	"time"
 
+	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-pubsub"
+	"github.com/pkg/errors"
 
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/chain"
 	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
+	e "github.com/sbwtw/go-filecoin/internal/pkg/enccid"
 	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
 	"github.com/sbwtw/go-filecoin/internal/pkg/metrics"
 )
@@ -15,6 +21,20 @@ import (
 var blockTopicLogger = log.Logger("net/block_validator")
 var mDecodeBlkFail = metrics.NewInt64Counter("net/pubsub_block_decode_failure", "Number of blocks that fail to decode seen on block pubsub channel")
 var mInvalidBlk = metrics.NewInt64Counter("net/pubsub_invalid_block", "Number of blocks that fail syntax validation seen on block pubsub channel")
+var mInvalidBlkMsgs = metrics.NewInt64Counter("net/pubsub_invalid_block_messages", "Number of blocks whose message cids do not match their header seen on block pubsub channel")
+var mInvalidBlkHeader = metrics.NewInt64Counter("net/pubsub_invalid_block_header", "Number of blocks that fail signature, election or ticket validation seen on block pubsub channel")
+var mBlkHeaderUnvalidated = metrics.NewInt64Counter("net/pubsub_block_header_unvalidated", "Number of blocks relayed on block pubsub channel without validating their header in budget or against a known parent")
+
+// HeaderValidator validates the signature, election and ticket of a block
+// header against the state of its parent.
+type HeaderValidator interface {
+	ValidateHeader(ctx context.Context, blk *block.Block) error
+}
+
+// PeerScorer is told of peers relaying invalid blocks.
+type PeerScorer interface {
+	Penalize(p peer.ID)
+}
 
 // BlockTopicValidator may be registered on go-libp2p-pubsub to validate blocksub messages.
 type BlockTopicValidator struct {
@@ -22,8 +42,19 @@ type BlockTopicValidator struct {
 	opts      []pubsub.ValidatorOpt
 }
 
-// NewBlockTopicValidator retruns a BlockTopicValidator using `bv` for message validation
-func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, opts ...pubsub.ValidatorOpt) *BlockTopicValidator {
+// NewBlockTopicValidator retruns a BlockTopicValidator using `bv` for syntax
+// validation and `hv` for header validation, spending at most `budget` on the
+// latter: header validation is given a context with that deadline. Peers
+// relaying blocks that break a consensus rule are penalized with `scorer`.
+// Blocks whose header cannot be validated in budget, or whose parent state is
+// not known yet, are relayed; the syncer validates them fully.
+func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, hv HeaderValidator, scorer PeerScorer, budget time.Duration, opts ...pubsub.ValidatorOpt) *BlockTopicValidator {
+	reject := func(p peer.ID) bool {
+		if scorer != nil {
+			scorer.Penalize(p)
+		}
+		return false
+	}
 	return &BlockTopicValidator{
 		opts: opts,
 		validator: func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
@@ -32,23 +63,69 @@ func NewBlockTopicValidator(bv consensus.BlockSyntaxValidator, opts ...pubsub.Va
 			if err != nil {
 				blockTopicLogger.Debugf("failed to decode blocksub payload from peer %s: %s", p.String(), err.Error())
 				mDecodeBlkFail.Inc(ctx, 1)
-				return false
+				return reject(p)
 			}
 			if err := bv.ValidateSyntax(ctx, &payload.Header); err != nil {
 				blockTopicLogger.Debugf("failed to validate block %s from peer %s: %s", payload.Header.Cid().String(), p.String(), err.Error())
 				mInvalidBlk.Inc(ctx, 1)
+				if errors.Cause(err) == consensus.ErrBlockTime {
+					// Either clock may be skewed, the peer is not to blame.
+					return false
+				}
+				return reject(p)
+			}
+			if err := validateMessageCids(ctx, &payload); err != nil {
+				blockTopicLogger.Debugf("failed to validate messages of block %s from peer %s: %s", payload.Header.Cid().String(), p.String(), err.Error())
+				mInvalidBlkMsgs.Inc(ctx, 1)
+				return reject(p)
+			}
+			if hv == nil {
+				return true
+			}
+			ctx, cancel := context.WithTimeout(ctx, budget)
+			defer cancel()
+			err = hv.ValidateHeader(ctx, &payload.Header)
+			switch {
+			case err == nil:
+				return true
+			case consensus.IsInvalid(err):
+				blockTopicLogger.Debugf("failed to validate header of block %s from peer %s: %s", payload.Header.Cid().String(), p.String(), err.Error())
+				mInvalidBlkHeader.Inc(ctx, 1)
+				return reject(p)
+			case errors.Cause(err) == consensus.ErrParentStateUnknown || errors.Cause(err) == context.DeadlineExceeded:
+				blockTopicLogger.Debugf("relaying block %s from peer %s unvalidated: %s", payload.Header.Cid().String(), p.String(), err.Error())
+				mBlkHeaderUnvalidated.Inc(ctx, 1)
+				return true
+			default:
+				// The node failed to check the header, the peer is not to blame.
+				blockTopicLogger.Warnf("failed to check header of block %s from peer %s: %s", payload.Header.Cid().String(), p.String(), err.Error())
 				return false
 			}
-			// Note: there is no validation here that the BLS and SECP message CIDs included in the payload
-			// produce the AMT roots referenced in the block header.
-			// At present, those lists are ignored by chain validation anyway.
-			// Such a check happens later in block semantic validation, but it would probably be a good idea to do
-			// it here too. https://github.com/sbwtw/go-filecoin/issues/3903
-			return true
 		},
 	}
 }
 
+// validateMessageCids checks the BLS and SECP message cids of the payload
+// produce the messages cid referenced in the header.
+func validateMessageCids(ctx context.Context, payload *Payload) error {
+	msgsCid, err := chain.ComputeMessagesCid(ctx, unwrapCids(payload.SECPMsgCids), unwrapCids(payload.BLSMsgCids))
+	if err != nil {
+		return err
+	}
+	if !msgsCid.Equals(payload.Header.Messages.Cid) {
+		return errors.Errorf("message cids produce %s, header references %s", msgsCid, payload.Header.Messages.Cid)
+	}
+	return nil
+}
+
+func unwrapCids(cids []e.Cid) []cid.Cid {
+	out := make([]cid.Cid, len(cids))
+	for i, c := range cids {
+		out[i] = c.Cid
+	}
+	return out
+}
+
 func (btv *BlockTopicValidator) Topic(network string) string {
 	return Topic(network)
 }
@@ -8,9 +8,12 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	pubsubpb "github.com/libp2p/go-libp2p-pubsub/pb"
 	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -33,16 +36,39 @@ func TestBlockTopicValidator(t *testing.T) {
 
 	ctx := context.Background()
 	mbv := th.NewStubBlockValidator()
-	tv := blocksub.NewBlockTopicValidator(mbv, nil)
+	hv := &stubHeaderValidator{errs: make(map[cid.Cid]error)}
+	scorer := &countingScorer{penalties: make(map[peer.ID]int)}
+	tv := blocksub.NewBlockTopicValidator(mbv, hv, scorer, 100*time.Millisecond)
 	builder := chain.NewBuilder(t, address.Undef)
 	pid1 := th.RequireIntPeerID(t, 1)
+	pid2 := th.RequireIntPeerID(t, 2)
 
 	goodBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {})
 	badBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
 		b.IncHeight(1)
 	})
+	badHeaderBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
+		b.IncHeight(2)
+	})
+	unknownParentBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
+		b.IncHeight(3)
+	})
+	slowBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
+		b.IncHeight(4)
+	})
+	futureBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
+		b.IncHeight(5)
+	})
+	uncheckedBlk := builder.BuildOnBlock(nil, func(b *chain.BlockBuilder) {
+		b.IncHeight(6)
+	})
 
 	mbv.StubSyntaxValidationForBlock(badBlk, fmt.Errorf("invalid block"))
+	mbv.StubSyntaxValidationForBlock(futureBlk, errors.Wrap(consensus.ErrBlockTime, "future epoch"))
+	hv.errs[badHeaderBlk.Cid()] = consensus.Invalid(fmt.Errorf("invalid ticket"))
+	hv.errs[uncheckedBlk.Cid()] = fmt.Errorf("failed to read worker address")
+	hv.errs[unknownParentBlk.Cid()] = consensus.ErrParentStateUnknown
+	hv.errs[slowBlk.Cid()] = context.DeadlineExceeded
 
 	validator := tv.Validator()
 
@@ -51,6 +77,29 @@ func TestBlockTopicValidator(t *testing.T) {
 	assert.True(t, validator(ctx, pid1, blkToPubSub(t, goodBlk)))
 	assert.False(t, validator(ctx, pid1, blkToPubSub(t, badBlk)))
 	assert.False(t, validator(ctx, pid1, nonBlkPubSubMsg()))
+	assert.False(t, validator(ctx, pid2, blkToPubSub(t, badHeaderBlk)))
+
+	// Blocks out of time are dropped without blaming the peer.
+	assert.False(t, validator(ctx, pid1, blkToPubSub(t, futureBlk)))
+
+	// Blocks that cannot be validated yet or in budget are relayed.
+	assert.True(t, validator(ctx, pid2, blkToPubSub(t, unknownParentBlk)))
+	assert.True(t, validator(ctx, pid2, blkToPubSub(t, slowBlk)))
+
+	// Blocks the node fails to check are dropped without blaming the peer.
+	assert.False(t, validator(ctx, pid2, blkToPubSub(t, uncheckedBlk)))
+
+	// Message cids must produce the header's messages cid.
+	payload := blocksub.Payload{
+		Header:      *goodBlk,
+		SECPMsgCids: []e.Cid{e.NewCid(types.CidFromString(t, "somecid"))},
+	}
+	data, err := encoding.Encode(&payload)
+	require.NoError(t, err)
+	assert.False(t, validator(ctx, pid2, &pubsub.Message{Message: &pubsubpb.Message{Data: data}}))
+
+	assert.Equal(t, 2, scorer.penalties[pid1])
+	assert.Equal(t, 2, scorer.penalties[pid2])
 }
 
 func TestBlockPubSubValidation(t *testing.T) {
@@ -71,7 +120,7 @@ func TestBlockPubSubValidation(t *testing.T) {
 	// setup a block validator and a topic validator
 	chainClock := clock.NewChainClockFromClock(uint64(now.Unix()), blocktime, mclock)
 	bv := consensus.NewDefaultBlockValidator(chainClock)
-	btv := blocksub.NewBlockTopicValidator(bv)
+	btv := blocksub.NewBlockTopicValidator(bv, nil, nil, time.Second)
 
 	// setup a floodsub instance on the host and register the topic validator
 	network := "gfctest"
@@ -121,6 +170,7 @@ func TestBlockPubSubValidation(t *testing.T) {
 		Height:          1,
 		Timestamp:       uint64(validTime.Unix()),
 		StateRoot:       e.NewCid(types.NewCidForTestGetter()()),
+		Messages:        e.NewCid(types.EmptyTxMetaCID),
 		Miner:           miner,
 		Ticket:          block.Ticket{VRFProof: []byte{0}},
 		BlockSig:        &crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte{}},
@@ -177,6 +227,28 @@ func blkToPubSub(t *testing.T, blk *block.Block) *pubsub.Message {
 	}
 }
 
+// stubHeaderValidator fails header validation of the blocks it has errors for.
+type stubHeaderValidator struct {
+	errs map[cid.Cid]error
+}
+
+func (hv *stubHeaderValidator) ValidateHeader(ctx context.Context, blk *block.Block) error {
+	if err := hv.errs[blk.Cid()]; err != context.DeadlineExceeded {
+		return err
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// countingScorer counts the penalties of each peer.
+type countingScorer struct {
+	penalties map[peer.ID]int
+}
+
+func (s *countingScorer) Penalize(p peer.ID) {
+	s.penalties[p]++
+}
+
 // returns a pubsub message that will not decode to a types.Block
 func nonBlkPubSubMsg() *pubsub.Message {
 	pbm := &pubsubpb.Message{
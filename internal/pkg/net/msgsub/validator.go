@@ -6,6 +6,7 @@ import (
 	"github.com/ipfs/go-log"
 	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-pubsub"
+	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
 	"github.com/sbwtw/go-filecoin/internal/pkg/metrics"
@@ -15,6 +16,23 @@ import (
 var messageTopicLogger = log.Logger("net/message_validator")
 var mDecodeMsgFail = metrics.NewInt64Counter("net/pubsub_message_decode_failure", "Number of messages that fail to decode seen on message pubsub channel")
 var mInvalidMsg = metrics.NewInt64Counter("net/pubsub_invalid_message", "Number of messages that fail syntax validation seen on message pubsub channel")
+var mMsgGasPriceLow = metrics.NewInt64Counter("net/pubsub_message_gas_price_low", "Number of messages priced below the minimum gas price seen on message pubsub channel")
+var mMsgSenderMissing = metrics.NewInt64Counter("net/pubsub_message_sender_missing", "Number of messages from missing senders seen on message pubsub channel")
+var mMsgSenderNotAccount = metrics.NewInt64Counter("net/pubsub_message_sender_not_account", "Number of messages from non-account senders seen on message pubsub channel")
+var mMsgInsufficientFunds = metrics.NewInt64Counter("net/pubsub_message_insufficient_funds", "Number of messages whose sender cannot pay for them seen on message pubsub channel")
+var mMsgNonceTooLow = metrics.NewInt64Counter("net/pubsub_message_nonce_low", "Number of messages with a nonce already used seen on message pubsub channel")
+var mMsgNonceTooHigh = metrics.NewInt64Counter("net/pubsub_message_nonce_high", "Number of messages with a nonce far ahead of the sender's seen on message pubsub channel")
+var mMsgSenderUnchecked = metrics.NewInt64Counter("net/pubsub_message_sender_unchecked", "Number of messages whose sender state failed to load seen on message pubsub channel")
+
+// SenderChecker checks a message against the state of its sender at the head.
+type SenderChecker interface {
+	SenderCheck(ctx context.Context, msg *types.UnsignedMessage, maxNonceGap uint64) error
+}
+
+// PeerScorer is told of peers relaying invalid messages.
+type PeerScorer interface {
+	Penalize(p peer.ID)
+}
 
 // MessageTopicValidator may be registered on go-libp3p-pubsub to validate msgsub payloads.
 type MessageTopicValidator struct {
@@ -23,8 +41,20 @@ type MessageTopicValidator struct {
 }
 
 // NewMessageTopicValidator returns a MessageTopicValidator using the input
-// signature and syntax validators.
-func NewMessageTopicValidator(syntaxVal *consensus.MessageSyntaxValidator, sigVal *consensus.MessageSignatureValidator, opts ...pubsub.ValidatorOpt) *MessageTopicValidator {
+// signature and syntax validators, and checking the sender state with
+// `senderCheck`. Messages priced below `minGasPrice` or with a nonce more than
+// `maxNonceGap` ahead of the sender's are not relayed. Peers relaying messages
+// invalid regardless of state are penalized with `scorer`; those failing the
+// sender check are dropped only, as the peer may have checked them against
+// another head, and so are those the node fails to load the state for.
+func NewMessageTopicValidator(syntaxVal *consensus.MessageSyntaxValidator, sigVal *consensus.MessageSignatureValidator, senderCheck SenderChecker,
+	scorer PeerScorer, maxNonceGap uint64, minGasPrice types.AttoFIL, opts ...pubsub.ValidatorOpt) *MessageTopicValidator {
+	reject := func(p peer.ID) bool {
+		if scorer != nil {
+			scorer.Penalize(p)
+		}
+		return false
+	}
 	return &MessageTopicValidator{
 		opts: opts,
 		validator: func(ctx context.Context, p peer.ID, msg *pubsub.Message) bool {
@@ -32,18 +62,34 @@ func NewMessageTopicValidator(syntaxVal *consensus.MessageSyntaxValidator, sigVa
 			if err := unmarshaled.Unmarshal(msg.GetData()); err != nil {
 				messageTopicLogger.Debugf("message from peer: %s failed to decode: %s", p.String(), err.Error())
 				mDecodeMsgFail.Inc(ctx, 1)
-				return false
+				return reject(p)
 			}
 			if err := syntaxVal.Validate(ctx, unmarshaled); err != nil {
 				mCid, _ := unmarshaled.Cid()
 				messageTopicLogger.Debugf("message %s from peer: %s failed to syntax validate: %s", mCid.String(), p.String(), err.Error())
 				mInvalidMsg.Inc(ctx, 1)
+				return reject(p)
+			}
+			if unmarshaled.Message.GasPrice.LessThan(minGasPrice) {
+				mMsgGasPriceLow.Inc(ctx, 1)
 				return false
 			}
 			if err := sigVal.Validate(ctx, unmarshaled); err != nil {
 				mCid, _ := unmarshaled.Cid()
+				if errors.Cause(err) == consensus.ErrStateUnavailable {
+					// The node failed to validate the message, the peer is not to blame.
+					messageTopicLogger.Warnf("message %s from peer: %s could not be signature validated: %s", mCid.String(), p.String(), err.Error())
+					mMsgSenderUnchecked.Inc(ctx, 1)
+					return false
+				}
 				messageTopicLogger.Debugf("message %s from peer: %s failed to signature validate: %s", mCid.String(), p.String(), err.Error())
 				mInvalidMsg.Inc(ctx, 1)
+				return reject(p)
+			}
+			if err := senderCheck.SenderCheck(ctx, &unmarshaled.Message, maxNonceGap); err != nil {
+				mCid, _ := unmarshaled.Cid()
+				messageTopicLogger.Debugf("message %s from peer: %s failed sender check: %s", mCid.String(), p.String(), err.Error())
+				senderCheckCounter(errors.Cause(err)).Inc(ctx, 1)
 				return false
 			}
 			return true
@@ -51,6 +97,24 @@ func NewMessageTopicValidator(syntaxVal *consensus.MessageSyntaxValidator, sigVa
 	}
 }
 
+// senderCheckCounter returns the counter of sender check failures with the cause.
+func senderCheckCounter(cause error) *metrics.Int64Counter {
+	switch cause {
+	case consensus.ErrSenderMissing:
+		return mMsgSenderMissing
+	case consensus.ErrSenderNotAccount:
+		return mMsgSenderNotAccount
+	case consensus.ErrInsufficientFunds:
+		return mMsgInsufficientFunds
+	case consensus.ErrNonceTooLow:
+		return mMsgNonceTooLow
+	case consensus.ErrNonceTooHigh:
+		return mMsgNonceTooHigh
+	default:
+		return mMsgSenderUnchecked
+	}
+}
+
 func (mtv *MessageTopicValidator) Topic(network string) string {
 	return Topic(network)
 }
@@ -0,0 +1,172 @@
+package msgsub_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/ipfs/go-cid"
+	"github.com/libp2p/go-libp2p-core/peer"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	pubsubpb "github.com/libp2p/go-libp2p-pubsub/pb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/consensus"
+	"github.com/sbwtw/go-filecoin/internal/pkg/net/msgsub"
+	"github.com/sbwtw/go-filecoin/internal/pkg/state"
+	th "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/actor"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
+)
+
+func TestMessageTopicValidator(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	signer, _ := types.NewMockSignersAndKeyInfo(3)
+	alice, bob, unknown := signer.Addresses[0], signer.Addresses[1], signer.Addresses[2]
+	api := &fakeValidatorAPI{actors: map[address.Address]*actor.Actor{
+		alice: actor.NewActor(builtin.AccountActorCodeID, abi.NewTokenAmount(1000000), cid.Undef),
+	}}
+	api.actors[alice].CallSeqNum = 5
+
+	scorer := &countingScorer{penalties: make(map[peer.ID]int)}
+	tv := msgsub.NewMessageTopicValidator(consensus.NewMessageSyntaxValidator(), consensus.NewMessageSignatureValidator(api),
+		consensus.NewMessagePenaltyChecker(api), scorer, 10, types.NewGasPrice(2))
+	validator := tv.Validator()
+	pid1, pid2 := th.RequireIntPeerID(t, 1), th.RequireIntPeerID(t, 2)
+
+	newMessage := func(from address.Address, nonce uint64, value int64, gasPrice int64) *types.SignedMessage {
+		msg := types.NewMeteredMessage(from, bob, nonce, abi.NewTokenAmount(value), builtin.MethodSend, []byte{}, types.NewGasPrice(gasPrice), gas.NewGas(1000))
+		smsg, err := types.NewSignedMessage(ctx, *msg, signer)
+		require.NoError(t, err)
+		return smsg
+	}
+
+	network := "gfctest"
+	assert.Equal(t, msgsub.Topic(network), tv.Topic(network))
+
+	t.Run("relays valid messages", func(t *testing.T) {
+		assert.True(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 5, 10, 2))))
+		assert.True(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 15, 10, 2))))
+	})
+
+	t.Run("rejects and penalizes invalid messages", func(t *testing.T) {
+		decodeFailures := counterValue(t, "net/pubsub_message_decode_failure")
+		invalid := counterValue(t, "net/pubsub_invalid_message")
+
+		assert.False(t, validator(ctx, pid1, &pubsub.Message{Message: &pubsubpb.Message{Data: []byte("meow")}}))
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 5, -1, 2))))
+		forged := newMessage(alice, 5, 10, 2)
+		forged.Message.Value = abi.NewTokenAmount(11)
+		assert.False(t, validator(ctx, pid2, msgToPubSub(t, forged)))
+
+		assert.Equal(t, decodeFailures+1, counterValue(t, "net/pubsub_message_decode_failure"))
+		assert.Equal(t, invalid+2, counterValue(t, "net/pubsub_invalid_message"))
+		assert.Equal(t, 2, scorer.penalties[pid1])
+		assert.Equal(t, 1, scorer.penalties[pid2])
+	})
+
+	t.Run("drops messages failing the sender check without penalty", func(t *testing.T) {
+		scorer.penalties = make(map[peer.ID]int)
+		counters := []string{
+			"net/pubsub_message_gas_price_low",
+			"net/pubsub_message_nonce_low",
+			"net/pubsub_message_nonce_high",
+			"net/pubsub_message_insufficient_funds",
+			"net/pubsub_message_sender_missing",
+		}
+		before := make([]int64, len(counters))
+		for i, name := range counters {
+			before[i] = counterValue(t, name)
+		}
+
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 5, 10, 1))))
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 4, 10, 2))))
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 16, 10, 2))))
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 5, 1000000, 2))))
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(unknown, 0, 10, 2))))
+
+		for i, name := range counters {
+			assert.Equal(t, before[i]+1, counterValue(t, name), name)
+		}
+		assert.Empty(t, scorer.penalties)
+	})
+
+	t.Run("drops messages without penalty when state fails to load", func(t *testing.T) {
+		scorer.penalties = make(map[peer.ID]int)
+		unchecked := counterValue(t, "net/pubsub_message_sender_unchecked")
+
+		api.stateErr = errors.New("failed to load state")
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 5, 10, 2))))
+		api.stateErr = nil
+		api.actorErr = errors.New("failed to load actor")
+		assert.False(t, validator(ctx, pid1, msgToPubSub(t, newMessage(alice, 5, 10, 2))))
+		api.actorErr = nil
+
+		assert.Equal(t, unchecked+2, counterValue(t, "net/pubsub_message_sender_unchecked"))
+		assert.Empty(t, scorer.penalties)
+	})
+}
+
+func msgToPubSub(t *testing.T, msg *types.SignedMessage) *pubsub.Message {
+	data, err := msg.Marshal()
+	require.NoError(t, err)
+	return &pubsub.Message{Message: &pubsubpb.Message{Data: data}}
+}
+
+// counterValue returns the number of increments of the counter with the name.
+func counterValue(t *testing.T, name string) int64 {
+	rows, err := view.RetrieveData(name)
+	require.NoError(t, err)
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.CountData).Value
+}
+
+// fakeValidatorAPI provides the actors of the head state, or fails to load it.
+type fakeValidatorAPI struct {
+	actors   map[address.Address]*actor.Actor
+	stateErr error
+	actorErr error
+}
+
+func (api *fakeValidatorAPI) Head() block.TipSetKey {
+	return block.NewTipSetKey()
+}
+
+func (api *fakeValidatorAPI) AccountStateView(_ block.TipSetKey) (state.AccountStateView, error) {
+	if api.stateErr != nil {
+		return nil, api.stateErr
+	}
+	return &state.FakeStateView{}, nil
+}
+
+func (api *fakeValidatorAPI) GetActorAt(_ context.Context, _ block.TipSetKey, addr address.Address) (*actor.Actor, error) {
+	if api.actorErr != nil {
+		return nil, api.actorErr
+	}
+	act, ok := api.actors[addr]
+	if !ok {
+		return nil, types.ErrNotFound
+	}
+	return act, nil
+}
+
+// countingScorer counts the penalties of each peer.
+type countingScorer struct {
+	penalties map[peer.ID]int
+}
+
+func (s *countingScorer) Penalize(p peer.ID) {
+	s.penalties[p]++
+}
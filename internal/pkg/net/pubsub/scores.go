@@ -0,0 +1,138 @@
+package pubsub
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-log"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+)
+
+var scoresLogger = log.Logger("net/pubsub_scores")
+
+// PeerScores scores the peers relaying messages on pubsub topics. A peer
+// loses a point for each invalid message it relays and regains one every
+// decay interval, up to a score of zero. It is blacklisted once it has lost
+// threshold points, so that its messages are dropped unread, and starts over
+// from zero. Its methods are thread safe.
+type PeerScores struct {
+	mu     sync.Mutex
+	scores map[peer.ID]*peerScore
+
+	threshold int
+	decay     time.Duration
+	clock     clock.Clock
+	blacklist func(peer.ID)
+}
+
+type peerScore struct {
+	points int
+	// decayed is the time up to which the points have been decayed.
+	decayed time.Time
+}
+
+// NewPeerScores creates peer scores blacklisting peers with blacklist.
+func NewPeerScores(threshold int, decay time.Duration, c clock.Clock, blacklist func(peer.ID)) *PeerScores {
+	return &PeerScores{
+		scores:    make(map[peer.ID]*peerScore),
+		threshold: threshold,
+		decay:     decay,
+		clock:     c,
+		blacklist: blacklist,
+	}
+}
+
+// Penalize records that p relayed an invalid message.
+func (s *PeerScores) Penalize(p peer.ID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score, ok := s.scores[p]
+	if !ok {
+		score = &peerScore{decayed: s.clock.Now()}
+		s.scores[p] = score
+	}
+	s.applyDecay(score)
+	score.points--
+	if score.points <= -s.threshold {
+		scoresLogger.Warnf("blacklisting peer %s for relaying %d invalid messages", p, s.threshold)
+		s.blacklist(p)
+		delete(s.scores, p)
+	}
+}
+
+// Score returns the score of p.
+func (s *PeerScores) Score(p peer.ID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score, ok := s.scores[p]
+	if !ok {
+		return 0
+	}
+	s.applyDecay(score)
+	if score.points == 0 {
+		delete(s.scores, p)
+	}
+	return score.points
+}
+
+// applyDecay gives back the points regained since the score last decayed. The
+// caller must hold the lock.
+func (s *PeerScores) applyDecay(score *peerScore) {
+	now := s.clock.Now()
+	if score.points == 0 || s.decay <= 0 {
+		score.decayed = now
+		return
+	}
+	regained := int(now.Sub(score.decayed) / s.decay)
+	if regained >= -score.points {
+		score.points = 0
+		score.decayed = now
+		return
+	}
+	score.points += regained
+	score.decayed = score.decayed.Add(time.Duration(regained) * s.decay)
+}
+
+// TimedBlacklist is a go-libp2p-pubsub blacklist whose entries expire after a
+// time to live, so that blacklisted peers are heard again once it has passed.
+// Its methods are thread safe.
+type TimedBlacklist struct {
+	mu      sync.Mutex
+	expires map[peer.ID]time.Time
+
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// NewTimedBlacklist creates a blacklist keeping peers for ttl.
+func NewTimedBlacklist(ttl time.Duration, c clock.Clock) *TimedBlacklist {
+	return &TimedBlacklist{
+		expires: make(map[peer.ID]time.Time),
+		ttl:     ttl,
+		clock:   c,
+	}
+}
+
+// Add blacklists p for the time to live.
+func (b *TimedBlacklist) Add(p peer.ID) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expires[p] = b.clock.Now().Add(b.ttl)
+}
+
+// Contains returns true if p is blacklisted.
+func (b *TimedBlacklist) Contains(p peer.ID) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	expiry, ok := b.expires[p]
+	if !ok {
+		return false
+	}
+	if !b.clock.Now().Before(expiry) {
+		delete(b.expires, p)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,65 @@
+package pubsub_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/net/pubsub"
+	th "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestPeerScoresDecay(t *testing.T) {
+	tf.UnitTest(t)
+	fc := clock.NewFake(time.Unix(1234567890, 0))
+	var blacklisted []peer.ID
+	scores := pubsub.NewPeerScores(3, time.Minute, fc, func(p peer.ID) {
+		blacklisted = append(blacklisted, p)
+	})
+	pid := th.RequireIntPeerID(t, 1)
+
+	scores.Penalize(pid)
+	scores.Penalize(pid)
+	assert.Equal(t, -2, scores.Score(pid))
+
+	// A point is regained every minute, up to zero.
+	fc.Advance(90 * time.Second)
+	assert.Equal(t, -1, scores.Score(pid))
+	fc.Advance(30 * time.Second)
+	assert.Equal(t, 0, scores.Score(pid))
+	fc.Advance(time.Hour)
+	assert.Equal(t, 0, scores.Score(pid))
+
+	// Slowly relayed invalid messages are forgiven.
+	for i := 0; i < 10; i++ {
+		scores.Penalize(pid)
+		fc.Advance(time.Minute)
+	}
+	assert.Empty(t, blacklisted)
+
+	for i := 0; i < 3; i++ {
+		scores.Penalize(pid)
+	}
+	assert.Equal(t, []peer.ID{pid}, blacklisted)
+	assert.Equal(t, 0, scores.Score(pid))
+}
+
+func TestTimedBlacklist(t *testing.T) {
+	tf.UnitTest(t)
+	fc := clock.NewFake(time.Unix(1234567890, 0))
+	blacklist := pubsub.NewTimedBlacklist(time.Hour, fc)
+	pid1, pid2 := th.RequireIntPeerID(t, 1), th.RequireIntPeerID(t, 2)
+
+	blacklist.Add(pid1)
+	assert.True(t, blacklist.Contains(pid1))
+	assert.False(t, blacklist.Contains(pid2))
+
+	fc.Advance(59 * time.Minute)
+	assert.True(t, blacklist.Contains(pid1))
+	fc.Advance(time.Minute)
+	assert.False(t, blacklist.Contains(pid1))
+}
@@ -0,0 +1,293 @@
+package pledger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
+	fsm "github.com/filecoin-project/storage-fsm"
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	appstate "github.com/sbwtw/go-filecoin/internal/pkg/state"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+)
+
+var log = logging.Logger("pledger")
+
+// pledgeTimeout is how long a pledged sector is counted as sealing before the
+// sealing state machine records it. Pledging writes the sector's piece before
+// recording it, which takes a while for large sectors.
+const pledgeTimeout = time.Hour
+
+// sealingFootprint is the space a sector takes while it seals, in multiples of
+// the sector size. Besides the unsealed and sealed copies, the cache holds a
+// layer per stacked DRG layer and the trees built over them until the sector
+// is finalized.
+const sealingFootprint = 14
+
+// Status is the outcome of the last check of the pledge policy.
+type Status struct {
+	CheckedAt time.Time
+	// Sealing is the number of sectors in the sealing pipeline at the check,
+	// counting those pledged but not yet recorded by the sealing state
+	// machine.
+	Sealing int
+	// Pledged is the number of sectors pledged at the check.
+	Pledged int
+	// Paused is the reason no more sectors were pledged at the check, if one
+	// held pledging back.
+	Paused string
+}
+
+type sealer interface {
+	ListSectors() ([]fsm.SectorInfo, error)
+	PledgeSector() error
+}
+
+type chainState interface {
+	GetTipSetStateRoot(ctx context.Context, tsk block.TipSetKey) (cid.Cid, error)
+}
+
+type minerView interface {
+	MinerSectorConfiguration(ctx context.Context, maddr address.Address) (*appstate.MinerSectorConfiguration, error)
+	MinerClaimedPower(ctx context.Context, maddr address.Address) (raw, qa abi.StoragePower, err error)
+	MinerControlAddresses(ctx context.Context, maddr address.Address) (owner, worker address.Address, err error)
+	ActorBalance(ctx context.Context, a address.Address) (abi.TokenAmount, error)
+	MinerBalances(ctx context.Context, maddr address.Address) (appstate.MinerBalances, error)
+	MinerSectorCollateral(ctx context.Context, maddr address.Address) (deposit, pledge abi.TokenAmount, err error)
+}
+
+// Pledger pledges committed-capacity sectors for a miner on its own, keeping
+// the sealing pipeline busy within the limits of the pledge policy in the
+// node's mining config: the number of sectors sealing at once, the free
+// space of the storage paths, the worker balance available for
+// pre-commit deposits, the miner balance available for initial pledges and
+// the miner's target raw power.
+type Pledger struct {
+	lk        sync.Mutex
+	checkedAt time.Time
+	status    *Status
+	// pending are the times the sectors pledged but not yet recorded by the
+	// sealing state machine are given up on, oldest first. Pledging adds
+	// sectors asynchronously and may fail without the pledger hearing of it.
+	pending []time.Time
+	// recorded is the number of sectors the sealing state machine recorded
+	// at the last check.
+	recorded int
+
+	minerAddr address.Address
+	sealer    sealer
+	mining    func() *config.MiningConfig
+	freeSpace func() (uint64, error)
+	headView  func(ctx context.Context, tsk block.TipSetKey) (minerView, error)
+	clock     clock.Clock
+}
+
+// NewPledger creates a pledger of the miner's sectors, reading the policy from
+// the mining config and the free space of the storage sectors are sealed and
+// stored in.
+func NewPledger(minerAddr address.Address, sealer *fsm.Sealing, mining func() *config.MiningConfig, freeSpace func() (uint64, error), chain chainState, stateViewer *appstate.Viewer) *Pledger {
+	return &Pledger{
+		minerAddr: minerAddr,
+		sealer:    sealer,
+		mining:    mining,
+		freeSpace: freeSpace,
+		headView: func(ctx context.Context, tsk block.TipSetKey) (minerView, error) {
+			root, err := chain.GetTipSetStateRoot(ctx, tsk)
+			if err != nil {
+				return nil, err
+			}
+			return stateViewer.StateView(root), nil
+		},
+		clock: clock.NewSystemClock(),
+	}
+}
+
+// Status returns the outcome of the last check of the pledge policy, nil if
+// it has not been checked.
+func (p *Pledger) Status() *Status {
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	if p.status == nil {
+		return nil
+	}
+	status := *p.status
+	return &status
+}
+
+// HandleNewHead checks the pledge policy at the new head, at most once every
+// mining.autoSealIntervalSeconds, and pledges the sectors it allows.
+func (p *Pledger) HandleNewHead(ctx context.Context, newHead block.TipSet) error {
+	cfg := p.mining()
+	if !cfg.PledgePolicy.Enabled {
+		return nil
+	}
+
+	p.lk.Lock()
+	defer p.lk.Unlock()
+	now := p.clock.Now()
+	if now.Sub(p.checkedAt) < time.Duration(cfg.AutoSealIntervalSeconds)*time.Second {
+		return nil
+	}
+	p.checkedAt = now
+
+	view, err := p.headView(ctx, newHead.Key())
+	if err != nil {
+		return err
+	}
+	status, err := p.check(ctx, cfg.PledgePolicy, view)
+	status.CheckedAt = now
+	p.status = &status
+	if err != nil {
+		return errors.Wrap(err, "failed to check pledge policy")
+	}
+	if status.Pledged > 0 {
+		log.Infof("pledged %d sectors for miner %s, %d sealing", status.Pledged, p.minerAddr, status.Sealing)
+	}
+	return nil
+}
+
+// check pledges as many sectors as the policy allows at the view.
+func (p *Pledger) check(ctx context.Context, policy config.PledgePolicyConfig, view minerView) (Status, error) {
+	sectors, err := p.sealer.ListSectors()
+	if err != nil {
+		return Status{}, errors.Wrap(err, "failed to list sectors")
+	}
+	p.expirePledges(len(sectors))
+	status := Status{Sealing: len(p.pending)}
+	for _, sector := range sectors {
+		if sealing(sector.State) {
+			status.Sealing++
+		}
+	}
+
+	count := int(policy.MaxSealing) - status.Sealing
+	if count <= 0 {
+		status.Paused = fmt.Sprintf("%d of %d sectors sealing", status.Sealing, policy.MaxSealing)
+		return status, nil
+	}
+	limit := func(n int, reason string) {
+		if n < count {
+			count = n
+			status.Paused = reason
+		}
+	}
+
+	conf, err := view.MinerSectorConfiguration(ctx, p.minerAddr)
+	if err != nil {
+		return status, err
+	}
+	sectorSize := uint64(conf.SectorSize)
+
+	if policy.TargetRawPower > 0 {
+		raw, _, err := view.MinerClaimedPower(ctx, p.minerAddr)
+		if err != nil {
+			return status, err
+		}
+		committed := big.Add(raw, big.NewIntUnsigned(uint64(status.Sealing)*sectorSize))
+		target := big.NewIntUnsigned(policy.TargetRawPower)
+		short := 0
+		if committed.LessThan(target) {
+			// round up, so the target is reached
+			short = int(big.Div(big.Add(big.Sub(target, committed), big.NewIntUnsigned(sectorSize-1)), big.NewIntUnsigned(sectorSize)).Uint64())
+		}
+		limit(short, fmt.Sprintf("raw power %s and sealing sectors reach target %d", raw, policy.TargetRawPower))
+	}
+
+	free, err := p.freeSpace()
+	if err != nil {
+		return status, errors.Wrap(err, "failed to get free space of the sector storage")
+	}
+	// the sectors already sealing grow into their footprint first
+	footprint := sealingFootprint * sectorSize
+	reserved := policy.MinFreeSpace + uint64(status.Sealing)*footprint
+	fits := 0
+	if free > reserved {
+		fits = int((free - reserved) / footprint)
+	}
+	limit(fits, fmt.Sprintf("%d bytes free in sector storage, keeping %d free and %d per sealing sector", free, policy.MinFreeSpace, footprint))
+
+	deposit, pledge, err := view.MinerSectorCollateral(ctx, p.minerAddr)
+	if err != nil {
+		return status, err
+	}
+	if policy.Deposit.GreaterThan(deposit) {
+		deposit = policy.Deposit
+	}
+	if deposit.GreaterThan(types.ZeroAttoFIL) {
+		_, worker, err := view.MinerControlAddresses(ctx, p.minerAddr)
+		if err != nil {
+			return status, err
+		}
+		balance, err := view.ActorBalance(ctx, worker)
+		if err != nil {
+			return status, err
+		}
+		// the balance covers the sectors already sealing first
+		covered := int(big.Div(balance, deposit).Int64()) - status.Sealing
+		limit(covered, fmt.Sprintf("worker balance %s does not cover deposit %s per sector", balance, deposit))
+	}
+	if pledge.GreaterThan(types.ZeroAttoFIL) {
+		balances, err := view.MinerBalances(ctx, p.minerAddr)
+		if err != nil {
+			return status, err
+		}
+		// the initial pledge is locked from the miner's balance when a
+		// sector is proven, again covering the sectors already sealing first
+		covered := int(big.Div(balances.Available, pledge).Int64()) - status.Sealing
+		limit(covered, fmt.Sprintf("miner balance %s does not cover pledge %s per sector", balances.Available, pledge))
+	}
+
+	for status.Pledged < count {
+		if err := p.sealer.PledgeSector(); err != nil {
+			return status, errors.Wrap(err, "failed to pledge sector")
+		}
+		p.pending = append(p.pending, p.clock.Now().Add(pledgeTimeout))
+		status.Pledged++
+		status.Sealing++
+	}
+	return status, nil
+}
+
+// expirePledges forgets the pending pledges the sealing state machine has
+// recorded since the last check, taken to be the oldest, and those not
+// recorded in time.
+func (p *Pledger) expirePledges(recorded int) {
+	added := recorded - p.recorded
+	p.recorded = recorded
+	if added > len(p.pending) {
+		added = len(p.pending)
+	}
+	if added > 0 {
+		p.pending = p.pending[added:]
+	}
+
+	now := p.clock.Now()
+	expired := 0
+	for expired < len(p.pending) && !now.Before(p.pending[expired]) {
+		expired++
+	}
+	if expired > 0 {
+		log.Warnf("%d sectors pledged for miner %s were not recorded within %s", expired, p.minerAddr, pledgeTimeout)
+		p.pending = p.pending[expired:]
+	}
+}
+
+// sealing returns whether a sector in the state occupies the sealing
+// pipeline. Failed sectors do until they are retried or removed.
+func sealing(state fsm.SectorState) bool {
+	switch state {
+	case fsm.Proving, fsm.Faulty, fsm.FaultReported, fsm.FaultedFinal:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,252 @@
+package pledger
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	fsm "github.com/filecoin-project/storage-fsm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	"github.com/sbwtw/go-filecoin/internal/pkg/clock"
+	"github.com/sbwtw/go-filecoin/internal/pkg/config"
+	"github.com/sbwtw/go-filecoin/internal/pkg/state"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+)
+
+const (
+	sectorSize = 2048
+	footprint  = sealingFootprint * sectorSize
+)
+
+func TestPledgerKeepsSectorsSealing(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+
+	t.Run("fills the free sealing slots", func(t *testing.T) {
+		p, sealer, view, _ := newTestPledger(t, policy(3))
+		sealer.states = []fsm.SectorState{fsm.Proving, fsm.PreCommit1}
+
+		status, err := p.check(ctx, policy(3), view)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Pledged)
+		assert.Equal(t, 3, status.Sealing)
+		assert.Equal(t, "", status.Paused)
+
+		// the pledged sectors are counted until the state machine records them
+		status, err = p.check(ctx, policy(3), view)
+		require.NoError(t, err)
+		assert.Equal(t, 0, status.Pledged)
+		assert.Equal(t, "3 of 3 sectors sealing", status.Paused)
+	})
+
+	t.Run("gives up on pledged sectors never recorded", func(t *testing.T) {
+		p, sealer, view, _ := newTestPledger(t, policy(3))
+		fakeClock := clock.NewFake(time.Unix(1000, 0))
+		p.clock = fakeClock
+
+		status, err := p.check(ctx, policy(3), view)
+		require.NoError(t, err)
+		assert.Equal(t, 3, status.Pledged)
+
+		// one pledge is recorded, the others failed
+		sealer.states = []fsm.SectorState{fsm.PreCommit1}
+		fakeClock.Advance(pledgeTimeout - time.Second)
+		status, err = p.check(ctx, policy(3), view)
+		require.NoError(t, err)
+		assert.Equal(t, 0, status.Pledged)
+		assert.Equal(t, 3, status.Sealing)
+
+		fakeClock.Advance(time.Second)
+		status, err = p.check(ctx, policy(3), view)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Pledged)
+		assert.Equal(t, 3, status.Sealing)
+	})
+
+	t.Run("counts failed sectors as sealing", func(t *testing.T) {
+		p, sealer, view, _ := newTestPledger(t, policy(2))
+		sealer.states = []fsm.SectorState{fsm.SealFailed, fsm.FaultedFinal}
+
+		status, err := p.check(ctx, policy(2), view)
+		require.NoError(t, err)
+		assert.Equal(t, 1, status.Pledged)
+	})
+
+	t.Run("keeps the minimum free space", func(t *testing.T) {
+		p, _, view, _ := newTestPledger(t, policy(3))
+		p.freeSpace = func() (uint64, error) { return sectorSize + 2*footprint - 1, nil }
+		pol := policy(3)
+		pol.MinFreeSpace = sectorSize
+
+		status, err := p.check(ctx, pol, view)
+		require.NoError(t, err)
+		assert.Equal(t, 1, status.Pledged)
+		assert.Contains(t, status.Paused, "bytes free in sector storage")
+	})
+
+	t.Run("reserves space for the sectors sealing", func(t *testing.T) {
+		p, sealer, view, _ := newTestPledger(t, policy(5))
+		sealer.states = []fsm.SectorState{fsm.Proving, fsm.PreCommit1, fsm.WaitSeed}
+		p.freeSpace = func() (uint64, error) { return 4 * footprint, nil }
+
+		status, err := p.check(ctx, policy(5), view)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Pledged)
+		assert.Contains(t, status.Paused, "per sealing sector")
+	})
+
+	t.Run("pauses when the worker cannot cover deposits", func(t *testing.T) {
+		p, sealer, view, miner := newTestPledger(t, policy(3))
+		sealer.states = []fsm.SectorState{fsm.Committing}
+		pol := policy(3)
+		pol.Deposit = types.NewAttoFILFromFIL(1)
+
+		view.Balances[view.Miners[miner].Worker] = types.NewAttoFILFromFIL(1)
+		status, err := p.check(ctx, pol, view)
+		require.NoError(t, err)
+		assert.Equal(t, 0, status.Pledged)
+		assert.Contains(t, status.Paused, "does not cover deposit")
+
+		view.Balances[view.Miners[miner].Worker] = types.NewAttoFILFromFIL(2)
+		status, err = p.check(ctx, pol, view)
+		require.NoError(t, err)
+		assert.Equal(t, 1, status.Pledged)
+	})
+
+	t.Run("pauses when the worker cannot cover the miner's deposit", func(t *testing.T) {
+		p, sealer, view, miner := newTestPledger(t, policy(3))
+		sealer.states = []fsm.SectorState{fsm.Committing}
+		view.Miners[miner].SectorDeposit = types.NewAttoFILFromFIL(2)
+
+		// the policy sets no deposit, the miner's requirement applies
+		view.Balances[view.Miners[miner].Worker] = types.NewAttoFILFromFIL(3)
+		status, err := p.check(ctx, policy(3), view)
+		require.NoError(t, err)
+		assert.Equal(t, 0, status.Pledged)
+		assert.Contains(t, status.Paused, "does not cover deposit")
+
+		// a higher policy deposit is a floor on the requirement
+		view.Balances[view.Miners[miner].Worker] = types.NewAttoFILFromFIL(6)
+		pol := policy(3)
+		pol.Deposit = types.NewAttoFILFromFIL(3)
+		status, err = p.check(ctx, pol, view)
+		require.NoError(t, err)
+		assert.Equal(t, 1, status.Pledged)
+	})
+
+	t.Run("pauses when the miner cannot cover initial pledges", func(t *testing.T) {
+		p, sealer, view, miner := newTestPledger(t, policy(4))
+		sealer.states = []fsm.SectorState{fsm.PreCommitting}
+		view.Miners[miner].SectorPledge = types.NewAttoFILFromFIL(1)
+
+		view.Miners[miner].Balances.Available = types.NewAttoFILFromFIL(1)
+		status, err := p.check(ctx, policy(4), view)
+		require.NoError(t, err)
+		assert.Equal(t, 0, status.Pledged)
+		assert.Contains(t, status.Paused, "does not cover pledge")
+
+		view.Miners[miner].Balances.Available = types.NewAttoFILFromFIL(3)
+		status, err = p.check(ctx, policy(4), view)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Pledged)
+	})
+
+	t.Run("stops at the target raw power", func(t *testing.T) {
+		p, sealer, view, miner := newTestPledger(t, policy(5))
+		sealer.states = []fsm.SectorState{fsm.PreCommit2}
+		view.Miners[miner].ClaimedRawPower = abi.NewStoragePower(2 * sectorSize)
+		pol := policy(5)
+		pol.TargetRawPower = 4*sectorSize + 1
+
+		status, err := p.check(ctx, pol, view)
+		require.NoError(t, err)
+		assert.Equal(t, 2, status.Pledged)
+		assert.Contains(t, status.Paused, "reach target")
+	})
+}
+
+func TestPledgerChecksOnInterval(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	p, sealer, _, _ := newTestPledger(t, policy(1))
+	fakeClock := clock.NewFake(time.Unix(1000, 0))
+	p.clock = fakeClock
+
+	require.NoError(t, p.HandleNewHead(ctx, block.UndefTipSet))
+	assert.Equal(t, 1, sealer.pledged)
+	sealer.states = []fsm.SectorState{fsm.Proving}
+
+	fakeClock.Advance(59 * time.Second)
+	require.NoError(t, p.HandleNewHead(ctx, block.UndefTipSet))
+	assert.Equal(t, 1, sealer.pledged)
+
+	fakeClock.Advance(time.Second)
+	require.NoError(t, p.HandleNewHead(ctx, block.UndefTipSet))
+	assert.Equal(t, 2, sealer.pledged)
+	assert.Equal(t, time.Unix(1060, 0), p.Status().CheckedAt)
+
+	p.mining().PledgePolicy.Enabled = false
+	fakeClock.Advance(time.Minute)
+	require.NoError(t, p.HandleNewHead(ctx, block.UndefTipSet))
+	assert.Equal(t, 2, sealer.pledged)
+}
+
+func newTestPledger(t *testing.T, pol config.PledgePolicyConfig) (*Pledger, *fakeSealer, *state.FakeStateView, address.Address) {
+	addrs := vmaddr.NewForTestGetter()
+	minerAddr, workerAddr := addrs(), addrs()
+
+	view := state.NewFakeStateView(abi.NewStoragePower(0), abi.NewStoragePower(0), 0, 0)
+	view.Miners[minerAddr] = &state.FakeMinerState{
+		SectorConfiguration: &state.MinerSectorConfiguration{SectorSize: sectorSize},
+		Worker:              workerAddr,
+		ClaimedRawPower:     abi.NewStoragePower(0),
+		Balances:            state.MinerBalances{Available: types.ZeroAttoFIL},
+	}
+	view.Balances[workerAddr] = types.ZeroAttoFIL
+
+	cfg := config.NewDefaultConfig().Mining
+	cfg.AutoSealIntervalSeconds = 60
+	cfg.PledgePolicy = pol
+
+	sealer := &fakeSealer{}
+	p := &Pledger{
+		minerAddr: minerAddr,
+		sealer:    sealer,
+		mining:    func() *config.MiningConfig { return cfg },
+		freeSpace: func() (uint64, error) { return 100 * footprint, nil },
+		headView: func(context.Context, block.TipSetKey) (minerView, error) {
+			return view, nil
+		},
+		clock: clock.NewSystemClock(),
+	}
+	return p, sealer, view, minerAddr
+}
+
+func policy(maxSealing uint) config.PledgePolicyConfig {
+	return config.PledgePolicyConfig{Enabled: true, MaxSealing: maxSealing, Deposit: types.ZeroAttoFIL}
+}
+
+type fakeSealer struct {
+	states  []fsm.SectorState
+	pledged int
+}
+
+func (f *fakeSealer) ListSectors() ([]fsm.SectorInfo, error) {
+	out := make([]fsm.SectorInfo, len(f.states))
+	for i, s := range f.states {
+		out[i] = fsm.SectorInfo{SectorNumber: abi.SectorNumber(i), State: s}
+	}
+	return out, nil
+}
+
+func (f *fakeSealer) PledgeSector() error {
+	f.pledged++
+	return nil
+}
@@ -0,0 +1,64 @@
+package poster
+
+import (
+	"context"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+)
+
+// SectorChecker checks that sectors can be proven.
+type SectorChecker interface {
+	// CheckProvable returns the sectors that cannot be proven.
+	CheckProvable(ctx context.Context, sectors []abi.SectorID) ([]abi.SectorID, error)
+}
+
+// SectorIndex finds the storage holding the files of a sector.
+type SectorIndex interface {
+	StorageFindSector(ctx context.Context, sector abi.SectorID, ft stores.SectorFileType, allowFetch bool) ([]stores.StorageInfo, error)
+}
+
+// IndexSectorChecker checks with the sector storage index that the sealed
+// replica and the cache of each sector are held in some storage, wherever the
+// sector manager put them.
+type IndexSectorChecker struct {
+	index SectorIndex
+}
+
+// NewIndexSectorChecker creates a checker of the sectors in the index.
+func NewIndexSectorChecker(index SectorIndex) *IndexSectorChecker {
+	return &IndexSectorChecker{index: index}
+}
+
+// CheckProvable returns the sectors whose sealed replica or cache is not in
+// any storage.
+func (c *IndexSectorChecker) CheckProvable(ctx context.Context, sectors []abi.SectorID) ([]abi.SectorID, error) {
+	var bad []abi.SectorID
+	for _, sector := range sectors {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		present, err := c.sectorPresent(ctx, sector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to find storage of sector %d", sector.Number)
+		}
+		if !present {
+			bad = append(bad, sector)
+		}
+	}
+	return bad, nil
+}
+
+func (c *IndexSectorChecker) sectorPresent(ctx context.Context, sector abi.SectorID) (bool, error) {
+	for _, ft := range []stores.SectorFileType{stores.FTSealed, stores.FTCache} {
+		found, err := c.index.StorageFindSector(ctx, sector, ft, false)
+		if err != nil {
+			return false, err
+		}
+		if len(found) == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
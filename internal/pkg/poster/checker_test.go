@@ -0,0 +1,59 @@
+package poster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/sector-storage/stores"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestIndexSectorChecker(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	sector := func(number abi.SectorNumber) abi.SectorID {
+		return abi.SectorID{Miner: 1000, Number: number}
+	}
+
+	// Sector 1 is whole, 2 lacks its cache, 3 its replica, 4 is unknown and
+	// 5 is held across two storages.
+	index := &fakeIndex{stored: map[abi.SectorID]map[stores.SectorFileType][]stores.ID{
+		sector(1): {stores.FTSealed: {"a"}, stores.FTCache: {"a"}},
+		sector(2): {stores.FTSealed: {"a"}},
+		sector(3): {stores.FTCache: {"b"}},
+		sector(5): {stores.FTSealed: {"a"}, stores.FTCache: {"b"}},
+	}}
+	checker := NewIndexSectorChecker(index)
+
+	bad, err := checker.CheckProvable(ctx, []abi.SectorID{sector(1), sector(2), sector(3), sector(4), sector(5)})
+	require.NoError(t, err)
+	assert.Equal(t, []abi.SectorID{sector(2), sector(3), sector(4)}, bad)
+
+	t.Run("fails when the index fails", func(t *testing.T) {
+		index.err = errors.New("index unavailable")
+		_, err := checker.CheckProvable(ctx, []abi.SectorID{sector(1)})
+		assert.Error(t, err)
+	})
+}
+
+// fakeIndex holds the files of sectors in storages.
+type fakeIndex struct {
+	stored map[abi.SectorID]map[stores.SectorFileType][]stores.ID
+	err    error
+}
+
+func (i *fakeIndex) StorageFindSector(_ context.Context, sector abi.SectorID, ft stores.SectorFileType, _ bool) ([]stores.StorageInfo, error) {
+	if i.err != nil {
+		return nil, i.err
+	}
+	var found []stores.StorageInfo
+	for _, id := range i.stored[sector][ft] {
+		found = append(found, stores.StorageInfo{ID: id})
+	}
+	return found, nil
+}
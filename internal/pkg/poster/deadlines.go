@@ -0,0 +1,224 @@
+package poster
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/encoding"
+)
+
+// deadlinePrefix is the datastore namespace deadline results are persisted under.
+var deadlinePrefix = datastore.NewKey("/poster/deadlines")
+
+// DeadlineStatus is the state of the window PoSt for a deadline.
+type DeadlineStatus string
+
+const (
+	// DeadlineProving is the status of a deadline whose PoSt is being generated or sent.
+	DeadlineProving = DeadlineStatus("proving")
+	// DeadlineSubmitted is the status of a deadline whose PoSt is sent but not yet on chain.
+	DeadlineSubmitted = DeadlineStatus("submitted")
+	// DeadlineProven is the status of a deadline whose PoSt is on chain.
+	DeadlineProven = DeadlineStatus("proven")
+	// DeadlineEmpty is the status of a deadline without sectors to prove.
+	DeadlineEmpty = DeadlineStatus("empty")
+	// DeadlineFailed is the status of a deadline whose PoSt could not be generated or sent.
+	DeadlineFailed = DeadlineStatus("failed")
+	// DeadlineMissed is the status of a deadline that closed before its PoSt landed on chain.
+	DeadlineMissed = DeadlineStatus("missed")
+)
+
+// DeadlineResult is the outcome of the window PoSt for a deadline.
+type DeadlineResult struct {
+	Index uint64
+	Open  abi.ChainEpoch
+	Close abi.ChainEpoch
+
+	Status DeadlineStatus
+	// Attempts is the number of times the PoSt was sent.
+	Attempts uint64
+	// Message is the last PoSt message sent, if any.
+	Message cid.Cid
+	// Error is the reason the last attempt failed, if it did.
+	Error string
+}
+
+// final returns whether the result will not change any more.
+func (r DeadlineResult) final() bool {
+	return r.Status == DeadlineEmpty || r.Status == DeadlineFailed || r.Status == DeadlineMissed
+}
+
+// ProvingInfo is the state of the miner's proving period at the head.
+type ProvingInfo struct {
+	Epoch       abi.ChainEpoch
+	PeriodStart abi.ChainEpoch
+
+	// The current deadline.
+	Deadline  uint64
+	Open      abi.ChainEpoch
+	Close     abi.ChainEpoch
+	Challenge abi.ChainEpoch
+	// Current is the result of the current deadline's PoSt, if it is being
+	// proven.
+	Current *DeadlineResult
+}
+
+// DeadlineSchedule is a deadline of the proving period, with its current or
+// next window and the last result recorded for it.
+type DeadlineSchedule struct {
+	Index uint64
+	Open  abi.ChainEpoch
+	Close abi.ChainEpoch
+	Last  *DeadlineResult
+}
+
+// ProvingInfo returns the state of the miner's proving period at the head.
+func (p *Poster) ProvingInfo(ctx context.Context) (ProvingInfo, error) {
+	head, err := p.chain.GetTipSet(p.chain.Head())
+	if err != nil {
+		return ProvingInfo{}, err
+	}
+	height, err := head.Height()
+	if err != nil {
+		return ProvingInfo{}, err
+	}
+	view, err := p.headView(ctx)
+	if err != nil {
+		return ProvingInfo{}, err
+	}
+	index, open, close, challenge, err := view.MinerDeadlineInfo(ctx, p.minerAddr, height)
+	if err != nil {
+		return ProvingInfo{}, err
+	}
+
+	info := ProvingInfo{
+		Epoch:       height,
+		PeriodStart: open - abi.ChainEpoch(index)*miner.WPoStChallengeWindow,
+		Deadline:    index,
+		Open:        open,
+		Close:       close,
+		Challenge:   challenge,
+	}
+
+	p.postMutex.Lock()
+	defer p.postMutex.Unlock()
+	if p.active != nil && p.active.result.Open == open {
+		current := p.active.result
+		info.Current = &current
+	}
+	return info, nil
+}
+
+// Deadlines returns the deadlines of the proving period, from the first, with
+// their current or upcoming window and their last recorded result.
+func (p *Poster) Deadlines(ctx context.Context) ([]DeadlineSchedule, error) {
+	info, err := p.ProvingInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results, err := p.results.list()
+	if err != nil {
+		return nil, err
+	}
+	last := make(map[uint64]DeadlineResult, len(results))
+	for _, result := range results {
+		last[result.Index] = result
+	}
+
+	schedule := make([]DeadlineSchedule, miner.WPoStPeriodDeadlines)
+	for i := range schedule {
+		index := uint64(i)
+		open := info.PeriodStart + abi.ChainEpoch(index)*miner.WPoStChallengeWindow
+		if index < info.Deadline {
+			// passed in this period, next open in the next
+			open += miner.WPoStProvingPeriod
+		}
+		schedule[i] = DeadlineSchedule{Index: index, Open: open, Close: open + miner.WPoStChallengeWindow}
+		if result, ok := last[index]; ok {
+			schedule[i].Last = &result
+		}
+	}
+	return schedule, nil
+}
+
+// deadlineRecord is the persisted form of a DeadlineResult.
+type deadlineRecord struct {
+	_        struct{} `cbor:",toarray"`
+	Index    uint64
+	Open     abi.ChainEpoch
+	Close    abi.ChainEpoch
+	Status   string
+	Attempts uint64
+	Message  string
+	Error    string
+}
+
+// deadlineStore persists the last result of each deadline of the proving
+// period.
+type deadlineStore struct {
+	ds datastore.Datastore
+}
+
+// put records the result as the last of its deadline.
+func (s *deadlineStore) put(result DeadlineResult) error {
+	record := deadlineRecord{
+		Index:    result.Index,
+		Open:     result.Open,
+		Close:    result.Close,
+		Status:   string(result.Status),
+		Attempts: result.Attempts,
+		Error:    result.Error,
+	}
+	if result.Message.Defined() {
+		record.Message = result.Message.String()
+	}
+	val, err := encoding.Encode(record)
+	if err != nil {
+		return err
+	}
+	return s.ds.Put(deadlinePrefix.ChildString(strconv.FormatUint(result.Index, 10)), val)
+}
+
+// list returns the last result recorded of each deadline, by deadline index.
+func (s *deadlineStore) list() ([]DeadlineResult, error) {
+	results, err := s.ds.Query(query.Query{Prefix: deadlinePrefix.String()})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to query deadline results")
+	}
+	defer results.Close() // nolint: errcheck
+
+	var out []DeadlineResult
+	for entry := range results.Next() {
+		if entry.Error != nil {
+			return nil, errors.Wrap(entry.Error, "failed to read deadline results")
+		}
+		var record deadlineRecord
+		if err := encoding.Decode(entry.Value, &record); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode deadline result %s", entry.Key)
+		}
+		result := DeadlineResult{
+			Index:    record.Index,
+			Open:     record.Open,
+			Close:    record.Close,
+			Status:   DeadlineStatus(record.Status),
+			Attempts: record.Attempts,
+			Error:    record.Error,
+		}
+		if record.Message != "" {
+			if result.Message, err = cid.Decode(record.Message); err != nil {
+				return nil, errors.Wrapf(err, "failed to decode message of deadline result %s", entry.Key)
+			}
+		}
+		out = append(out, result)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Index < out[j].Index })
+	return out, nil
+}
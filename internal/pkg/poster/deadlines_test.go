@@ -0,0 +1,40 @@
+package poster
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+)
+
+func TestDeadlineStoreKeepsLastResultOfEachDeadline(t *testing.T) {
+	tf.UnitTest(t)
+	store := &deadlineStore{ds: datastore.NewMapDatastore()}
+
+	proving := DeadlineResult{Index: 3, Open: 100, Close: 120, Status: DeadlineProving}
+	require.NoError(t, store.put(proving))
+	empty := DeadlineResult{Index: 1, Open: 60, Close: 80, Status: DeadlineEmpty}
+	require.NoError(t, store.put(empty))
+
+	// A later result of the same deadline replaces the earlier.
+	proven := DeadlineResult{
+		Index:    3,
+		Open:     100,
+		Close:    120,
+		Status:   DeadlineProven,
+		Attempts: 2,
+		Message:  types.CidFromString(t, "post"),
+		Error:    "not mined within 5 epochs",
+	}
+	require.NoError(t, store.put(proven))
+
+	results, err := store.list()
+	require.NoError(t, err)
+	assert.Equal(t, []DeadlineResult{empty, proven}, results)
+	assert.Equal(t, cid.Undef, results[0].Message)
+}
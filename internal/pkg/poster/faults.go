@@ -0,0 +1,289 @@
+package poster
+
+import (
+	"context"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+)
+
+// faultDeclarationLookahead is the number of deadlines past the current one
+// that faults and recoveries are declared for, so that the declarations land
+// on chain before the deadline's fault declaration cutoff.
+const faultDeclarationLookahead = 2
+
+// SectorHealth is the fault status of a sector of the miner.
+type SectorHealth struct {
+	Sector   abi.SectorNumber
+	Deadline uint64
+	// Faulty is set when the sector is declared faulty on chain.
+	Faulty bool
+	// Recovering is set when the sector is declared recovered on chain, to
+	// be proven at its next deadline.
+	Recovering bool
+	// Provable is set when the sector passes the local health check.
+	Provable bool
+}
+
+// SectorFaults returns the health of the miner's sectors that are declared
+// faulty or recovering, or that fail the local health check.
+func (p *Poster) SectorFaults(ctx context.Context) ([]SectorHealth, error) {
+	view, err := p.headView(ctx)
+	if err != nil {
+		return nil, err
+	}
+	health, err := p.sectorHealth(ctx, view, func(uint64) bool { return true })
+	if err != nil {
+		return nil, err
+	}
+
+	var faults []SectorHealth
+	for _, h := range health {
+		if h.Faulty || h.Recovering || !h.Provable {
+			faults = append(faults, h)
+		}
+	}
+	return faults, nil
+}
+
+// DeclareFaults declares the sectors faulty at their deadlines, returning the
+// cid of the declaration message.
+func (p *Poster) DeclareFaults(ctx context.Context, sectors []abi.SectorNumber) (cid.Cid, error) {
+	view, err := p.headView(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	byDeadline, err := p.sectorsByDeadline(ctx, view, sectors)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	params := &miner.DeclareFaultsParams{}
+	for _, deadline := range sortedDeadlines(byDeadline) {
+		params.Faults = append(params.Faults, miner.FaultDeclaration{
+			Deadline: deadline,
+			Sectors:  byDeadline[deadline],
+		})
+	}
+	return p.sendDeclaration(ctx, view, builtin.MethodsMiner.DeclareFaults, params)
+}
+
+// DeclareRecoveries declares the faulty sectors recovered at their deadlines,
+// returning the cid of the declaration message.
+func (p *Poster) DeclareRecoveries(ctx context.Context, sectors []abi.SectorNumber) (cid.Cid, error) {
+	view, err := p.headView(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	byDeadline, err := p.sectorsByDeadline(ctx, view, sectors)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	params := &miner.DeclareFaultsRecoveredParams{}
+	for _, deadline := range sortedDeadlines(byDeadline) {
+		params.Recoveries = append(params.Recoveries, miner.RecoveryDeclaration{
+			Deadline: deadline,
+			Sectors:  byDeadline[deadline],
+		})
+	}
+	return p.sendDeclaration(ctx, view, builtin.MethodsMiner.DeclareFaultsRecovered, params)
+}
+
+// checkDeadlineFaults checks the faults of the deadline for the deadline
+// open at the epoch, marking them checked for it when the check succeeds.
+func (p *Poster) checkDeadlineFaults(ctx context.Context, view minerView, open abi.ChainEpoch, deadline uint64) {
+	err := p.checkFaults(ctx, view, deadline)
+
+	p.postMutex.Lock()
+	defer p.postMutex.Unlock()
+	p.checkingFaults = false
+	if err != nil {
+		log.Errorf("error checking faults at deadline %d, checking again with the next head: %s", deadline, err)
+		return
+	}
+	p.faultsCheckedAt = open
+}
+
+// checkFaults checks the health of the sectors due at the deadline, declaring
+// faults for those failing the check and recoveries for the faulty ones
+// passing it.
+func (p *Poster) checkFaults(ctx context.Context, view minerView, deadline uint64) error {
+	health, err := p.sectorHealth(ctx, view, func(d uint64) bool { return d == deadline })
+	if err != nil {
+		return errors.Wrapf(err, "failed to check health of sectors due at deadline %d", deadline)
+	}
+
+	var faults, recoveries []abi.SectorNumber
+	for _, h := range health {
+		if !h.Provable && (!h.Faulty || h.Recovering) {
+			faults = append(faults, h.Sector)
+		}
+		if h.Provable && h.Faulty && !h.Recovering {
+			recoveries = append(recoveries, h.Sector)
+		}
+	}
+
+	if len(faults) > 0 {
+		log.Warnf("declaring %d sectors faulty at deadline %d: %v", len(faults), deadline, faults)
+		if _, err := p.DeclareFaults(ctx, faults); err != nil {
+			return errors.Wrapf(err, "failed to declare faults at deadline %d", deadline)
+		}
+	}
+	if len(recoveries) > 0 {
+		log.Infof("declaring %d sectors recovered at deadline %d: %v", len(recoveries), deadline, recoveries)
+		if _, err := p.DeclareRecoveries(ctx, recoveries); err != nil {
+			return errors.Wrapf(err, "failed to declare recoveries at deadline %d", deadline)
+		}
+	}
+	return nil
+}
+
+// sectorHealth returns the health of the miner's sectors due at the deadlines
+// included, checking that each can be proven with the sector checker.
+func (p *Poster) sectorHealth(ctx context.Context, view minerView, include func(deadline uint64) bool) ([]SectorHealth, error) {
+	minerID, err := address.IDFromAddress(p.minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	due, faults, recoveries, err := view.MinerDeadlineSectors(ctx, p.minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	faulty := sectorSet(faults)
+	recovering := sectorSet(recoveries)
+
+	var health []SectorHealth
+	var ids []abi.SectorID
+	for deadline, sectors := range due {
+		if !include(uint64(deadline)) {
+			continue
+		}
+		for _, sector := range sectors {
+			health = append(health, SectorHealth{
+				Sector:     abi.SectorNumber(sector),
+				Deadline:   uint64(deadline),
+				Faulty:     faulty[sector],
+				Recovering: recovering[sector],
+				Provable:   true,
+			})
+			ids = append(ids, abi.SectorID{Miner: abi.ActorID(minerID), Number: abi.SectorNumber(sector)})
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	bad, err := p.checker.CheckProvable(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check sectors are provable")
+	}
+	unprovable := make(map[abi.SectorNumber]bool, len(bad))
+	for _, id := range bad {
+		unprovable[id.Number] = true
+	}
+	for i := range health {
+		health[i].Provable = !unprovable[health[i].Sector]
+	}
+	return health, nil
+}
+
+// sectorsByDeadline groups the sectors by the deadline they are due at.
+func (p *Poster) sectorsByDeadline(ctx context.Context, view minerView, sectors []abi.SectorNumber) (map[uint64]*abi.BitField, error) {
+	due, _, _, err := view.MinerDeadlineSectors(ctx, p.minerAddr)
+	if err != nil {
+		return nil, err
+	}
+	deadlines := make(map[uint64]uint64)
+	for deadline, dueSectors := range due {
+		for _, sector := range dueSectors {
+			deadlines[sector] = uint64(deadline)
+		}
+	}
+
+	grouped := make(map[uint64][]uint64)
+	for _, sector := range sectors {
+		deadline, ok := deadlines[uint64(sector)]
+		if !ok {
+			return nil, errors.Errorf("sector %d is not due at any deadline of miner %s", sector, p.minerAddr)
+		}
+		grouped[deadline] = append(grouped[deadline], uint64(sector))
+	}
+
+	byDeadline := make(map[uint64]*abi.BitField, len(grouped))
+	for deadline, set := range grouped {
+		bf, err := bitfield.NewFromSet(set)
+		if err != nil {
+			return nil, err
+		}
+		byDeadline[deadline] = bf
+	}
+	return byDeadline, nil
+}
+
+// sendDeclaration sends a fault or recovery declaration from the miner's
+// worker, with an estimated gas limit at the suggested gas price, returning
+// once the outbox has published it.
+func (p *Poster) sendDeclaration(ctx context.Context, view minerView, method abi.MethodNum, params interface{}) (cid.Cid, error) {
+	_, workerAddr, err := view.MinerControlAddresses(ctx, p.minerAddr)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	gasLimit, err := p.estimator.MessageEstimateGasLimit(ctx, workerAddr, p.minerAddr, types.ZeroAttoFIL, method, params)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to estimate gas of declaration")
+	}
+	gasPrice, err := p.estimator.MessageSuggestGasPrice(ctx)
+	if err != nil {
+		return cid.Undef, errors.Wrap(err, "failed to suggest gas price of declaration")
+	}
+
+	mcid, errCh, err := p.outbox.Send(
+		ctx,
+		workerAddr,
+		p.minerAddr,
+		types.ZeroAttoFIL,
+		gasPrice,
+		gasLimit,
+		true,
+		method,
+		params,
+	)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := <-errCh; err != nil {
+		return cid.Undef, err
+	}
+	return mcid, nil
+}
+
+func (p *Poster) headView(ctx context.Context) (minerView, error) {
+	return p.stateAt(ctx, p.chain.Head())
+}
+
+func sectorSet(sectors []uint64) map[uint64]bool {
+	set := make(map[uint64]bool, len(sectors))
+	for _, sector := range sectors {
+		set[sector] = true
+	}
+	return set
+}
+
+func sortedDeadlines(byDeadline map[uint64]*abi.BitField) []uint64 {
+	deadlines := make([]uint64, 0, len(byDeadline))
+	for deadline := range byDeadline {
+		deadlines = append(deadlines, deadline)
+	}
+	sort.Slice(deadlines, func(i, j int) bool { return deadlines[i] < deadlines[j] })
+	return deadlines
+}
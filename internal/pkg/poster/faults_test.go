@@ -0,0 +1,445 @@
+package poster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
+)
+
+func TestCheckFaults(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	p, view, deps := newTestPoster(t)
+
+	// Deadline 0 holds a healthy sector (1), a sector turned unprovable (2),
+	// an already faulty one still unprovable (3), a recovering one unprovable
+	// again (4), a faulty one provable again (5) and a recovering one still
+	// provable (6). Deadline 1 holds an unprovable sector (7).
+	view.due = [][]uint64{{1, 2, 3, 4, 5, 6}, {7}}
+	view.faults = []uint64{3, 4, 5, 6}
+	view.recoveries = []uint64{4, 6}
+	deps.checker.bad = map[abi.SectorNumber]bool{2: true, 3: true, 4: true, 7: true}
+
+	require.NoError(t, p.checkFaults(ctx, view, 0))
+
+	assert.Equal(t, [][]abi.SectorNumber{{1, 2, 3, 4, 5, 6}}, deps.checker.checked)
+	sent := deps.outbox.sent
+	require.Len(t, sent, 2)
+
+	assert.Equal(t, builtin.MethodsMiner.DeclareFaults, sent[0].method)
+	faults := sent[0].params.(*miner.DeclareFaultsParams).Faults
+	require.Len(t, faults, 1)
+	assert.Equal(t, uint64(0), faults[0].Deadline)
+	assert.Equal(t, []uint64{2, 4}, bitfieldSectors(t, faults[0].Sectors))
+
+	assert.Equal(t, builtin.MethodsMiner.DeclareFaultsRecovered, sent[1].method)
+	recoveries := sent[1].params.(*miner.DeclareFaultsRecoveredParams).Recoveries
+	require.Len(t, recoveries, 1)
+	assert.Equal(t, uint64(0), recoveries[0].Deadline)
+	assert.Equal(t, []uint64{5}, bitfieldSectors(t, recoveries[0].Sectors))
+
+	for _, m := range sent {
+		assert.Equal(t, view.worker, m.from)
+		assert.Equal(t, p.minerAddr, m.to)
+		assert.Equal(t, deps.estimator.limit, m.gasLimit)
+		assert.Equal(t, deps.estimator.price, m.gasPrice)
+	}
+
+	t.Run("declines to declare already faulty sectors", func(t *testing.T) {
+		deps.outbox.sent = nil
+		view.due = [][]uint64{{3, 6}}
+		deps.checker.bad = map[abi.SectorNumber]bool{3: true}
+
+		require.NoError(t, p.checkFaults(ctx, view, 0))
+		assert.Empty(t, deps.outbox.sent)
+	})
+}
+
+func TestCheckFaultsAgainAfterFailing(t *testing.T) {
+	tf.UnitTest(t)
+	p, deps, handle := newProvingPoster(t)
+	defer p.StopPoSting()
+	deps.view.due = [][]uint64{{}, {}, {}, {}, {}, {1}}
+	deps.checker.setErr(errors.New("index unavailable"))
+
+	handle(100)
+	requireFaultsChecked(t, p, -1)
+	assert.Len(t, deps.checker.checkedDeadlines(), 0)
+
+	deps.checker.setErr(nil)
+	handle(101)
+	requireFaultsChecked(t, p, 100)
+	assert.Equal(t, [][]abi.SectorNumber{{1}}, deps.checker.checkedDeadlines())
+
+	// checked once per deadline
+	handle(102)
+	requireFaultsChecked(t, p, 100)
+	assert.Len(t, deps.checker.checkedDeadlines(), 1)
+}
+
+// requireFaultsChecked waits for the fault check under way to finish with
+// faults checked for the deadline open at the epoch.
+func requireFaultsChecked(t *testing.T, p *Poster, open abi.ChainEpoch) {
+	require.Eventually(t, func() bool {
+		p.postMutex.Lock()
+		defer p.postMutex.Unlock()
+		return !p.checkingFaults && p.faultsCheckedAt == open
+	}, time.Second, time.Millisecond)
+}
+
+func TestSectorHealth(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	p, view, deps := newTestPoster(t)
+	view.due = [][]uint64{{1, 2}, {3}, {}, {4}}
+	view.faults = []uint64{2, 3}
+	view.recoveries = []uint64{3}
+	deps.checker.bad = map[abi.SectorNumber]bool{2: true, 4: true}
+
+	health, err := p.sectorHealth(ctx, view, func(uint64) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, []SectorHealth{
+		{Sector: 1, Deadline: 0, Provable: true},
+		{Sector: 2, Deadline: 0, Faulty: true},
+		{Sector: 3, Deadline: 1, Faulty: true, Recovering: true, Provable: true},
+		{Sector: 4, Deadline: 3},
+	}, health)
+
+	faults, err := p.SectorFaults(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, health[1:], faults)
+
+	t.Run("checks nothing without sectors due", func(t *testing.T) {
+		deps.checker.checked = nil
+		health, err := p.sectorHealth(ctx, view, func(d uint64) bool { return d == 2 })
+		require.NoError(t, err)
+		assert.Empty(t, health)
+		assert.Empty(t, deps.checker.checked)
+	})
+
+	t.Run("fails when the check fails", func(t *testing.T) {
+		deps.checker.err = errors.New("no storage paths")
+		_, err := p.sectorHealth(ctx, view, func(uint64) bool { return true })
+		assert.Error(t, err)
+	})
+}
+
+func TestSectorsByDeadline(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	p, view, _ := newTestPoster(t)
+	view.due = [][]uint64{{1, 2}, {}, {3, 4, 5}}
+
+	byDeadline, err := p.sectorsByDeadline(ctx, view, []abi.SectorNumber{5, 1, 3})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{0, 2}, sortedDeadlines(byDeadline))
+	assert.Equal(t, []uint64{1}, bitfieldSectors(t, byDeadline[0]))
+	assert.Equal(t, []uint64{3, 5}, bitfieldSectors(t, byDeadline[2]))
+
+	_, err = p.sectorsByDeadline(ctx, view, []abi.SectorNumber{1, 6})
+	assert.Error(t, err)
+}
+
+func TestDeclareFaultsAndRecoveries(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	p, view, deps := newTestPoster(t)
+	view.due = [][]uint64{{1, 2}, {}, {3}}
+
+	mcid, err := p.DeclareFaults(ctx, []abi.SectorNumber{3, 1, 2})
+	require.NoError(t, err)
+	require.Len(t, deps.outbox.sent, 1)
+	assert.Equal(t, deps.outbox.sent[0].cid, mcid)
+	faults := deps.outbox.sent[0].params.(*miner.DeclareFaultsParams).Faults
+	require.Len(t, faults, 2)
+	assert.Equal(t, uint64(0), faults[0].Deadline)
+	assert.Equal(t, []uint64{1, 2}, bitfieldSectors(t, faults[0].Sectors))
+	assert.Equal(t, uint64(2), faults[1].Deadline)
+	assert.Equal(t, []uint64{3}, bitfieldSectors(t, faults[1].Sectors))
+
+	mcid, err = p.DeclareRecoveries(ctx, []abi.SectorNumber{3})
+	require.NoError(t, err)
+	require.Len(t, deps.outbox.sent, 2)
+	assert.Equal(t, deps.outbox.sent[1].cid, mcid)
+	assert.Equal(t, builtin.MethodsMiner.DeclareFaultsRecovered, deps.outbox.sent[1].method)
+	recoveries := deps.outbox.sent[1].params.(*miner.DeclareFaultsRecoveredParams).Recoveries
+	require.Len(t, recoveries, 1)
+	assert.Equal(t, uint64(2), recoveries[0].Deadline)
+
+	t.Run("declares nothing for sectors not due", func(t *testing.T) {
+		deps.outbox.sent = nil
+		_, err := p.DeclareFaults(ctx, []abi.SectorNumber{1, 9})
+		assert.Error(t, err)
+		_, err = p.DeclareRecoveries(ctx, []abi.SectorNumber{9})
+		assert.Error(t, err)
+		assert.Empty(t, deps.outbox.sent)
+	})
+}
+
+func TestSendDeclaration(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	params := &miner.DeclareFaultsParams{}
+
+	t.Run("fails without sending when gas cannot be estimated", func(t *testing.T) {
+		p, view, deps := newTestPoster(t)
+		deps.estimator.err = errors.New("exit code 16")
+
+		_, err := p.sendDeclaration(ctx, view, builtin.MethodsMiner.DeclareFaults, params)
+		assert.Error(t, err)
+		assert.Empty(t, deps.outbox.sent)
+	})
+
+	t.Run("fails when the message is not published", func(t *testing.T) {
+		p, view, deps := newTestPoster(t)
+		deps.outbox.publishErr = errors.New("no peers")
+
+		_, err := p.sendDeclaration(ctx, view, builtin.MethodsMiner.DeclareFaults, params)
+		assert.Error(t, err)
+	})
+}
+
+// testDeps are the fakes a test poster is built on.
+type testDeps struct {
+	chain     *fakeChain
+	outbox    *fakeOutbox
+	waiter    *fakeWaiter
+	prover    *fakeProver
+	checker   *fakeChecker
+	estimator *fakeEstimator
+}
+
+func newTestPoster(t *testing.T) (*Poster, *fakeView, *testDeps) {
+	view := &fakeView{
+		close:  miner.WPoStChallengeWindow,
+		worker: vmaddr.RequireIDAddress(t, 101),
+	}
+	deps := &testDeps{
+		chain:     &fakeChain{},
+		outbox:    &fakeOutbox{newCid: types.NewCidForTestGetter()},
+		waiter:    &fakeWaiter{},
+		prover:    &fakeProver{},
+		checker:   &fakeChecker{},
+		estimator: &fakeEstimator{limit: gas.NewGas(1234), price: types.NewGasPrice(3)},
+	}
+	p := NewPoster(vmaddr.RequireIDAddress(t, 100), deps.outbox, deps.prover, deps.checker, nil, nil, deps.waiter, deps.estimator, datastore.NewMapDatastore())
+	p.chain = deps.chain
+	p.stateAt = func(context.Context, block.TipSetKey) (minerView, error) {
+		return view, nil
+	}
+	return p, view, deps
+}
+
+func bitfieldSectors(t *testing.T, bf *abi.BitField) []uint64 {
+	sectors, err := bf.All(miner.SectorsMax)
+	require.NoError(t, err)
+	return sectors
+}
+
+// fakeChain serves the head tipset and constant challenge randomness.
+type fakeChain struct {
+	head       block.TipSet
+	randomness abi.Randomness
+}
+
+func (c *fakeChain) Head() block.TipSetKey {
+	return c.head.Key()
+}
+
+func (c *fakeChain) GetTipSet(block.TipSetKey) (block.TipSet, error) {
+	return c.head, nil
+}
+
+func (c *fakeChain) SampleChainRandomness(context.Context, block.TipSetKey, acrypto.DomainSeparationTag, abi.ChainEpoch, []byte) (abi.Randomness, error) {
+	return c.randomness, nil
+}
+
+// fakeView is the state of the miner at every tipset.
+type fakeView struct {
+	deadline               uint64
+	open, close, challenge abi.ChainEpoch
+	partitions             []uint64
+	sectors                []abi.SectorInfo
+	worker                 address.Address
+	due                    [][]uint64
+	faults, recoveries     []uint64
+}
+
+func (v *fakeView) MinerDeadlineInfo(context.Context, address.Address, abi.ChainEpoch) (uint64, abi.ChainEpoch, abi.ChainEpoch, abi.ChainEpoch, error) {
+	return v.deadline, v.open, v.close, v.challenge, nil
+}
+
+func (v *fakeView) MinerPartitionIndicesForDeadline(context.Context, address.Address, uint64) ([]uint64, error) {
+	return v.partitions, nil
+}
+
+func (v *fakeView) MinerSectorInfoForDeadline(context.Context, address.Address, uint64, []uint64) ([]abi.SectorInfo, error) {
+	return v.sectors, nil
+}
+
+func (v *fakeView) MinerControlAddresses(_ context.Context, maddr address.Address) (address.Address, address.Address, error) {
+	return maddr, v.worker, nil
+}
+
+func (v *fakeView) MinerDeadlineSectors(context.Context, address.Address) ([][]uint64, []uint64, []uint64, error) {
+	return v.due, v.faults, v.recoveries, nil
+}
+
+type sentMessage struct {
+	cid      cid.Cid
+	from, to address.Address
+	gasPrice types.AttoFIL
+	gasLimit gas.Unit
+	method   abi.MethodNum
+	params   interface{}
+	// replaced is the message this one replaced, if any.
+	replaced cid.Cid
+}
+
+// fakeOutbox records the messages sent, publishing them unless publishErr is
+// set.
+type fakeOutbox struct {
+	lk         sync.Mutex
+	sent       []sentMessage
+	publishErr error
+	newCid     func() cid.Cid
+}
+
+func (o *fakeOutbox) Send(_ context.Context, from, to address.Address, _ types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, _ bool, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	c := o.newCid()
+	o.sent = append(o.sent, sentMessage{cid: c, from: from, to: to, gasPrice: gasPrice, gasLimit: gasLimit, method: method, params: params})
+	errCh := make(chan error, 1)
+	errCh <- o.publishErr
+	return c, errCh, nil
+}
+
+// Replace records the replacement of a message sent before with the same
+// method and params.
+func (o *fakeOutbox) Replace(_ context.Context, from address.Address, replaced cid.Cid, gasPrice types.AttoFIL, gasLimit gas.Unit, _ bool) (cid.Cid, chan error, error) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	for _, m := range o.sent {
+		if !m.cid.Equals(replaced) {
+			continue
+		}
+		c := o.newCid()
+		o.sent = append(o.sent, sentMessage{cid: c, from: from, to: m.to, gasPrice: gasPrice, gasLimit: gasLimit, method: m.method, params: m.params, replaced: replaced})
+		errCh := make(chan error, 1)
+		errCh <- o.publishErr
+		return c, errCh, nil
+	}
+	return cid.Undef, nil, errors.Errorf("message %s is not queued", replaced)
+}
+
+// fakeWaiter finds the messages mined with their exit codes.
+type fakeWaiter struct {
+	lk       sync.Mutex
+	mined    map[cid.Cid]*msg.ChainMessage
+	searched []uint64
+}
+
+func (w *fakeWaiter) Find(_ context.Context, lookback uint64, pred msg.WaitPredicate) (*msg.ChainMessage, bool, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	w.searched = append(w.searched, lookback)
+	for c, found := range w.mined {
+		if pred(found.Message, c) {
+			return found, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// fakeProver generates an empty proof, once release is closed if it is set.
+type fakeProver struct {
+	release chan struct{}
+}
+
+func (p *fakeProver) GenerateWindowPoSt(ctx context.Context, _ abi.ActorID, _ []abi.SectorInfo, _ abi.PoStRandomness) ([]abi.PoStProof, error) {
+	if p.release != nil {
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return []abi.PoStProof{{}}, nil
+}
+
+// fakeChecker fails the check of the bad sectors, recording the sectors
+// checked.
+type fakeChecker struct {
+	lk      sync.Mutex
+	bad     map[abi.SectorNumber]bool
+	err     error
+	checked [][]abi.SectorNumber
+}
+
+func (c *fakeChecker) setErr(err error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	c.err = err
+}
+
+func (c *fakeChecker) checkedDeadlines() [][]abi.SectorNumber {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	return append([][]abi.SectorNumber{}, c.checked...)
+}
+
+func (c *fakeChecker) CheckProvable(_ context.Context, sectors []abi.SectorID) ([]abi.SectorID, error) {
+	c.lk.Lock()
+	defer c.lk.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	var checked []abi.SectorNumber
+	var bad []abi.SectorID
+	for _, sector := range sectors {
+		checked = append(checked, sector.Number)
+		if c.bad[sector.Number] {
+			bad = append(bad, sector)
+		}
+	}
+	c.checked = append(c.checked, checked)
+	return bad, nil
+}
+
+// fakeEstimator estimates a constant gas limit and price.
+type fakeEstimator struct {
+	lk    sync.Mutex
+	limit gas.Unit
+	price types.AttoFIL
+	err   error
+}
+
+func (e *fakeEstimator) MessageEstimateGasLimit(context.Context, address.Address, address.Address, types.AttoFIL, abi.MethodNum, interface{}) (gas.Unit, error) {
+	return e.limit, e.err
+}
+
+func (e *fakeEstimator) MessageSuggestGasPrice(context.Context) (types.AttoFIL, error) {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	return e.price, nil
+}
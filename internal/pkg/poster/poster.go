@@ -3,67 +3,209 @@ package poster
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"sync"
 
-	"github.com/sbwtw/go-filecoin/internal/pkg/types"
-	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
-	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/abi/big"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log"
-
-	"github.com/filecoin-project/go-address"
-	sectorstorage "github.com/filecoin-project/sector-storage"
+	"github.com/pkg/errors"
 
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/cst"
 	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/msg"
 	"github.com/sbwtw/go-filecoin/internal/pkg/block"
-	"github.com/sbwtw/go-filecoin/internal/pkg/message"
 	appstate "github.com/sbwtw/go-filecoin/internal/pkg/state"
-	"github.com/filecoin-project/specs-actors/actors/abi"
-	acrypto "github.com/filecoin-project/specs-actors/actors/crypto"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/gas"
 )
 
 var log = logging.Logger("poster")
 
+// postRetryEpochs is the number of epochs a sent PoSt may stay off chain
+// before it is sent again.
+const postRetryEpochs = abi.ChainEpoch(5)
+
+// maxPoStAttempts is the number of times the PoSt of a deadline is sent at
+// most. Each attempt doubles the suggested gas price.
+const maxPoStAttempts = 5
+
+type chainState interface {
+	Head() block.TipSetKey
+	GetTipSet(block.TipSetKey) (block.TipSet, error)
+	SampleChainRandomness(ctx context.Context, head block.TipSetKey, tag acrypto.DomainSeparationTag, epoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error)
+}
+
+type minerView interface {
+	MinerDeadlineInfo(ctx context.Context, maddr address.Address, epoch abi.ChainEpoch) (index uint64, open, close, challenge abi.ChainEpoch, _ error)
+	MinerPartitionIndicesForDeadline(ctx context.Context, maddr address.Address, deadlineIndex uint64) ([]uint64, error)
+	MinerSectorInfoForDeadline(ctx context.Context, maddr address.Address, deadlineIndex uint64, partitions []uint64) ([]abi.SectorInfo, error)
+	MinerControlAddresses(ctx context.Context, maddr address.Address) (owner, worker address.Address, err error)
+	MinerDeadlineSectors(ctx context.Context, maddr address.Address) (due [][]uint64, faults, recoveries []uint64, err error)
+}
+
+type messageSender interface {
+	Send(ctx context.Context, from, to address.Address, value types.AttoFIL, gasPrice types.AttoFIL, gasLimit gas.Unit, bcast bool, method abi.MethodNum, params interface{}) (cid.Cid, chan error, error)
+	Replace(ctx context.Context, from address.Address, replaced cid.Cid, gasPrice types.AttoFIL, gasLimit gas.Unit, bcast bool) (cid.Cid, chan error, error)
+}
+
+type messageFinder interface {
+	Find(ctx context.Context, lookback uint64, pred msg.WaitPredicate) (*msg.ChainMessage, bool, error)
+}
+
+// GasEstimator estimates the gas limit of the messages the poster sends and
+// suggests a gas price for them.
+type GasEstimator interface {
+	MessageEstimateGasLimit(ctx context.Context, from, to address.Address, value types.AttoFIL, method abi.MethodNum, params interface{}) (gas.Unit, error)
+	MessageSuggestGasPrice(ctx context.Context) (types.AttoFIL, error)
+}
+
+type windowProver interface {
+	GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []abi.SectorInfo, randomness abi.PoStRandomness) ([]abi.PoStProof, error)
+}
+
 // Poster listens for changes to the chain head and generates and submits a PoSt if one is required.
+// It tracks the PoSt of the current deadline until the deadline closes,
+// sending it again when it fails or does not land on chain in time, and
+// records the outcome of each deadline.
 type Poster struct {
-	postMutex      sync.Mutex
-	postCancel     context.CancelFunc
-	scheduleCancel context.CancelFunc
-	challenge      abi.Randomness
+	postMutex sync.Mutex
+	// active is the deadline being proven, nil until the first is.
+	active *activeDeadline
+	// height is the height of the last head handled.
+	height abi.ChainEpoch
+	// faultsCheckedAt is the opening epoch of the deadline faults were last
+	// checked in.
+	faultsCheckedAt abi.ChainEpoch
+	// checkingFaults is set while faults are checked.
+	checkingFaults bool
+
+	minerAddr address.Address
+	outbox    messageSender
+	prover    windowProver
+	checker   SectorChecker
+	chain     chainState
+	stateAt   func(ctx context.Context, tsk block.TipSetKey) (minerView, error)
+	waiter    messageFinder
+	estimator GasEstimator
+	results   *deadlineStore
+}
 
-	minerAddr   address.Address
-	outbox      *message.Outbox
-	mgr         sectorstorage.SectorManager
-	chain       *cst.ChainStateReadWriter
-	stateViewer *appstate.Viewer
-	waiter      *msg.Waiter
+// activeDeadline is the state of the PoSt of the current deadline.
+type activeDeadline struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	result    DeadlineResult
+	challenge abi.Randomness
+
+	// sending is set while the PoSt is generated or sent.
+	sending bool
+	// sentAt is the head height when the PoSt was last sent.
+	sentAt abi.ChainEpoch
+	// sent are the messages sent with the nonce of the last attempt, each
+	// replacing the one before. Whichever is mined proves the deadline.
+	sent []cid.Cid
+	// gasPrice is the gas price of the last message sent.
+	gasPrice types.AttoFIL
+	// replace is set when the next attempt replaces the last message, which
+	// was not mined in time, rather than sending a new one.
+	replace bool
+
+	// The generated PoSt, kept to be sent again.
+	worker     address.Address
+	partitions []uint64
+	proofs     []abi.PoStProof
 }
 
 // NewPoster creates a Poster struct
 func NewPoster(
 	minerAddr address.Address,
-	outbox *message.Outbox,
-	mgr sectorstorage.SectorManager,
+	outbox messageSender,
+	prover windowProver,
+	checker SectorChecker,
 	chain *cst.ChainStateReadWriter,
 	stateViewer *appstate.Viewer,
-	waiter *msg.Waiter) *Poster {
+	waiter messageFinder,
+	estimator GasEstimator,
+	ds datastore.Datastore) *Poster {
 
 	return &Poster{
-		minerAddr:   minerAddr,
-		outbox:      outbox,
-		mgr:         mgr,
-		chain:       chain,
-		stateViewer: stateViewer,
-		waiter:      waiter,
-		challenge:   abi.Randomness{},
+		minerAddr: minerAddr,
+		outbox:    outbox,
+		prover:    prover,
+		checker:   checker,
+		chain:     chain,
+		stateAt: func(ctx context.Context, tsk block.TipSetKey) (minerView, error) {
+			root, err := chain.GetTipSetStateRoot(ctx, tsk)
+			if err != nil {
+				return nil, err
+			}
+			return stateViewer.StateView(root), nil
+		},
+		waiter:    waiter,
+		estimator: estimator,
+		results:   &deadlineStore{ds: ds},
+
+		faultsCheckedAt: -1,
 	}
 }
 
 // HandleNewHead submits a new chain head for possible fallback PoSt.
 func (p *Poster) HandleNewHead(ctx context.Context, newHead block.TipSet) error {
-	return p.startPoStIfNeeded(ctx, newHead)
+	tipsetHeight, err := newHead.Height()
+	if err != nil {
+		return err
+	}
+	stateView, err := p.stateAt(ctx, newHead.Key())
+	if err != nil {
+		return err
+	}
+	index, open, close, challengeAt, err := stateView.MinerDeadlineInfo(ctx, p.minerAddr, tipsetHeight)
+	if err != nil {
+		return err
+	}
+
+	p.postMutex.Lock()
+	p.height = tipsetHeight
+
+	// exit if we haven't yet hit the deadline
+	if tipsetHeight < open {
+		p.postMutex.Unlock()
+		return nil
+	}
+
+	// once per deadline, check the health of the sectors due a few deadlines
+	// ahead, in time to declare their faults and recoveries. A failed check
+	// is tried again with the next head.
+	if open != p.faultsCheckedAt && !p.checkingFaults {
+		p.checkingFaults = true
+		go p.checkDeadlineFaults(ctx, stateView, open, (index+faultDeclarationLookahead)%miner.WPoStPeriodDeadlines)
+	}
+	p.postMutex.Unlock()
+
+	randomness, err := p.getChallenge(ctx, newHead.Key(), challengeAt)
+	if err != nil {
+		return err
+	}
+
+	// A new deadline, or a reorg of the chain to a point prior to the
+	// challenge of the current one, starts a new PoSt. Otherwise the PoSt
+	// under way is followed up.
+	p.postMutex.Lock()
+	if p.active == nil || p.active.result.Open != open || !bytes.Equal(p.active.challenge, randomness) {
+		p.startDeadline(ctx, stateView, DeadlineResult{Index: index, Open: open, Close: close, Status: DeadlineProving}, randomness)
+		p.postMutex.Unlock()
+		return nil
+	}
+	dl := p.active
+	p.postMutex.Unlock()
+	return p.followUp(dl)
 }
 
 // StopPoSting stops the posting scheduler if running and any outstanding PoSts.
@@ -71,114 +213,238 @@ func (p *Poster) StopPoSting() {
 	p.postMutex.Lock()
 	defer p.postMutex.Unlock()
 
-	if p.scheduleCancel != nil {
-		p.postCancel()
+	if p.active != nil {
+		p.active.cancel()
+		p.active = nil
+	}
+}
 
-		p.scheduleCancel()
-		p.scheduleCancel = nil
+// startDeadline abandons the PoSt under way and starts proving the deadline.
+func (p *Poster) startDeadline(ctx context.Context, stateView minerView, result DeadlineResult, challenge abi.Randomness) {
+	if prev := p.active; prev != nil {
+		prev.cancel()
+		if prev.result.Open != result.Open && prev.result.Status != DeadlineProven && !prev.result.final() {
+			prev.result.Status = DeadlineMissed
+			p.record(prev.result)
+		}
 	}
+
+	dl := &activeDeadline{result: result, challenge: challenge, sending: true}
+	dl.ctx, dl.cancel = context.WithCancel(ctx)
+	p.active = dl
+	p.record(dl.result)
+
+	go p.prove(dl, stateView)
 }
 
-func (p *Poster) startPoStIfNeeded(ctx context.Context, newHead block.TipSet) error {
+// followUp checks on the PoSt sent for the deadline, marking it proven once
+// on chain and sending it again when it failed or is not mined in time. The
+// chain is searched for the PoSt message without holding the lock.
+func (p *Poster) followUp(dl *activeDeadline) error {
 	p.postMutex.Lock()
-	defer p.postMutex.Unlock()
-
-	if p.postCancel != nil {
-		// already posting
+	if dl.sending || dl.result.final() {
+		p.postMutex.Unlock()
+		return nil
+	}
+	if dl.result.Status == DeadlineProving {
+		// the last attempt failed to send
+		p.retry(dl)
+		p.postMutex.Unlock()
+		return nil
+	}
+	last := lastSent(dl)
+	sent := append([]cid.Cid{}, dl.sent...)
+	// a reorg may take the head below the height the PoSt was sent at
+	lookback := uint64(1)
+	if p.height > dl.sentAt {
+		lookback += uint64(p.height - dl.sentAt)
+	}
+	p.postMutex.Unlock()
+
+	var mined cid.Cid
+	found, ok, err := p.waiter.Find(dl.ctx, lookback, func(_ *types.SignedMessage, c cid.Cid) bool {
+		for _, sentCid := range sent {
+			if c.Equals(sentCid) {
+				mined = c
+				return true
+			}
+		}
+		return false
+	})
+	if dl.ctx.Err() != nil {
 		return nil
 	}
-
-	tipsetHeight, err := newHead.Height()
 	if err != nil {
 		return err
 	}
 
-	root, err := p.chain.GetTipSetStateRoot(ctx, newHead.Key())
-	if err != nil {
-		return err
+	p.postMutex.Lock()
+	defer p.postMutex.Unlock()
+	// the PoSt may have been abandoned or sent again in the meantime
+	if p.active != dl || dl.sending || dl.result.final() || !lastSent(dl).Equals(last) {
+		return nil
 	}
 
-	stateView := p.stateViewer.StateView(root)
-	index, open, _, challengeAt, err := stateView.MinerDeadlineInfo(ctx, p.minerAddr, tipsetHeight)
-	if err != nil {
-		return err
+	switch {
+	case ok && found.Receipt.ExitCode == exitcode.Ok:
+		if dl.result.Status != DeadlineProven || !dl.result.Message.Equals(mined) {
+			dl.result.Status = DeadlineProven
+			dl.result.Message = mined
+			dl.result.Error = ""
+			p.record(dl.result)
+		}
+	case ok:
+		// the nonce is spent, the PoSt goes in a new message
+		dl.result.Error = fmt.Sprintf("PoSt message %s failed with exit code %d", mined, found.Receipt.ExitCode)
+		dl.replace = false
+		p.retry(dl)
+	case dl.result.Status == DeadlineProven:
+		// reorged out of the chain, wait for it to be mined again
+		dl.result.Status = DeadlineSubmitted
+		dl.sentAt = p.height
+		p.record(dl.result)
+	case p.height >= dl.sentAt+postRetryEpochs:
+		dl.result.Error = fmt.Sprintf("PoSt message %s not mined within %d epochs", dl.result.Message, postRetryEpochs)
+		dl.replace = true
+		p.retry(dl)
 	}
+	return nil
+}
 
-	// exit if we haven't yet hit the deadline
-	if tipsetHeight < open {
-		return nil
+// retry sends the PoSt of the deadline again, unless out of attempts.
+func (p *Poster) retry(dl *activeDeadline) {
+	if dl.result.Attempts >= maxPoStAttempts {
+		dl.result.Status = DeadlineFailed
+		p.record(dl.result)
+		return
 	}
+	log.Warnf("sending PoSt for deadline %d again: %s", dl.result.Index, dl.result.Error)
+	dl.sending = true
+	go p.submit(dl)
+}
 
-	randomness, err := p.getChallenge(ctx, newHead.Key(), challengeAt)
-	if err != nil {
-		return err
+// prove generates the PoSt of the deadline and sends it.
+func (p *Poster) prove(dl *activeDeadline, stateView minerView) {
+	worker, partitions, proofs, err := p.generatePoSt(dl.ctx, stateView, dl.result.Index, dl.challenge)
+
+	p.postMutex.Lock()
+	if dl.ctx.Err() != nil {
+		p.postMutex.Unlock()
+		return
 	}
+	switch {
+	case err != nil:
+		log.Errorf("error generating window PoSt for deadline %d: %s", dl.result.Index, err)
+		dl.sending = false
+		dl.result.Status = DeadlineFailed
+		dl.result.Error = err.Error()
+		p.record(dl.result)
+	case len(partitions) == 0:
+		dl.sending = false
+		dl.result.Status = DeadlineEmpty
+		p.record(dl.result)
+	default:
+		dl.worker, dl.partitions, dl.proofs = worker, partitions, proofs
+	}
+	sending := dl.sending
+	p.postMutex.Unlock()
 
-	// If we have not already seen this randomness, either the deadline has changed
-	// or the chain as reorged to a point prior to the challenge. Either way,
-	// it is time to start a new PoSt.
-	if bytes.Equal(p.challenge, randomness) {
-		return nil
+	if sending {
+		p.submit(dl)
 	}
-	p.challenge = randomness
+}
 
-	// stop existing PoSt, if one exists
-	p.cancelPoSt()
+// submit sends the generated PoSt of the deadline, with an estimated gas
+// limit at the suggested gas price doubled with each attempt. A PoSt not mined
+// in time is sent again with the nonce of the last message to replace it.
+func (p *Poster) submit(dl *activeDeadline) {
+	p.postMutex.Lock()
+	attempt := dl.result.Attempts + 1
+	replaced, replacedPrice := cid.Undef, dl.gasPrice
+	if dl.replace {
+		replaced = lastSent(dl)
+	}
+	p.postMutex.Unlock()
 
-	ctx, p.postCancel = context.WithCancel(ctx)
-	go p.doPoSt(ctx, stateView, index)
+	mcid, gasPrice, err := p.sendPoSt(dl.ctx, dl.worker, dl.result.Index, dl.partitions, dl.proofs, attempt, replaced, replacedPrice)
 
-	return nil
+	p.postMutex.Lock()
+	defer p.postMutex.Unlock()
+	if dl.ctx.Err() != nil {
+		return
+	}
+	dl.sending = false
+	dl.result.Attempts = attempt
+	if err != nil {
+		log.Errorf("error sending window PoSt for deadline %d: %s", dl.result.Index, err)
+		dl.result.Status = DeadlineProving
+		dl.result.Error = err.Error()
+		if attempt >= maxPoStAttempts {
+			dl.result.Status = DeadlineFailed
+		}
+	} else {
+		if replaced.Defined() {
+			dl.sent = append(dl.sent, mcid)
+		} else {
+			dl.sent = []cid.Cid{mcid}
+		}
+		dl.gasPrice = gasPrice
+		dl.replace = false
+		dl.result.Status = DeadlineSubmitted
+		dl.result.Message = mcid
+		dl.sentAt = p.height
+	}
+	p.record(dl.result)
 }
 
-func (p *Poster) doPoSt(ctx context.Context, stateView *appstate.View, deadlineIndex uint64) {
-	defer p.safeCancelPoSt()
+// lastSent returns the last PoSt message sent for the deadline.
+func lastSent(dl *activeDeadline) cid.Cid {
+	if len(dl.sent) == 0 {
+		return cid.Undef
+	}
+	return dl.sent[len(dl.sent)-1]
+}
 
+// generatePoSt generates the PoSt of the partitions due at the deadline,
+// returning the worker to send it from. It returns no partitions when none
+// are due.
+func (p *Poster) generatePoSt(ctx context.Context, stateView minerView, deadlineIndex uint64, challenge abi.Randomness) (address.Address, []uint64, []abi.PoStProof, error) {
 	minerID, err := address.IDFromAddress(p.minerAddr)
 	if err != nil {
-		log.Errorf("Error retrieving miner ID from address %s: %s", p.minerAddr, err)
-		return
+		return address.Undef, nil, nil, fmt.Errorf("error retrieving miner ID from address %s: %s", p.minerAddr, err)
 	}
 
 	partitions, err := stateView.MinerPartitionIndicesForDeadline(ctx, p.minerAddr, deadlineIndex)
 	if err != nil {
-		log.Errorf("Error retrieving partitions for address %s at index %d: %s", p.minerAddr, deadlineIndex, err)
-		return
+		return address.Undef, nil, nil, fmt.Errorf("error retrieving partitions for address %s at index %d: %s", p.minerAddr, deadlineIndex, err)
 	}
 
 	// if no partitions, we're done
 	if len(partitions) == 0 {
-		return
+		return address.Undef, nil, nil, nil
 	}
 
 	// Some day we might want to choose a subset of partitions to prove at one time. Today is not that day.
 	sectors, err := stateView.MinerSectorInfoForDeadline(ctx, p.minerAddr, deadlineIndex, partitions)
 	if err != nil {
-		log.Errorf("error retrieving sector info for miner %s partitions at index %d: %s", p.minerAddr, deadlineIndex, err)
-		return
+		return address.Undef, nil, nil, fmt.Errorf("error retrieving sector info for miner %s partitions at index %d: %s", p.minerAddr, deadlineIndex, err)
 	}
 
-	proofs, err := p.mgr.GenerateWindowPoSt(ctx, abi.ActorID(minerID), sectors, abi.PoStRandomness(p.challenge))
+	proofs, err := p.prover.GenerateWindowPoSt(ctx, abi.ActorID(minerID), sectors, abi.PoStRandomness(challenge))
 	if err != nil {
-		log.Errorf("error generating window PoSt: %s", err)
-		return
+		return address.Undef, nil, nil, fmt.Errorf("error generating window PoSt: %s", err)
 	}
 
 	_, workerAddr, err := stateView.MinerControlAddresses(ctx, p.minerAddr)
 	if err != nil {
-		log.Errorf("could not get miner worker address fro miner %s: %s", p.minerAddr, err)
-		return
-	}
-
-	err = p.sendPoSt(ctx, workerAddr, deadlineIndex, partitions, proofs)
-	if err != nil {
-		log.Error("error sending window PoSt: ", err)
-		return
+		return address.Undef, nil, nil, fmt.Errorf("could not get miner worker address fro miner %s: %s", p.minerAddr, err)
 	}
+	return workerAddr, partitions, proofs, nil
 }
 
-func (p *Poster) sendPoSt(ctx context.Context, workerAddr address.Address, index uint64, partitions []uint64, proofs []abi.PoStProof) error {
-
+// sendPoSt sends the PoSt, replacing the replaced message when defined and
+// still queued, returning the message cid and its gas price.
+func (p *Poster) sendPoSt(ctx context.Context, workerAddr address.Address, index uint64, partitions []uint64, proofs []abi.PoStProof, attempt uint64, replaced cid.Cid, replacedPrice types.AttoFIL) (cid.Cid, types.AttoFIL, error) {
 	windowedPost := &miner.SubmitWindowedPoStParams{
 		Deadline:   index,
 		Partitions: partitions,
@@ -186,33 +452,56 @@ func (p *Poster) sendPoSt(ctx context.Context, workerAddr address.Address, index
 		Skipped:    abi.BitField{},
 	}
 
-	mcid, errCh, err := p.outbox.Send(
-		ctx,
-		workerAddr,
-		p.minerAddr,
-		types.ZeroAttoFIL,
-		types.NewGasPrice(1),
-		gas.NewGas(10000),
-		true,
-		builtin.MethodsMiner.SubmitWindowedPoSt,
-		windowedPost,
-	)
+	gasLimit, err := p.estimator.MessageEstimateGasLimit(ctx, workerAddr, p.minerAddr, types.ZeroAttoFIL, builtin.MethodsMiner.SubmitWindowedPoSt, windowedPost)
 	if err != nil {
-		return err
+		return cid.Undef, types.ZeroAttoFIL, errors.Wrap(err, "failed to estimate gas of PoSt")
 	}
-	if err := <-errCh; err != nil {
-		return err
+	suggested, err := p.estimator.MessageSuggestGasPrice(ctx)
+	if err != nil {
+		return cid.Undef, types.ZeroAttoFIL, errors.Wrap(err, "failed to suggest gas price of PoSt")
+	}
+	gasPrice := big.Mul(suggested, big.NewInt(int64(1)<<(attempt-1)))
+
+	var mcid cid.Cid
+	var errCh chan error
+	if replaced.Defined() {
+		// a replacement must pay more than the message it replaces
+		if !gasPrice.GreaterThan(replacedPrice) {
+			gasPrice = big.Add(replacedPrice, big.NewInt(1))
+		}
+		mcid, errCh, err = p.outbox.Replace(ctx, workerAddr, replaced, gasPrice, gasLimit, true)
+		if err != nil {
+			log.Warnf("sending PoSt for deadline %d in a new message, failed to replace message %s: %s", index, replaced, err)
+		}
+	}
+	if !replaced.Defined() || err != nil {
+		mcid, errCh, err = p.outbox.Send(
+			ctx,
+			workerAddr,
+			p.minerAddr,
+			types.ZeroAttoFIL,
+			gasPrice,
+			gasLimit,
+			true,
+			builtin.MethodsMiner.SubmitWindowedPoSt,
+			windowedPost,
+		)
 	}
-
-	// wait until we see the post on chain at least once
-	err = p.waiter.Wait(ctx, mcid, msg.DefaultMessageWaitLookback, func(_ *block.Block, _ *types.SignedMessage, recp *vm.MessageReceipt) error {
-		return nil
-	})
 	if err != nil {
-		return err
+		return cid.Undef, types.ZeroAttoFIL, err
 	}
+	if err := <-errCh; err != nil {
+		return cid.Undef, types.ZeroAttoFIL, err
+	}
+	return mcid, gasPrice, nil
+}
 
-	return nil
+// record persists the result of a deadline. Failing to is logged, the PoSt
+// goes on regardless.
+func (p *Poster) record(result DeadlineResult) {
+	if err := p.results.put(result); err != nil {
+		log.Errorf("failed to record result of deadline %d: %s", result.Index, err)
+	}
 }
 
 func (p *Poster) getChallenge(ctx context.Context, head block.TipSetKey, at abi.ChainEpoch) (abi.Randomness, error) {
@@ -224,17 +513,3 @@ func (p *Poster) getChallenge(ctx context.Context, head block.TipSetKey, at abi.
 
 	return p.chain.SampleChainRandomness(ctx, head, acrypto.DomainSeparationTag_WindowedPoStChallengeSeed, at, buf.Bytes())
 }
-
-func (p *Poster) safeCancelPoSt() {
-	p.postMutex.Lock()
-	defer p.postMutex.Unlock()
-
-	p.cancelPoSt()
-}
-
-func (p *Poster) cancelPoSt() {
-	if p.postCancel != nil {
-		p.postCancel()
-		p.postCancel = nil
-	}
-}
@@ -0,0 +1,294 @@
+package poster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/runtime/exitcode"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/app/go-filecoin/plumbing/msg"
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/types"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm"
+)
+
+func TestPosterFollowsPoStUntilMined(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("proves the deadline once the PoSt is mined", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(100)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		sent := deps.outbox.messages()
+		require.Len(t, sent, 1)
+		assert.Equal(t, builtin.MethodsMiner.SubmitWindowedPoSt, sent[0].method)
+		assert.Equal(t, deps.estimator.price, sent[0].gasPrice)
+		assert.Equal(t, deps.estimator.limit, sent[0].gasLimit)
+
+		deps.waiter.mine(sent[0].cid, exitcode.Ok)
+		handle(101)
+		requireDeadline(t, p, DeadlineProven, 1)
+		assert.Equal(t, DeadlineProven, lastResult(t, p).Status)
+	})
+
+	t.Run("replaces the PoSt at a higher price when not mined in time", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(100)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		for height := abi.ChainEpoch(101); height < 100+postRetryEpochs; height++ {
+			handle(height)
+		}
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		assert.Len(t, deps.outbox.messages(), 1)
+
+		handle(100 + postRetryEpochs)
+		requireDeadline(t, p, DeadlineSubmitted, 2)
+		sent := deps.outbox.messages()
+		require.Len(t, sent, 2)
+		assert.Equal(t, sent[0].cid, sent[1].replaced)
+		assert.Equal(t, types.NewGasPrice(6), sent[1].gasPrice)
+		assert.Contains(t, lastResult(t, p).Error, "not mined within")
+
+		// the replaced PoSt landing late proves the deadline all the same
+		deps.waiter.mine(sent[0].cid, exitcode.Ok)
+		handle(101 + postRetryEpochs)
+		requireDeadline(t, p, DeadlineProven, 2)
+		assert.Equal(t, sent[0].cid, lastResult(t, p).Message)
+	})
+
+	t.Run("sends the PoSt in a new message when the replaced one is gone", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(100)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		deps.outbox.forget()
+
+		handle(100 + postRetryEpochs)
+		requireDeadline(t, p, DeadlineSubmitted, 2)
+		sent := deps.outbox.messages()
+		require.Len(t, sent, 1)
+		assert.False(t, sent[0].replaced.Defined())
+	})
+
+	t.Run("replaces the PoSt above the price of the last when the suggestion drops", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(100)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		deps.estimator.setPrice(types.NewGasPrice(1))
+
+		handle(100 + postRetryEpochs)
+		requireDeadline(t, p, DeadlineSubmitted, 2)
+		sent := deps.outbox.messages()
+		require.Len(t, sent, 2)
+		assert.Equal(t, types.NewGasPrice(4), sent[1].gasPrice)
+	})
+
+	t.Run("fails the attempt when gas cannot be estimated", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+		deps.estimator.err = errors.New("exit code 16")
+
+		handle(100)
+		requireDeadline(t, p, DeadlineProving, 1)
+		assert.Empty(t, deps.outbox.messages())
+	})
+
+	t.Run("searches from the head after a reorg below the PoSt", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(110)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		handle(105)
+		assert.Equal(t, []uint64{1}, deps.waiter.lookbacks())
+	})
+
+	t.Run("sends the PoSt in a new message when it fails on chain", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(100)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		deps.waiter.mine(deps.outbox.messages()[0].cid, exitcode.ErrIllegalArgument)
+		handle(101)
+		requireDeadline(t, p, DeadlineSubmitted, 2)
+		assert.Contains(t, lastResult(t, p).Error, "failed with exit code")
+		sent := deps.outbox.messages()
+		require.Len(t, sent, 2)
+		assert.False(t, sent[1].replaced.Defined())
+	})
+
+	t.Run("fails the deadline once out of attempts", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		height := abi.ChainEpoch(100)
+		handle(height)
+		for attempt := uint64(1); attempt <= maxPoStAttempts; attempt++ {
+			requireDeadline(t, p, DeadlineSubmitted, attempt)
+			height += postRetryEpochs
+			handle(height)
+		}
+		requireDeadline(t, p, DeadlineFailed, maxPoStAttempts)
+
+		sent := deps.outbox.messages()
+		require.Len(t, sent, maxPoStAttempts)
+		assert.Equal(t, types.NewGasPrice(48), sent[maxPoStAttempts-1].gasPrice)
+
+		handle(height + postRetryEpochs)
+		assert.Len(t, deps.outbox.messages(), maxPoStAttempts)
+		assert.Equal(t, DeadlineFailed, lastResult(t, p).Status)
+	})
+
+	t.Run("waits for the PoSt again when a reorg drops it", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+
+		handle(100)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+		mcid := deps.outbox.messages()[0].cid
+		deps.waiter.mine(mcid, exitcode.Ok)
+		handle(101)
+		requireDeadline(t, p, DeadlineProven, 1)
+
+		deps.waiter.drop(mcid)
+		handle(102)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+
+		deps.waiter.mine(mcid, exitcode.Ok)
+		handle(103)
+		requireDeadline(t, p, DeadlineProven, 1)
+		assert.Len(t, deps.outbox.messages(), 1)
+
+		deps.waiter.drop(mcid)
+		handle(104)
+		handle(104 + postRetryEpochs)
+		requireDeadline(t, p, DeadlineSubmitted, 2)
+	})
+
+	t.Run("misses the deadline when it closes before the PoSt is proven", func(t *testing.T) {
+		p, deps, handle := newProvingPoster(t)
+		defer p.StopPoSting()
+		deps.prover.release = make(chan struct{})
+
+		handle(100)
+		handle(101)
+		requireDeadline(t, p, DeadlineProving, 0)
+
+		// the next deadline opens
+		deps.view.deadline, deps.view.open, deps.view.close = 4, 160, 220
+		handle(160)
+		close(deps.prover.release)
+		requireDeadline(t, p, DeadlineSubmitted, 1)
+
+		results, err := p.results.list()
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, uint64(3), results[0].Index)
+		assert.Equal(t, DeadlineMissed, results[0].Status)
+		assert.Equal(t, uint64(4), results[1].Index)
+
+		// only the PoSt of the next deadline was sent
+		sent := deps.outbox.messages()
+		require.Len(t, sent, 1)
+		assert.Equal(t, uint64(4), sent[0].params.(*miner.SubmitWindowedPoStParams).Deadline)
+	})
+}
+
+// provingDeps are the fakes of a poster proving deadline 3, open from epoch
+// 100 to 160.
+type provingDeps struct {
+	*testDeps
+	view *fakeView
+}
+
+func newProvingPoster(t *testing.T) (*Poster, *provingDeps, func(abi.ChainEpoch)) {
+	p, view, deps := newTestPoster(t)
+	view.deadline, view.open, view.close, view.challenge = 3, 100, 160, 80
+	view.partitions = []uint64{0}
+	view.sectors = []abi.SectorInfo{{SectorNumber: 1}}
+	deps.chain.randomness = abi.Randomness("challenge")
+
+	handle := func(height abi.ChainEpoch) {
+		head := block.RequireNewTipSet(t, &block.Block{Height: height})
+		require.NoError(t, p.HandleNewHead(context.Background(), head))
+	}
+	return p, &provingDeps{testDeps: deps, view: view}, handle
+}
+
+// requireDeadline waits for the PoSt of the deadline under way to settle with
+// the status after the attempts.
+func requireDeadline(t *testing.T, p *Poster, status DeadlineStatus, attempts uint64) {
+	require.Eventually(t, func() bool {
+		p.postMutex.Lock()
+		defer p.postMutex.Unlock()
+		dl := p.active
+		if dl == nil || (dl.sending && status != DeadlineProving) {
+			return false
+		}
+		return dl.result.Status == status && dl.result.Attempts == attempts
+	}, time.Second, time.Millisecond)
+}
+
+func lastResult(t *testing.T, p *Poster) DeadlineResult {
+	results, err := p.results.list()
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	return results[len(results)-1]
+}
+
+func (o *fakeOutbox) messages() []sentMessage {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	return append([]sentMessage{}, o.sent...)
+}
+
+// forget drops the messages sent, as the outbox does once they expire.
+func (o *fakeOutbox) forget() {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	o.sent = nil
+}
+
+func (w *fakeWaiter) lookbacks() []uint64 {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	return append([]uint64{}, w.searched...)
+}
+
+func (e *fakeEstimator) setPrice(price types.AttoFIL) {
+	e.lk.Lock()
+	defer e.lk.Unlock()
+	e.price = price
+}
+
+func (w *fakeWaiter) mine(c cid.Cid, code exitcode.ExitCode) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	if w.mined == nil {
+		w.mined = make(map[cid.Cid]*msg.ChainMessage)
+	}
+	w.mined[c] = &msg.ChainMessage{Receipt: &vm.MessageReceipt{ExitCode: code}}
+}
+
+func (w *fakeWaiter) drop(c cid.Cid) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+	delete(w.mined, c)
+}
@@ -27,6 +27,7 @@ type API struct {
 	stopMiningFunc  func(context.Context)
 	getWorkerFunc   func(ctx context.Context) (*mining.DefaultWorker, error)
 	chainClock      clock.ChainEpochClock
+	history         *mining.History
 }
 
 // New creates a new API instance with the provided deps
@@ -40,6 +41,7 @@ func New(
 	stopMiningfunc func(context.Context),
 	getWorkerFunc func(ctx context.Context) (*mining.DefaultWorker, error),
 	chainClock clock.ChainEpochClock,
+	history *mining.History,
 ) API {
 	return API{
 		minerAddress:    minerAddr,
@@ -51,6 +53,7 @@ func New(
 		stopMiningFunc:  stopMiningfunc,
 		getWorkerFunc:   getWorkerFunc,
 		chainClock:      chainClock,
+		history:         history,
 	}
 }
 
@@ -104,6 +107,12 @@ func (a *API) MiningStart(ctx context.Context) error {
 	return a.startMiningFunc(ctx)
 }
 
+// MiningHistory returns the reports of the most recent epochs mined in, at
+// most limit of them, or all if limit is zero.
+func (a *API) MiningHistory(limit uint) ([]mining.EpochReport, error) {
+	return a.history.List(limit)
+}
+
 // MiningStop calls the node's StopMining function
 func (a *API) MiningStop(ctx context.Context) {
 	a.stopMiningFunc(ctx)
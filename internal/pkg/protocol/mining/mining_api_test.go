@@ -152,5 +152,6 @@ func newAPI(t *testing.T) (bapi.API, *node.Node) {
 		nd.StopMining,
 		nd.CreateMiningWorker,
 		nd.ChainClock,
+		nd.BlockMining.History,
 	), nd
 }
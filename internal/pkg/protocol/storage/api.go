@@ -7,24 +7,39 @@ import (
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-fil-markets/storagemarket"
+	fsmstorage "github.com/sbwtw/go-filecoin/internal/app/go-filecoin/connectors/fsm_storage"
 	"github.com/sbwtw/go-filecoin/internal/pkg/piecemanager"
+	"github.com/sbwtw/go-filecoin/internal/pkg/pledger"
+	"github.com/sbwtw/go-filecoin/internal/pkg/poster"
+	"github.com/sbwtw/go-filecoin/internal/pkg/sectorstatus"
 	"github.com/filecoin-project/specs-actors/actors/abi"
 )
 
 type storage interface {
 	Client() storagemarket.StorageClient
 	Provider() (storagemarket.StorageProvider, error)
+	MinerProvider(minerAddr address.Address) (storagemarket.StorageProvider, error)
 	PieceManager() (piecemanager.PieceManager, error)
 }
 
+type storagePaths interface {
+	AttachStorage(ctx context.Context, minerAddr address.Address, path string, weight uint64, canSeal, canStore bool) error
+	DetachStorage(minerAddr address.Address, path string, force bool) error
+	ListStorage(minerAddr address.Address) ([]fsmstorage.StoragePath, error)
+}
+
 // API is the storage API for the test environment
 type API struct {
 	storage storage
+	poster  func() (*poster.Poster, error)
+	sectors func() (*sectorstatus.Inspector, error)
+	pledger func() (*pledger.Pledger, error)
+	paths   storagePaths
 }
 
 // NewAPI creates a new API
-func NewAPI(storage storage) *API {
-	return &API{storage}
+func NewAPI(storage storage, poster func() (*poster.Poster, error), sectors func() (*sectorstatus.Inspector, error), pledger func() (*pledger.Pledger, error), paths storagePaths) *API {
+	return &API{storage, poster, sectors, pledger, paths}
 }
 
 // PledgeSector creates a new, empty sector and seals it.
@@ -37,9 +52,141 @@ func (api *API) PledgeSector(ctx context.Context) error {
 	return pm.PledgeSector(ctx)
 }
 
-// AddAsk stores a new price for storage
-func (api *API) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch) error {
-	provider, err := api.storage.Provider()
+// PledgeStatus returns the outcome of the last check of the pledge policy,
+// nil if it has not been checked.
+func (api *API) PledgeStatus() (*pledger.Status, error) {
+	p, err := api.pledger()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Status(), nil
+}
+
+// SectorFaults returns the health of the miner's sectors that are declared
+// faulty or recovering, or that fail the local health check.
+func (api *API) SectorFaults(ctx context.Context) ([]poster.SectorHealth, error) {
+	p, err := api.poster()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.SectorFaults(ctx)
+}
+
+// DeclareFaults declares the miner's sectors faulty.
+func (api *API) DeclareFaults(ctx context.Context, sectors []abi.SectorNumber) (cid.Cid, error) {
+	p, err := api.poster()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return p.DeclareFaults(ctx, sectors)
+}
+
+// DeclareRecoveries declares the miner's faulty sectors recovered.
+func (api *API) DeclareRecoveries(ctx context.Context, sectors []abi.SectorNumber) (cid.Cid, error) {
+	p, err := api.poster()
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	return p.DeclareRecoveries(ctx, sectors)
+}
+
+// ProvingInfo returns the state of the miner's proving period.
+func (api *API) ProvingInfo(ctx context.Context) (poster.ProvingInfo, error) {
+	p, err := api.poster()
+	if err != nil {
+		return poster.ProvingInfo{}, err
+	}
+
+	return p.ProvingInfo(ctx)
+}
+
+// ProvingDeadlines returns the deadlines of the miner's proving period with
+// their upcoming window and last PoSt result.
+func (api *API) ProvingDeadlines(ctx context.Context) ([]poster.DeadlineSchedule, error) {
+	p, err := api.poster()
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Deadlines(ctx)
+}
+
+// ListSectors returns the miner's sectors with their sealing and on-chain
+// state.
+func (api *API) ListSectors(ctx context.Context) ([]sectorstatus.Sector, error) {
+	s, err := api.sectors()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.List(ctx)
+}
+
+// SectorStatus returns the sealing and on-chain state of a sector.
+func (api *API) SectorStatus(ctx context.Context, sectorNum abi.SectorNumber) (sectorstatus.Sector, error) {
+	s, err := api.sectors()
+	if err != nil {
+		return sectorstatus.Sector{}, err
+	}
+
+	return s.Status(ctx, sectorNum)
+}
+
+// SectorLog returns the events of a sector recorded while sealing it.
+func (api *API) SectorLog(ctx context.Context, sectorNum abi.SectorNumber) ([]sectorstatus.LogEntry, error) {
+	s, err := api.sectors()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Log(ctx, sectorNum)
+}
+
+// RetrySector moves a failed sector back into the sealing pipeline.
+func (api *API) RetrySector(ctx context.Context, sectorNum abi.SectorNumber, state string) (sectorstatus.Sector, error) {
+	s, err := api.sectors()
+	if err != nil {
+		return sectorstatus.Sector{}, err
+	}
+
+	return s.Retry(ctx, sectorNum, state)
+}
+
+// RemoveSector takes a sector that is not on chain out of the sealing
+// pipeline.
+func (api *API) RemoveSector(ctx context.Context, sectorNum abi.SectorNumber) error {
+	s, err := api.sectors()
+	if err != nil {
+		return err
+	}
+
+	return s.Remove(ctx, sectorNum)
+}
+
+// AttachStorage attaches a local storage path to a miner, initializing it
+// with the weight and uses given unless it is initialized already.
+func (api *API) AttachStorage(ctx context.Context, minerAddr address.Address, path string, weight uint64, canSeal, canStore bool) error {
+	return api.paths.AttachStorage(ctx, minerAddr, path, weight, canSeal, canStore)
+}
+
+// DetachStorage detaches a local storage path from a miner.
+func (api *API) DetachStorage(minerAddr address.Address, path string, force bool) error {
+	return api.paths.DetachStorage(minerAddr, path, force)
+}
+
+// ListStorage returns the local storage paths of a miner.
+func (api *API) ListStorage(minerAddr address.Address) ([]fsmstorage.StoragePath, error) {
+	return api.paths.ListStorage(minerAddr)
+}
+
+// AddAsk stores a new price for storage of a miner of the node, the primary
+// miner if the address is undefined.
+func (api *API) AddAsk(minerAddr address.Address, price abi.TokenAmount, duration abi.ChainEpoch) error {
+	provider, err := api.storage.MinerProvider(minerAddr)
 	if err != nil {
 		return err
 	}
@@ -49,7 +196,7 @@ func (api *API) AddAsk(price abi.TokenAmount, duration abi.ChainEpoch) error {
 
 // ListAsks lists all asks for the miner
 func (api *API) ListAsks(maddr address.Address) ([]*storagemarket.SignedStorageAsk, error) {
-	provider, err := api.storage.Provider()
+	provider, err := api.storage.MinerProvider(maddr)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,307 @@
+package sectorstatus
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	fsm "github.com/filecoin-project/storage-fsm"
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/block"
+	appstate "github.com/sbwtw/go-filecoin/internal/pkg/state"
+)
+
+// Sector is the state of a sector of the miner, as recorded by the sealing
+// state machine and on chain.
+type Sector struct {
+	Number abi.SectorNumber
+	// State is the state of the sector in the sealing state machine, empty
+	// when the node has no record of the sector.
+	State string
+	Deals []abi.DealID
+	// LastError is the last error the sealing state machine recorded for the
+	// sector.
+	LastError string
+
+	// PreCommitted is set while the sector is pre-committed on chain.
+	PreCommitted bool
+	// OnChain is set when the sector is proven on chain.
+	OnChain bool
+	// Expiration is the epoch the sector's pre-commitment or commitment
+	// expires at.
+	Expiration abi.ChainEpoch
+}
+
+// LogEntry is an event of the sector recorded by the sealing state machine.
+type LogEntry struct {
+	Time    time.Time
+	Kind    string
+	Message string
+	Trace   string
+}
+
+type sealingAPI interface {
+	ListSectors() ([]fsm.SectorInfo, error)
+	GetSectorInfo(abi.SectorNumber) (fsm.SectorInfo, error)
+	ForceSectorState(ctx context.Context, sectorNum abi.SectorNumber, state fsm.SectorState) error
+}
+
+type chainState interface {
+	Head() block.TipSetKey
+	GetTipSetStateRoot(ctx context.Context, tsk block.TipSetKey) (cid.Cid, error)
+}
+
+type sectorView interface {
+	MinerSectorsForEach(ctx context.Context, maddr address.Address, f func(abi.SectorNumber, cid.Cid, abi.RegisteredProof, []abi.DealID) error) error
+	MinerGetPrecommittedSector(ctx context.Context, maddr address.Address, sectorNum uint64) (*miner.SectorPreCommitOnChainInfo, bool, error)
+	MinerSectorExpiration(ctx context.Context, maddr address.Address, sectorNum abi.SectorNumber) (abi.ChainEpoch, bool, error)
+}
+
+// Inspector reports the sectors of a miner, combining the records of the
+// sealing state machine with the miner's state at the chain head, and moves
+// sectors stuck in the sealing pipeline along.
+type Inspector struct {
+	minerAddr address.Address
+	sealing   sealingAPI
+	headView  func(ctx context.Context) (sectorView, error)
+}
+
+// NewInspector creates an inspector of the miner's sectors.
+func NewInspector(minerAddr address.Address, sealing *fsm.Sealing, chain chainState, stateViewer *appstate.Viewer) *Inspector {
+	return &Inspector{
+		minerAddr: minerAddr,
+		sealing:   sealing,
+		headView: func(ctx context.Context) (sectorView, error) {
+			root, err := chain.GetTipSetStateRoot(ctx, chain.Head())
+			if err != nil {
+				return nil, err
+			}
+			return stateViewer.StateView(root), nil
+		},
+	}
+}
+
+// List returns the sectors known to the sealing state machine or on chain, by
+// sector number.
+func (i *Inspector) List(ctx context.Context) ([]Sector, error) {
+	infos, err := i.sealing.ListSectors()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sectors")
+	}
+	view, err := i.headView(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sectors := make(map[abi.SectorNumber]Sector, len(infos))
+	for _, info := range infos {
+		sectors[info.SectorNumber] = fromSectorInfo(info)
+	}
+	// include the sectors on chain the sealing state machine has no record of
+	err = view.MinerSectorsForEach(ctx, i.minerAddr, func(num abi.SectorNumber, _ cid.Cid, _ abi.RegisteredProof, dealIDs []abi.DealID) error {
+		if _, ok := sectors[num]; !ok {
+			sectors[num] = Sector{Number: num, Deals: dealIDs}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Sector, 0, len(sectors))
+	for _, sector := range sectors {
+		if err := i.addChainState(ctx, view, &sector); err != nil {
+			return nil, err
+		}
+		out = append(out, sector)
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Number < out[b].Number })
+	return out, nil
+}
+
+// Status returns the state of a sector. A sector the sealing state machine
+// has no record of is reported from the chain.
+func (i *Inspector) Status(ctx context.Context, sectorNum abi.SectorNumber) (Sector, error) {
+	view, err := i.headView(ctx)
+	if err != nil {
+		return Sector{}, err
+	}
+	info, err := i.sealing.GetSectorInfo(sectorNum)
+	if err != nil {
+		sector, found, chainErr := i.chainSector(ctx, view, sectorNum)
+		if chainErr != nil {
+			return Sector{}, chainErr
+		}
+		if !found {
+			return Sector{}, errors.Wrapf(err, "failed to get sector %d", sectorNum)
+		}
+		return sector, nil
+	}
+
+	sector := fromSectorInfo(info)
+	if err := i.addChainState(ctx, view, &sector); err != nil {
+		return Sector{}, err
+	}
+	return sector, nil
+}
+
+// Log returns the events of a sector recorded by the sealing state machine,
+// oldest first. A sector on chain the sealing state machine has no record of
+// has no events.
+func (i *Inspector) Log(ctx context.Context, sectorNum abi.SectorNumber) ([]LogEntry, error) {
+	info, err := i.sealing.GetSectorInfo(sectorNum)
+	if err != nil {
+		found, chainErr := i.onChain(ctx, sectorNum)
+		if chainErr != nil {
+			return nil, chainErr
+		}
+		if !found {
+			return nil, errors.Wrapf(err, "failed to get sector %d", sectorNum)
+		}
+		return []LogEntry{}, nil
+	}
+
+	entries := make([]LogEntry, len(info.Log))
+	for idx, l := range info.Log {
+		entries[idx] = LogEntry{
+			Time:    time.Unix(int64(l.Timestamp), 0),
+			Kind:    l.Kind,
+			Message: l.Message,
+			Trace:   l.Trace,
+		}
+	}
+	return entries, nil
+}
+
+// Retry moves a failed sector back to the sealing step that failed, or to
+// the state named if one is given, which must be a state failed sectors are
+// retried from.
+func (i *Inspector) Retry(ctx context.Context, sectorNum abi.SectorNumber, stateName string) (Sector, error) {
+	info, err := i.sealing.GetSectorInfo(sectorNum)
+	if err != nil {
+		found, chainErr := i.onChain(ctx, sectorNum)
+		if chainErr != nil {
+			return Sector{}, chainErr
+		}
+		if found {
+			return Sector{}, errors.Errorf("sector %d is on chain without a sealing record to retry", sectorNum)
+		}
+		return Sector{}, errors.Wrapf(err, "failed to get sector %d", sectorNum)
+	}
+
+	var to fsm.SectorState
+	if stateName == "" {
+		var ok bool
+		if to, ok = retryStates[info.State]; !ok {
+			return Sector{}, errors.Errorf("sector %d is %s, not in a failed state that can be retried", sectorNum, stateString(info.State))
+		}
+	} else {
+		var ok bool
+		if to, ok = stateNamed(stateName); !ok {
+			return Sector{}, errors.Errorf("unknown sector state %s", stateName)
+		}
+		if !retryable(to) {
+			return Sector{}, errors.Errorf("sectors cannot be retried from %s, only from %s", stateName, strings.Join(retryableNames(), ", "))
+		}
+	}
+
+	if err := i.sealing.ForceSectorState(ctx, sectorNum, to); err != nil {
+		return Sector{}, errors.Wrapf(err, "failed to move sector %d to %s", sectorNum, stateString(to))
+	}
+	return i.Status(ctx, sectorNum)
+}
+
+// Remove takes a sector that is not on chain out of the sealing pipeline,
+// leaving it in a final state. The sector's files are left in storage.
+// Sectors pre-committed or proven on chain must be terminated instead.
+func (i *Inspector) Remove(ctx context.Context, sectorNum abi.SectorNumber) error {
+	sector, err := i.Status(ctx, sectorNum)
+	if err != nil {
+		return err
+	}
+	if sector.PreCommitted || sector.OnChain {
+		return errors.Errorf("sector %d is on chain and must be terminated rather than removed", sectorNum)
+	}
+	return i.sealing.ForceSectorState(ctx, sectorNum, fsm.FaultedFinal)
+}
+
+// addChainState fills in the pre-commitment or commitment of the sector at the
+// view.
+func (i *Inspector) addChainState(ctx context.Context, view sectorView, sector *Sector) error {
+	expiration, onChain, err := view.MinerSectorExpiration(ctx, i.minerAddr, sector.Number)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get sector %d on chain", sector.Number)
+	}
+	if onChain {
+		sector.OnChain = true
+		sector.Expiration = expiration
+		return nil
+	}
+
+	precommit, found, err := view.MinerGetPrecommittedSector(ctx, i.minerAddr, uint64(sector.Number))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get pre-commitment of sector %d", sector.Number)
+	}
+	if found {
+		sector.PreCommitted = true
+		sector.Expiration = precommit.Info.Expiration
+	}
+	return nil
+}
+
+// chainSector returns a sector pre-committed or proven at the view, and
+// whether it is.
+func (i *Inspector) chainSector(ctx context.Context, view sectorView, sectorNum abi.SectorNumber) (Sector, bool, error) {
+	sector := Sector{Number: sectorNum}
+	if err := i.addChainState(ctx, view, &sector); err != nil {
+		return Sector{}, false, err
+	}
+	if sector.PreCommitted {
+		precommit, _, err := view.MinerGetPrecommittedSector(ctx, i.minerAddr, uint64(sectorNum))
+		if err != nil {
+			return Sector{}, false, errors.Wrapf(err, "failed to get pre-commitment of sector %d", sectorNum)
+		}
+		sector.Deals = precommit.Info.DealIDs
+	} else if sector.OnChain {
+		err := view.MinerSectorsForEach(ctx, i.minerAddr, func(num abi.SectorNumber, _ cid.Cid, _ abi.RegisteredProof, dealIDs []abi.DealID) error {
+			if num == sectorNum {
+				sector.Deals = dealIDs
+			}
+			return nil
+		})
+		if err != nil {
+			return Sector{}, false, err
+		}
+	}
+	return sector, sector.PreCommitted || sector.OnChain, nil
+}
+
+// onChain returns whether a sector is pre-committed or proven at the head.
+func (i *Inspector) onChain(ctx context.Context, sectorNum abi.SectorNumber) (bool, error) {
+	view, err := i.headView(ctx)
+	if err != nil {
+		return false, err
+	}
+	_, found, err := i.chainSector(ctx, view, sectorNum)
+	return found, err
+}
+
+func fromSectorInfo(info fsm.SectorInfo) Sector {
+	sector := Sector{
+		Number:    info.SectorNumber,
+		State:     stateString(info.State),
+		LastError: info.LastErr,
+	}
+	for _, piece := range info.Pieces {
+		if piece.DealInfo != nil {
+			sector.Deals = append(sector.Deals, piece.DealInfo.DealID)
+		}
+	}
+	return sector
+}
@@ -0,0 +1,175 @@
+package sectorstatus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	fsm "github.com/filecoin-project/storage-fsm"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/state"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+)
+
+func TestInspectorCombinesSealingAndChainState(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	inspector, sealing := newTestInspector(t)
+
+	sectors, err := inspector.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, sectors, 4)
+
+	assert.Equal(t, Sector{Number: 1, State: "Proving", Deals: []abi.DealID{7}, OnChain: true, Expiration: 1000}, sectors[0])
+	assert.Equal(t, Sector{Number: 2, State: "WaitSeed", PreCommitted: true, Expiration: 500}, sectors[1])
+	assert.Equal(t, Sector{Number: 3, State: "PreCommitFailed", LastError: "out of funds"}, sectors[2])
+	// on chain without a record in the sealing state machine
+	assert.Equal(t, Sector{Number: 4, OnChain: true, Expiration: 1200}, sectors[3])
+
+	t.Run("status", func(t *testing.T) {
+		sector, err := inspector.Status(ctx, 2)
+		require.NoError(t, err)
+		assert.Equal(t, sectors[1], sector)
+
+		// from the chain without a record in the sealing state machine
+		sector, err = inspector.Status(ctx, 4)
+		require.NoError(t, err)
+		assert.Equal(t, sectors[3], sector)
+
+		_, err = inspector.Status(ctx, 9)
+		assert.Error(t, err)
+	})
+
+	t.Run("log", func(t *testing.T) {
+		entries, err := inspector.Log(ctx, 3)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "event", entries[0].Kind)
+		assert.Equal(t, "out of funds", entries[0].Message)
+		assert.Equal(t, int64(1589000000), entries[0].Time.Unix())
+
+		entries, err = inspector.Log(ctx, 4)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+
+		_, err = inspector.Log(ctx, 9)
+		assert.Error(t, err)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		_, err := inspector.Retry(ctx, 1, "")
+		assert.Error(t, err)
+
+		sector, err := inspector.Retry(ctx, 3, "")
+		require.NoError(t, err)
+		assert.Equal(t, "PreCommitting", sector.State)
+
+		sector, err = inspector.Retry(ctx, 3, "Packing")
+		require.NoError(t, err)
+		assert.Equal(t, "Packing", sector.State)
+
+		_, err = inspector.Retry(ctx, 3, "Sideways")
+		assert.Error(t, err)
+
+		// only to states failed sectors are retried from
+		_, err = inspector.Retry(ctx, 3, "Proving")
+		assert.Error(t, err)
+		_, err = inspector.Retry(ctx, 3, "FailedUnrecoverable")
+		assert.Error(t, err)
+
+		// on chain without a record to retry
+		_, err = inspector.Retry(ctx, 4, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		assert.Error(t, inspector.Remove(ctx, 1))
+		assert.Error(t, inspector.Remove(ctx, 2))
+
+		require.NoError(t, inspector.Remove(ctx, 3))
+		assert.Equal(t, fsm.FaultedFinal, sealing.sectors[3].State)
+	})
+}
+
+func newTestInspector(t *testing.T) (*Inspector, *fakeSealing) {
+	minerAddr := vmaddr.NewForTestGetter()()
+	view := state.NewFakeStateView(abi.NewStoragePower(0), abi.NewStoragePower(0), 0, 0)
+	view.Miners[minerAddr] = &state.FakeMinerState{
+		Sectors: []miner.SectorOnChainInfo{
+			{Info: miner.SectorPreCommitInfo{SectorNumber: 1, Expiration: 1000}},
+			{Info: miner.SectorPreCommitInfo{SectorNumber: 4, Expiration: 1200}},
+		},
+		ProvingSet: []state.FakeSectorInfo{{ID: 1}, {ID: 4}},
+		PreCommits: []miner.SectorPreCommitOnChainInfo{
+			{Info: miner.SectorPreCommitInfo{SectorNumber: 2, Expiration: 500}},
+		},
+	}
+
+	sealing := &fakeSealing{sectors: map[abi.SectorNumber]*fsm.SectorInfo{
+		1: {
+			SectorNumber: 1,
+			State:        fsm.Proving,
+			Pieces:       []fsm.Piece{{DealInfo: &fsm.DealInfo{DealID: 7}}},
+		},
+		2: {SectorNumber: 2, State: fsm.WaitSeed, Pieces: []fsm.Piece{{}}},
+		3: {
+			SectorNumber: 3,
+			State:        fsm.PreCommitFailed,
+			LastErr:      "out of funds",
+			Log:          []fsm.Log{{Timestamp: 1589000000, Kind: "event", Message: "out of funds"}},
+		},
+	}}
+
+	inspector := &Inspector{
+		minerAddr: minerAddr,
+		sealing:   sealing,
+		headView: func(context.Context) (sectorView, error) {
+			return view, nil
+		},
+	}
+	return inspector, sealing
+}
+
+type fakeSealing struct {
+	sectors map[abi.SectorNumber]*fsm.SectorInfo
+}
+
+func (f *fakeSealing) ListSectors() ([]fsm.SectorInfo, error) {
+	var out []fsm.SectorInfo
+	for _, info := range f.sectors {
+		out = append(out, *info)
+	}
+	return out, nil
+}
+
+func (f *fakeSealing) GetSectorInfo(sectorNum abi.SectorNumber) (fsm.SectorInfo, error) {
+	info, ok := f.sectors[sectorNum]
+	if !ok {
+		return fsm.SectorInfo{}, errors.Errorf("no sector %d", sectorNum)
+	}
+	return *info, nil
+}
+
+func (f *fakeSealing) ForceSectorState(_ context.Context, sectorNum abi.SectorNumber, to fsm.SectorState) error {
+	info, ok := f.sectors[sectorNum]
+	if !ok {
+		return errors.Errorf("no sector %d", sectorNum)
+	}
+	info.State = to
+	return nil
+}
+
+func TestStateNames(t *testing.T) {
+	tf.UnitTest(t)
+	assert.Equal(t, "FailedUnrecoverable", stateString(fsm.FailedUnrecoverable))
+	state, ok := stateNamed("FailedUnrecoverable")
+	assert.True(t, ok)
+	assert.Equal(t, fsm.FailedUnrecoverable, state)
+
+	assert.Equal(t, []string{"Committing", "FinalizeSector", "Packing", "PreCommit1", "PreCommitting"}, retryableNames())
+}
@@ -0,0 +1,86 @@
+package sectorstatus
+
+import (
+	"fmt"
+	"sort"
+
+	fsm "github.com/filecoin-project/storage-fsm"
+)
+
+// stateNames are the names of the sealing states sectors are reported in and
+// may be moved to.
+var stateNames = map[fsm.SectorState]string{
+	fsm.UndefinedSectorState: "Undefined",
+	fsm.Empty:                "Empty",
+	fsm.Packing:              "Packing",
+	fsm.PreCommit1:           "PreCommit1",
+	fsm.PreCommit2:           "PreCommit2",
+	fsm.PreCommitting:        "PreCommitting",
+	fsm.WaitSeed:             "WaitSeed",
+	fsm.Committing:           "Committing",
+	fsm.CommitWait:           "CommitWait",
+	fsm.FinalizeSector:       "FinalizeSector",
+	fsm.Proving:              "Proving",
+
+	fsm.SealFailed:          "SealFailed",
+	fsm.PreCommitFailed:     "PreCommitFailed",
+	fsm.ComputeProofFailed:  "ComputeProofFailed",
+	fsm.CommitFailed:        "CommitFailed",
+	fsm.PackingFailed:       "PackingFailed",
+	fsm.FinalizeFailed:      "FinalizeFailed",
+	fsm.FailedUnrecoverable: "FailedUnrecoverable",
+
+	fsm.Faulty:        "Faulty",
+	fsm.FaultReported: "FaultReported",
+	fsm.FaultedFinal:  "FaultedFinal",
+}
+
+// retryStates are the states failed sectors are retried from, by the state
+// they failed in.
+var retryStates = map[fsm.SectorState]fsm.SectorState{
+	fsm.PackingFailed:      fsm.Packing,
+	fsm.SealFailed:         fsm.PreCommit1,
+	fsm.PreCommitFailed:    fsm.PreCommitting,
+	fsm.ComputeProofFailed: fsm.Committing,
+	fsm.CommitFailed:       fsm.Committing,
+	fsm.FinalizeFailed:     fsm.FinalizeSector,
+}
+
+// retryable returns whether failed sectors are retried from the state.
+func retryable(state fsm.SectorState) bool {
+	for _, to := range retryStates {
+		if to == state {
+			return true
+		}
+	}
+	return false
+}
+
+// retryableNames returns the names of the states failed sectors are retried
+// from, sorted.
+func retryableNames() []string {
+	var names []string
+	for state := range stateNames {
+		if retryable(state) {
+			names = append(names, stateNames[state])
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func stateString(state fsm.SectorState) string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("Unknown(%v)", state)
+}
+
+func stateNamed(name string) (fsm.SectorState, bool) {
+	for state, n := range stateNames {
+		if n == name {
+			return state, true
+		}
+	}
+	return fsm.UndefinedSectorState, false
+}
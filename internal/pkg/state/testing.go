@@ -19,6 +19,7 @@ type FakeStateView struct {
 	NetworkName string
 	Power       *NetworkPower
 	Miners      map[address.Address]*FakeMinerState
+	Balances    map[address.Address]abi.TokenAmount
 }
 
 // NewFakeStateView creates a new fake state view.
@@ -30,7 +31,8 @@ func NewFakeStateView(rawBytePower, qaPower abi.StoragePower, minerCount, minPow
 			MinerCount:           minerCount,
 			MinPowerMinerCount:   minPowerMinerCount,
 		},
-		Miners: make(map[address.Address]*FakeMinerState),
+		Miners:   make(map[address.Address]*FakeMinerState),
+		Balances: make(map[address.Address]abi.TokenAmount),
 	}
 }
 
@@ -44,11 +46,15 @@ type FakeMinerState struct {
 	ProvingPeriodEnd    abi.ChainEpoch
 	PoStFailures        int
 	Sectors             []miner.SectorOnChainInfo
+	PreCommits          []miner.SectorPreCommitOnChainInfo
 	ProvingSet          []FakeSectorInfo
 	ClaimedRawPower     abi.StoragePower
 	ClaimedQAPower      abi.StoragePower
 	PledgeRequirement   abi.TokenAmount
 	PledgeBalance       abi.TokenAmount
+	Balances            MinerBalances
+	SectorDeposit       abi.TokenAmount
+	SectorPledge        abi.TokenAmount
 }
 
 // FakeSectorInfo fakes a subset of sector onchain info
@@ -125,6 +131,14 @@ func (v *FakeStateView) MinerSectorsForEach(_ context.Context, maddr address.Add
 	return nil
 }
 
+func (v *FakeStateView) ActorBalance(_ context.Context, a address.Address) (abi.TokenAmount, error) {
+	balance, ok := v.Balances[a]
+	if !ok {
+		return big.Zero(), errors.Errorf("no actor %s", a)
+	}
+	return balance, nil
+}
+
 func (v *FakeStateView) AccountSignerAddress(ctx context.Context, a address.Address) (address.Address, error) {
 	return a, nil
 }
@@ -156,3 +170,52 @@ func (v *FakeStateView) MinerDeadlines(ctx context.Context, maddr address.Addres
 func (v *FakeStateView) MinerInfo(ctx context.Context, maddr address.Address) (miner.MinerInfo, error) {
 	return miner.MinerInfo{}, nil
 }
+
+func (v *FakeStateView) MinerBalances(_ context.Context, maddr address.Address) (MinerBalances, error) {
+	m, ok := v.Miners[maddr]
+	if !ok {
+		return MinerBalances{}, errors.Errorf("no miner %s", maddr)
+	}
+	return m.Balances, nil
+}
+
+func (v *FakeStateView) MinerSectorCollateral(_ context.Context, maddr address.Address) (deposit, pledge abi.TokenAmount, err error) {
+	m, ok := v.Miners[maddr]
+	if !ok {
+		return big.Zero(), big.Zero(), errors.Errorf("no miner %s", maddr)
+	}
+	deposit, pledge = big.Zero(), big.Zero()
+	if !m.SectorDeposit.Nil() {
+		deposit = m.SectorDeposit
+	}
+	if !m.SectorPledge.Nil() {
+		pledge = m.SectorPledge
+	}
+	return deposit, pledge, nil
+}
+
+func (v *FakeStateView) MinerGetPrecommittedSector(_ context.Context, maddr address.Address, sectorNum uint64) (*miner.SectorPreCommitOnChainInfo, bool, error) {
+	m, ok := v.Miners[maddr]
+	if !ok {
+		return nil, false, errors.Errorf("no miner %s", maddr)
+	}
+	for i := range m.PreCommits {
+		if m.PreCommits[i].Info.SectorNumber == abi.SectorNumber(sectorNum) {
+			return &m.PreCommits[i], true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (v *FakeStateView) MinerSectorExpiration(_ context.Context, maddr address.Address, sectorNum abi.SectorNumber) (abi.ChainEpoch, bool, error) {
+	m, ok := v.Miners[maddr]
+	if !ok {
+		return 0, false, errors.Errorf("no miner %s", maddr)
+	}
+	for _, sector := range m.Sectors {
+		if sector.Info.SectorNumber == sectorNum {
+			return sector.Info.Expiration, true, nil
+		}
+	}
+	return 0, false, nil
+}
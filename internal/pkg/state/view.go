@@ -99,6 +99,19 @@ func (v *View) AccountSignerAddress(ctx context.Context, a addr.Address) (addr.A
 	return accountActorState.Address, nil
 }
 
+// ActorBalance returns the balance of an actor.
+func (v *View) ActorBalance(ctx context.Context, a addr.Address) (abi.TokenAmount, error) {
+	resolvedAddr, err := v.InitResolveAddress(ctx, a)
+	if err != nil {
+		return big.Zero(), err
+	}
+	actr, err := v.loadActor(ctx, resolvedAddr)
+	if err != nil {
+		return big.Zero(), err
+	}
+	return actr.Balance, nil
+}
+
 // MinerControlAddresses returns the owner and worker addresses for a miner actor
 func (v *View) MinerControlAddresses(ctx context.Context, maddr addr.Address) (owner, worker addr.Address, err error) {
 	minerState, err := v.loadMinerActor(ctx, maddr)
@@ -366,6 +379,34 @@ func (v *View) MinerFaults(ctx context.Context, maddr addr.Address) ([]uint64, e
 	return minerState.Faults.All(miner.SectorsMax)
 }
 
+// MinerDeadlineSectors returns the sectors due to be proven at each proving
+// period deadline, with the sectors declared faulty and declared recovered.
+func (v *View) MinerDeadlineSectors(ctx context.Context, maddr addr.Address) (due [][]uint64, faults, recoveries []uint64, err error) {
+	minerState, err := v.loadMinerActor(ctx, maddr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	deadlines, err := minerState.LoadDeadlines(StoreFromCbor(ctx, v.ipldStore))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	due = make([][]uint64, len(deadlines.Due))
+	for i, sectors := range deadlines.Due {
+		if due[i], err = sectors.All(miner.SectorsMax); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if faults, err = minerState.Faults.All(miner.SectorsMax); err != nil {
+		return nil, nil, nil, err
+	}
+	if recoveries, err = minerState.Recoveries.All(miner.SectorsMax); err != nil {
+		return nil, nil, nil, err
+	}
+	return due, faults, recoveries, nil
+}
+
 // MinerGetPrecommittedSector Looks up info for a miners precommitted sector.
 // NOTE: exposes on-chain structures directly for storage FSM API.
 func (v *View) MinerGetPrecommittedSector(ctx context.Context, maddr addr.Address, sectorNum uint64) (*miner.SectorPreCommitOnChainInfo, bool, error) {
@@ -377,6 +418,98 @@ func (v *View) MinerGetPrecommittedSector(ctx context.Context, maddr addr.Addres
 	return minerState.GetPrecommittedSector(StoreFromCbor(ctx, v.ipldStore), abi.SectorNumber(sectorNum))
 }
 
+// MinerBalances are the balances of a miner actor.
+type MinerBalances struct {
+	Balance           abi.TokenAmount
+	LockedFunds       abi.TokenAmount
+	PreCommitDeposits abi.TokenAmount
+	// Available is the part of the balance the owner may withdraw.
+	Available abi.TokenAmount
+}
+
+// MinerBalances returns the balance of a miner actor, with the funds locked
+// as pledge and pre-commit deposits.
+func (v *View) MinerBalances(ctx context.Context, maddr addr.Address) (MinerBalances, error) {
+	resolvedAddr, err := v.InitResolveAddress(ctx, maddr)
+	if err != nil {
+		return MinerBalances{}, err
+	}
+	actr, err := v.loadActor(ctx, resolvedAddr)
+	if err != nil {
+		return MinerBalances{}, err
+	}
+	var minerState miner.State
+	if err := v.ipldStore.Get(ctx, actr.Head.Cid, &minerState); err != nil {
+		return MinerBalances{}, err
+	}
+
+	available := big.Sub(big.Sub(actr.Balance, minerState.LockedFunds), minerState.PreCommitDeposits)
+	if available.LessThan(big.Zero()) {
+		available = big.Zero()
+	}
+	return MinerBalances{
+		Balance:           actr.Balance,
+		LockedFunds:       minerState.LockedFunds,
+		PreCommitDeposits: minerState.PreCommitDeposits,
+		Available:         available,
+	}, nil
+}
+
+// MinerSectorCollateral estimates the collateral a miner puts up for its next
+// sector: the pre-commit deposit, averaged over the sectors it has
+// pre-committed, and the initial pledge, the share of the network's total
+// pledge collateral for a sector's raw power. Either is zero when there is
+// nothing to estimate it from.
+func (v *View) MinerSectorCollateral(ctx context.Context, maddr addr.Address) (deposit, pledge abi.TokenAmount, err error) {
+	minerState, err := v.loadMinerActor(ctx, maddr)
+	if err != nil {
+		return big.Zero(), big.Zero(), err
+	}
+	precommits, err := v.asMap(ctx, minerState.PreCommittedSectors)
+	if err != nil {
+		return big.Zero(), big.Zero(), err
+	}
+	deposit = big.Zero()
+	count := int64(0)
+	var precommit miner.SectorPreCommitOnChainInfo
+	err = precommits.ForEach(&precommit, func(_ string) error {
+		deposit = big.Add(deposit, precommit.PreCommitDeposit)
+		count++
+		return nil
+	})
+	if err != nil {
+		return big.Zero(), big.Zero(), err
+	}
+	if count > 0 {
+		deposit = big.Div(deposit, big.NewInt(count))
+	}
+
+	powerState, err := v.loadPowerActor(ctx)
+	if err != nil {
+		return big.Zero(), big.Zero(), err
+	}
+	pledge = big.Zero()
+	if powerState.TotalRawBytePower.GreaterThan(big.Zero()) {
+		sectorPower := big.NewIntUnsigned(uint64(minerState.Info.SectorSize))
+		pledge = big.Div(big.Mul(powerState.TotalPledgeCollateral, sectorPower), powerState.TotalRawBytePower)
+	}
+	return deposit, pledge, nil
+}
+
+// MinerSectorExpiration returns the expiration epoch of a miner's sector, and
+// whether the sector is on chain.
+func (v *View) MinerSectorExpiration(ctx context.Context, maddr addr.Address, sectorNum abi.SectorNumber) (abi.ChainEpoch, bool, error) {
+	minerState, err := v.loadMinerActor(ctx, maddr)
+	if err != nil {
+		return 0, false, err
+	}
+	sector, found, err := minerState.GetSector(StoreFromCbor(ctx, v.ipldStore), sectorNum)
+	if err != nil || !found {
+		return 0, false, err
+	}
+	return sector.Info.Expiration, true, nil
+}
+
 // MarketEscrowBalance looks up a token amount in the escrow table for the given address
 func (v *View) MarketEscrowBalance(ctx context.Context, addr addr.Address) (found bool, amount abi.TokenAmount, err error) {
 	marketState, err := v.loadMarketActor(ctx)
@@ -0,0 +1,73 @@
+package state_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/filecoin-project/specs-actors/actors/builtin"
+	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/util/adt"
+	"github.com/ipfs/go-datastore"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sbwtw/go-filecoin/internal/pkg/cborutil"
+	"github.com/sbwtw/go-filecoin/internal/pkg/state"
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+	"github.com/sbwtw/go-filecoin/internal/pkg/vm/actor"
+	vmaddr "github.com/sbwtw/go-filecoin/internal/pkg/vm/address"
+	vmstate "github.com/sbwtw/go-filecoin/internal/pkg/vm/state"
+)
+
+func TestMinerDeadlineSectors(t *testing.T) {
+	tf.UnitTest(t)
+	ctx := context.Background()
+	store := cborutil.NewIpldStore(blockstore.NewBlockstore(datastore.NewMapDatastore()))
+	adtStore := state.StoreFromCbor(ctx, store)
+	minerAddr := vmaddr.RequireIDAddress(t, 1000)
+	owner := vmaddr.RequireIDAddress(t, 100)
+
+	emptyMap, err := adt.MakeEmptyMap(adtStore).Root()
+	require.NoError(t, err)
+	emptyArray, err := adt.MakeEmptyArray(adtStore).Root()
+	require.NoError(t, err)
+
+	deadlines := miner.ConstructDeadlines()
+	deadlines.Due[0] = bitfieldOf(t, 1, 2)
+	deadlines.Due[3] = bitfieldOf(t, 3)
+	deadlinesCid, err := store.Put(ctx, deadlines)
+	require.NoError(t, err)
+
+	minerState, err := miner.ConstructState(emptyArray, emptyMap, deadlinesCid, owner, owner, "peer", abi.RegisteredProof_StackedDRG2KiBSeal, 0)
+	require.NoError(t, err)
+	minerState.Faults = bitfieldOf(t, 2, 3)
+	minerState.Recoveries = bitfieldOf(t, 3)
+	head, err := store.Put(ctx, minerState)
+	require.NoError(t, err)
+
+	tree := vmstate.NewState(store)
+	require.NoError(t, tree.SetActor(ctx, minerAddr, actor.NewActor(builtin.StorageMinerActorCodeID, abi.NewTokenAmount(0), head)))
+	root, err := tree.Commit(ctx)
+	require.NoError(t, err)
+
+	due, faults, recoveries, err := state.NewView(store, root).MinerDeadlineSectors(ctx, minerAddr)
+	require.NoError(t, err)
+	require.Len(t, due, miner.WPoStPeriodDeadlines)
+	assert.Equal(t, []uint64{1, 2}, due[0])
+	assert.Empty(t, due[1])
+	assert.Equal(t, []uint64{3}, due[3])
+	assert.Equal(t, []uint64{2, 3}, faults)
+	assert.Equal(t, []uint64{3}, recoveries)
+
+	_, _, _, err = state.NewView(store, root).MinerDeadlineSectors(ctx, vmaddr.RequireIDAddress(t, 1001))
+	assert.Error(t, err)
+}
+
+func bitfieldOf(t *testing.T, sectors ...uint64) *abi.BitField {
+	bf, err := bitfield.NewFromSet(sectors)
+	require.NoError(t, err)
+	return bf
+}
@@ -63,6 +63,7 @@ type TestDaemon struct {
 	withMiner        address.Address
 	autoSealInterval string
 	isRelay          bool
+	mockClock        bool
 	initArgs         []string
 
 	firstRun bool
@@ -679,6 +680,11 @@ func IsRelay(td *TestDaemon) {
 	td.isRelay = true
 }
 
+// MockClock starts the daemon with the --mock-clock option.
+func MockClock(td *TestDaemon) {
+	td.mockClock = true
+}
+
 // NewDaemon creates a new `TestDaemon`, using the passed in configuration options.
 func NewDaemon(t *testing.T, options ...func(*TestDaemon)) *TestDaemon {
 	t.Helper()
@@ -757,6 +763,9 @@ func NewDaemon(t *testing.T, options ...func(*TestDaemon)) *TestDaemon {
 	if td.isRelay {
 		td.daemonArgs = append(td.daemonArgs, "--is-relay")
 	}
+	if td.mockClock {
+		td.daemonArgs = append(td.daemonArgs, "--mock-clock")
+	}
 
 	return td
 }
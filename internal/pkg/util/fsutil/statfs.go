@@ -0,0 +1,32 @@
+package fsutil
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Stat returns the capacity of the file system holding path and the bytes
+// available on it to unprivileged users.
+func Stat(path string) (capacity, available uint64, err error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, 0, err
+	}
+	return stat.Blocks * uint64(stat.Bsize), stat.Bavail * uint64(stat.Bsize), nil
+}
+
+// Device returns the ID of the device holding path, which is shared by the
+// paths on the same file system.
+func Device(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, errors.Errorf("no device of %s", path)
+	}
+	return uint64(stat.Dev), nil
+}
@@ -16,6 +16,12 @@ type VMInterpreter interface {
 	//
 	// Note: any message processing error will be present as an `ExitCode` in the `MessageReceipt`.
 	ApplyTipSetMessages(blocks []BlockMessagesInfo, head block.TipSetKey, epoch abi.ChainEpoch, rnd crypto.RandomnessSource) ([]message.Receipt, error)
+
+	// ApplyMessagesForEstimation applies messages in order on top of the current state, as if they
+	// were included in a block on top of `head` at `epoch`.
+	//
+	// No block reward or cron tick is applied and the resulting state is not flushed to the store.
+	ApplyMessagesForEstimation(msgs []*types.SignedMessage, head block.TipSetKey, epoch abi.ChainEpoch, rnd crypto.RandomnessSource) []message.Receipt
 }
 
 // BlockMessagesInfo contains messages for one block in a tipset.
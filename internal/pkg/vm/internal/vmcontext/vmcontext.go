@@ -280,6 +280,24 @@ func (vm *VM) ApplyTipSetMessages(blocks []interpreter.BlockMessagesInfo, head b
 	return receipts, nil
 }
 
+// ApplyMessagesForEstimation implements interpreter.VMInterpreter
+func (vm *VM) ApplyMessagesForEstimation(msgs []*types.SignedMessage, head block.TipSetKey, epoch abi.ChainEpoch, rnd crypto.RandomnessSource) []message.Receipt {
+	// update current tipset
+	vm.currentHead = head
+	vm.currentEpoch = epoch
+	vm.pricelist = gascost.PricelistByEpoch(epoch)
+
+	receipts := make([]message.Receipt, len(msgs))
+	for i, sm := range msgs {
+		// Note: the message is copied since applying it normalizes the sender address in place
+		m := sm.Message
+		receipts[i], _, _ = vm.applyMessage(&m, sm.OnChainLen(), rnd)
+	}
+
+	// Note: the state is intentionally not committed, estimation must never reach the store
+	return receipts
+}
+
 // applyImplicitMessage applies messages automatically generated by the vm itself.
 //
 // This messages do not consume client gas and must not fail.
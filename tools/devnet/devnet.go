@@ -0,0 +1,318 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	logging "github.com/ipfs/go-log"
+	iptb "github.com/ipfs/iptb/testbed"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	ma "github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr-net"
+
+	commands "github.com/sbwtw/go-filecoin/cmd/go-filecoin"
+	"github.com/sbwtw/go-filecoin/tools/fast"
+	gengen "github.com/sbwtw/go-filecoin/tools/gengen/util"
+	lpfc "github.com/sbwtw/go-filecoin/tools/iptb-plugins/filecoin/local"
+)
+
+var log = logging.Logger("devnet")
+
+// Node is a running devnet node.
+type Node struct {
+	*fast.Filecoin
+
+	// MinerAddress is the address of the node's genesis miner, or undefined for clients
+	MinerAddress address.Address
+
+	// WalletAddress is the node's default wallet address, funded in genesis
+	WalletAddress address.Address
+}
+
+// Devnet is a set of local filecoin nodes sharing a generated genesis.
+type Devnet struct {
+	topology *Topology
+	workdir  string
+	binpath  string
+
+	genesisPath string
+	genesisInfo *gengen.RenderedGenInfo
+	peerKeys    []crypto.PrivKey
+
+	nodes []*Node
+}
+
+// NewDevnet creates a devnet for the topology, storing repos and genesis under workdir.
+func NewDevnet(topology *Topology, workdir, binpath string) *Devnet {
+	return &Devnet{
+		topology: topology,
+		workdir:  workdir,
+		binpath:  binpath,
+	}
+}
+
+// Nodes returns the started nodes, miners first.
+func (d *Devnet) Nodes() []*Node {
+	return d.nodes
+}
+
+// GenesisPath returns the location of the genesis car file.
+func (d *Devnet) GenesisPath() string {
+	return d.genesisPath
+}
+
+// Start generates genesis, then initializes and starts every node. The first miner
+// acts as the bootstrap peer for all others. Nodes only listen on loopback, use
+// fake proofs and a local drand beacon, so no network access is needed.
+func (d *Devnet) Start(ctx context.Context) error {
+	if err := os.MkdirAll(d.workdir, 0775); err != nil {
+		return err
+	}
+	if err := d.writeGenesis(); err != nil {
+		return err
+	}
+
+	var bootstrap []ma.Multiaddr
+	for i := 0; i < d.topology.NodeCount(); i++ {
+		n, err := d.startNode(ctx, i, bootstrap)
+		if err != nil {
+			return fmt.Errorf("failed to start node %d: %s", i, err)
+		}
+		d.nodes = append(d.nodes, n)
+
+		if i == 0 {
+			bootstrap, err = loopbackAddrs(ctx, n.Filecoin)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		// Don't wait for the bootstrapper's next round to join the network.
+		if _, err := n.SwarmConnect(ctx, bootstrap...); err != nil {
+			return fmt.Errorf("failed to connect node %d to bootstrap peer: %s", i, err)
+		}
+	}
+
+	for _, n := range d.nodes {
+		if n.MinerAddress.Empty() {
+			continue
+		}
+		if err := n.MiningStart(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AdvanceClock moves the mock clock of every node forward one epoch at a time,
+// waiting interval after each epoch so blocks can be mined and propagated.
+func (d *Devnet) AdvanceClock(ctx context.Context, epochs abi.ChainEpoch, interval time.Duration) error {
+	if !d.topology.MockClock {
+		return fmt.Errorf("devnet is not running with a mock clock")
+	}
+	for i := abi.ChainEpoch(0); i < epochs; i++ {
+		for j, n := range d.nodes {
+			if _, err := n.DebugClockAdvance(ctx, 1); err != nil {
+				return fmt.Errorf("failed to advance clock of node %d: %s", j, err)
+			}
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Teardown stops every node. The working directory is removed unless keep is set.
+func (d *Devnet) Teardown(ctx context.Context, keep bool) error {
+	var firstErr error
+	// Stop in reverse so the bootstrap peer goes last.
+	for i := len(d.nodes) - 1; i >= 0; i-- {
+		if err := d.nodes[i].StopDaemon(ctx); err != nil {
+			log.Errorf("failed to stop node %d: %s", i, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	d.nodes = nil
+
+	if keep {
+		return firstErr
+	}
+	if err := os.RemoveAll(d.workdir); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+func (d *Devnet) writeGenesis() error {
+	peerKeys, err := d.topology.PeerKeys()
+	if err != nil {
+		return err
+	}
+	cfg, err := d.topology.GenesisConfig(peerKeys)
+	if err != nil {
+		return err
+	}
+
+	d.genesisPath = filepath.Join(d.workdir, "genesis.car")
+	f, err := os.Create(d.genesisPath)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	info, err := gengen.GenGenesisCar(cfg, f)
+	if err != nil {
+		return err
+	}
+	d.genesisInfo = info
+	d.peerKeys = peerKeys
+	return nil
+}
+
+func (d *Devnet) startNode(ctx context.Context, idx int, bootstrap []ma.Multiaddr) (*Node, error) {
+	dir := filepath.Join(d.workdir, fmt.Sprintf("node%d", idx))
+	if err := os.MkdirAll(dir, 0775); err != nil {
+		return nil, err
+	}
+
+	peerKeyFile, walletKeyFile, err := d.writeNodeKeys(dir, idx)
+	if err != nil {
+		return nil, err
+	}
+	walletAddr, err := d.genesisInfo.Keys[idx].Address()
+	if err != nil {
+		return nil, err
+	}
+
+	initOpts := []fast.ProcessInitOption{
+		fast.POGenesisFile(d.genesisPath),
+		fast.POPeerKeyFile(peerKeyFile),
+		fast.POWalletKeyFile(walletKeyFile),
+		fast.PODefaultAddress(walletAddr),
+	}
+	var minerAddr address.Address
+	if idx < len(d.genesisInfo.Miners) {
+		minerAddr = d.genesisInfo.Miners[idx].Address
+		initOpts = append(initOpts, fast.POWithMiner(minerAddr))
+	}
+
+	ns := iptb.NodeSpec{
+		Type: lpfc.PluginName,
+		Dir:  filepath.Join(dir, "repo"),
+		Attrs: map[string]string{
+			lpfc.AttrLogJSON:        "0",
+			lpfc.AttrLogLevel:       "4",
+			lpfc.AttrFilecoinBinary: d.binpath,
+		},
+	}
+	if err := os.MkdirAll(ns.Dir, 0775); err != nil {
+		return nil, err
+	}
+	c, err := ns.Load()
+	if err != nil {
+		return nil, err
+	}
+	fc, ok := c.(fast.IPTBCoreExt)
+	if !ok {
+		return nil, fmt.Errorf("%s does not implement the extended IPTB.Core interface IPTBCoreExt", ns.Type)
+	}
+
+	daemonOpts := []fast.ProcessDaemonOption{fast.POBlockTime(d.topology.BlockDuration()), fast.POFakeProofs()}
+	if d.topology.MockClock {
+		daemonOpts = append(daemonOpts, fast.POMockClock())
+	}
+
+	p := fast.NewFilecoinProcess(ctx, fc, fast.FilecoinOpts{
+		InitOpts:   initOpts,
+		DaemonOpts: daemonOpts,
+	})
+	if _, err := p.InitDaemon(ctx); err != nil {
+		return nil, err
+	}
+
+	cfg, err := p.Config()
+	if err != nil {
+		return nil, err
+	}
+	// Every node generates the same local drand chain, with one round per
+	// epoch starting the epoch before genesis.
+	roundSeconds := int(d.topology.BlockDuration() / time.Second)
+	if roundSeconds < 1 {
+		roundSeconds = 1
+	}
+	cfg.Drand.Local = true
+	cfg.Drand.LocalSecret = d.topology.DrandSecret
+	cfg.Drand.DistKey = nil
+	cfg.Drand.RoundSeconds = roundSeconds
+	cfg.Drand.StartTimeUnix = int64(d.topology.GenesisTime) - int64(roundSeconds)
+	if len(bootstrap) > 0 {
+		cfg.Bootstrap.Addresses = nil
+		for _, a := range bootstrap {
+			cfg.Bootstrap.Addresses = append(cfg.Bootstrap.Addresses, a.String())
+		}
+		cfg.Bootstrap.MinPeerThreshold = 1
+		cfg.Bootstrap.Period = "10s"
+	}
+	if err := p.WriteConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	if _, err := p.StartDaemon(ctx, true); err != nil {
+		return nil, err
+	}
+	return &Node{Filecoin: p, MinerAddress: minerAddr, WalletAddress: walletAddr}, nil
+}
+
+// writeNodeKeys writes the node's libp2p identity and its genesis wallet key where
+// `go-filecoin init` can import them.
+func (d *Devnet) writeNodeKeys(dir string, idx int) (string, string, error) {
+	peerKeyBytes, err := crypto.MarshalPrivateKey(d.peerKeys[idx])
+	if err != nil {
+		return "", "", err
+	}
+	peerKeyFile := filepath.Join(dir, "peer.key")
+	if err := ioutil.WriteFile(peerKeyFile, peerKeyBytes, 0600); err != nil {
+		return "", "", err
+	}
+
+	walletKeyBytes, err := json.Marshal(commands.WalletSerializeResult{KeyInfo: d.genesisInfo.Keys[idx : idx+1]})
+	if err != nil {
+		return "", "", err
+	}
+	walletKeyFile := filepath.Join(dir, "wallet.key")
+	if err := ioutil.WriteFile(walletKeyFile, walletKeyBytes, 0600); err != nil {
+		return "", "", err
+	}
+	return peerKeyFile, walletKeyFile, nil
+}
+
+// loopbackAddrs returns the node's swarm addresses on the loopback interface,
+// including its peer ID.
+func loopbackAddrs(ctx context.Context, p *fast.Filecoin) ([]ma.Multiaddr, error) {
+	details, err := p.ID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var addrs []ma.Multiaddr
+	for _, a := range details.Addresses {
+		if manet.IsIPLoopback(a) {
+			addrs = append(addrs, a)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("node %s has no loopback swarm address", details.ID)
+	}
+	return addrs, nil
+}
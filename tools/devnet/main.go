@@ -0,0 +1,130 @@
+package main
+
+// devnet
+//
+// devnet starts a deterministic local network described by a topology file. It
+// generates genesis, initializes a repo per node, starts the daemons on loopback
+// with fake proofs and connects them all to the first miner. The network runs
+// until the program is interrupted, then every node is stopped and the working
+// directory removed. No network access is required.
+
+import (
+	"bufio"
+	"context"
+	flg "flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	flag := flg.NewFlagSet(os.Args[0], flg.ExitOnError)
+	topologyPath := flag.String("topology", "", "path of the json topology file describing the network")
+	workdir := flag.String("workdir", "", "directory for genesis and node repos, defaults to a new temporary directory")
+	binpath := flag.String("binpath", "", "go-filecoin binary used to run nodes, defaults to the one found in PATH")
+	keep := flag.Bool("keep", false, "keep the working directory after shutdown")
+	flag.Parse(os.Args[1:]) // nolint: errcheck
+
+	if *topologyPath == "" {
+		fmt.Fprintln(os.Stderr, "a -topology file is required")
+		flag.Usage()
+		return 1
+	}
+	topology, err := LoadTopology(*topologyPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load topology:", err)
+		return 1
+	}
+
+	if *binpath == "" {
+		if *binpath, err = exec.LookPath("go-filecoin"); err != nil {
+			fmt.Fprintln(os.Stderr, "please build `go-filecoin` or pass -binpath:", err)
+			return 1
+		}
+	}
+	if *workdir == "" {
+		if *workdir, err = ioutil.TempDir("", "devnet"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	net := NewDevnet(topology, *workdir, *binpath)
+	defer func() {
+		if err := net.Teardown(context.Background(), *keep); err != nil {
+			fmt.Fprintln(os.Stderr, "teardown failed:", err)
+		}
+	}()
+
+	started := make(chan error, 1)
+	go func() { started <- net.Start(ctx) }()
+	select {
+	case err := <-started:
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to start devnet:", err)
+			return 1
+		}
+	case <-signals:
+		fmt.Println("interrupted, shutting down")
+		cancel()
+		<-started
+		return 1
+	}
+
+	fmt.Printf("genesis: %s\n", net.GenesisPath())
+	for i, n := range net.Nodes() {
+		role := "client"
+		if !n.MinerAddress.Empty() {
+			role = "miner " + n.MinerAddress.String()
+		}
+		fmt.Printf("node%d: peer %s, wallet %s, %s, repo %s\n", i, n.PeerID, n.WalletAddress, role, n.Dir())
+	}
+	if !topology.MockClock {
+		fmt.Println("devnet running, press Ctrl-C to stop")
+		<-signals
+		fmt.Println("shutting down")
+		return 0
+	}
+
+	fmt.Println("devnet running on a mock clock, enter `advance <epochs>` to move every node forward, press Ctrl-C to stop")
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+	for {
+		select {
+		case line := <-lines:
+			var epochs int64
+			if _, err := fmt.Sscanf(line, "advance %d", &epochs); err != nil {
+				fmt.Println("unknown command, expected `advance <epochs>`")
+				continue
+			}
+			if err := net.AdvanceClock(ctx, abi.ChainEpoch(epochs), topology.BlockDuration()); err != nil {
+				fmt.Fprintln(os.Stderr, "failed to advance clock:", err)
+				continue
+			}
+			fmt.Printf("advanced %d epochs\n", epochs)
+		case <-signals:
+			fmt.Println("shutting down")
+			return 0
+		}
+	}
+}
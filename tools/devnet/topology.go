@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"os"
+	"time"
+
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/crypto"
+	"github.com/libp2p/go-libp2p-core/peer"
+
+	gengen "github.com/sbwtw/go-filecoin/tools/gengen/util"
+)
+
+// Topology is the declarative description of a local devnet.
+type Topology struct {
+	// Network is the name of the network written into genesis
+	Network string `json:"network"`
+
+	// Seed makes key generation and therefore the whole genesis deterministic
+	Seed int64 `json:"seed"`
+
+	// BlockTime is the duration between epochs, e.g. "5s"
+	BlockTime string `json:"blockTime"`
+
+	// GenesisTime is the genesis block time in unix seconds. If unset, a fixed
+	// time is used on a mock clock so that repeated runs produce the same
+	// genesis, and the current time otherwise, as nodes on the real clock
+	// would have to catch up on every epoch and beacon round since genesis.
+	GenesisTime uint64 `json:"genesisTime"`
+
+	// MockClock runs every node on a mock clock which only moves when the devnet
+	// advances it, so epochs pass as fast as the nodes can process them.
+	MockClock bool `json:"mockClock"`
+
+	// DrandSecret generates the local drand beacon the nodes share in place
+	// of a drand network. If unset, it is derived from the seed.
+	DrandSecret string `json:"drandSecret"`
+
+	// Miners are the nodes which own a storage miner with power in genesis
+	Miners []*MinerSpec `json:"miners"`
+
+	// Clients are nodes with a funded wallet and no miner
+	Clients []*ClientSpec `json:"clients"`
+}
+
+// MinerSpec describes a genesis miner and the node that runs it.
+type MinerSpec struct {
+	// SectorSize is the size in bytes of the miner's sectors
+	SectorSize abi.SectorSize `json:"sectorSize"`
+
+	// Sectors is the number of sectors committed in genesis
+	Sectors int `json:"sectors"`
+
+	// Prealloc is the FIL balance of the miner's owner
+	Prealloc string `json:"prealloc"`
+}
+
+// ClientSpec describes a client node.
+type ClientSpec struct {
+	// Prealloc is the FIL balance of the client's wallet
+	Prealloc string `json:"prealloc"`
+}
+
+const (
+	defaultNetwork     = "devnet"
+	defaultBlockTime   = 5 * time.Second
+	defaultGenesisTime = 123456789
+	defaultSectorSize  = abi.SectorSize(2048)
+	defaultSectors     = 10
+
+	// Miner owners pay their genesis sectors' pledge out of this balance.
+	defaultMinerPrealloc  = "1000000"
+	defaultClientPrealloc = "10000"
+)
+
+// LoadTopology reads a topology from the JSON file at path.
+func LoadTopology(path string) (*Topology, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return ReadTopology(f)
+}
+
+// ReadTopology decodes a topology and fills in defaults.
+func ReadTopology(r io.Reader) (*Topology, error) {
+	var t Topology
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	if err := t.setDefaults(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (t *Topology) setDefaults() error {
+	if len(t.Miners) == 0 {
+		return fmt.Errorf("topology must have at least one miner")
+	}
+	if t.Network == "" {
+		t.Network = defaultNetwork
+	}
+	if t.BlockTime == "" {
+		t.BlockTime = defaultBlockTime.String()
+	}
+	if _, err := time.ParseDuration(t.BlockTime); err != nil {
+		return fmt.Errorf("invalid block time %q: %s", t.BlockTime, err)
+	}
+	if t.GenesisTime == 0 && t.MockClock {
+		t.GenesisTime = defaultGenesisTime
+	} else if t.GenesisTime == 0 {
+		t.GenesisTime = uint64(time.Now().Unix())
+	}
+	if t.DrandSecret == "" {
+		t.DrandSecret = fmt.Sprintf("%s-%d", t.Network, t.Seed)
+	}
+	for _, m := range t.Miners {
+		if m.SectorSize == 0 {
+			m.SectorSize = defaultSectorSize
+		}
+		if m.Sectors <= 0 {
+			m.Sectors = defaultSectors
+		}
+		if m.Prealloc == "" {
+			m.Prealloc = defaultMinerPrealloc
+		}
+	}
+	for _, c := range t.Clients {
+		if c.Prealloc == "" {
+			c.Prealloc = defaultClientPrealloc
+		}
+	}
+	return nil
+}
+
+// BlockDuration returns the parsed block time.
+func (t *Topology) BlockDuration() time.Duration {
+	d, _ := time.ParseDuration(t.BlockTime)
+	return d
+}
+
+// NodeCount returns the number of nodes in the topology.
+func (t *Topology) NodeCount() int {
+	return len(t.Miners) + len(t.Clients)
+}
+
+// PeerKeys deterministically generates a libp2p identity for every node,
+// miners first, so that miners can be registered with their peer IDs in genesis.
+func (t *Topology) PeerKeys() ([]crypto.PrivKey, error) {
+	rnd := mrand.New(mrand.NewSource(t.Seed))
+	keys := make([]crypto.PrivKey, t.NodeCount())
+	for i := range keys {
+		sk, _, err := crypto.GenerateEd25519Key(rnd)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = sk
+	}
+	return keys, nil
+}
+
+// GenesisConfig builds the gengen configuration for the topology. Key i belongs to
+// node i: miner owners come first, followed by clients.
+func (t *Topology) GenesisConfig(peerKeys []crypto.PrivKey) (*gengen.GenesisCfg, error) {
+	cfg := &gengen.GenesisCfg{
+		Seed:      t.Seed,
+		KeysToGen: t.NodeCount(),
+		Network:   t.Network,
+		Time:      t.GenesisTime,
+	}
+
+	for i, m := range t.Miners {
+		proofType, err := ffiwrapper.SealProofTypeFromSectorSize(m.SectorSize)
+		if err != nil {
+			return nil, fmt.Errorf("miner %d: %s", i, err)
+		}
+		commCfgs, err := gengen.MakeCommitCfgs(m.Sectors)
+		if err != nil {
+			return nil, err
+		}
+		for _, comm := range commCfgs {
+			comm.ProofType = proofType
+		}
+		pid, err := peer.IDFromPrivateKey(peerKeys[i])
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.PreallocatedFunds = append(cfg.PreallocatedFunds, m.Prealloc)
+		cfg.Miners = append(cfg.Miners, &gengen.CreateStorageMinerConfig{
+			Owner:            i,
+			PeerID:           pid.String(),
+			CommittedSectors: commCfgs,
+			SealProofType:    proofType,
+		})
+	}
+	for _, c := range t.Clients {
+		cfg.PreallocatedFunds = append(cfg.PreallocatedFunds, c.Prealloc)
+	}
+	return cfg, nil
+}
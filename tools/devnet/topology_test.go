@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/specs-actors/actors/abi"
+	"github.com/libp2p/go-libp2p-core/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	tf "github.com/sbwtw/go-filecoin/internal/pkg/testhelpers/testflags"
+)
+
+func TestReadTopology(t *testing.T) {
+	tf.UnitTest(t)
+
+	t.Run("fills in defaults", func(t *testing.T) {
+		topo, err := ReadTopology(strings.NewReader(`{"miners": [{}], "clients": [{"prealloc": "5"}]}`))
+		require.NoError(t, err)
+
+		assert.Equal(t, defaultNetwork, topo.Network)
+		assert.Equal(t, defaultBlockTime, topo.BlockDuration())
+		assert.Equal(t, defaultSectorSize, topo.Miners[0].SectorSize)
+		assert.Equal(t, defaultSectors, topo.Miners[0].Sectors)
+		assert.Equal(t, defaultMinerPrealloc, topo.Miners[0].Prealloc)
+		assert.Equal(t, "5", topo.Clients[0].Prealloc)
+		assert.Equal(t, 2, topo.NodeCount())
+	})
+
+	t.Run("starts at a fixed time only on a mock clock", func(t *testing.T) {
+		topo, err := ReadTopology(strings.NewReader(`{"miners": [{}], "mockClock": true}`))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(defaultGenesisTime), topo.GenesisTime)
+
+		before := uint64(time.Now().Unix())
+		topo, err = ReadTopology(strings.NewReader(`{"miners": [{}]}`))
+		require.NoError(t, err)
+		assert.True(t, topo.GenesisTime >= before)
+		assert.True(t, topo.GenesisTime <= uint64(time.Now().Unix()))
+	})
+
+	t.Run("requires a miner", func(t *testing.T) {
+		_, err := ReadTopology(strings.NewReader(`{"clients": [{}]}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects a bad block time", func(t *testing.T) {
+		_, err := ReadTopology(strings.NewReader(`{"miners": [{}], "blockTime": "soon"}`))
+		assert.Error(t, err)
+	})
+}
+
+func TestGenesisConfig(t *testing.T) {
+	tf.UnitTest(t)
+
+	topo, err := ReadTopology(strings.NewReader(`{"seed": 7, "miners": [{"sectors": 3}, {"sectorSize": 2048}], "clients": [{}]}`))
+	require.NoError(t, err)
+
+	keys, err := topo.PeerKeys()
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	// Identities are derived from the seed.
+	again, err := topo.PeerKeys()
+	require.NoError(t, err)
+	for i := range keys {
+		assert.True(t, keys[i].Equals(again[i]))
+	}
+
+	cfg, err := topo.GenesisConfig(keys)
+	require.NoError(t, err)
+	assert.Equal(t, 3, cfg.KeysToGen)
+	assert.Equal(t, []string{defaultMinerPrealloc, defaultMinerPrealloc, defaultClientPrealloc}, cfg.PreallocatedFunds)
+	require.Len(t, cfg.Miners, 2)
+	assert.Len(t, cfg.Miners[0].CommittedSectors, 3)
+
+	proofType, err := ffiwrapper.SealProofTypeFromSectorSize(abi.SectorSize(2048))
+	require.NoError(t, err)
+	for i, m := range cfg.Miners {
+		assert.Equal(t, i, m.Owner)
+		assert.Equal(t, proofType, m.SealProofType)
+
+		pid, err := peer.IDFromPrivateKey(keys[i])
+		require.NoError(t, err)
+		assert.Equal(t, pid.String(), m.PeerID)
+	}
+}
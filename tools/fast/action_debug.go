@@ -0,0 +1,38 @@
+package fast
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/filecoin-project/specs-actors/actors/abi"
+
+	commands "github.com/sbwtw/go-filecoin/cmd/go-filecoin"
+)
+
+// DebugClockNow runs the `debug clock now` command against the filecoin process
+func (f *Filecoin) DebugClockNow(ctx context.Context) (*commands.ClockResult, error) {
+	var out commands.ClockResult
+
+	if err := f.RunCmdJSONWithStdin(ctx, nil, &out, "go-filecoin", "debug", "clock", "now"); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+// DebugClockAdvance runs the `debug clock advance` command against the filecoin process
+func (f *Filecoin) DebugClockAdvance(ctx context.Context, epochs abi.ChainEpoch, options ...ActionOption) (*commands.ClockResult, error) {
+	var out commands.ClockResult
+
+	args := []string{"go-filecoin", "debug", "clock", "advance", fmt.Sprintf("%d", epochs)}
+
+	for _, option := range options {
+		args = append(args, option()...)
+	}
+
+	if err := f.RunCmdJSONWithStdin(ctx, nil, &out, args...); err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
@@ -87,7 +87,7 @@ func (f *Filecoin) MinerSetPrice(ctx context.Context, fil *big.Float, expiry *bi
 
 // MinerSetWorker runs the `miner set-worker` command against the filecoin process
 func (f *Filecoin) MinerSetWorker(ctx context.Context, newAddr address.Address, options ...ActionOption) (cid.Cid, error) {
-	var out cid.Cid
+	var out commands.MinerSetWorkerResult
 
 	args := []string{"go-filecoin", "miner", "set-worker", newAddr.String()}
 
@@ -96,7 +96,7 @@ func (f *Filecoin) MinerSetWorker(ctx context.Context, newAddr address.Address,
 	}
 
 	if err := f.RunCmdJSONWithStdin(ctx, nil, &out, args...); err != nil {
-		return out, err
+		return cid.Undef, err
 	}
-	return out, nil
+	return out.Cid, nil
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math/big"
 	"strconv"
+	"time"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/specs-actors/actors/abi"
@@ -137,3 +138,10 @@ func AOWaitForCount(count uint) ActionOption {
 		return []string{"--wait-for-count", strconv.Itoa(int(count))}
 	}
 }
+
+// AOInterval provides the `--interval` option to actions
+func AOInterval(interval time.Duration) ActionOption {
+	return func() []string {
+		return []string{"--interval", interval.String()}
+	}
+}
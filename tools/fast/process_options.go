@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/multiformats/go-multiaddr"
 )
 
@@ -24,6 +25,27 @@ func POPeerKeyFile(pkf string) ProcessInitOption {
 	}
 }
 
+// POWalletKeyFile provides the `--wallet-keyfile=<path>` option to process at init
+func POWalletKeyFile(wkf string) ProcessInitOption {
+	return func() []string {
+		return []string{"--wallet-keyfile", wkf}
+	}
+}
+
+// PODefaultAddress provides the `--default-address=<address>` option to process at init
+func PODefaultAddress(addr address.Address) ProcessInitOption {
+	return func() []string {
+		return []string{"--default-address", addr.String()}
+	}
+}
+
+// POWithMiner provides the `--with-miner=<address>` option to process at init
+func POWithMiner(addr address.Address) ProcessInitOption {
+	return func() []string {
+		return []string{"--with-miner", addr.String()}
+	}
+}
+
 // POAutoSealIntervalSeconds provides the `--auto-seal-interval-seconds=<seconds>` option to process at init
 func POAutoSealIntervalSeconds(seconds int) ProcessInitOption {
 	return func() []string {
@@ -69,6 +91,20 @@ func POBlockTime(d time.Duration) ProcessDaemonOption {
 	}
 }
 
+// POFakeProofs provides the `--fake-proofs` to process when starting.
+func POFakeProofs() ProcessDaemonOption {
+	return func() []string {
+		return []string{"--fake-proofs"}
+	}
+}
+
+// POMockClock provides the `--mock-clock` to process when starting.
+func POMockClock() ProcessDaemonOption {
+	return func() []string {
+		return []string{"--mock-clock"}
+	}
+}
+
 // POIsRelay provides the `--is-relay` to process when starting.
 func POIsRelay() ProcessDaemonOption {
 	return func() []string {
@@ -17,6 +17,8 @@ import (
 	init_ "github.com/filecoin-project/specs-actors/actors/builtin/init"
 	"github.com/filecoin-project/specs-actors/actors/builtin/market"
 	"github.com/filecoin-project/specs-actors/actors/builtin/miner"
+	"github.com/filecoin-project/specs-actors/actors/builtin/multisig"
+	"github.com/filecoin-project/specs-actors/actors/builtin/paych"
 	"github.com/filecoin-project/specs-actors/actors/builtin/power"
 	"github.com/filecoin-project/specs-actors/actors/builtin/reward"
 	"github.com/filecoin-project/specs-actors/actors/builtin/system"
@@ -252,6 +254,181 @@ func (g *GenesisGenerator) setupPrealloc() error {
 	return nil
 }
 
+func (g *GenesisGenerator) setupMultisigs() ([]address.Address, error) {
+	var addrs []address.Address
+	for i, m := range g.cfg.Multisigs {
+		var signers []address.Address
+		for _, idx := range m.Signers {
+			signer, err := g.keyAddress(idx)
+			if err != nil {
+				return nil, err
+			}
+			signers = append(signers, signer)
+		}
+
+		value, err := parseFIL(m.Balance)
+		if err != nil {
+			return nil, err
+		}
+
+		// Funds are drawn from the reward actor, as with preallocated accounts.
+		addr, err := g.execActor(builtin.RewardActorAddr, builtin.MultisigActorCodeID, value, &multisig.ConstructorParams{
+			Signers:               signers,
+			NumApprovalsThreshold: m.Threshold,
+			UnlockDuration:        m.UnlockDuration,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create multisig %d: %s", i, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func (g *GenesisGenerator) setupPaymentChannels() ([]address.Address, error) {
+	var addrs []address.Address
+	for i, p := range g.cfg.PaymentChannels {
+		from, err := g.keyAddress(p.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := g.keyAddress(p.To)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := parseFIL(p.Balance)
+		if err != nil {
+			return nil, err
+		}
+
+		// The channel constructor requires both parties to be account actors.
+		// Sending nothing to the payee creates its account if it has no preallocation.
+		_, err = g.vm.ApplyGenesisMessage(builtin.RewardActorAddr, to, builtin.MethodSend, big.Zero(), nil, &g.chainRand)
+		if err != nil {
+			return nil, err
+		}
+
+		addr, err := g.execActor(from, builtin.PaymentChannelActorCodeID, value, &paych.ConstructorParams{From: from, To: to})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create payment channel %d: %s", i, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func (g *GenesisGenerator) setupVerifiedClients(ctx context.Context) error {
+	if len(g.cfg.VerifiedClients) == 0 {
+		return nil
+	}
+
+	// The registry's root key has no account on chain to send AddVerifier from,
+	// so clients are written into the registry state directly.
+	vrAct, found, err := g.stateTree.GetActor(ctx, builtin.VerifiedRegistryActorAddr)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("state tree could not find verified registry actor")
+	}
+	var vrState verifreg.State
+	_, err = g.store.Get(ctx, vrAct.Head.Cid, &vrState)
+	if err != nil {
+		return err
+	}
+
+	clients, err := adt.AsMap(g.vm.ContextStore(), vrState.VerifiedClients)
+	if err != nil {
+		return err
+	}
+	for _, c := range g.cfg.VerifiedClients {
+		pkAddr, err := g.keyAddress(c.Client)
+		if err != nil {
+			return err
+		}
+		// The market actor looks clients up by ID address.
+		clientAddr, err := g.resolveAddress(ctx, pkAddr)
+		if err != nil {
+			return err
+		}
+		dataCap := c.DataCap
+		if err := clients.Put(adt.AddrKey(clientAddr), &dataCap); err != nil {
+			return err
+		}
+	}
+	vrState.VerifiedClients, err = clients.Root()
+	if err != nil {
+		return err
+	}
+
+	// Persist new state.
+	newVrCid, _, err := g.store.Put(ctx, &vrState)
+	if err != nil {
+		return err
+	}
+	vrAct.Head = e.NewCid(newVrCid)
+	return g.stateTree.SetActor(ctx, builtin.VerifiedRegistryActorAddr, vrAct)
+}
+
+func (g *GenesisGenerator) setupActorBalances() error {
+	for _, b := range g.cfg.ActorBalances {
+		value, err := parseFIL(b.Balance)
+		if err != nil {
+			return err
+		}
+		_, err = g.vm.ApplyGenesisMessage(builtin.RewardActorAddr, b.Address, builtin.MethodSend, value, nil, &g.chainRand)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execActor creates an actor through the init actor and returns its ID address.
+func (g *GenesisGenerator) execActor(from address.Address, codeCid cid.Cid, value abi.TokenAmount, ctorParams interface{}) (address.Address, error) {
+	encodedParams, err := encoding.Encode(ctorParams)
+	if err != nil {
+		return address.Undef, err
+	}
+	out, err := g.vm.ApplyGenesisMessage(from, builtin.InitActorAddr, builtin.MethodsInit.Exec, value, &init_.ExecParams{
+		CodeCID:           codeCid,
+		ConstructorParams: encodedParams,
+	}, &g.chainRand)
+	if err != nil {
+		return address.Undef, err
+	}
+	ret := out.(*init_.ExecReturn)
+	return ret.IDAddress, nil
+}
+
+func (g *GenesisGenerator) keyAddress(idx int) (address.Address, error) {
+	if idx < 0 || idx >= len(g.keys) {
+		return address.Undef, fmt.Errorf("no key with index %d", idx)
+	}
+	return g.keys[idx].Address()
+}
+
+func (g *GenesisGenerator) resolveAddress(ctx context.Context, addr address.Address) (address.Address, error) {
+	stateRoot, err := g.flush(ctx)
+	if err != nil {
+		return address.Undef, err
+	}
+	view := gfcstate.NewView(g.cst, stateRoot)
+	return view.InitResolveAddress(ctx, addr)
+}
+
+func parseFIL(v string) (abi.TokenAmount, error) {
+	if v == "" {
+		return big.Zero(), nil
+	}
+	value, ok := types.NewAttoFILFromFILString(v)
+	if !ok {
+		return big.Zero(), fmt.Errorf("failed to parse FIL value '%s'", v)
+	}
+	return value, nil
+}
+
 func (g *GenesisGenerator) genBlock(ctx context.Context) (cid.Cid, error) {
 	stateRoot, err := g.flush(ctx)
 	if err != nil {
@@ -443,12 +620,7 @@ func (g *GenesisGenerator) createMiner(ctx context.Context, m *CreateStorageMine
 	}
 
 	// Resolve worker account's ID address.
-	stateRoot, err := g.flush(ctx)
-	if err != nil {
-		return address.Undef, address.Undef, err
-	}
-	view := gfcstate.NewView(g.cst, stateRoot)
-	ownerAddr, err := view.InitResolveAddress(ctx, pkAddr)
+	ownerAddr, err := g.resolveAddress(ctx, pkAddr)
 	if err != nil {
 		return address.Undef, address.Undef, err
 	}
@@ -72,6 +72,54 @@ type DealConfig struct {
 	// Collateral values are 0 for now (might need to change to some minimum)
 }
 
+// MultisigConfig carries the information needed to create a multisig wallet
+// in the genesis state.
+type MultisigConfig struct {
+	// Signers are indexes of keys from the configs 'Keys' list
+	Signers []int
+
+	// Threshold is the number of signers required to approve a transaction
+	Threshold int64
+
+	// UnlockDuration is the number of epochs over which the initial balance vests.
+	// Zero means the balance is available immediately.
+	UnlockDuration abi.ChainEpoch
+
+	// Balance is the string value of whole filecoin held by the wallet
+	Balance string
+}
+
+// PaymentChannelConfig carries the information needed to create a payment
+// channel in the genesis state. The channel is funded by its From account, which
+// must have preallocated funds to cover Balance.
+type PaymentChannelConfig struct {
+	// From and To are indexes of keys from the configs 'Keys' list
+	From int
+	To   int
+
+	// Balance is the string value of whole filecoin locked in the channel
+	Balance string
+}
+
+// VerifiedClientConfig carries the information needed to register a client with
+// the verified registry in the genesis state.
+type VerifiedClientConfig struct {
+	// Client is the index of a key from the configs 'Keys' list
+	Client int
+
+	// DataCap is the number of bytes of verified deals the client may make
+	DataCap abi.StoragePower
+}
+
+// ActorBalanceConfig sets up a balance for an arbitrary address. If no actor
+// exists at a public key address an account actor is created for it.
+type ActorBalanceConfig struct {
+	Address address.Address
+
+	// Balance is the string value of whole filecoin to send to the address
+	Balance string
+}
+
 // GenesisCfg is the top level configuration struct used to create a genesis block.
 type GenesisCfg struct {
 	// Seed is used to sample randomness for generating keys
@@ -90,6 +138,18 @@ type GenesisCfg struct {
 	// Miners is a list of miners that should be set up at the start of the network
 	Miners []*CreateStorageMinerConfig
 
+	// Multisigs is a list of multisig wallets that should exist at the start of the network
+	Multisigs []*MultisigConfig
+
+	// PaymentChannels is a list of payment channels that should exist at the start of the network
+	PaymentChannels []*PaymentChannelConfig
+
+	// VerifiedClients is a list of clients registered with the verified registry
+	VerifiedClients []*VerifiedClientConfig
+
+	// ActorBalances is a list of additional balances to allocate to arbitrary addresses
+	ActorBalances []*ActorBalanceConfig
+
 	// Network is the name of the network
 	Network string
 
@@ -105,6 +165,12 @@ type RenderedGenInfo struct {
 	// Miners is the list of addresses of miners created
 	Miners []*RenderedMinerInfo
 
+	// Multisigs is the list of addresses of multisig wallets created, in config order
+	Multisigs []address.Address
+
+	// PaymentChannels is the list of addresses of payment channels created, in config order
+	PaymentChannels []address.Address
+
 	// GenesisCid is the cid of the created genesis block
 	GenesisCid cid.Cid
 }
@@ -177,6 +243,7 @@ func NetworkName(name string) GenOption {
 	}
 }
 
+// MinerConfigs returns a config option that sets the miners to create.
 func MinerConfigs(minerCfgs []*CreateStorageMinerConfig) GenOption {
 	return func(gc *GenesisCfg) error {
 		gc.Miners = minerCfgs
@@ -184,6 +251,38 @@ func MinerConfigs(minerCfgs []*CreateStorageMinerConfig) GenOption {
 	}
 }
 
+// Multisigs returns a config option that adds multisig wallets.
+func Multisigs(msigCfgs ...*MultisigConfig) GenOption {
+	return func(gc *GenesisCfg) error {
+		gc.Multisigs = append(gc.Multisigs, msigCfgs...)
+		return nil
+	}
+}
+
+// PaymentChannels returns a config option that adds payment channels.
+func PaymentChannels(paychCfgs ...*PaymentChannelConfig) GenOption {
+	return func(gc *GenesisCfg) error {
+		gc.PaymentChannels = append(gc.PaymentChannels, paychCfgs...)
+		return nil
+	}
+}
+
+// VerifiedClients returns a config option that registers verified clients.
+func VerifiedClients(clientCfgs ...*VerifiedClientConfig) GenOption {
+	return func(gc *GenesisCfg) error {
+		gc.VerifiedClients = append(gc.VerifiedClients, clientCfgs...)
+		return nil
+	}
+}
+
+// ActorBalance returns a config option that allocates funds to an arbitrary address.
+func ActorBalance(addr address.Address, amt string) GenOption {
+	return func(gc *GenesisCfg) error {
+		gc.ActorBalances = append(gc.ActorBalances, &ActorBalanceConfig{Address: addr, Balance: amt})
+		return nil
+	}
+}
+
 var defaultGenTimeOpt = GenTime(123456789)
 
 // MakeGenesisFunc returns a genesis function configured by a set of options.
@@ -236,6 +335,22 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, bs blockstore.Blockstore) (*Re
 	if err != nil {
 		return nil, err
 	}
+	msigAddrs, err := generator.setupMultisigs()
+	if err != nil {
+		return nil, err
+	}
+	paychAddrs, err := generator.setupPaymentChannels()
+	if err != nil {
+		return nil, err
+	}
+	err = generator.setupVerifiedClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = generator.setupActorBalances()
+	if err != nil {
+		return nil, err
+	}
 	minerInfos, err := generator.setupMiners(ctx)
 	if err != nil {
 		return nil, err
@@ -246,9 +361,11 @@ func GenGen(ctx context.Context, cfg *GenesisCfg, bs blockstore.Blockstore) (*Re
 	}
 
 	return &RenderedGenInfo{
-		Keys:       generator.keys,
-		GenesisCid: genCid,
-		Miners:     minerInfos,
+		Keys:            generator.keys,
+		GenesisCid:      genCid,
+		Miners:          minerInfos,
+		Multisigs:       msigAddrs,
+		PaymentChannels: paychAddrs,
 	}, nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"testing"
 
+	"github.com/filecoin-project/specs-actors/actors/abi"
 	"github.com/filecoin-project/specs-actors/actors/builtin"
 	ds "github.com/ipfs/go-datastore"
 	blockstore "github.com/ipfs/go-ipfs-blockstore"
@@ -39,6 +40,15 @@ func testConfig(t *testing.T) *GenesisCfg {
 				SealProofType:    constants.DevSealProofType,
 			},
 		},
+		Multisigs: []*MultisigConfig{
+			{Signers: []int{1, 2, 3}, Threshold: 2, Balance: "10000"},
+		},
+		PaymentChannels: []*PaymentChannelConfig{
+			{From: 1, To: 2, Balance: "100"},
+		},
+		VerifiedClients: []*VerifiedClientConfig{
+			{Client: 0, DataCap: abi.NewStoragePower(1 << 30)},
+		},
 		Network: "gfctest",
 		Seed:    defaultSeed,
 		Time:    defaultTime,
@@ -67,6 +77,8 @@ func TestGenGenLoading(t *testing.T) {
 	assert.Contains(t, stdout, builtin.StoragePowerActorCodeID.String())
 	assert.Contains(t, stdout, builtin.StorageMarketActorCodeID.String())
 	assert.Contains(t, stdout, builtin.InitActorCodeID.String())
+	assert.Contains(t, stdout, builtin.MultisigActorCodeID.String())
+	assert.Contains(t, stdout, builtin.PaymentChannelActorCodeID.String())
 }
 
 func TestGenGenDeterministic(t *testing.T) {
@@ -78,6 +90,8 @@ func TestGenGenDeterministic(t *testing.T) {
 		bstore := blockstore.NewBlockstore(ds.NewMapDatastore())
 		inf, err := GenGen(ctx, testConfig(t), bstore)
 		assert.NoError(t, err)
+		assert.Len(t, inf.Multisigs, 1)
+		assert.Len(t, inf.PaymentChannels, 1)
 		if info == nil {
 			info = inf
 		} else {